@@ -19,3 +19,10 @@ func (c FullSlackForm) Validate() error {
 	}
 	return nil
 }
+
+// Status struct used for status endpoint response
+type Status struct {
+	SchemaVersion       int64  `json:"schema_version"`
+	LatestSchemaVersion int64  `json:"latest_schema_version"`
+	Error               string `json:"error,omitempty"`
+}
@@ -1,13 +1,20 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/schema"
@@ -19,7 +26,6 @@ import (
 	"github.com/maddevsio/comedian/storage"
 	"github.com/maddevsio/comedian/teammonitoring"
 	"github.com/maddevsio/comedian/utils"
-	"github.com/nlopes/slack"
 	"github.com/sirupsen/logrus"
 )
 
@@ -30,8 +36,14 @@ type REST struct {
 	conf    config.Config
 	decoder *schema.Decoder
 	report  *reporting.Reporter
-	slack   *chat.Slack
-	api     *slack.Client
+	chat    chat.Messenger
+
+	// workspaces handle commands of every served workspace by team_id
+	workspaces map[string]*REST
+	// signatures of accepted slash commands, a request with the same signature is a replay
+	signatures *chat.SeenIDs
+	// wg waits for commands which finish their work in background
+	wg sync.WaitGroup
 }
 
 type Template struct {
@@ -54,6 +66,23 @@ const (
 	commandReportByProject       = "/report_by_project"
 	commandReportByUser          = "/report_by_user"
 	commandReportByUserInProject = "/report_by_user_in_project"
+	commandReportBlockers        = "/report_blockers"
+	commandReportPlanVsActual    = "/report_plan_vs_actual"
+
+	commandStandupHistory = "/standup_history"
+	commandStandupRestore = "/standup_restore"
+
+	commandStandupQuestions = "/standup_questions"
+
+	commandAutoEnroll = "/auto_enroll"
+
+	commandTimeZone    = "/time_zone"
+	commandWorkingDays = "/working_days"
+
+	commandHolidays = "/holidays"
+	commandVacation = "/vacation"
+
+	commandStandup = "/standup"
 
 	commandHelp = "/helper"
 )
@@ -61,30 +90,51 @@ const (
 //ResponseText is Comedian API response text message to be displayed
 var ResponseText string
 
-// NewRESTAPI creates API for Slack commands
-func NewRESTAPI(slack *chat.Slack) (*REST, error) {
+// NewRESTAPI creates API for commands of all workspaces. The first workspace
+// serves HTTP endpoints which do not belong to any workspace, e.g. /status
+func NewRESTAPI(messengers ...chat.Messenger) (*REST, error) {
+	if len(messengers) == 0 {
+		return nil, errors.New("no workspaces to serve")
+	}
 	e := echo.New()
 	decoder := schema.NewDecoder()
 	decoder.IgnoreUnknownKeys(true)
-	rep := reporting.NewReporter(slack)
 
-	r := &REST{
-		echo:    e,
-		decoder: decoder,
-		report:  rep,
-		db:      slack.DB,
-		slack:   slack,
-		api:     slack.API,
-		conf:    slack.Conf,
+	workspaces := map[string]*REST{}
+	for _, m := range messengers {
+		conf := m.Config()
+		if _, ok := workspaces[conf.TeamID]; ok {
+			return nil, fmt.Errorf("workspace %v is served twice", conf.TeamID)
+		}
+		workspaces[conf.TeamID] = &REST{
+			echo:       e,
+			decoder:    decoder,
+			report:     reporting.NewReporter(m),
+			db:         m.Storage(),
+			chat:       m,
+			conf:       conf,
+			workspaces: workspaces,
+			signatures: &chat.SeenIDs{},
+		}
+		if t, ok := m.(*chat.Telegram); ok {
+			t.HandleCommand = workspaces[conf.TeamID].telegramCommand
+		}
 	}
 
+	r := workspaces[messengers[0].Config().TeamID]
 	r.initEndpoints()
 	return r, nil
 }
 
 func (r *REST) initEndpoints() {
-	endPoint := fmt.Sprintf("/commands%s", r.conf.SecretToken)
-	r.echo.POST(endPoint, r.handleCommands)
+	r.echo.POST("/commands", r.handleCommands, r.verifySignature)
+	if r.conf.SecretToken != "" {
+		// legacy URL with secret token, kept until slash commands of all workspaces are signed
+		r.echo.POST(fmt.Sprintf("/commands%s", r.conf.SecretToken), r.handleCommands)
+	}
+	r.echo.POST("/events", r.handleEvents)
+	r.echo.POST("/interactions", r.handleInteractions, r.verifySignature)
+	r.echo.GET("/status", r.handleStatus)
 }
 
 // Start starts http server
@@ -92,123 +142,417 @@ func (r *REST) Start() error {
 	return r.echo.Start(r.conf.HTTPBindAddr)
 }
 
+func (r *REST) handleStatus(c echo.Context) error {
+	status := Status{}
+	m, ok := r.db.(storage.Migrator)
+	if !ok {
+		return c.JSON(http.StatusOK, status)
+	}
+	latest, err := m.LatestSchemaVersion()
+	if err != nil {
+		logrus.Errorf("rest: LatestSchemaVersion failed: %v\n", err)
+		status.Error = err.Error()
+		return c.JSON(http.StatusInternalServerError, status)
+	}
+	status.LatestSchemaVersion = latest
+	version, err := m.SchemaVersion()
+	if err != nil {
+		logrus.Errorf("rest: SchemaVersion failed: %v\n", err)
+		status.Error = err.Error()
+		return c.JSON(http.StatusServiceUnavailable, status)
+	}
+	status.SchemaVersion = version
+	return c.JSON(http.StatusOK, status)
+}
+
+// workspaceKey keeps in echo context handler of the workspace whose signature was checked
+const workspaceKey = "workspace"
+
+// requestWorkspace returns handler of the workspace verifySignature checked the request of,
+// of the workspace with the team ID if request did not go through verifySignature
+func (r *REST) requestWorkspace(c echo.Context, teamID string) (*REST, bool) {
+	if w, ok := c.Get(workspaceKey).(*REST); ok {
+		return w, true
+	}
+	return r.workspace(teamID)
+}
+
+// workspace returns handler of the workspace which sent the command. Commands of
+// a single workspace configured without team ID are accepted from any team
+func (r *REST) workspace(teamID string) (*REST, bool) {
+	if w, ok := r.workspaces[teamID]; ok {
+		return w, true
+	}
+	if len(r.workspaces) == 1 && r.conf.TeamID == "" {
+		return r, true
+	}
+	return nil, false
+}
+
 func (r *REST) handleCommands(c echo.Context) error {
 	form, err := c.FormParams()
 	if err != nil {
 		logrus.Errorf("rest: c.FormParams failed: %v\n", err)
 	}
+	w, ok := r.requestWorkspace(c, form.Get("team_id"))
+	if !ok {
+		logrus.Errorf("rest: command from unknown workspace %v\n", form.Get("team_id"))
+		return c.String(http.StatusForbidden, "Unknown workspace")
+	}
+	if m, ok := w.chat.(*chat.Mattermost); ok {
+		form.Set("text", m.EscapeMentions(form.Get("text")))
+	}
+	reply, ok := w.runCommand(form)
+	if !ok {
+		return c.String(http.StatusNotImplemented, "Not implemented")
+	}
+	if reply == "" {
+		return c.NoContent(http.StatusOK)
+	}
+	return c.String(http.StatusOK, reply)
+}
+
+// telegramCommand runs command received by Telegram bot and returns the reply to it.
+// Commands of other bots in the same chat are not answered
+func (r *REST) telegramCommand(form url.Values) string {
+	reply, _ := r.runCommand(form)
+	return reply
+}
+
+// verifySignature accepts slash commands and interactions of Slack workspaces signed with
+// their signing secret and commands of Mattermost workspaces with one of their tokens.
+// Unsigned Slack commands are accepted unless they should come to the URL with COMEDIAN_SECRET_TOKEN,
+// unsigned interactions are not accepted. Requests of workspaces which are not served are refused
+func (r *REST) verifySignature(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		body, err := ioutil.ReadAll(c.Request().Body)
+		if err != nil {
+			logrus.Errorf("rest: could not read command: %v\n", err)
+			return c.String(http.StatusBadRequest, "Bad request")
+		}
+		c.Request().Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			logrus.Errorf("rest: could not parse command: %v\n", err)
+			return c.String(http.StatusBadRequest, "Bad request")
+		}
+		// interactions tell their team in payload, commands in team_id
+		teamID := form.Get("team_id")
+		if c.Path() == "/interactions" {
+			var i chat.Interaction
+			err = json.Unmarshal([]byte(form.Get("payload")), &i)
+			if err != nil {
+				logrus.Errorf("rest: could not parse interaction: %v\n", err)
+				return c.String(http.StatusBadRequest, "Bad request")
+			}
+			teamID = i.Team.ID
+		}
+		w, ok := r.workspace(teamID)
+		if !ok {
+			logrus.Errorf("rest: request from unknown workspace %v\n", teamID)
+			return c.String(http.StatusForbidden, "Unknown workspace")
+		}
+		// handler serves the workspace the signature is checked against
+		c.Set(workspaceKey, w)
+		if w.conf.Platform == config.PlatformMattermost {
+			// Mattermost does not sign commands, every command has its own token instead
+			for _, token := range strings.Split(w.conf.CommandTokens, ",") {
+				if token = strings.TrimSpace(token); token != "" && token == form.Get("token") {
+					return next(c)
+				}
+			}
+			logrus.Errorf("rest: command %v of workspace %v with unknown token\n", form.Get("command"), teamID)
+			return c.String(http.StatusUnauthorized, "Wrong token")
+		}
+		if w.conf.SigningSecret == "" {
+			// interactions post standups on behalf of users, they are never accepted unsigned
+			if c.Path() == "/interactions" {
+				logrus.Errorf("rest: interaction from workspace %v without signing secret\n", teamID)
+				return c.String(http.StatusUnauthorized, "Request is not signed")
+			}
+			if r.conf.SecretToken != "" {
+				logrus.Errorf("rest: unsigned command from workspace %v\n", form.Get("team_id"))
+				return c.String(http.StatusUnauthorized, "Request is not signed")
+			}
+			return next(c)
+		}
+
+		now := time.Now()
+		err = chat.VerifyRequest(w.conf.SigningSecret, c.Request().Header, body, now)
+		if err != nil {
+			logrus.Errorf("rest: command is not verified: %v\n", err)
+			return c.String(http.StatusUnauthorized, "Wrong signature")
+		}
+		if !r.signatures.Add(c.Request().Header.Get("X-Slack-Signature"), now, 2*chat.RequestMaxAge) {
+			logrus.Errorf("rest: command %v is replayed\n", form.Get("command"))
+			return c.String(http.StatusUnauthorized, "Request is replayed")
+		}
+		return next(c)
+	}
+}
+
+// handleEvents receives Slack Events API requests of workspaces which use events transport
+func (r *REST) handleEvents(c echo.Context) error {
+	body, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		logrus.Errorf("rest: could not read event: %v\n", err)
+		return c.String(http.StatusBadRequest, "Bad request")
+	}
+	var req chat.EventsAPIRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		logrus.Errorf("rest: could not parse event: %v\n", err)
+		return c.String(http.StatusBadRequest, "Bad request")
+	}
+
+	if req.Type == chat.EventURLVerification {
+		// handshake has no team, any workspace secret will do
+		for _, w := range r.allWorkspaces() {
+			if w.conf.SlackTransport == config.TransportEvents && chat.VerifyRequest(w.conf.SigningSecret, c.Request().Header, body, time.Now()) == nil {
+				return c.String(http.StatusOK, req.Challenge)
+			}
+		}
+		logrus.Errorf("rest: url_verification request is not verified\n")
+		return c.String(http.StatusUnauthorized, "Wrong signature")
+	}
+
+	w, ok := r.workspace(req.TeamID)
+	if !ok || w.conf.SlackTransport != config.TransportEvents {
+		logrus.Errorf("rest: event from workspace %v which does not use Events API\n", req.TeamID)
+		return c.String(http.StatusNotFound, "Unknown workspace")
+	}
+	err = chat.VerifyRequest(w.conf.SigningSecret, c.Request().Header, body, time.Now())
+	if err != nil {
+		logrus.Errorf("rest: event %v is not verified: %v\n", req.EventID, err)
+		return c.String(http.StatusUnauthorized, "Wrong signature")
+	}
+	if retry := c.Request().Header.Get("X-Slack-Retry-Num"); retry != "" {
+		logrus.Infof("rest: event %v is retried %v time(s), reason: %v\n", req.EventID, retry, c.Request().Header.Get("X-Slack-Retry-Reason"))
+	}
+	if req.Type != chat.EventCallback {
+		return c.NoContent(http.StatusOK)
+	}
+	slack, ok := w.chat.(*chat.Slack)
+	if !ok {
+		return c.String(http.StatusNotFound, "Unknown workspace")
+	}
+	err = slack.PushEvent(req)
+	if err != nil {
+		logrus.Errorf("rest: PushEvent failed: %v\n", err)
+		return c.String(http.StatusServiceUnavailable, err.Error())
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// handleInteractions receives clicks on "Write standup", "Show more" and "Set up standups" buttons and submissions of standup and setup modals
+func (r *REST) handleInteractions(c echo.Context) error {
+	var i chat.Interaction
+	err := json.Unmarshal([]byte(c.FormValue("payload")), &i)
+	if err != nil {
+		logrus.Errorf("rest: could not parse interaction: %v\n", err)
+		return c.String(http.StatusBadRequest, "Bad request")
+	}
+	w, ok := r.requestWorkspace(c, i.Team.ID)
+	if !ok {
+		logrus.Errorf("rest: interaction from unknown workspace %v\n", i.Team.ID)
+		return c.String(http.StatusForbidden, "Unknown workspace")
+	}
+	slack, ok := w.chat.(*chat.Slack)
+	if !ok {
+		return c.String(http.StatusNotFound, "Unknown workspace")
+	}
+
+	switch i.Type {
+	case chat.InteractionMessage, chat.InteractionBlockActions:
+		for _, action := range i.Actions {
+			if action.ActionID == chat.ActionSetupChannel {
+				err = slack.OpenSetupModal(i.TriggerID, action.Value)
+				if err != nil {
+					logrus.Errorf("rest: OpenSetupModal failed: %v\n", err)
+					slack.SendUserMessage(i.User.ID, w.conf.Translate.SomethingWentWrong)
+				}
+				continue
+			}
+			if action.ActionID == chat.ActionShowMore {
+				err = slack.ShowMore(i)
+				if err != nil {
+					logrus.Errorf("rest: ShowMore failed: %v\n", err)
+				}
+				continue
+			}
+			if action.Name != chat.CallbackWriteStandup && action.ActionID != chat.CallbackWriteStandup {
+				continue
+			}
+			err = slack.OpenStandupModal(i.TriggerID, action.Value, i.User.ID)
+			if err != nil {
+				logrus.Errorf("rest: OpenStandupModal failed: %v\n", err)
+				slack.SendEphemeralMessage(action.Value, i.User.ID, w.conf.Translate.SomethingWentWrong)
+			}
+		}
+	case chat.InteractionViewSubmission:
+		var fieldErrors map[string]string
+		switch i.View.CallbackID {
+		case chat.CallbackWriteStandup:
+			fieldErrors = slack.SubmitStandupModal(i)
+		case chat.CallbackSetupChannel:
+			fieldErrors = slack.SubmitSetupModal(i)
+		}
+		if len(fieldErrors) > 0 {
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"response_action": "errors",
+				"errors":          fieldErrors,
+			})
+		}
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// allWorkspaces returns handlers of every served workspace
+func (r *REST) allWorkspaces() []*REST {
+	workspaces := []*REST{}
+	for _, w := range r.workspaces {
+		workspaces = append(workspaces, w)
+	}
+	return workspaces
+}
+
+// runCommand runs slash command and returns its reply. Returns false if command is unknown
+func (r *REST) runCommand(form url.Values) (string, bool) {
 	command := form.Get("command")
 
 	switch command {
 	case commandHelp:
-		return r.helpCommand(c, form)
+		return r.helpCommand(form), true
 	case commandAdd:
-		return r.addCommand(c, form)
+		return r.addCommand(form), true
 	case commandList:
-		return r.listCommand(c, form)
+		return r.listCommand(form), true
 	case commandDelete:
-		return r.deleteCommand(c, form)
+		return r.deleteCommand(form), true
 	case commandAddTime:
-		return r.addTime(c, form)
+		return r.addTime(form), true
 	case commandRemoveTime:
-		return r.removeTime(c, form)
+		return r.removeTime(form), true
 	case commandListTime:
-		return r.listTime(c, form)
+		return r.listTime(form), true
 	case commandAddTimeTable:
-		return r.addTimeTable(c, form)
+		return r.addTimeTable(form), true
 	case commandRemoveTimeTable:
-		return r.removeTimeTable(c, form)
+		return r.removeTimeTable(form), true
 	case commandShowTimeTable:
-		return r.showTimeTable(c, form)
+		return r.showTimeTable(form), true
 	case commandReportByProject:
-		return r.reportByProject(c, form)
+		return r.reportByProject(form), true
 	case commandReportByUser:
-		return r.reportByUser(c, form)
+		return r.reportByUser(form), true
 	case commandReportByUserInProject:
-		return r.reportByProjectAndUser(c, form)
+		return r.reportByProjectAndUser(form), true
+	case commandReportBlockers:
+		return r.reportBlockers(form), true
+	case commandReportPlanVsActual:
+		return r.reportPlanVsActual(form), true
+	case commandStandupHistory:
+		return r.standupHistory(form), true
+	case commandStandupRestore:
+		return r.standupRestore(form), true
+	case commandStandupQuestions:
+		return r.standupQuestions(form), true
+	case commandAutoEnroll:
+		return r.autoEnroll(form), true
+	case commandTimeZone:
+		return r.timeZone(form), true
+	case commandWorkingDays:
+		return r.workingDays(form), true
+	case commandHolidays:
+		return r.holidays(form), true
+	case commandVacation:
+		return r.vacation(form), true
+	case commandStandup:
+		return r.standupModal(form), true
 	default:
-		return c.String(http.StatusNotImplemented, "Not implemented")
+		return "", false
 	}
 }
 
-func (r *REST) helpCommand(c echo.Context, f url.Values) error {
-	return c.String(http.StatusOK, r.conf.Translate.HelpCommand)
+func (r *REST) helpCommand(f url.Values) string {
+	return r.conf.Translate.HelpCommand
 }
 
-func (r *REST) addCommand(c echo.Context, f url.Values) error {
-	members, role, channel, accessLevel, err := r.processCommand(c, f)
+func (r *REST) addCommand(f url.Values) string {
+	members, role, channel, accessLevel, err := r.processCommand(f)
 	if err != nil {
-		return c.String(http.StatusOK, r.conf.Translate.SomethingWentWrong)
+		return r.conf.Translate.SomethingWentWrong
 	}
 	switch role {
 	case "admin", "админ":
 		if accessLevel > 2 {
-			return c.String(http.StatusOK, r.conf.Translate.AccessAtLeastAdmin)
+			return r.conf.Translate.AccessAtLeastAdmin
 		}
-		return c.String(http.StatusOK, r.addAdmins(members))
+		return r.addAdmins(members)
 	case "developer", "разработчик", "":
 		if accessLevel > 3 {
-			return c.String(http.StatusOK, r.conf.Translate.AccessAtLeastPM)
+			return r.conf.Translate.AccessAtLeastPM
 		}
-		return c.String(http.StatusOK, r.addMembers(members, "developer", channel))
+		return r.addMembers(members, "developer", channel)
 	case "designer", "дизайнер":
 		if accessLevel > 3 {
-			return c.String(http.StatusOK, r.conf.Translate.AccessAtLeastPM)
+			return r.conf.Translate.AccessAtLeastPM
 		}
-		return c.String(http.StatusOK, r.addMembers(members, "designer", channel))
+		return r.addMembers(members, "designer", channel)
 	case "pm", "пм":
 		if accessLevel > 2 {
-			return c.String(http.StatusOK, r.conf.Translate.AccessAtLeastAdmin)
+			return r.conf.Translate.AccessAtLeastAdmin
 		}
-		return c.String(http.StatusOK, r.addMembers(members, "pm", channel))
+		return r.addMembers(members, "pm", channel)
 	default:
-		return c.String(http.StatusOK, r.conf.Translate.NeedCorrectUserRole)
+		return r.conf.Translate.NeedCorrectUserRole
 	}
 }
 
-func (r *REST) listCommand(c echo.Context, f url.Values) error {
-	ca, err := r.validateRequest(c, f)
+func (r *REST) listCommand(f url.Values) string {
+	ca, err := r.validateRequest(f)
 	if err != nil {
 		logrus.Errorf("Validate Request Failed: %v", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	role := ca.Text
 
 	switch role {
 	case "admin", "админ":
-		return c.String(http.StatusOK, r.listAdmins())
+		return r.listAdmins()
 	case "developer", "разработчик", "":
-		return c.String(http.StatusOK, r.listMembers(ca.ChannelID, "developer"))
+		return r.listMembers(ca.ChannelID, "developer")
 	case "designer", "дизайнер":
-		return c.String(http.StatusOK, r.listMembers(ca.ChannelID, "designer"))
+		return r.listMembers(ca.ChannelID, "designer")
 	case "pm", "пм":
-		return c.String(http.StatusOK, r.listMembers(ca.ChannelID, "pm"))
+		return r.listMembers(ca.ChannelID, "pm")
 	default:
-		return c.String(http.StatusOK, r.conf.Translate.NeedCorrectUserRole)
+		return r.conf.Translate.NeedCorrectUserRole
 	}
 
 }
 
-func (r *REST) deleteCommand(c echo.Context, f url.Values) error {
+func (r *REST) deleteCommand(f url.Values) string {
 
-	users, role, channel, accessLevel, err := r.processCommand(c, f)
+	users, role, channel, accessLevel, err := r.processCommand(f)
 	if err != nil {
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 	switch role {
 	case "admin", "админ":
 		if accessLevel > 2 {
-			return c.String(http.StatusOK, r.conf.Translate.AccessAtLeastAdmin)
+			return r.conf.Translate.AccessAtLeastAdmin
 		}
-		return c.String(http.StatusOK, r.deleteAdmins(users))
+		return r.deleteAdmins(users)
 	case "developer", "разработчик", "designer", "дизайнер", "pm", "пм", "":
 		if accessLevel > 3 {
-			return c.String(http.StatusOK, r.conf.Translate.AccessAtLeastPM)
+			return r.conf.Translate.AccessAtLeastPM
 		}
-		return c.String(http.StatusOK, r.deleteMembers(users, channel))
+		return r.deleteMembers(users, channel)
 	default:
-		return c.String(http.StatusOK, r.conf.Translate.NeedCorrectUserRole)
+		return r.conf.Translate.NeedCorrectUserRole
 	}
 }
 
@@ -275,7 +619,7 @@ func (r *REST) addAdmins(users []string) string {
 		user.Role = "admin"
 		r.db.UpdateUser(user)
 		message := r.conf.Translate.PMAssigned
-		err = r.slack.SendUserMessage(userID, message)
+		err = r.chat.SendUserMessage(userID, message)
 		if err != nil {
 			logrus.Errorf("rest: SendUserMessage failed: %v\n", err)
 		}
@@ -385,7 +729,7 @@ func (r *REST) deleteAdmins(users []string) string {
 		user.Role = ""
 		r.db.UpdateUser(user)
 		message := fmt.Sprintf(r.conf.Translate.PMRemoved)
-		err = r.slack.SendUserMessage(userID, message)
+		err = r.chat.SendUserMessage(userID, message)
 		if err != nil {
 			logrus.Errorf("rest: SendUserMessage failed: %v\n", err)
 		}
@@ -402,100 +746,107 @@ func (r *REST) deleteAdmins(users []string) string {
 	return text
 }
 
-func (r *REST) addTime(c echo.Context, f url.Values) error {
-	ca, err := r.validateRequest(c, f)
+func (r *REST) addTime(f url.Values) string {
+	ca, err := r.validateRequest(f)
 	if err != nil {
 		logrus.Errorf("Validate Request Failed: %v", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	accessLevel, _ := r.getAccessLevel(f.Get("user_id"), f.Get("channel_id"))
 	logrus.Infof("Access level for %v in %v is %v", f.Get("user_id"), f.Get("channel_id"), accessLevel)
 	if accessLevel > 3 {
-		return c.String(http.StatusOK, r.conf.Translate.AccessAtLeastPM)
+		return r.conf.Translate.AccessAtLeastPM
 	}
 
 	timeInt, err := utils.ParseTimeTextToInt(ca.Text)
 	if err != nil {
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
+	// typed time is the time in time zone of the channel
+	channel, _ := r.db.SelectChannel(ca.ChannelID)
+	timeInt = model.MoveDeadline(timeInt, time.Local, channel.Location())
 	err = r.db.CreateStandupTime(timeInt, ca.ChannelID)
 	if err != nil {
 		logrus.Errorf("rest: CreateStandupTime failed: %v\n", err)
-		return c.String(http.StatusOK, r.conf.Translate.SomethingWentWrong)
+		return r.conf.Translate.SomethingWentWrong
 	}
+	r.chat.ScheduleChanged()
 	channelMembers, err := r.db.ListChannelMembers(ca.ChannelID)
 	if err != nil {
 		logrus.Errorf("rest: ListChannelMembers failed: %v\n", err)
 	}
 	if len(channelMembers) == 0 {
-		return c.String(http.StatusOK, fmt.Sprintf(r.conf.Translate.AddStandupTimeNoUsers, timeInt))
+		return fmt.Sprintf(r.conf.Translate.AddStandupTimeNoUsers, timeInt)
 	}
-	return c.String(http.StatusOK, fmt.Sprintf(r.conf.Translate.AddStandupTime, timeInt))
+	return fmt.Sprintf(r.conf.Translate.AddStandupTime, timeInt)
 }
 
-func (r *REST) removeTime(c echo.Context, f url.Values) error {
-	ca, err := r.validateRequest(c, f)
+func (r *REST) removeTime(f url.Values) string {
+	ca, err := r.validateRequest(f)
 	if err != nil {
 		logrus.Errorf("Validate Request Failed: %v", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	accessLevel, _ := r.getAccessLevel(f.Get("user_id"), f.Get("channel_id"))
 	logrus.Infof("Access level for %v in %v is %v", f.Get("user_id"), f.Get("channel_id"), accessLevel)
 	if accessLevel > 3 {
-		return c.String(http.StatusOK, r.conf.Translate.AccessAtLeastPM)
+		return r.conf.Translate.AccessAtLeastPM
 	}
 
 	err = r.db.DeleteStandupTime(ca.ChannelID)
 	if err != nil {
 		logrus.Errorf("rest: DeleteStandupTime failed: %v\n", err)
-		return c.String(http.StatusOK, r.conf.Translate.SomethingWentWrong)
+		return r.conf.Translate.SomethingWentWrong
 	}
+	r.chat.ScheduleChanged()
 	st, err := r.db.ListChannelMembers(ca.ChannelID)
 	if len(st) != 0 {
-		return c.String(http.StatusOK, r.conf.Translate.RemoveStandupTimeWithUsers)
+		return r.conf.Translate.RemoveStandupTimeWithUsers
 	}
-	return c.String(http.StatusOK, fmt.Sprintf(r.conf.Translate.RemoveStandupTime, ca.ChannelName))
+	return fmt.Sprintf(r.conf.Translate.RemoveStandupTime, ca.ChannelName)
 }
 
-func (r *REST) listTime(c echo.Context, f url.Values) error {
-	ca, err := r.validateRequest(c, f)
+func (r *REST) listTime(f url.Values) string {
+	ca, err := r.validateRequest(f)
 	if err != nil {
 		logrus.Errorf("Validate Request Failed: %v", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	standupTime, err := r.db.GetChannelStandupTime(ca.ChannelID)
 	if err != nil || standupTime == int64(0) {
 		logrus.Errorf("GetChannelStandupTime failed: %v", err)
-		return c.String(http.StatusOK, r.conf.Translate.ShowNoStandupTime)
+		return r.conf.Translate.ShowNoStandupTime
 	}
-	return c.String(http.StatusOK, fmt.Sprintf(r.conf.Translate.ShowStandupTime, standupTime))
+	return fmt.Sprintf(r.conf.Translate.ShowStandupTime, standupTime)
 }
 
-func (r *REST) addTimeTable(c echo.Context, f url.Values) error {
-	ca, err := r.validateRequest(c, f)
+func (r *REST) addTimeTable(f url.Values) string {
+	ca, err := r.validateRequest(f)
 	if err != nil {
 		logrus.Errorf("Validate Request Failed: %v", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	accessLevel, _ := r.getAccessLevel(f.Get("user_id"), f.Get("channel_id"))
 	logrus.Infof("Access level for %v in %v is %v", f.Get("user_id"), f.Get("channel_id"), accessLevel)
 	if accessLevel > 3 {
-		return c.String(http.StatusOK, r.conf.Translate.AccessAtLeastPM)
+		return r.conf.Translate.AccessAtLeastPM
 	}
 
-	usersText, weekdays, time, err := utils.SplitTimeTalbeCommand(ca.Text, r.conf.Translate.DaysDivider, r.conf.Translate.TimeDivider)
+	usersText, weekdays, timeInt, err := utils.SplitTimeTalbeCommand(ca.Text, r.conf.Translate.DaysDivider, r.conf.Translate.TimeDivider)
 	if err != nil {
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 	users := strings.Split(usersText, " ")
 	rg, _ := regexp.Compile("<@([a-z0-9]+)|([a-z0-9]+)>")
+	defer r.chat.ScheduleChanged()
+	reply := ""
 	for _, u := range users {
 		if !rg.MatchString(u) {
-			c.String(http.StatusOK, r.conf.Translate.WrongUsernameError)
+			reply += r.conf.Translate.WrongUsernameError
 			continue
 		}
 		userID, userName := utils.SplitUser(u)
@@ -511,159 +862,165 @@ func (r *REST) addTimeTable(c echo.Context, f url.Values) error {
 			}
 		}
 
+		// typed time is the time in time zone of the standuper
+		loc := storage.MemberLocation(r.db, userID, f.Get("channel_id"))
+		deadline := model.MoveDeadline(timeInt, time.Local, loc)
 		tt, err := r.db.SelectTimeTable(m.ID)
 		if err != nil {
 			logrus.Infof("Timetable for this standuper does not exist. Creating...")
 			ttNew, err := r.db.CreateTimeTable(model.TimeTable{
 				ChannelMemberID: m.ID,
 			})
-			ttNew = utils.PrepareTimeTable(ttNew, weekdays, time)
+			ttNew = utils.PrepareTimeTable(ttNew, weekdays, deadline)
 			ttNew, err = r.db.UpdateTimeTable(ttNew)
 			if err != nil {
-				c.String(http.StatusOK, fmt.Sprintf(r.conf.Translate.CanNotUpdateTimetable, userName, err))
+				reply += fmt.Sprintf(r.conf.Translate.CanNotUpdateTimetable, userName, err)
 				continue
 			}
 			logrus.Infof("Timetable created id:%v", ttNew.ID)
-			c.String(http.StatusOK, fmt.Sprintf(r.conf.Translate.TimetableCreated, userID, ttNew.Show()))
+			reply += fmt.Sprintf(r.conf.Translate.TimetableCreated, userID, ttNew.Show(loc))
 			continue
 		}
-		tt = utils.PrepareTimeTable(tt, weekdays, time)
+		tt = utils.PrepareTimeTable(tt, weekdays, deadline)
 		tt, err = r.db.UpdateTimeTable(tt)
 		if err != nil {
-			c.String(http.StatusOK, fmt.Sprintf(r.conf.Translate.CanNotUpdateTimetable, userName, err))
+			reply += fmt.Sprintf(r.conf.Translate.CanNotUpdateTimetable, userName, err)
 			continue
 		}
 		logrus.Infof("Timetable updated id:%v", tt.ID)
-		c.String(http.StatusOK, fmt.Sprintf(r.conf.Translate.TimetableUpdated, userID, tt.Show()))
+		reply += fmt.Sprintf(r.conf.Translate.TimetableUpdated, userID, tt.Show(loc))
 	}
-	return nil
+	return reply
 }
 
-func (r *REST) showTimeTable(c echo.Context, f url.Values) error {
-	ca, err := r.validateRequest(c, f)
+func (r *REST) showTimeTable(f url.Values) string {
+	ca, err := r.validateRequest(f)
 	if err != nil {
 		logrus.Errorf("Validate Request Failed: %v", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	users := strings.Split(ca.Text, " ")
 	rg, _ := regexp.Compile("<@([a-z0-9]+)|([a-z0-9]+)>")
+	reply := ""
 	for _, u := range users {
 		if !rg.MatchString(u) {
-			c.String(http.StatusOK, r.conf.Translate.WrongUsernameError)
+			reply += r.conf.Translate.WrongUsernameError
 			continue
 		}
 		userID, userName := utils.SplitUser(u)
 
 		m, err := r.db.FindChannelMemberByUserID(userID, f.Get("channel_id"))
 		if err != nil {
-			c.String(http.StatusOK, fmt.Sprintf(r.conf.Translate.NotAStanduper, userName))
+			reply += fmt.Sprintf(r.conf.Translate.NotAStanduper, userName)
 			continue
 		}
 		tt, err := r.db.SelectTimeTable(m.ID)
 		if err != nil {
-			c.String(http.StatusOK, fmt.Sprintf(r.conf.Translate.NoTimetableSet, userName))
+			reply += fmt.Sprintf(r.conf.Translate.NoTimetableSet, userName)
 			continue
 		}
-		c.String(http.StatusOK, fmt.Sprintf(r.conf.Translate.TimetableShow, userName, tt.Show()))
+		reply += fmt.Sprintf(r.conf.Translate.TimetableShow, userName, tt.Show(storage.MemberLocation(r.db, userID, f.Get("channel_id"))))
 	}
-	return nil
+	return reply
 }
 
-func (r *REST) removeTimeTable(c echo.Context, f url.Values) error {
-	ca, err := r.validateRequest(c, f)
+func (r *REST) removeTimeTable(f url.Values) string {
+	ca, err := r.validateRequest(f)
 	if err != nil {
 		logrus.Errorf("Validate Request Failed: %v", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	accessLevel, _ := r.getAccessLevel(f.Get("user_id"), f.Get("channel_id"))
 	logrus.Infof("Access level for %v in %v is %v", f.Get("user_id"), f.Get("channel_id"), accessLevel)
 	if accessLevel > 3 {
-		return c.String(http.StatusOK, r.conf.Translate.AccessAtLeastPM)
+		return r.conf.Translate.AccessAtLeastPM
 	}
 
 	users := strings.Split(ca.Text, " ")
 	rg, _ := regexp.Compile("<@([a-z0-9]+)|([a-z0-9]+)>")
+	reply := ""
 	for _, u := range users {
 		if !rg.MatchString(u) {
-			c.String(http.StatusOK, r.conf.Translate.WrongUsernameError)
+			reply += r.conf.Translate.WrongUsernameError
 			continue
 		}
 		userID, userName := utils.SplitUser(u)
 
 		m, err := r.db.FindChannelMemberByUserID(userID, f.Get("channel_id"))
 		if err != nil {
-			c.String(http.StatusOK, fmt.Sprintf(r.conf.Translate.NotAStanduper, userName))
+			reply += fmt.Sprintf(r.conf.Translate.NotAStanduper, userName)
 			continue
 		}
 		tt, err := r.db.SelectTimeTable(m.ID)
 		if err != nil {
-			c.String(http.StatusOK, fmt.Sprintf(r.conf.Translate.NoTimetableSet, userName))
+			reply += fmt.Sprintf(r.conf.Translate.NoTimetableSet, userName)
 			continue
 		}
 		err = r.db.DeleteTimeTable(tt.ID)
 		if err != nil {
-			c.String(http.StatusOK, fmt.Sprintf(r.conf.Translate.CanNotDeleteTimetable, userName))
+			reply += fmt.Sprintf(r.conf.Translate.CanNotDeleteTimetable, userName)
 			continue
 		}
-		c.String(http.StatusOK, fmt.Sprintf(r.conf.Translate.TimetableDeleted, userName))
+		r.chat.ScheduleChanged()
+		reply += fmt.Sprintf(r.conf.Translate.TimetableDeleted, userName)
 	}
-	return nil
+	return reply
 }
 
-func (r *REST) reportByProject(c echo.Context, f url.Values) error {
-	ca, err := r.validateRequest(c, f)
+func (r *REST) reportByProject(f url.Values) string {
+	ca, err := r.validateRequest(f)
 	if err != nil {
 		logrus.Errorf("Validate Request Failed: %v", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	accessLevel, _ := r.getAccessLevel(f.Get("user_id"), f.Get("channel_id"))
 	logrus.Infof("Access level for %v in %v is %v", f.Get("user_id"), f.Get("channel_id"), accessLevel)
 	if accessLevel > 3 {
-		return c.String(http.StatusOK, r.conf.Translate.AccessAtLeastPM)
+		return r.conf.Translate.AccessAtLeastPM
 	}
 
 	commandParams := strings.Fields(ca.Text)
 	if len(commandParams) != 3 {
-		return c.String(http.StatusOK, r.conf.Translate.WrongNArgs)
+		return r.conf.Translate.WrongNArgs
 	}
 	channelName := strings.Replace(commandParams[0], "#", "", -1)
 	channelID, err := r.db.GetChannelID(channelName)
 	if err != nil {
 		logrus.Errorf("rest: GetChannelID failed: %v\n", err)
-		return c.String(http.StatusOK, "Неверное название проекта!")
+		return "Неверное название проекта!"
 	}
 
 	channel, err := r.db.SelectChannel(channelID)
 	if err != nil {
 		logrus.Errorf("rest: SelectChannel failed: %v\n", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	dateFrom, err := time.Parse("2006-01-02", commandParams[1])
 	if err != nil {
 		logrus.Errorf("rest: time.Parse failed: %v\n", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 	dateTo, err := time.Parse("2006-01-02", commandParams[2])
 	if err != nil {
 		logrus.Errorf("rest: time.Parse failed: %v\n", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	report, err := r.report.StandupReportByProject(channel, dateFrom, dateTo)
 	if err != nil {
 		logrus.Errorf("rest: StandupReportByProject: %v\n", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	text := ""
 	text += report.ReportHead
 	if len(report.ReportBody) == 0 {
 		text += r.conf.Translate.ReportNoData
-		return c.String(http.StatusOK, text)
+		return text
 	}
 	for _, t := range report.ReportBody {
 		text += t.Text
@@ -675,54 +1032,54 @@ func (r *REST) reportByProject(c echo.Context, f url.Values) error {
 			text += fmt.Sprintf(r.conf.Translate.ReportOnProjectCollectorData, cd.TotalCommits, utils.SecondsToHuman(cd.Worklogs))
 		}
 	}
-	return c.String(http.StatusOK, text)
+	return text
 }
 
-func (r *REST) reportByUser(c echo.Context, f url.Values) error {
-	ca, err := r.validateRequest(c, f)
+func (r *REST) reportByUser(f url.Values) string {
+	ca, err := r.validateRequest(f)
 	if err != nil {
 		logrus.Errorf("Validate Request Failed: %v", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	commandParams := strings.Fields(ca.Text)
 	if len(commandParams) != 3 {
-		return c.String(http.StatusOK, r.conf.Translate.WrongNArgs)
+		return r.conf.Translate.WrongNArgs
 	}
 	username := strings.Replace(commandParams[0], "@", "", -1)
 	user, err := r.db.SelectUserByUserName(username)
 	if err != nil {
-		return c.String(http.StatusOK, "User does not exist!")
+		return "User does not exist!"
 	}
 
 	accessLevel, _ := r.getAccessLevel(f.Get("user_id"), f.Get("channel_id"))
 	logrus.Infof("Access level for %v in %v is %v", f.Get("user_id"), f.Get("channel_id"), accessLevel)
 	if f.Get("user_id") != user.UserID && accessLevel > 2 {
-		return c.String(http.StatusOK, r.conf.Translate.AccessAtLeastAdminOrOwner)
+		return r.conf.Translate.AccessAtLeastAdminOrOwner
 	}
 
 	dateFrom, err := time.Parse("2006-01-02", commandParams[1])
 	if err != nil {
 		logrus.Errorf("rest: time.Parse failed: %v\n", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 	dateTo, err := time.Parse("2006-01-02", commandParams[2])
 	if err != nil {
 		logrus.Errorf("rest: time.Parse failed: %v\n", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	report, err := r.report.StandupReportByUser(user.UserID, dateFrom, dateTo)
 	if err != nil {
 		logrus.Errorf("rest: StandupReportByUser failed: %v\n", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	text := ""
 	text += report.ReportHead
 	if len(report.ReportBody) == 0 {
 		text += r.conf.Translate.ReportNoData
-		return c.String(http.StatusOK, text)
+		return text
 	}
 	for _, t := range report.ReportBody {
 		text += t.Text
@@ -734,73 +1091,73 @@ func (r *REST) reportByUser(c echo.Context, f url.Values) error {
 			text += fmt.Sprintf(r.conf.Translate.ReportCollectorDataUser, cd.TotalCommits, utils.SecondsToHuman(cd.Worklogs))
 		}
 	}
-	return c.String(http.StatusOK, text)
+	return text
 }
 
-func (r *REST) reportByProjectAndUser(c echo.Context, f url.Values) error {
-	ca, err := r.validateRequest(c, f)
+func (r *REST) reportByProjectAndUser(f url.Values) string {
+	ca, err := r.validateRequest(f)
 	if err != nil {
 		logrus.Errorf("Validate Request Failed: %v", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	commandParams := strings.Fields(ca.Text)
 	if len(commandParams) != 4 {
-		return c.String(http.StatusOK, r.conf.Translate.WrongNArgs)
+		return r.conf.Translate.WrongNArgs
 	}
 
 	channelName := strings.Replace(commandParams[0], "#", "", -1)
 	channelID, err := r.db.GetChannelID(channelName)
 	if err != nil {
 		logrus.Errorf("rest: GetChannelID failed: %v\n", err)
-		return c.String(http.StatusOK, r.conf.Translate.WrongProjectName)
+		return r.conf.Translate.WrongProjectName
 	}
 
 	channel, err := r.db.SelectChannel(channelID)
 	if err != nil {
 		logrus.Errorf("rest: SelectChannel failed: %v\n", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	username := strings.Replace(commandParams[1], "@", "", -1)
 
 	user, err := r.db.SelectUserByUserName(username)
 	if err != nil {
-		return c.String(http.StatusOK, r.conf.Translate.NoSuchUserInWorkspace)
+		return r.conf.Translate.NoSuchUserInWorkspace
 	}
 	member, err := r.db.FindChannelMemberByUserName(user.UserName, channelID)
 	if err != nil {
-		return c.String(http.StatusOK, fmt.Sprintf(r.conf.Translate.CanNotFindMember, user.UserID))
+		return fmt.Sprintf(r.conf.Translate.CanNotFindMember, user.UserID)
 	}
 
 	accessLevel, _ := r.getAccessLevel(f.Get("user_id"), f.Get("channel_id"))
 	logrus.Infof("Access level for %v in %v is %v", f.Get("user_id"), f.Get("channel_id"), accessLevel)
 	if (f.Get("user_id") != member.UserID && f.Get("channel_id") != member.ChannelID) && accessLevel > 3 {
-		return c.String(http.StatusOK, r.conf.Translate.AccessAtLeastPMOrOwner)
+		return r.conf.Translate.AccessAtLeastPMOrOwner
 	}
 
 	dateFrom, err := time.Parse("2006-01-02", commandParams[2])
 	if err != nil {
 		logrus.Errorf("rest: time.Parse failed: %v\n", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 	dateTo, err := time.Parse("2006-01-02", commandParams[3])
 	if err != nil {
 		logrus.Errorf("rest: time.Parse failed: %v\n", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	report, err := r.report.StandupReportByProjectAndUser(channel, member.UserID, dateFrom, dateTo)
 	if err != nil {
 		logrus.Errorf("rest: StandupReportByProjectAndUser failed: %v\n", err)
-		return c.String(http.StatusOK, err.Error())
+		return err.Error()
 	}
 
 	text := ""
 	text += report.ReportHead
 	if len(report.ReportBody) == 0 {
 		text += r.conf.Translate.ReportNoData
-		return c.String(http.StatusOK, text)
+		return text
 	}
 	for _, t := range report.ReportBody {
 		text += t.Text
@@ -813,7 +1170,856 @@ func (r *REST) reportByProjectAndUser(c echo.Context, f url.Values) error {
 			text += fmt.Sprintf(r.conf.Translate.ReportCollectorDataUser, cd.TotalCommits, utils.SecondsToHuman(cd.Worklogs))
 		}
 	}
-	return c.String(http.StatusOK, text)
+	return text
+}
+
+// projectReportParams parses "#channel 2017-01-01 2017-01-31" arguments of project reports
+func (r *REST) projectReportParams(text string) (model.Channel, time.Time, time.Time, error) {
+	commandParams := strings.Fields(text)
+	if len(commandParams) != 3 {
+		return model.Channel{}, time.Time{}, time.Time{}, errors.New(r.conf.Translate.WrongNArgs)
+	}
+	channelName := strings.Replace(commandParams[0], "#", "", -1)
+	channelID, err := r.db.GetChannelID(channelName)
+	if err != nil {
+		logrus.Errorf("rest: GetChannelID failed: %v\n", err)
+		return model.Channel{}, time.Time{}, time.Time{}, errors.New(r.conf.Translate.WrongProjectName)
+	}
+	channel, err := r.db.SelectChannel(channelID)
+	if err != nil {
+		logrus.Errorf("rest: SelectChannel failed: %v\n", err)
+		return model.Channel{}, time.Time{}, time.Time{}, err
+	}
+	dateFrom, err := time.Parse("2006-01-02", commandParams[1])
+	if err != nil {
+		logrus.Errorf("rest: time.Parse failed: %v\n", err)
+		return model.Channel{}, time.Time{}, time.Time{}, err
+	}
+	dateTo, err := time.Parse("2006-01-02", commandParams[2])
+	if err != nil {
+		logrus.Errorf("rest: time.Parse failed: %v\n", err)
+		return model.Channel{}, time.Time{}, time.Time{}, err
+	}
+	return channel, dateFrom, dateTo, nil
+}
+
+func (r *REST) reportBlockers(f url.Values) string {
+	return r.sectionsReport(f, r.report.BlockersReport)
+}
+
+func (r *REST) reportPlanVsActual(f url.Values) string {
+	return r.sectionsReport(f, r.report.PlanVsActualReport)
+}
+
+// sectionsReport runs report built from standup sections on a project
+func (r *REST) sectionsReport(f url.Values, build func(model.Channel, time.Time, time.Time) (reporting.Report, error)) string {
+	ca, err := r.validateRequest(f)
+	if err != nil {
+		logrus.Errorf("Validate Request Failed: %v", err)
+		return err.Error()
+	}
+
+	accessLevel, _ := r.getAccessLevel(f.Get("user_id"), f.Get("channel_id"))
+	if accessLevel > 3 {
+		return r.conf.Translate.AccessAtLeastPM
+	}
+
+	channel, dateFrom, dateTo, err := r.projectReportParams(ca.Text)
+	if err != nil {
+		return err.Error()
+	}
+
+	report, err := build(channel, dateFrom, dateTo)
+	if err != nil {
+		logrus.Errorf("rest: sections report failed: %v\n", err)
+		return err.Error()
+	}
+
+	text := report.ReportHead
+	if len(report.ReportBody) == 0 {
+		return text + r.conf.Translate.ReportNoData
+	}
+	for _, t := range report.ReportBody {
+		text += t.Text
+	}
+	return text
+}
+
+func (r *REST) standupHistory(f url.Values) string {
+	ca, err := r.validateRequest(f)
+	if err != nil {
+		logrus.Errorf("Validate Request Failed: %v", err)
+		return err.Error()
+	}
+
+	commandParams := strings.Fields(ca.Text)
+	if len(commandParams) != 2 {
+		return r.conf.Translate.WrongNArgs
+	}
+
+	rg, _ := regexp.Compile("<@([a-z0-9]+)|([a-z0-9]+)>")
+	if !rg.MatchString(commandParams[0]) {
+		return r.conf.Translate.WrongUsernameError
+	}
+	userID, _ := utils.SplitUser(commandParams[0])
+
+	accessLevel, _ := r.getAccessLevel(f.Get("user_id"), f.Get("channel_id"))
+	logrus.Infof("Access level for %v in %v is %v", f.Get("user_id"), f.Get("channel_id"), accessLevel)
+	if f.Get("user_id") != userID && accessLevel > 3 {
+		return r.conf.Translate.AccessAtLeastPMOrOwner
+	}
+
+	// the day is taken in time zone of the standuper
+	loc := storage.MemberLocation(r.db, userID, ca.ChannelID)
+	date, err := time.ParseInLocation(model.HolidayDay, commandParams[1], loc)
+	if err != nil {
+		logrus.Errorf("rest: time.Parse failed: %v\n", err)
+		return err.Error()
+	}
+	dayStart := model.StartOfDay(date)
+
+	standup, err := r.db.SelectStandupsFiltered(userID, ca.ChannelID, dayStart, dayStart.AddDate(0, 0, 1))
+	if err != nil {
+		return fmt.Sprintf(r.conf.Translate.StandupHistoryNoStandup, userID, commandParams[1])
+	}
+
+	history, err := r.db.ListStandupHistory(standup.ID)
+	if err != nil {
+		logrus.Errorf("rest: ListStandupHistory failed: %v\n", err)
+		return r.conf.Translate.SomethingWentWrong
+	}
+	if len(history) == 0 {
+		return fmt.Sprintf(r.conf.Translate.StandupHistoryNotEdited, userID, commandParams[1], standup.Comment)
+	}
+
+	text := fmt.Sprintf(r.conf.Translate.StandupHistoryHead, userID, commandParams[1], len(history))
+	text += fmt.Sprintf(r.conf.Translate.StandupHistoryOriginal, standup.Created.In(loc).Format("15:04"), history[0].StandupText)
+	for i, version := range history {
+		next := standup.Comment
+		if i+1 < len(history) {
+			next = history[i+1].StandupText
+		}
+		edit := r.conf.Translate.StandupHistoryEdit
+		if version.AfterDeadline {
+			edit = r.conf.Translate.StandupHistoryEditAfterDeadline
+		}
+		text += fmt.Sprintf(edit, i+1, version.Created.In(loc).Format("15:04"), utils.DiffLines(version.StandupText, next))
+	}
+	return text
+}
+
+func (r *REST) standupRestore(f url.Values) string {
+	ca, err := r.validateRequest(f)
+	if err != nil {
+		logrus.Errorf("Validate Request Failed: %v", err)
+		return err.Error()
+	}
+
+	accessLevel, _ := r.getAccessLevel(f.Get("user_id"), f.Get("channel_id"))
+	logrus.Infof("Access level for %v in %v is %v", f.Get("user_id"), f.Get("channel_id"), accessLevel)
+	if accessLevel > 2 {
+		return r.conf.Translate.AccessAtLeastAdmin
+	}
+
+	commandParams := strings.Fields(ca.Text)
+	if len(commandParams) != 2 {
+		return r.conf.Translate.WrongNArgs
+	}
+
+	rg, _ := regexp.Compile("<@([a-z0-9]+)|([a-z0-9]+)>")
+	if !rg.MatchString(commandParams[0]) {
+		return r.conf.Translate.WrongUsernameError
+	}
+	userID, _ := utils.SplitUser(commandParams[0])
+
+	date, err := time.Parse("2006-01-02", commandParams[1])
+	if err != nil {
+		logrus.Errorf("rest: time.Parse failed: %v\n", err)
+		return err.Error()
+	}
+	dateTo := date.Add(24 * time.Hour)
+
+	deleted, err := r.db.SelectDeletedStandup(userID, ca.ChannelID, date, dateTo)
+	if err != nil {
+		return fmt.Sprintf(r.conf.Translate.StandupRestoreNoDeleted, userID, commandParams[1])
+	}
+
+	active, err := r.db.SelectStandupsFiltered(userID, ca.ChannelID, date, dateTo)
+	if err == nil {
+		// empty standups are created for non reporters at the end of the day and can be replaced
+		if active.Comment != "" {
+			return fmt.Sprintf(r.conf.Translate.StandupRestoreHasActive, userID, commandParams[1])
+		}
+		err = r.db.DeleteStandup(active.ID)
+		if err != nil {
+			logrus.Errorf("rest: DeleteStandup failed: %v\n", err)
+			return r.conf.Translate.SomethingWentWrong
+		}
+	}
+
+	standup, err := r.db.RestoreStandup(deleted.ID)
+	if err != nil {
+		logrus.Errorf("rest: RestoreStandup failed: %v\n", err)
+		return r.conf.Translate.SomethingWentWrong
+	}
+	return fmt.Sprintf(r.conf.Translate.StandupRestored, userID, commandParams[1], standup.Comment)
+}
+
+// standupModal opens modal to write standup in the current channel
+func (r *REST) standupModal(f url.Values) string {
+	slack, ok := r.chat.(*chat.Slack)
+	if !ok {
+		return r.conf.Translate.StandupModalNotSupported
+	}
+	err := slack.OpenStandupModal(f.Get("trigger_id"), f.Get("channel_id"), f.Get("user_id"))
+	if err != nil {
+		logrus.Errorf("rest: OpenStandupModal failed: %v\n", err)
+		return r.conf.Translate.SomethingWentWrong
+	}
+	// modal is opened, nothing is posted in the channel
+	return ""
+}
+
+func (r *REST) standupQuestions(f url.Values) string {
+	ca, err := r.validateRequest(f)
+	if err != nil {
+		logrus.Errorf("Validate Request Failed: %v", err)
+		return err.Error()
+	}
+
+	questions, err := r.db.ListStandupQuestions(ca.ChannelID)
+	if err != nil {
+		logrus.Errorf("rest: ListStandupQuestions failed: %v\n", err)
+		return r.conf.Translate.SomethingWentWrong
+	}
+
+	text := strings.TrimSpace(ca.Text)
+	if text == "" {
+		return r.showStandupQuestions(questions)
+	}
+
+	accessLevel, _ := r.getAccessLevel(f.Get("user_id"), f.Get("channel_id"))
+	logrus.Infof("Access level for %v in %v is %v", f.Get("user_id"), f.Get("channel_id"), accessLevel)
+	if accessLevel > 3 {
+		return r.conf.Translate.AccessAtLeastPM
+	}
+
+	action := strings.Fields(text)[0]
+	params := strings.TrimSpace(strings.TrimPrefix(text, action))
+	switch action {
+	case "add":
+		question, err := model.ParseStandupQuestion(ca.ChannelID, params)
+		if err != nil {
+			return r.conf.Translate.StandupQuestionsUsage
+		}
+		question, err = r.db.CreateStandupQuestion(question)
+		if err != nil {
+			logrus.Errorf("rest: CreateStandupQuestion failed: %v\n", err)
+			return r.conf.Translate.SomethingWentWrong
+		}
+		return fmt.Sprintf(r.conf.Translate.StandupQuestionAdded, question.Question)
+	case "remove":
+		number, err := strconv.Atoi(params)
+		if err != nil {
+			return r.conf.Translate.StandupQuestionsUsage
+		}
+		if number < 1 || number > len(questions) {
+			return fmt.Sprintf(r.conf.Translate.StandupQuestionNotFound, number)
+		}
+		question := questions[number-1]
+		err = r.db.DeleteStandupQuestion(question.ID)
+		if err != nil {
+			logrus.Errorf("rest: DeleteStandupQuestion failed: %v\n", err)
+			return r.conf.Translate.SomethingWentWrong
+		}
+		return fmt.Sprintf(r.conf.Translate.StandupQuestionRemoved, question.Question)
+	case "reset":
+		for _, question := range questions {
+			err = r.db.DeleteStandupQuestion(question.ID)
+			if err != nil {
+				logrus.Errorf("rest: DeleteStandupQuestion failed: %v\n", err)
+				return r.conf.Translate.SomethingWentWrong
+			}
+		}
+		return r.conf.Translate.StandupQuestionsReset
+	default:
+		return r.conf.Translate.StandupQuestionsUsage
+	}
+}
+
+func (r *REST) showStandupQuestions(questions []model.StandupQuestion) string {
+	if len(questions) == 0 {
+		return r.conf.Translate.StandupQuestionsDefault
+	}
+	text := r.conf.Translate.StandupQuestionsList
+	for i, q := range questions {
+		keywords := strings.Join(q.KeywordList(), ", ")
+		if keywords == "" {
+			keywords = "-"
+		}
+		if q.Required {
+			text += fmt.Sprintf(r.conf.Translate.StandupQuestionsItem, i+1, q.Question, keywords)
+			continue
+		}
+		text += fmt.Sprintf(r.conf.Translate.StandupQuestionsItemOptional, i+1, q.Question, keywords)
+	}
+	return text
+}
+
+func (r *REST) autoEnroll(f url.Values) string {
+	ca, err := r.validateRequest(f)
+	if err != nil {
+		logrus.Errorf("Validate Request Failed: %v", err)
+		return err.Error()
+	}
+
+	channel, err := r.db.SelectChannel(ca.ChannelID)
+	if err != nil {
+		logrus.Errorf("rest: SelectChannel failed: %v\n", err)
+		return r.conf.Translate.SomethingWentWrong
+	}
+
+	text := strings.TrimSpace(ca.Text)
+	if text == "" {
+		return r.showAutoEnroll(channel)
+	}
+
+	accessLevel, _ := r.getAccessLevel(f.Get("user_id"), f.Get("channel_id"))
+	logrus.Infof("Access level for %v in %v is %v", f.Get("user_id"), f.Get("channel_id"), accessLevel)
+	if accessLevel > 3 {
+		return r.conf.Translate.AccessAtLeastPM
+	}
+
+	action := strings.Fields(text)[0]
+	users := strings.Fields(strings.TrimPrefix(text, action))
+	switch action {
+	case "on", "off":
+		channel.AutoEnroll = action == "on"
+		channel, err = r.db.UpdateChannel(channel)
+		if err != nil {
+			logrus.Errorf("rest: UpdateChannel failed: %v\n", err)
+			return r.conf.Translate.SomethingWentWrong
+		}
+		if !channel.AutoEnroll {
+			return r.conf.Translate.AutoEnrollTurnedOff
+		}
+		added, err := r.chat.EnrollMembers(channel.ChannelID)
+		if err != nil {
+			logrus.Errorf("rest: EnrollMembers failed: %v\n", err)
+		}
+		return fmt.Sprintf(r.conf.Translate.AutoEnrollTurnedOn, added)
+	case "exclude", "include":
+		if len(users) == 0 {
+			return r.conf.Translate.AutoEnrollUsage
+		}
+		for _, u := range users {
+			if !strings.HasPrefix(u, "<@") || !strings.Contains(u, "|") {
+				return r.conf.Translate.AutoEnrollUsage
+			}
+		}
+		for _, u := range users {
+			userID, _ := utils.SplitUser(u)
+			if action == "include" {
+				err = r.db.IncludeInAutoEnroll(channel.ChannelID, userID)
+			} else {
+				err = r.excludeFromAutoEnroll(channel, userID)
+			}
+			if err != nil {
+				logrus.Errorf("rest: %v %v failed: %v\n", action, userID, err)
+				return r.conf.Translate.SomethingWentWrong
+			}
+		}
+		if action == "include" {
+			if channel.AutoEnroll {
+				_, err = r.chat.EnrollMembers(channel.ChannelID)
+				if err != nil {
+					logrus.Errorf("rest: EnrollMembers failed: %v\n", err)
+				}
+			}
+			return fmt.Sprintf(r.conf.Translate.AutoEnrollIncludedUsers, strings.Join(users, ", "))
+		}
+		return fmt.Sprintf(r.conf.Translate.AutoEnrollExcludedUsers, strings.Join(users, ", "))
+	default:
+		return r.conf.Translate.AutoEnrollUsage
+	}
+}
+
+// excludeFromAutoEnroll excludes user from auto-enroll. Developers enrolled before
+// are removed from standupers if auto-enroll is on
+func (r *REST) excludeFromAutoEnroll(channel model.Channel, userID string) error {
+	err := r.db.ExcludeFromAutoEnroll(channel.ChannelID, userID)
+	if err != nil || !channel.AutoEnroll {
+		return err
+	}
+	member, err := r.db.FindChannelMemberByUserID(userID, channel.ChannelID)
+	if err != nil || member.RoleInChannel != "developer" {
+		return nil
+	}
+	if tt, err := r.db.SelectTimeTable(member.ID); err == nil {
+		r.db.DeleteTimeTable(tt.ID)
+	}
+	err = r.db.DeleteChannelMember(userID, channel.ChannelID)
+	if err != nil {
+		return err
+	}
+	r.chat.ScheduleChanged()
+	return nil
+}
+
+func (r *REST) showAutoEnroll(channel model.Channel) string {
+	text := r.conf.Translate.AutoEnrollOff
+	if channel.AutoEnroll {
+		text = r.conf.Translate.AutoEnrollOn
+	}
+	excluded, err := r.db.ListAutoEnrollExclusions(channel.ChannelID)
+	if err != nil {
+		logrus.Errorf("rest: ListAutoEnrollExclusions failed: %v\n", err)
+		return text
+	}
+	if len(excluded) == 0 {
+		return text
+	}
+	users := []string{}
+	for _, userID := range excluded {
+		users = append(users, fmt.Sprintf("<@%v>", userID))
+	}
+	return text + fmt.Sprintf(r.conf.Translate.AutoEnrollExcluded, strings.Join(users, ", "))
+}
+
+// timeZone shows or changes time zone of the channel. Standup time keeps its hours and
+// minutes when time zone changes
+func (r *REST) timeZone(f url.Values) string {
+	ca, err := r.validateRequest(f)
+	if err != nil {
+		logrus.Errorf("Validate Request Failed: %v", err)
+		return err.Error()
+	}
+
+	channel, err := r.db.SelectChannel(ca.ChannelID)
+	if err != nil {
+		logrus.Errorf("rest: SelectChannel failed: %v\n", err)
+		return r.conf.Translate.SomethingWentWrong
+	}
+
+	name := strings.TrimSpace(ca.Text)
+	if name == "" {
+		if channel.TimeZone == "" {
+			return fmt.Sprintf(r.conf.Translate.TimeZoneShowDefault, time.Local)
+		}
+		return fmt.Sprintf(r.conf.Translate.TimeZoneShow, channel.TimeZone)
+	}
+
+	accessLevel, _ := r.getAccessLevel(f.Get("user_id"), f.Get("channel_id"))
+	logrus.Infof("Access level for %v in %v is %v", f.Get("user_id"), f.Get("channel_id"), accessLevel)
+	if accessLevel > 3 {
+		return r.conf.Translate.AccessAtLeastPM
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return fmt.Sprintf(r.conf.Translate.TimeZoneUnknown, name)
+	}
+	standupTime := model.MoveDeadline(channel.StandupTime, channel.Location(), loc)
+	from := channel.Location()
+	channel.TimeZone = loc.String()
+	channel, err = r.db.UpdateChannel(channel)
+	if err != nil {
+		logrus.Errorf("rest: UpdateChannel failed: %v\n", err)
+		return r.conf.Translate.SomethingWentWrong
+	}
+	defer r.chat.ScheduleChanged()
+	// timetables of standupers without own time zone keep their local time too
+	members, err := r.db.ListChannelMembers(channel.ChannelID)
+	if err != nil {
+		logrus.Errorf("rest: ListChannelMembers failed: %v\n", err)
+	}
+	for _, m := range members {
+		user, _ := r.db.SelectUser(m.UserID)
+		if user.TimeZone != "" {
+			continue
+		}
+		err := storage.MoveTimeTable(r.db, m.ID, from, loc)
+		if err != nil {
+			logrus.Errorf("rest: UpdateTimeTable failed: %v\n", err)
+		}
+	}
+	if standupTime == 0 {
+		return fmt.Sprintf(r.conf.Translate.TimeZoneSet, channel.TimeZone)
+	}
+	err = r.db.CreateStandupTime(standupTime, channel.ChannelID)
+	if err != nil {
+		logrus.Errorf("rest: CreateStandupTime failed: %v\n", err)
+		return r.conf.Translate.SomethingWentWrong
+	}
+	return fmt.Sprintf(r.conf.Translate.TimeZoneSetWithDeadline, channel.TimeZone, time.Unix(standupTime, 0).In(loc).Format("15:04"))
+}
+
+// workingDays shows working days of channel or sets them. Deadlines, reminders and
+// reports of the channel are skipped on other days
+func (r *REST) workingDays(f url.Values) string {
+	ca, err := r.validateRequest(f)
+	if err != nil {
+		logrus.Errorf("Validate Request Failed: %v", err)
+		return err.Error()
+	}
+
+	channel, err := r.db.SelectChannel(ca.ChannelID)
+	if err != nil {
+		logrus.Errorf("rest: SelectChannel failed: %v\n", err)
+		return r.conf.Translate.SomethingWentWrong
+	}
+
+	text := strings.TrimSpace(ca.Text)
+	if text == "" {
+		return fmt.Sprintf(r.conf.Translate.WorkingDaysShow, channel.Days())
+	}
+
+	accessLevel, _ := r.getAccessLevel(f.Get("user_id"), f.Get("channel_id"))
+	logrus.Infof("Access level for %v in %v is %v", f.Get("user_id"), f.Get("channel_id"), accessLevel)
+	if accessLevel > 3 {
+		return r.conf.Translate.AccessAtLeastPM
+	}
+
+	days, ok := model.ParseWorkingDays(text)
+	if !ok {
+		return fmt.Sprintf(r.conf.Translate.WorkingDaysWrong, text)
+	}
+	channel.WorkingDays = days
+	channel, err = r.db.UpdateChannel(channel)
+	if err != nil {
+		logrus.Errorf("rest: UpdateChannel failed: %v\n", err)
+		return r.conf.Translate.SomethingWentWrong
+	}
+	defer r.chat.ScheduleChanged()
+	return fmt.Sprintf(r.conf.Translate.WorkingDaysSet, channel.Days())
+}
+
+// holidaysImportTimeout limits how long calendar of holidays is downloaded
+const holidaysImportTimeout = 30 * time.Second
+
+// holidaysImportYears limits how many years ahead yearly holidays of calendar are imported
+const holidaysImportYears = 2
+
+// holidaysClient downloads calendars of holidays. It connects to public addresses only,
+// so that links can not reach services in the network of Comedian
+var holidaysClient = &http.Client{
+	Timeout: holidaysImportTimeout,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: holidaysImportTimeout, Control: dialPublicOnly}).DialContext,
+		TLSHandshakeTimeout: holidaysImportTimeout,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if req.URL.Scheme != "https" {
+			return fmt.Errorf("redirect to %v is not https", req.URL)
+		}
+		if len(via) >= 10 {
+			return errors.New("too many redirects")
+		}
+		return nil
+	},
+}
+
+// dialPublicOnly refuses connections to loopback, private, link-local and other addresses
+// which are not public. It is checked after name is resolved, so names pointing to such
+// addresses are refused too
+func dialPublicOnly(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("address %v is not public", host)
+	}
+	return nil
+}
+
+// sharedAddressSpace is carrier-grade NAT range, it is not public either
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublicIP returns true if ip is an address of the Internet
+func isPublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil && (ip4[0] == 0 || sharedAddressSpace.Contains(ip4)) {
+		return false
+	}
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() && !ip.IsMulticast() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast()
+}
+
+// holidays shows upcoming holidays of the channel, adds, removes or imports them from ICS
+// calendar. Holidays of the whole workspace are managed with "workspace" before the action
+func (r *REST) holidays(f url.Values) string {
+	ca, err := r.validateRequest(f)
+	if err != nil {
+		logrus.Errorf("Validate Request Failed: %v", err)
+		return err.Error()
+	}
+
+	channel, err := r.db.SelectChannel(ca.ChannelID)
+	if err != nil {
+		logrus.Errorf("rest: SelectChannel failed: %v\n", err)
+		return r.conf.Translate.SomethingWentWrong
+	}
+
+	args := strings.Fields(ca.Text)
+	if len(args) == 0 {
+		return r.showHolidays(channel)
+	}
+
+	accessLevel, _ := r.getAccessLevel(f.Get("user_id"), f.Get("channel_id"))
+	logrus.Infof("Access level for %v in %v is %v", f.Get("user_id"), f.Get("channel_id"), accessLevel)
+	channelID := channel.ChannelID
+	if args[0] == "workspace" {
+		if accessLevel > 2 {
+			return r.conf.Translate.AccessAtLeastAdmin
+		}
+		channelID = ""
+		args = args[1:]
+	}
+	if accessLevel > 3 {
+		return r.conf.Translate.AccessAtLeastPM
+	}
+	if len(args) < 2 {
+		return r.conf.Translate.HolidaysUsage
+	}
+
+	switch args[0] {
+	case "add", "remove":
+		day := args[1]
+		if _, err := time.Parse(model.HolidayDay, day); err != nil {
+			return fmt.Sprintf(r.conf.Translate.HolidayWrongDay, day)
+		}
+		if args[0] == "remove" {
+			err = r.db.DeleteHoliday(channelID, day)
+			if err != nil {
+				logrus.Errorf("rest: DeleteHoliday failed: %v\n", err)
+				return r.conf.Translate.SomethingWentWrong
+			}
+			defer r.chat.ScheduleChanged()
+			return fmt.Sprintf(r.conf.Translate.HolidayRemoved, day)
+		}
+		if len(args) < 3 {
+			return r.conf.Translate.HolidaysUsage
+		}
+		name := strings.Join(args[2:], " ")
+		_, err = r.db.CreateHoliday(model.Holiday{ChannelID: channelID, Day: day, Name: name})
+		if err != nil {
+			logrus.Errorf("rest: CreateHoliday failed: %v\n", err)
+			return r.conf.Translate.SomethingWentWrong
+		}
+		defer r.chat.ScheduleChanged()
+		return fmt.Sprintf(r.conf.Translate.HolidayAdded, day, name)
+	case "import":
+		// Slack sends links as <https://example.com/calendar.ics|example.com/calendar.ics>
+		link := strings.SplitN(strings.Trim(args[1], "<>"), "|", 2)[0]
+		u, err := url.Parse(link)
+		if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+			return fmt.Sprintf(r.conf.Translate.HolidaysWrongLink, link)
+		}
+		// command is answered at once, calendar may take longer to download
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			r.importHolidays(ca.ChannelID, f.Get("user_id"), channelID, link)
+		}()
+		return fmt.Sprintf(r.conf.Translate.HolidaysImporting, link)
+	default:
+		return r.conf.Translate.HolidaysUsage
+	}
+}
+
+// importHolidays saves holidays of calendar at link as holidays of channel, of the workspace
+// if channel is empty. User who imports them is told the result in the channel of command
+func (r *REST) importHolidays(commandChannelID, userID, channelID, link string) {
+	report := func(text string) {
+		err := r.chat.SendEphemeralMessage(commandChannelID, userID, text)
+		if err != nil {
+			logrus.Errorf("rest: SendEphemeralMessage failed: %v\n", err)
+		}
+	}
+	holidays, err := downloadHolidays(link)
+	if err != nil {
+		logrus.Errorf("rest: downloadHolidays failed: %v\n", err)
+		report(fmt.Sprintf(r.conf.Translate.HolidaysImportFailed, link))
+		return
+	}
+	for i := range holidays {
+		holidays[i].ChannelID = channelID
+	}
+	// holidays are saved all together, so failed import leaves holidays as they were
+	err = r.db.ImportHolidays(holidays)
+	if err != nil {
+		logrus.Errorf("rest: ImportHolidays failed: %v\n", err)
+		report(fmt.Sprintf(r.conf.Translate.HolidaysImportFailed, link))
+		return
+	}
+	r.chat.ScheduleChanged()
+	report(fmt.Sprintf(r.conf.Translate.HolidaysImported, len(holidays)))
+}
+
+// showHolidays lists holidays of the channel and of the workspace from today on
+func (r *REST) showHolidays(channel model.Channel) string {
+	holidays, err := r.db.ListHolidays(channel.ChannelID)
+	if err != nil {
+		logrus.Errorf("rest: ListHolidays failed: %v\n", err)
+		return r.conf.Translate.SomethingWentWrong
+	}
+	today := time.Now().In(channel.Location()).Format(model.HolidayDay)
+	list := ""
+	for _, h := range holidays {
+		if h.Day < today {
+			continue
+		}
+		if h.ChannelID == "" {
+			list += fmt.Sprintf(r.conf.Translate.HolidaysItemWorkspace, h.Day, h.Name)
+			continue
+		}
+		list += fmt.Sprintf(r.conf.Translate.HolidaysItem, h.Day, h.Name)
+	}
+	if list == "" {
+		return r.conf.Translate.HolidaysNone
+	}
+	return fmt.Sprintf(r.conf.Translate.HolidaysList, list)
+}
+
+// downloadHolidays returns holidays of ICS calendar published at link
+func downloadHolidays(link string) ([]model.Holiday, error) {
+	resp, err := holidaysClient.Get(link)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	return utils.ParseHolidays(resp.Body, time.Now().AddDate(holidaysImportYears, 0, 0))
+}
+
+// vacation sets, lists or cancels vacations of user, of the user who sends command if
+// nobody is mentioned. Vacations of others are managed by PMs
+func (r *REST) vacation(f url.Values) string {
+	ca, err := r.validateRequest(f)
+	if err != nil {
+		logrus.Errorf("Validate Request Failed: %v", err)
+		return err.Error()
+	}
+
+	args := strings.Fields(ca.Text)
+	userID := f.Get("user_id")
+	if len(args) > 0 && strings.HasPrefix(args[0], "<@") {
+		if !strings.Contains(args[0], "|") {
+			return r.conf.Translate.VacationUsage
+		}
+		userID, _ = utils.SplitUser(args[0])
+		args = args[1:]
+	}
+	if userID != f.Get("user_id") {
+		accessLevel, _ := r.getAccessLevel(f.Get("user_id"), f.Get("channel_id"))
+		logrus.Infof("Access level for %v in %v is %v", f.Get("user_id"), f.Get("channel_id"), accessLevel)
+		if accessLevel > 3 {
+			return r.conf.Translate.AccessAtLeastPM
+		}
+	}
+
+	today := time.Now().In(storage.MemberLocation(r.db, userID, ca.ChannelID)).Format(model.HolidayDay)
+	absences, err := r.db.ListAbsences(userID)
+	if err != nil {
+		logrus.Errorf("rest: ListAbsences failed: %v\n", err)
+		return r.conf.Translate.SomethingWentWrong
+	}
+	upcoming := []model.Absence{}
+	for _, a := range absences {
+		if a.LastDay >= today {
+			upcoming = append(upcoming, a)
+		}
+	}
+
+	switch {
+	case len(args) == 0:
+		if len(upcoming) == 0 {
+			return fmt.Sprintf(r.conf.Translate.VacationNone, userID)
+		}
+		list := ""
+		for _, a := range upcoming {
+			list += fmt.Sprintf(r.conf.Translate.VacationItem, a.FirstDay, a.LastDay, a.Reason)
+		}
+		return fmt.Sprintf(r.conf.Translate.VacationList, userID, list)
+	case args[0] == "cancel":
+		return r.cancelVacation(userID, today, upcoming, args[1:])
+	case len(args) < 2:
+		return r.conf.Translate.VacationUsage
+	}
+
+	firstDay, err := time.Parse(model.HolidayDay, args[0])
+	if err != nil {
+		return r.conf.Translate.VacationWrongDays
+	}
+	lastDay, err := time.Parse(model.HolidayDay, args[1])
+	if err != nil || lastDay.Before(firstDay) {
+		return r.conf.Translate.VacationWrongDays
+	}
+	absence, err := r.db.CreateAbsence(model.Absence{
+		UserID:   userID,
+		FirstDay: args[0],
+		LastDay:  args[1],
+		Reason:   strings.Join(args[2:], " "),
+	})
+	if err != nil {
+		logrus.Errorf("rest: CreateAbsence failed: %v\n", err)
+		return r.conf.Translate.SomethingWentWrong
+	}
+	defer r.chat.ScheduleChanged()
+	return fmt.Sprintf(r.conf.Translate.VacationAdded, userID, absence.FirstDay, absence.LastDay)
+}
+
+// cancelVacation cancels upcoming vacation of user which includes the day given in args,
+// the only upcoming one if no day is given. Days of ongoing vacation before today are kept
+func (r *REST) cancelVacation(userID, today string, upcoming []model.Absence, args []string) string {
+	if len(upcoming) == 0 {
+		return fmt.Sprintf(r.conf.Translate.VacationNone, userID)
+	}
+	var absence model.Absence
+	switch {
+	case len(args) > 0:
+		day, err := time.Parse(model.HolidayDay, args[0])
+		if err != nil {
+			return r.conf.Translate.VacationUsage
+		}
+		a, ok := model.AbsenceOn(upcoming, day)
+		if !ok {
+			return fmt.Sprintf(r.conf.Translate.VacationNotFound, userID, args[0])
+		}
+		absence = a
+	case len(upcoming) == 1:
+		absence = upcoming[0]
+	default:
+		list := ""
+		for _, a := range upcoming {
+			list += fmt.Sprintf(r.conf.Translate.VacationItem, a.FirstDay, a.LastDay, a.Reason)
+		}
+		return fmt.Sprintf(r.conf.Translate.VacationCancelWhich, userID, list)
+	}
+
+	days := absence.FirstDay + " - " + absence.LastDay
+	if absence.FirstDay < today {
+		// days already spent on vacation stay in reports
+		t, _ := time.Parse(model.HolidayDay, today)
+		absence.LastDay = t.AddDate(0, 0, -1).Format(model.HolidayDay)
+		_, err := r.db.UpdateAbsence(absence)
+		if err != nil {
+			logrus.Errorf("rest: UpdateAbsence failed: %v\n", err)
+			return r.conf.Translate.SomethingWentWrong
+		}
+		defer r.chat.ScheduleChanged()
+		return fmt.Sprintf(r.conf.Translate.VacationShortened, userID, days, absence.LastDay)
+	}
+	err := r.db.DeleteAbsence(absence.ID)
+	if err != nil {
+		logrus.Errorf("rest: DeleteAbsence failed: %v\n", err)
+		return r.conf.Translate.SomethingWentWrong
+	}
+	defer r.chat.ScheduleChanged()
+	return fmt.Sprintf(r.conf.Translate.VacationCancelled, userID, days)
 }
 
 func (r *REST) getAccessLevel(userID, channelID string) (int, error) {
@@ -842,7 +2048,7 @@ func (r *REST) comedianIsInChannel(channelID string) bool {
 	return true
 }
 
-func (r *REST) validateRequest(c echo.Context, f url.Values) (FullSlackForm, error) {
+func (r *REST) validateRequest(f url.Values) (FullSlackForm, error) {
 	var ca FullSlackForm
 	if !r.comedianIsInChannel(f.Get("channel_id")) {
 		return ca, errors.New(r.conf.Translate.ComedianIsNotInChannel)
@@ -856,8 +2062,8 @@ func (r *REST) validateRequest(c echo.Context, f url.Values) (FullSlackForm, err
 	return ca, nil
 }
 
-func (r *REST) processCommand(c echo.Context, f url.Values) ([]string, string, string, int, error) {
-	ca, err := r.validateRequest(c, f)
+func (r *REST) processCommand(f url.Values) ([]string, string, string, int, error) {
+	ca, err := r.validateRequest(f)
 	if err != nil {
 		logrus.Errorf("Validate Request Failed: %v", err)
 		return []string{}, "", "", 0, err
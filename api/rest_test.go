@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -13,6 +16,7 @@ import (
 	"github.com/maddevsio/comedian/chat"
 	"github.com/maddevsio/comedian/config"
 	"github.com/maddevsio/comedian/model"
+	"github.com/maddevsio/comedian/storage"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/jarcoal/httpmock.v1"
@@ -663,6 +667,827 @@ func TestHandleHelpCommand(t *testing.T) {
 
 }
 
+func TestHandleStatus(t *testing.T) {
+	slack, err := chat.NewSlack(config.Config{DatabaseURL: "memory://"})
+	assert.NoError(t, err)
+	rest, err := NewRESTAPI(slack)
+	assert.NoError(t, err)
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.GET, "/status", nil)
+	rec := httptest.NewRecorder()
+	assert.NoError(t, rest.handleStatus(e.NewContext(req, rec)))
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, `{"schema_version":0,"latest_schema_version":0}`, strings.TrimSpace(rec.Body.String()))
+}
+
+func TestStandupHistoryCommand(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	slack, err := chat.NewSlack(config.Config{DatabaseURL: "memory://", Translate: translation})
+	assert.NoError(t, err)
+	rest, err := NewRESTAPI(slack)
+	assert.NoError(t, err)
+
+	// the standuper and the channel are 25 hours apart, so their days always differ
+	channel, err := rest.db.CreateChannel(model.Channel{ChannelName: "TestChannel", ChannelID: "TestChannelID", TimeZone: "Pacific/Pago_Pago"})
+	assert.NoError(t, err)
+	_, err = rest.db.CreateUser(model.User{UserName: "pm", UserID: "pmid"})
+	assert.NoError(t, err)
+	_, err = rest.db.CreateChannelMember(model.ChannelMember{UserID: "pmid", ChannelID: channel.ChannelID, RoleInChannel: "pm"})
+	assert.NoError(t, err)
+	_, err = rest.db.CreateUser(model.User{UserName: "developer", UserID: "devid"})
+	assert.NoError(t, err)
+	_, err = rest.db.CreateUser(model.User{UserName: "user", UserID: "userid", TimeZone: "Pacific/Kiritimati"})
+	assert.NoError(t, err)
+
+	standup, err := rest.db.CreateStandup(model.Standup{
+		ChannelID: channel.ChannelID,
+		UserID:    "userid",
+		Comment:   "yesterday: coding\ntoday: testing",
+		MessageTS: "12345",
+	})
+	assert.NoError(t, err)
+	today := time.Now().In(model.Location("Pacific/Kiritimati")).Format("2006-01-02")
+	channelToday := time.Now().In(channel.Location()).Format("2006-01-02")
+
+	testCases := []struct {
+		userID   string
+		text     string
+		expected string
+	}{
+		{"pmid", "<@userid|user>", translation.WrongNArgs},
+		{"pmid", "user " + today, translation.WrongUsernameError},
+		{"devid", "<@userid|user> " + today, translation.AccessAtLeastPMOrOwner},
+		{"pmid", "<@userid|user> 2018-01-01", fmt.Sprintf(translation.StandupHistoryNoStandup, "userid", "2018-01-01")},
+		{"pmid", "<@userid|user> " + channelToday, fmt.Sprintf(translation.StandupHistoryNoStandup, "userid", channelToday)},
+		{"pmid", "<@userid|user> " + today, fmt.Sprintf(translation.StandupHistoryNotEdited, "userid", today, "yesterday: coding\ntoday: testing")},
+	}
+	for _, tt := range testCases {
+		command := fmt.Sprintf("user_id=%v&command=/standup_history&channel_id=TestChannelID&channel_name=TestChannel&text=%v", tt.userID, url.QueryEscape(tt.text))
+		context, rec := getContext(command)
+		assert.NoError(t, rest.handleCommands(context))
+		assert.Equal(t, tt.expected, rec.Body.String())
+	}
+
+	_, err = rest.db.AddToStandupHistory(model.StandupEditHistory{
+		StandupID:     standup.ID,
+		StandupText:   "yesterday: coding\ntoday: testing",
+		AfterDeadline: true,
+	})
+	assert.NoError(t, err)
+	standup.Comment = "yesterday: coding\ntoday: deploying"
+	_, err = rest.db.UpdateStandup(standup)
+	assert.NoError(t, err)
+
+	command := fmt.Sprintf("user_id=userid&command=/standup_history&channel_id=TestChannelID&channel_name=TestChannel&text=%v", url.QueryEscape("<@userid|user> "+today))
+	context, rec := getContext(command)
+	assert.NoError(t, rest.handleCommands(context))
+	body := rec.Body.String()
+	assert.Contains(t, body, fmt.Sprintf(translation.StandupHistoryHead, "userid", today, 1))
+	assert.Contains(t, body, "after the deadline")
+	assert.Contains(t, body, "  yesterday: coding\n- today: testing\n+ today: deploying")
+}
+
+func TestStandupRestoreCommand(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	slack, err := chat.NewSlack(config.Config{DatabaseURL: "memory://", Translate: translation})
+	assert.NoError(t, err)
+	rest, err := NewRESTAPI(slack)
+	assert.NoError(t, err)
+
+	channel, err := rest.db.CreateChannel(model.Channel{ChannelName: "TestChannel", ChannelID: "TestChannelID"})
+	assert.NoError(t, err)
+	_, err = rest.db.CreateUser(model.User{UserName: "admin", UserID: "adminid", Role: "admin"})
+	assert.NoError(t, err)
+	_, err = rest.db.CreateUser(model.User{UserName: "pm", UserID: "pmid"})
+	assert.NoError(t, err)
+	_, err = rest.db.CreateChannelMember(model.ChannelMember{UserID: "pmid", ChannelID: channel.ChannelID, RoleInChannel: "pm"})
+	assert.NoError(t, err)
+
+	standup, err := rest.db.CreateStandup(model.Standup{
+		ChannelID: channel.ChannelID,
+		UserID:    "userid",
+		Comment:   "my standup",
+		MessageTS: "12345",
+	})
+	assert.NoError(t, err)
+	today := time.Now().UTC().Format("2006-01-02")
+
+	restore := func(userID, text string) string {
+		command := fmt.Sprintf("user_id=%v&command=/standup_restore&channel_id=TestChannelID&channel_name=TestChannel&text=%v", userID, url.QueryEscape(text))
+		context, rec := getContext(command)
+		assert.NoError(t, rest.handleCommands(context))
+		return rec.Body.String()
+	}
+
+	assert.Equal(t, translation.AccessAtLeastAdmin, restore("pmid", "<@userid|user> "+today))
+	assert.Equal(t, translation.WrongNArgs, restore("adminid", "<@userid|user>"))
+	assert.Equal(t, fmt.Sprintf(translation.StandupRestoreNoDeleted, "userid", today), restore("adminid", "<@userid|user> "+today))
+
+	assert.NoError(t, rest.db.MarkStandupDeleted(standup.ID))
+	second, err := rest.db.CreateStandup(model.Standup{
+		ChannelID: channel.ChannelID,
+		UserID:    "userid",
+		Comment:   "another standup",
+		MessageTS: "123456",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf(translation.StandupRestoreHasActive, "userid", today), restore("adminid", "<@userid|user> "+today))
+
+	// empty standup created for non reporter is replaced by restored one
+	second.Comment = ""
+	_, err = rest.db.UpdateStandup(second)
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf(translation.StandupRestored, "userid", today, "my standup"), restore("adminid", "<@userid|user> "+today))
+
+	restored, err := rest.db.SelectStandupByMessageTS("12345")
+	assert.NoError(t, err)
+	assert.Equal(t, standup.ID, restored.ID)
+	_, err = rest.db.SelectStandupByMessageTS("123456")
+	assert.Error(t, err)
+}
+
+func TestHandleCommandsWorkspaces(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	db := storage.NewMemory()
+	slack1 := chat.NewWorkspaceSlack(config.Config{TeamID: "T1", Translate: translation}, db)
+	slack2 := chat.NewWorkspaceSlack(config.Config{TeamID: "T2", Translate: translation}, db)
+	rest, err := NewRESTAPI(slack1, slack2)
+	assert.NoError(t, err)
+
+	_, err = NewRESTAPI(slack1, slack1)
+	assert.Error(t, err)
+
+	_, err = slack1.DB.CreateChannel(model.Channel{ChannelName: "TestChannel", ChannelID: "TestChannelID"})
+	assert.NoError(t, err)
+	assert.NoError(t, slack1.DB.CreateStandupTime(int64(12), "TestChannelID"))
+
+	listTime := func(teamID string) (int, string) {
+		command := fmt.Sprintf("team_id=%v&user_id=userID&command=/standup_time&channel_id=TestChannelID&channel_name=TestChannel", teamID)
+		context, rec := getContext(command)
+		assert.NoError(t, rest.handleCommands(context))
+		return rec.Code, rec.Body.String()
+	}
+
+	code, body := listTime("T1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, fmt.Sprintf(translation.ShowStandupTime, 12), body)
+
+	code, body = listTime("T2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, translation.ComedianIsNotInChannel, body)
+
+	code, _ = listTime("T3")
+	assert.Equal(t, http.StatusForbidden, code)
+}
+
+func TestHandleEvents(t *testing.T) {
+	db := storage.NewMemory()
+	slack1 := chat.NewWorkspaceSlack(config.Config{TeamID: "T1", SlackTransport: config.TransportEvents, SigningSecret: "secret1"}, db)
+	slack2 := chat.NewWorkspaceSlack(config.Config{TeamID: "T2", SlackTransport: config.TransportRTM}, db)
+	rest, err := NewRESTAPI(slack1, slack2)
+	assert.NoError(t, err)
+
+	send := func(secret, body string) (int, string) {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req := httptest.NewRequest(echo.POST, "/events", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+		req.Header.Set("X-Slack-Signature", chat.Sign(secret, timestamp, []byte(body)))
+		rec := httptest.NewRecorder()
+		assert.NoError(t, rest.handleEvents(echo.New().NewContext(req, rec)))
+		return rec.Code, rec.Body.String()
+	}
+
+	code, body := send("secret1", `{"type":"url_verification","challenge":"challenge1"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "challenge1", body)
+
+	code, _ = send("wrong", `{"type":"url_verification","challenge":"challenge1"}`)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	event := `{"type":"event_callback","team_id":"T1","event_id":"Ev1","event":{"type":"member_joined_channel","channel":"C1"}}`
+	code, _ = send("wrong", event)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = send("secret1", event)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = send("secret1", event)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _ = send("", `{"type":"event_callback","team_id":"T2","event_id":"Ev2","event":{"type":"member_joined_channel","channel":"C1"}}`)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = send("secret1", `not json`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestVerifySignature(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+
+	send := func(rest *REST, path, secret, body string) (int, string) {
+		req := httptest.NewRequest(echo.POST, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		if secret != "" {
+			timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+			req.Header.Set("X-Slack-Signature", chat.Sign(secret, timestamp, []byte(body)))
+		}
+		rec := httptest.NewRecorder()
+		rest.echo.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+	command := "team_id=T1&user_id=userID&command=/standup_time&channel_id=TestChannelID&channel_name=TestChannel"
+
+	db := storage.NewMemory()
+	slack := chat.NewWorkspaceSlack(config.Config{TeamID: "T1", SigningSecret: "secret", SecretToken: "token", Translate: translation}, db)
+	rest, err := NewRESTAPI(slack)
+	assert.NoError(t, err)
+
+	code, body := send(rest, "/commands", "secret", command)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, translation.ComedianIsNotInChannel, body)
+	code, _ = send(rest, "/commands", "secret", command+"&text=")
+	assert.Equal(t, http.StatusOK, code)
+
+	testCases := []struct {
+		path   string
+		secret string
+		body   string
+		code   int
+	}{
+		// the same signature can not be used twice
+		{"/commands", "secret", command + "&text=", http.StatusUnauthorized},
+		{"/commands", "wrong", command, http.StatusUnauthorized},
+		{"/commands", "", command, http.StatusUnauthorized},
+		{"/commandstoken", "", command, http.StatusOK},
+		{"/commands", "", "team_id=T2&command=/standup_time", http.StatusForbidden},
+	}
+	for _, tt := range testCases {
+		code, _ := send(rest, tt.path, tt.secret, tt.body)
+		assert.Equal(t, tt.code, code, tt.path+" "+tt.body)
+	}
+
+	slack = chat.NewWorkspaceSlack(config.Config{TeamID: "T1", SecretToken: "token", Translate: translation}, db)
+	rest, err = NewRESTAPI(slack)
+	assert.NoError(t, err)
+	code, _ = send(rest, "/commands", "", command)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = send(rest, "/commandstoken", "", command)
+	assert.Equal(t, http.StatusOK, code)
+
+	slack = chat.NewWorkspaceSlack(config.Config{TeamID: "T1", Translate: translation}, db)
+	rest, err = NewRESTAPI(slack)
+	assert.NoError(t, err)
+	code, _ = send(rest, "/commands", "", command)
+	assert.Equal(t, http.StatusOK, code)
+
+	// command is checked against secret of the team it runs for, payload of commands is ignored
+	slack1 := chat.NewWorkspaceSlack(config.Config{TeamID: "T1", SigningSecret: "secret1", Translate: translation}, db)
+	slack2 := chat.NewWorkspaceSlack(config.Config{TeamID: "T2", SigningSecret: "secret2", Translate: translation}, db)
+	rest, err = NewRESTAPI(slack1, slack2)
+	assert.NoError(t, err)
+	mixed := command + "&payload=" + url.QueryEscape(`{"team":{"id":"T2"}}`)
+	code, _ = send(rest, "/commands", "secret2", mixed)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = send(rest, "/commands", "secret1", mixed)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = send(rest, "/interactions", "secret1", "team_id=T1&payload=not+json")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// requests of teams which are not served are refused before any handler
+	forged := "team_id=nope&user_id=userID&command=/standup_time&channel_id=TestChannelID&payload=" + url.QueryEscape(`{"team":{"id":"T1"}}`)
+	code, _ = send(rest, "/commands", "secret1", forged)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = send(rest, "/interactions", "secret1", "team_id=T1&payload="+url.QueryEscape(`{"type":"block_actions","team":{"id":"nope"}}`))
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = send(rest, "/commands", "", "command=/standup_time")
+	assert.Equal(t, http.StatusForbidden, code)
+}
+
+func TestHandleInteractions(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	opened := 0
+	httpmock.RegisterResponder("POST", "https://slack.com/api/views.open", func(req *http.Request) (*http.Response, error) {
+		opened++
+		return httpmock.NewStringResponse(200, `{"ok": true}`), nil
+	})
+	updated := 0
+	httpmock.RegisterResponder("POST", "https://slack.com/api/chat.update", func(req *http.Request) (*http.Response, error) {
+		updated++
+		return httpmock.NewStringResponse(200, `{"ok": true}`), nil
+	})
+
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	slack := chat.NewWorkspaceSlack(config.Config{TeamID: "T1", SigningSecret: "secret", Translate: translation}, storage.NewMemory())
+	rest, err := NewRESTAPI(slack)
+	assert.NoError(t, err)
+
+	send := func(path, secret, body string) (int, string) {
+		req := httptest.NewRequest(echo.POST, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+		req.Header.Set("X-Slack-Signature", chat.Sign(secret, timestamp, []byte(body)))
+		rec := httptest.NewRecorder()
+		rest.echo.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+	payload := func(p string) string {
+		return "payload=" + url.QueryEscape(p)
+	}
+
+	click := payload(`{"type":"interactive_message","callback_id":"write_standup","trigger_id":"trigger1","team":{"id":"T1"},"user":{"id":"U1"},"actions":[{"name":"write_standup","type":"button","value":"C1"}]}`)
+	code, _ := send("/interactions", "wrong", click)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = send("/interactions", "secret", click)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, opened)
+
+	submission := payload(`{"type":"view_submission","team":{"id":"T1"},"user":{"id":"U1"},"view":{"callback_id":"write_standup","private_metadata":"C1","state":{"values":{"q0":{"answer":{"value":"fixed bugs"}}}}}}`)
+	code, body := send("/interactions", "secret", submission)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, fmt.Sprintf(`{"response_action":"errors","errors":{"q1":%q,"q2":%q}}`, translation.StandupModalEmptyAnswer, translation.StandupModalEmptyAnswer), body)
+
+	more := payload(`{"type":"block_actions","team":{"id":"T1"},"user":{"id":"U1"},"channel":{"id":"C1"},"message":{"ts":"1.2","blocks":[{"type":"actions","block_id":"b1"}]},"actions":[{"action_id":"show_more","block_id":"b1","value":"hidden"}]}`)
+	code, _ = send("/interactions", "secret", more)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, updated)
+
+	code, _ = send("/interactions", "secret", "payload=not+json")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = send("/commands", "secret", "team_id=T1&user_id=U1&command=/standup&channel_id=C1&channel_name=general&trigger_id=trigger2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, opened)
+
+	// without signing secret nobody can tell who really submits standup
+	slack = chat.NewWorkspaceSlack(config.Config{TeamID: "T1", Translate: translation}, storage.NewMemory())
+	rest, err = NewRESTAPI(slack)
+	assert.NoError(t, err)
+	code, _ = send("/interactions", "", click)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, 2, opened)
+}
+
+func TestMattermostCommands(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	db := storage.NewMemory()
+	mattermost := chat.NewMattermost(config.Config{
+		Platform:        config.PlatformMattermost,
+		TeamID:          "team1",
+		MattermostURL:   "http://localhost:8065",
+		MattermostToken: "token",
+		CommandTokens:   "token1, token2",
+		Translate:       translation,
+	}, db)
+	rest, err := NewRESTAPI(mattermost)
+	assert.NoError(t, err)
+
+	_, err = mattermost.Storage().CreateChannel(model.Channel{ChannelName: "town-square", ChannelID: "channel1"})
+	assert.NoError(t, err)
+	_, err = mattermost.Storage().CreateUser(model.User{UserName: "john.doe", UserID: "userid"})
+	assert.NoError(t, err)
+
+	send := func(token, text string) (int, string) {
+		body := fmt.Sprintf("team_id=team1&token=%v&user_id=userid&command=/timetable_show&channel_id=channel1&channel_name=town-square&text=%v", token, url.QueryEscape(text))
+		req := httptest.NewRequest(echo.POST, "/commands", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		rec := httptest.NewRecorder()
+		rest.echo.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+
+	code, body := send("token2", "@john.doe")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, fmt.Sprintf(translation.NotAStanduper, "john.doe"), body)
+
+	code, body = send("token2", "@unknown")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, translation.WrongUsernameError, body)
+
+	code, _ = send("token3", "@john.doe")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// Slack signing secret does not apply to Mattermost, commands without known token are rejected
+	mattermost = chat.NewMattermost(config.Config{
+		Platform:        config.PlatformMattermost,
+		TeamID:          "team1",
+		MattermostURL:   "http://localhost:8065",
+		MattermostToken: "token",
+		SigningSecret:   "secret",
+		Translate:       translation,
+	}, db)
+	rest, err = NewRESTAPI(mattermost)
+	assert.NoError(t, err)
+	code, _ = send("", "@john.doe")
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestTelegramCommands(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	telegram := chat.NewTelegram(config.Config{
+		Platform:      config.PlatformTelegram,
+		TeamID:        "team1",
+		TelegramToken: "token",
+		Translate:     translation,
+	}, storage.NewMemory())
+	_, err = NewRESTAPI(telegram)
+	assert.NoError(t, err)
+
+	_, err = telegram.Storage().CreateChannel(model.Channel{ChannelName: "Developers", ChannelID: "-1"})
+	assert.NoError(t, err)
+	_, err = telegram.Storage().CreateUser(model.User{UserName: "john", UserID: "1"})
+	assert.NoError(t, err)
+
+	form := url.Values{}
+	form.Set("team_id", "team1")
+	form.Set("user_id", "1")
+	form.Set("channel_id", "-1")
+	form.Set("channel_name", "Developers")
+	form.Set("command", "/timetable_show")
+	form.Set("text", "<@1|john>")
+	assert.Equal(t, fmt.Sprintf(translation.NotAStanduper, "john"), telegram.HandleCommand(form))
+
+	form.Set("command", "/start")
+	assert.Equal(t, "", telegram.HandleCommand(form))
+}
+
+func TestStandupQuestionsCommand(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	slack, err := chat.NewSlack(config.Config{DatabaseURL: "memory://", Translate: translation})
+	assert.NoError(t, err)
+	rest, err := NewRESTAPI(slack)
+	assert.NoError(t, err)
+
+	_, err = rest.db.CreateChannel(model.Channel{ChannelName: "TestChannel", ChannelID: "TestChannelID"})
+	assert.NoError(t, err)
+	_, err = rest.db.CreateUser(model.User{UserName: "pm", UserID: "pmid"})
+	assert.NoError(t, err)
+	_, err = rest.db.CreateChannelMember(model.ChannelMember{UserID: "pmid", ChannelID: "TestChannelID", RoleInChannel: "pm"})
+	assert.NoError(t, err)
+	_, err = rest.db.CreateUser(model.User{UserName: "user", UserID: "userid"})
+	assert.NoError(t, err)
+
+	questions := func(userID, text string) string {
+		command := fmt.Sprintf("user_id=%v&command=/standup_questions&channel_id=TestChannelID&channel_name=TestChannel&text=%v", userID, url.QueryEscape(text))
+		context, rec := getContext(command)
+		assert.NoError(t, rest.handleCommands(context))
+		return rec.Body.String()
+	}
+
+	testCases := []struct {
+		userID   string
+		text     string
+		response string
+	}{
+		{"userid", "", translation.StandupQuestionsDefault},
+		{"userid", "add Done / done", translation.AccessAtLeastPM},
+		{"pmid", "add", translation.StandupQuestionsUsage},
+		{"pmid", "add Done / done / optional / more", translation.StandupQuestionsUsage},
+		{"pmid", "rename Done", translation.StandupQuestionsUsage},
+		{"pmid", "add Done / done, Completed", fmt.Sprintf(translation.StandupQuestionAdded, "Done")},
+		{"pmid", "add Blockers", fmt.Sprintf(translation.StandupQuestionAdded, "Blockers")},
+		{"pmid", "add Retro / liked, disliked / optional", fmt.Sprintf(translation.StandupQuestionAdded, "Retro")},
+		{"userid", "", translation.StandupQuestionsList +
+			fmt.Sprintf(translation.StandupQuestionsItem, 1, "Done", "done, completed") +
+			fmt.Sprintf(translation.StandupQuestionsItem, 2, "Blockers", "-") +
+			fmt.Sprintf(translation.StandupQuestionsItemOptional, 3, "Retro", "liked, disliked")},
+		{"pmid", "remove first", translation.StandupQuestionsUsage},
+		{"pmid", "remove 4", fmt.Sprintf(translation.StandupQuestionNotFound, 4)},
+		{"pmid", "remove 2", fmt.Sprintf(translation.StandupQuestionRemoved, "Blockers")},
+		{"userid", "", translation.StandupQuestionsList +
+			fmt.Sprintf(translation.StandupQuestionsItem, 1, "Done", "done, completed") +
+			fmt.Sprintf(translation.StandupQuestionsItemOptional, 2, "Retro", "liked, disliked")},
+		{"pmid", "reset", translation.StandupQuestionsReset},
+		{"userid", "", translation.StandupQuestionsDefault},
+	}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.response, questions(tt.userID, tt.text), tt.text)
+	}
+}
+
+func TestAutoEnrollCommand(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", "https://slack.com/api/conversations.members",
+		httpmock.NewStringResponder(200, `{"ok": true, "members": ["BOT", "pmid", "userid", "observerid"], "response_metadata": {"next_cursor": ""}}`))
+
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	slack, err := chat.NewSlack(config.Config{DatabaseURL: "memory://", Translate: translation})
+	assert.NoError(t, err)
+	rest, err := NewRESTAPI(slack)
+	assert.NoError(t, err)
+
+	_, err = rest.db.CreateChannel(model.Channel{ChannelName: "TestChannel", ChannelID: "TestChannelID"})
+	assert.NoError(t, err)
+	for _, id := range []string{"pmid", "userid", "observerid"} {
+		_, err = rest.db.CreateUser(model.User{UserName: id, UserID: id})
+		assert.NoError(t, err)
+	}
+	_, err = rest.db.CreateChannelMember(model.ChannelMember{UserID: "pmid", ChannelID: "TestChannelID", RoleInChannel: "pm"})
+	assert.NoError(t, err)
+
+	autoEnroll := func(userID, text string) string {
+		command := fmt.Sprintf("user_id=%v&command=/auto_enroll&channel_id=TestChannelID&channel_name=TestChannel&text=%v", userID, url.QueryEscape(text))
+		context, rec := getContext(command)
+		assert.NoError(t, rest.handleCommands(context))
+		return rec.Body.String()
+	}
+
+	testCases := []struct {
+		userID   string
+		text     string
+		response string
+	}{
+		{"userid", "", translation.AutoEnrollOff},
+		{"userid", "on", translation.AccessAtLeastPM},
+		{"pmid", "exclude", translation.AutoEnrollUsage},
+		{"pmid", "exclude observer", translation.AutoEnrollUsage},
+		{"pmid", "exclude <@observerid|observer>", fmt.Sprintf(translation.AutoEnrollExcludedUsers, "<@observerid|observer>")},
+		{"pmid", "on", fmt.Sprintf(translation.AutoEnrollTurnedOn, 1)},
+		{"userid", "", translation.AutoEnrollOn + fmt.Sprintf(translation.AutoEnrollExcluded, "<@observerid>")},
+		{"pmid", "include <@observerid|observer>", fmt.Sprintf(translation.AutoEnrollIncludedUsers, "<@observerid|observer>")},
+		{"pmid", "exclude <@userid|user>", fmt.Sprintf(translation.AutoEnrollExcludedUsers, "<@userid|user>")},
+		{"pmid", "off", translation.AutoEnrollTurnedOff},
+		{"pmid", "maybe", translation.AutoEnrollUsage},
+	}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.response, autoEnroll(tt.userID, tt.text), tt.text)
+	}
+
+	members, err := rest.db.ListChannelMembers("TestChannelID")
+	assert.NoError(t, err)
+	roles := map[string]string{}
+	for _, m := range members {
+		roles[m.UserID] = m.RoleInChannel
+	}
+	// excluded developer is removed, the pm stays as is
+	assert.Equal(t, map[string]string{"pmid": "pm", "observerid": "developer"}, roles)
+
+	// timetable of excluded developer is removed too, so that reminders stop
+	observer, err := rest.db.FindChannelMemberByUserID("observerid", "TestChannelID")
+	assert.NoError(t, err)
+	_, err = rest.db.CreateTimeTable(model.TimeTable{ChannelMemberID: observer.ID, Monday: 1500000000})
+	assert.NoError(t, err)
+	autoEnroll("pmid", "on")
+	select {
+	case <-slack.ScheduleChanges():
+	default:
+	}
+	assert.Equal(t, fmt.Sprintf(translation.AutoEnrollExcludedUsers, "<@observerid|observer>"), autoEnroll("pmid", "exclude <@observerid|observer>"))
+	select {
+	case <-slack.ScheduleChanges():
+	default:
+		t.Error("schedule is not changed")
+	}
+	assert.False(t, rest.db.MemberHasTimeTable(observer.ID))
+	_, err = rest.db.FindChannelMemberByUserID("observerid", "TestChannelID")
+	assert.Error(t, err)
+}
+
+func TestTimeZoneCommand(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	slack, err := chat.NewSlack(config.Config{DatabaseURL: "memory://", Translate: translation})
+	assert.NoError(t, err)
+	rest, err := NewRESTAPI(slack)
+	assert.NoError(t, err)
+
+	_, err = rest.db.CreateChannel(model.Channel{ChannelName: "TestChannel", ChannelID: "TestChannelID"})
+	assert.NoError(t, err)
+	for _, id := range []string{"pmid", "userid"} {
+		_, err = rest.db.CreateUser(model.User{UserName: id, UserID: id})
+		assert.NoError(t, err)
+	}
+	_, err = rest.db.CreateChannelMember(model.ChannelMember{UserID: "pmid", ChannelID: "TestChannelID", RoleInChannel: "pm"})
+	assert.NoError(t, err)
+	member, err := rest.db.CreateChannelMember(model.ChannelMember{UserID: "userid", ChannelID: "TestChannelID", RoleInChannel: "developer"})
+	assert.NoError(t, err)
+
+	command := func(userID, name, text string) string {
+		command := fmt.Sprintf("user_id=%v&command=%v&channel_id=TestChannelID&channel_name=TestChannel&text=%v", userID, name, url.QueryEscape(text))
+		context, rec := getContext(command)
+		assert.NoError(t, rest.handleCommands(context))
+		return rec.Body.String()
+	}
+
+	assert.Equal(t, fmt.Sprintf(translation.TimeZoneShowDefault, time.Local), command("userid", "/time_zone", ""))
+	assert.Equal(t, translation.AccessAtLeastPM, command("userid", "/time_zone", "Europe/Berlin"))
+	assert.Equal(t, fmt.Sprintf(translation.TimeZoneUnknown, "Mars/Olympus_Mons"), command("pmid", "/time_zone", "Mars/Olympus_Mons"))
+	assert.Equal(t, fmt.Sprintf(translation.TimeZoneSet, "Europe/Berlin"), command("pmid", "/time_zone", "Europe/Berlin"))
+	assert.Equal(t, fmt.Sprintf(translation.TimeZoneShow, "Europe/Berlin"), command("userid", "/time_zone", ""))
+
+	// timetable of standuper without own time zone is in time zone of the channel
+	deadline := time.Date(2019, 1, 7, 10, 0, 0, 0, model.Location("Europe/Berlin")).Unix()
+	tt, err := rest.db.CreateTimeTable(model.TimeTable{ChannelMemberID: member.ID})
+	assert.NoError(t, err)
+	tt.Monday = deadline
+	_, err = rest.db.UpdateTimeTable(tt)
+	assert.NoError(t, err)
+
+	// standup time is typed in time zone of the channel and keeps its hours when time zone changes
+	command("pmid", "/standup_time_set", "10:00")
+	channel, err := rest.db.SelectChannel("TestChannelID")
+	assert.NoError(t, err)
+	assert.Equal(t, "10:00", time.Unix(channel.StandupTime, 0).In(model.Location("Europe/Berlin")).Format("15:04"))
+	assert.Equal(t, fmt.Sprintf(translation.TimeZoneSetWithDeadline, "Asia/Bishkek", "10:00"), command("pmid", "/time_zone", "Asia/Bishkek"))
+	channel, err = rest.db.SelectChannel("TestChannelID")
+	assert.NoError(t, err)
+	assert.Equal(t, "10:00", time.Unix(channel.StandupTime, 0).In(channel.Location()).Format("15:04"))
+	tt, err = rest.db.SelectTimeTable(member.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "10:00", time.Unix(tt.Monday, 0).In(channel.Location()).Format("15:04"))
+}
+
+func TestWorkingDaysCommand(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	slack, err := chat.NewSlack(config.Config{DatabaseURL: "memory://", Translate: translation})
+	assert.NoError(t, err)
+	rest, err := NewRESTAPI(slack)
+	assert.NoError(t, err)
+
+	_, err = rest.db.CreateChannel(model.Channel{ChannelName: "TestChannel", ChannelID: "TestChannelID"})
+	assert.NoError(t, err)
+	for _, id := range []string{"pmid", "userid"} {
+		_, err = rest.db.CreateUser(model.User{UserName: id, UserID: id})
+		assert.NoError(t, err)
+	}
+	_, err = rest.db.CreateChannelMember(model.ChannelMember{UserID: "pmid", ChannelID: "TestChannelID", RoleInChannel: "pm"})
+	assert.NoError(t, err)
+
+	command := func(userID, text string) string {
+		command := fmt.Sprintf("user_id=%v&command=/working_days&channel_id=TestChannelID&channel_name=TestChannel&text=%v", userID, url.QueryEscape(text))
+		context, rec := getContext(command)
+		assert.NoError(t, rest.handleCommands(context))
+		return rec.Body.String()
+	}
+
+	assert.Equal(t, fmt.Sprintf(translation.WorkingDaysShow, model.DefaultWorkingDays), command("userid", ""))
+	assert.Equal(t, translation.AccessAtLeastPM, command("userid", "sun-thu"))
+	assert.Equal(t, fmt.Sprintf(translation.WorkingDaysWrong, "someday"), command("pmid", "someday"))
+	assert.Equal(t, fmt.Sprintf(translation.WorkingDaysSet, "mon,tue,wed,thu,sun"), command("pmid", "sun-thu"))
+	assert.Equal(t, fmt.Sprintf(translation.WorkingDaysShow, "mon,tue,wed,thu,sun"), command("userid", ""))
+
+	channel, err := rest.db.SelectChannel("TestChannelID")
+	assert.NoError(t, err)
+	assert.False(t, channel.WorksOn(time.Date(2019, 11, 15, 12, 0, 0, 0, time.Local)))
+}
+
+func TestHolidaysCommand(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	slack, err := chat.NewSlack(config.Config{DatabaseURL: "memory://", Translate: translation})
+	assert.NoError(t, err)
+	rest, err := NewRESTAPI(slack)
+	assert.NoError(t, err)
+
+	_, err = rest.db.CreateChannel(model.Channel{ChannelName: "TestChannel", ChannelID: "TestChannelID"})
+	assert.NoError(t, err)
+	for _, id := range []string{"pmid", "userid"} {
+		_, err = rest.db.CreateUser(model.User{UserName: id, UserID: id})
+		assert.NoError(t, err)
+	}
+	_, err = rest.db.CreateChannelMember(model.ChannelMember{UserID: "pmid", ChannelID: "TestChannelID", RoleInChannel: "pm"})
+	assert.NoError(t, err)
+
+	command := func(userID, text string) string {
+		command := fmt.Sprintf("user_id=%v&command=/holidays&channel_id=TestChannelID&channel_name=TestChannel&text=%v", userID, url.QueryEscape(text))
+		context, rec := getContext(command)
+		assert.NoError(t, rest.handleCommands(context))
+		return rec.Body.String()
+	}
+
+	assert.Equal(t, translation.HolidaysNone, command("userid", ""))
+	assert.Equal(t, translation.AccessAtLeastPM, command("userid", "add 2999-01-01 New Year"))
+	assert.Equal(t, translation.AccessAtLeastAdmin, command("pmid", "workspace add 2999-01-01 New Year"))
+	assert.Equal(t, fmt.Sprintf(translation.HolidayWrongDay, "01.01.2999"), command("pmid", "add 01.01.2999 New Year"))
+	assert.Equal(t, translation.HolidaysUsage, command("pmid", "add 2999-01-01"))
+	assert.Equal(t, fmt.Sprintf(translation.HolidayAdded, "2999-01-01", "New Year"), command("pmid", "add 2999-01-01 New Year"))
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	var mu sync.Mutex
+	reports := []string{}
+	httpmock.RegisterResponder("POST", "https://slack.com/api/chat.postEphemeral", func(req *http.Request) (*http.Response, error) {
+		req.ParseForm()
+		mu.Lock()
+		defer mu.Unlock()
+		reports = append(reports, req.Form.Get("text"))
+		return httpmock.NewStringResponse(200, `{"ok": true}`), nil
+	})
+	calendar := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/holidays.ics" {
+			http.NotFound(w, req)
+			return
+		}
+		fmt.Fprint(w, "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:29990307\r\nDTEND;VALUE=DATE:29990309\r\nSUMMARY:Spring\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n")
+	}))
+	defer calendar.Close()
+	link := calendar.URL + "/holidays.ics"
+
+	// calendars are downloaded from public https links only
+	plain := strings.Replace(link, "https://", "http://", 1)
+	assert.Equal(t, fmt.Sprintf(translation.HolidaysWrongLink, plain), command("pmid", "import "+plain))
+	assert.Equal(t, fmt.Sprintf(translation.HolidaysImporting, link), command("pmid", "import "+link))
+	rest.wg.Wait()
+	assert.Equal(t, []string{fmt.Sprintf(translation.HolidaysImportFailed, link)}, reports)
+
+	defaultClient := holidaysClient
+	holidaysClient = calendar.Client()
+	defer func() { holidaysClient = defaultClient }()
+	reports = []string{}
+	assert.Equal(t, fmt.Sprintf(translation.HolidaysImporting, link), command("pmid", fmt.Sprintf("import <%v|calendar>", link)))
+	assert.Equal(t, fmt.Sprintf(translation.HolidaysImporting, calendar.URL+"/missing.ics"), command("pmid", "import "+calendar.URL+"/missing.ics"))
+	rest.wg.Wait()
+	assert.ElementsMatch(t, []string{fmt.Sprintf(translation.HolidaysImported, 2), fmt.Sprintf(translation.HolidaysImportFailed, calendar.URL+"/missing.ics")}, reports)
+
+	// past holidays are not listed
+	_, err = rest.db.CreateHoliday(model.Holiday{Day: "2018-01-01", Name: "New Year"})
+	assert.NoError(t, err)
+	list := fmt.Sprintf(translation.HolidaysItem, "2999-01-01", "New Year") +
+		fmt.Sprintf(translation.HolidaysItem, "2999-03-07", "Spring") +
+		fmt.Sprintf(translation.HolidaysItem, "2999-03-08", "Spring")
+	assert.Equal(t, fmt.Sprintf(translation.HolidaysList, list), command("userid", ""))
+
+	assert.Equal(t, fmt.Sprintf(translation.HolidayRemoved, "2999-01-01"), command("pmid", "remove 2999-01-01"))
+	holidays, err := rest.db.ListHolidays("TestChannelID")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(holidays))
+}
+
+func TestVacationCommand(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	slack, err := chat.NewSlack(config.Config{DatabaseURL: "memory://", Translate: translation})
+	assert.NoError(t, err)
+	rest, err := NewRESTAPI(slack)
+	assert.NoError(t, err)
+
+	_, err = rest.db.CreateChannel(model.Channel{ChannelName: "TestChannel", ChannelID: "TestChannelID"})
+	assert.NoError(t, err)
+	for _, id := range []string{"pmid", "userid"} {
+		_, err = rest.db.CreateUser(model.User{UserName: id, UserID: id})
+		assert.NoError(t, err)
+	}
+	_, err = rest.db.CreateChannelMember(model.ChannelMember{UserID: "pmid", ChannelID: "TestChannelID", RoleInChannel: "pm"})
+	assert.NoError(t, err)
+
+	command := func(userID, text string) string {
+		command := fmt.Sprintf("user_id=%v&command=/vacation&channel_id=TestChannelID&channel_name=TestChannel&text=%v", userID, url.QueryEscape(text))
+		context, rec := getContext(command)
+		assert.NoError(t, rest.handleCommands(context))
+		return rec.Body.String()
+	}
+
+	assert.Equal(t, fmt.Sprintf(translation.VacationNone, "userid"), command("userid", ""))
+	assert.Equal(t, translation.AccessAtLeastPM, command("userid", "<@pmid|pm> 2999-11-01 2999-11-14"))
+	assert.Equal(t, translation.VacationWrongDays, command("userid", "2999-11-14 2999-11-01"))
+	assert.Equal(t, translation.VacationWrongDays, command("userid", "01.11.2999 2999-11-14"))
+	assert.Equal(t, translation.VacationUsage, command("userid", "2999-11-01"))
+
+	// standupers set their own vacations, PMs set vacations of others
+	assert.Equal(t, fmt.Sprintf(translation.VacationAdded, "userid", "2999-11-01", "2999-11-14"), command("userid", "2999-11-01 2999-11-14 family trip"))
+	assert.Equal(t, fmt.Sprintf(translation.VacationAdded, "userid", "2999-12-30", "2999-12-31"), command("pmid", "<@userid|user> 2999-12-30 2999-12-31"))
+	_, err = rest.db.CreateAbsence(model.Absence{UserID: "userid", FirstDay: "2018-01-01", LastDay: "2018-01-05"})
+	assert.NoError(t, err)
+
+	list := fmt.Sprintf(translation.VacationItem, "2999-11-01", "2999-11-14", "family trip") +
+		fmt.Sprintf(translation.VacationItem, "2999-12-30", "2999-12-31", "")
+	assert.Equal(t, fmt.Sprintf(translation.VacationList, "userid", list), command("pmid", "<@userid|user>"))
+
+	// vacation to cancel is named by any of its days when there are several
+	assert.Equal(t, fmt.Sprintf(translation.VacationCancelWhich, "userid", list), command("userid", "cancel"))
+	assert.Equal(t, fmt.Sprintf(translation.VacationNotFound, "userid", "2999-11-20"), command("userid", "cancel 2999-11-20"))
+	assert.Equal(t, fmt.Sprintf(translation.VacationCancelled, "userid", "2999-11-01 - 2999-11-14"), command("userid", "cancel 2999-11-05"))
+	assert.Equal(t, fmt.Sprintf(translation.VacationCancelled, "userid", "2999-12-30 - 2999-12-31"), command("pmid", "<@userid|user> cancel"))
+
+	// past vacations are kept in history, days of ongoing one before today too
+	now := time.Now()
+	firstDay, lastDay := now.AddDate(0, 0, -3).Format(model.HolidayDay), now.AddDate(0, 0, 3).Format(model.HolidayDay)
+	ongoing, err := rest.db.CreateAbsence(model.Absence{UserID: "userid", FirstDay: firstDay, LastDay: lastDay})
+	assert.NoError(t, err)
+	yesterday := now.AddDate(0, 0, -1).Format(model.HolidayDay)
+	assert.Equal(t, fmt.Sprintf(translation.VacationShortened, "userid", firstDay+" - "+lastDay, yesterday), command("userid", "cancel"))
+	absences, err := rest.db.ListAbsences("userid")
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(absences)) {
+		assert.Equal(t, ongoing.ID, absences[1].ID)
+		assert.Equal(t, yesterday, absences[1].LastDay)
+	}
+	assert.Equal(t, fmt.Sprintf(translation.VacationNone, "userid"), command("userid", "cancel"))
+}
+
 func TestUserHasAccess(t *testing.T) {
 	c, err := config.Get()
 	c.ManagerSlackUserID = "SUPERADMINID"
@@ -742,3 +1567,55 @@ func getContext(command string) (echo.Context, *httptest.ResponseRecorder) {
 
 	return context, rec
 }
+
+func TestSectionsReportCommands(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	slack, err := chat.NewSlack(config.Config{DatabaseURL: "memory://", Translate: translation})
+	assert.NoError(t, err)
+	rest, err := NewRESTAPI(slack)
+	assert.NoError(t, err)
+
+	_, err = rest.db.CreateChannel(model.Channel{ChannelName: "TestChannel", ChannelID: "TestChannelID"})
+	assert.NoError(t, err)
+	_, err = rest.db.CreateUser(model.User{UserName: "pm", UserID: "pmid"})
+	assert.NoError(t, err)
+	_, err = rest.db.CreateChannelMember(model.ChannelMember{UserID: "pmid", ChannelID: "TestChannelID", RoleInChannel: "pm"})
+	assert.NoError(t, err)
+	_, err = rest.db.CreateUser(model.User{UserName: "user", UserID: "userid"})
+	assert.NoError(t, err)
+	_, err = rest.db.CreateStandup(model.Standup{ChannelID: "TestChannelID", UserID: "userid", Comment: "yesterday: docs\ntoday: tests\nblockers: no access to staging", MessageTS: "1"})
+	assert.NoError(t, err)
+
+	today := time.Now().UTC().Format("2006-01-02")
+	run := func(userID, command, text string) string {
+		form := fmt.Sprintf("user_id=%v&command=%v&channel_id=TestChannelID&channel_name=TestChannel&text=%v", userID, command, url.QueryEscape(text))
+		context, rec := getContext(form)
+		assert.NoError(t, rest.handleCommands(context))
+		return rec.Body.String()
+	}
+
+	testCases := []struct {
+		userID   string
+		command  string
+		text     string
+		response string
+	}{
+		{"userid", "/report_blockers", "#TestChannel " + today + " " + today, translation.AccessAtLeastPM},
+		{"pmid", "/report_blockers", "#TestChannel " + today, translation.WrongNArgs},
+		{"pmid", "/report_blockers", "#Unknown " + today + " " + today, translation.WrongProjectName},
+		{"pmid", "/report_blockers", "#TestChannel " + today + " " + today,
+			fmt.Sprintf(translation.ReportBlockersHead, "TestChannel", today, today) +
+				fmt.Sprintf(translation.ReportDate, today) +
+				fmt.Sprintf(translation.UserBlockers, "userid", "no access to staging")},
+		{"pmid", "/report_plan_vs_actual", "#TestChannel 2018-01-01 2018-01-02",
+			fmt.Sprintf(translation.ReportPlanVsActualHead, "TestChannel", "2018-01-01", "2018-01-02") + translation.ReportNoData},
+		{"pmid", "/report_plan_vs_actual", "#TestChannel " + today + " " + today,
+			fmt.Sprintf(translation.ReportPlanVsActualHead, "TestChannel", today, today) +
+				fmt.Sprintf(translation.ReportDate, today) +
+				fmt.Sprintf(translation.UserPlanVsActual, "userid", translation.ReportNoPlan, "docs")},
+	}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.response, run(tt.userID, tt.command, tt.text), tt.command+" "+tt.text)
+	}
+}
@@ -0,0 +1,115 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/maddevsio/comedian/model"
+)
+
+// ActionShowMore is action ID of "Show more" buttons which expand collapsed parts of messages
+const ActionShowMore = "show_more"
+
+// maxButtonValue is the longest value Slack accepts for button
+const maxButtonValue = 2000
+
+// BlockSender is a messenger which posts Block Kit messages. Only Slack supports them,
+// other platforms get attachments instead
+type BlockSender interface {
+	// SendBlocks posts Block Kit message in a specified channel, text is shown in notifications
+	SendBlocks(channel, text string, blocks []Block) error
+}
+
+// blockDeliverer is a deliverer which delivers Block Kit messages
+type blockDeliverer interface {
+	deliverBlocks(channel, text string, blocks json.RawMessage) error
+}
+
+// slackStatusError is returned when Slack Web API responds with unexpected HTTP status
+type slackStatusError struct {
+	Code int
+}
+
+func (e *slackStatusError) Error() string {
+	return fmt.Sprintf("slack: server responded with %v", e.Code)
+}
+
+// HTTPStatusCode returns HTTP status Slack responded with
+func (e *slackStatusError) HTTPStatusCode() int {
+	return e.Code
+}
+
+// HeaderBlock is a large bold title of message
+func HeaderBlock(text string) Block {
+	return Block{Type: "header", Text: plainText(text)}
+}
+
+// SectionBlock is a text with markup
+func SectionBlock(text string) Block {
+	return Block{Type: "section", Text: &TextObject{Type: "mrkdwn", Text: text}}
+}
+
+// ContextBlock is a line of small texts with markup
+func ContextBlock(texts ...string) Block {
+	block := Block{Type: "context"}
+	for _, text := range texts {
+		block.Elements = append(block.Elements, TextObject{Type: "mrkdwn", Text: text})
+	}
+	return block
+}
+
+// ButtonBlock is a button which sends its action ID and value when clicked
+func ButtonBlock(actionID, text, value string) Block {
+	return Block{Type: "actions", Elements: []interface{}{BlockElement{
+		Type:     "button",
+		ActionID: actionID,
+		Text:     plainText(text),
+		Value:    value,
+	}}}
+}
+
+// ShowMoreBlock is a button which is replaced with hidden text when clicked. Text longer
+// than Slack accepts for button value is cut
+func ShowMoreBlock(button, hidden string) Block {
+	return ButtonBlock(ActionShowMore, button, shorten(hidden, maxButtonValue-1))
+}
+
+// SendBlocks posts Block Kit message in a specified channel visible for everyone
+func (s *Slack) SendBlocks(channel, text string, blocks []Block) error {
+	data, err := json.Marshal(blocks)
+	if err != nil {
+		return err
+	}
+	return s.outbox.send(model.OutgoingMessage{Kind: model.MessageToChannel, ChannelID: channel, Text: text, Blocks: string(data)}, nil)
+}
+
+func (s *Slack) deliverBlocks(channel, text string, blocks json.RawMessage) error {
+	return s.callAPI("chat.postMessage", map[string]interface{}{
+		"channel": channel,
+		"text":    text,
+		"blocks":  blocks,
+	})
+}
+
+// ShowMore replaces clicked "Show more" button with the text it hides
+func (s *Slack) ShowMore(i Interaction) error {
+	for _, action := range i.Actions {
+		if action.ActionID != ActionShowMore {
+			continue
+		}
+		blocks := []Block{}
+		for _, block := range i.Message.Blocks {
+			if block.BlockID == action.BlockID {
+				block = SectionBlock(action.Value)
+			}
+			blocks = append(blocks, block)
+		}
+		return s.callAPI("chat.update", map[string]interface{}{
+			"channel": i.Channel.ID,
+			"ts":      i.Message.TS,
+			"text":    i.Message.Text,
+			"blocks":  blocks,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package chat
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/storage"
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/assert"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+func TestSendBlocks(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var posted struct {
+		Channel string  `json:"channel"`
+		Text    string  `json:"text"`
+		Blocks  []Block `json:"blocks"`
+	}
+	limited := true
+	httpmock.RegisterResponder("POST", "https://slack.com/api/chat.postMessage", func(req *http.Request) (*http.Response, error) {
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		if limited {
+			resp := httpmock.NewStringResponse(429, `{"ok": false, "error": "ratelimited"}`)
+			resp.Header.Set("Retry-After", "3")
+			return resp, nil
+		}
+		json.NewDecoder(req.Body).Decode(&posted)
+		return httpmock.NewStringResponse(200, `{"ok": true}`), nil
+	})
+
+	s := NewWorkspaceSlack(config.Config{TeamID: "team1", SlackToken: "token"}, storage.NewMemory())
+	blocks := []Block{HeaderBlock("Yesterday report"), SectionBlock("<@U1> in #general"), ContextBlock("worklogs: 8:00", "standup :heavy_check_mark:")}
+
+	// rate limited report waits in outbox
+	assert.NoError(t, s.SendBlocks("C1", "Yesterday report", blocks))
+	assert.True(t, s.outbox.paused().After(time.Now().Add(2*time.Second)))
+	assert.Equal(t, "", posted.Channel)
+
+	limited = false
+	s.outbox.flush(time.Now().Add(4 * time.Second))
+	assert.Equal(t, "C1", posted.Channel)
+	assert.Equal(t, "Yesterday report", posted.Text)
+	assert.Equal(t, 3, len(posted.Blocks))
+	assert.Equal(t, "header", posted.Blocks[0].Type)
+	assert.Equal(t, "<@U1> in #general", posted.Blocks[1].Text.Text)
+	assert.Equal(t, 2, len(posted.Blocks[2].Elements))
+}
+
+func TestShowMore(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var updated struct {
+		Channel string  `json:"channel"`
+		TS      string  `json:"ts"`
+		Blocks  []Block `json:"blocks"`
+	}
+	httpmock.RegisterResponder("POST", "https://slack.com/api/chat.update", func(req *http.Request) (*http.Response, error) {
+		json.NewDecoder(req.Body).Decode(&updated)
+		return httpmock.NewStringResponse(200, `{"ok": true}`), nil
+	})
+
+	button := ShowMoreBlock("Show 2 more", strings.Repeat("a", 3000))
+	assert.Equal(t, maxButtonValue, len([]rune(button.Elements[0].(BlockElement).Value)))
+
+	var i Interaction
+	err := json.Unmarshal([]byte(`{
+		"type": "block_actions",
+		"channel": {"id": "C1"},
+		"message": {"ts": "1.2", "text": "Yesterday report", "blocks": [
+			{"type": "header", "block_id": "h", "text": {"type": "plain_text", "text": "Yesterday report"}},
+			{"type": "actions", "block_id": "more", "elements": [{"type": "button", "action_id": "show_more", "value": "<@U2> in #general"}]}
+		]},
+		"actions": [{"action_id": "show_more", "block_id": "more", "value": "<@U2> in #general"}]
+	}`), &i)
+	assert.NoError(t, err)
+
+	s := NewWorkspaceSlack(config.Config{TeamID: "team1", SlackToken: "token"}, storage.NewMemory())
+	assert.NoError(t, s.ShowMore(i))
+	assert.Equal(t, "C1", updated.Channel)
+	assert.Equal(t, "1.2", updated.TS)
+	assert.Equal(t, 2, len(updated.Blocks))
+	assert.Equal(t, "header", updated.Blocks[0].Type)
+	assert.Equal(t, "section", updated.Blocks[1].Type)
+	assert.Equal(t, "<@U2> in #general", updated.Blocks[1].Text.Text)
+
+	httpmock.RegisterResponder("POST", "https://slack.com/api/chat.update", httpmock.NewStringResponder(503, ``))
+	err = s.ShowMore(i)
+	_, retry := retryDelay(err)
+	assert.True(t, retry)
+	_, ok := err.(*slack.RateLimitedError)
+	assert.False(t, ok)
+}
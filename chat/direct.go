@@ -0,0 +1,176 @@
+package chat
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/maddevsio/comedian/model"
+	"github.com/sirupsen/logrus"
+)
+
+// directStandup is a standup written in direct message which waits for user to choose its channels
+type directStandup struct {
+	text     string
+	channels []model.Channel
+}
+
+// channelMention is a channel mention in Slack markup, e.g. <#C1234|general>
+var channelMention = regexp.MustCompile(`^<#([^>|]+)(?:\|[^>]*)?>$`)
+
+// handleDirect posts standup written in direct message to the user's standup channels.
+// Users of several channels are asked which channels the standup is for
+func (s *standups) handleDirect(msg message) {
+	if strings.TrimSpace(msg.Text) == "" {
+		return
+	}
+	if s.chooseChannels(msg) {
+		return
+	}
+
+	channels, submitted := s.directStandupChannels(msg.UserID)
+	switch {
+	case len(channels) == 0 && submitted:
+		s.platform.SendUserMessage(msg.UserID, s.Conf.Translate.DirectStandupAllSubmitted)
+	case len(channels) == 0:
+		s.platform.SendUserMessage(msg.UserID, s.Conf.Translate.DirectStandupNoChannels)
+	case len(channels) == 1:
+		s.submitDirect(msg.UserID, msg.Text, channels[0])
+	default:
+		s.mu.Lock()
+		if s.direct == nil {
+			s.direct = map[string]directStandup{}
+		}
+		s.direct[msg.UserID] = directStandup{msg.Text, channels}
+		s.mu.Unlock()
+		list := []string{}
+		for i, channel := range channels {
+			list = append(list, fmt.Sprintf("%v. <#%v|%v>", i+1, channel.ChannelID, channel.ChannelName))
+		}
+		s.platform.SendUserMessage(msg.UserID, fmt.Sprintf(s.Conf.Translate.DirectStandupChooseChannels, strings.Join(list, "\n")))
+	}
+}
+
+// directStandupChannels returns channels user writes standups in and has not written
+// today's standup yet. submitted shows if some channels are left out because of that
+func (s *standups) directStandupChannels(userID string) (channels []model.Channel, submitted bool) {
+	ids, err := s.DB.GetUserChannels(userID)
+	if err != nil {
+		logrus.Errorf("GetUserChannels failed: %v", err)
+		return nil, false
+	}
+	for _, id := range ids {
+		if s.DB.UserIsPMForProject(userID, id) {
+			continue
+		}
+		if s.DB.SubmittedStandupToday(userID, id) {
+			submitted = true
+			continue
+		}
+		channel, err := s.DB.SelectChannel(id)
+		if err != nil {
+			logrus.Errorf("SelectChannel failed: %v", err)
+			continue
+		}
+		if !channel.Active() {
+			continue
+		}
+		channels = append(channels, channel)
+	}
+	return channels, submitted
+}
+
+// chooseChannels submits standup waiting for channels if message chooses them by numbers,
+// names or "all". Any other message is not a choice and replaces the waiting standup
+func (s *standups) chooseChannels(msg message) bool {
+	s.mu.Lock()
+	standup, ok := s.direct[msg.UserID]
+	if ok {
+		delete(s.direct, msg.UserID)
+	}
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	chosen := []model.Channel{}
+	for _, choice := range strings.FieldsFunc(msg.Text, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		choice = strings.ToLower(choice)
+		if choice == "all" || choice == "все" {
+			chosen = standup.channels
+			continue
+		}
+		n, err := strconv.Atoi(choice)
+		if err == nil && n > 0 && n <= len(standup.channels) {
+			chosen = append(chosen, standup.channels[n-1])
+			continue
+		}
+		channel, found := findChannel(standup.channels, choice)
+		if !found {
+			return false
+		}
+		chosen = append(chosen, channel)
+	}
+	if len(chosen) == 0 {
+		return false
+	}
+
+	posted := map[string]bool{}
+	for _, channel := range chosen {
+		if posted[channel.ChannelID] {
+			continue
+		}
+		posted[channel.ChannelID] = true
+		s.submitDirect(msg.UserID, standup.text, channel)
+	}
+	return true
+}
+
+// findChannel finds channel by name, #name or channel mention
+func findChannel(channels []model.Channel, choice string) (model.Channel, bool) {
+	id := ""
+	if m := channelMention.FindStringSubmatch(choice); m != nil {
+		id = strings.ToLower(m[1])
+	}
+	name := strings.TrimLeft(choice, "#~")
+	for _, channel := range channels {
+		if strings.ToLower(channel.ChannelID) == id || strings.ToLower(channel.ChannelName) == name {
+			return channel, true
+		}
+	}
+	return model.Channel{}, false
+}
+
+// submitDirect posts standup to the channel on behalf of the user and saves it
+func (s *standups) submitDirect(userID, text string, channel model.Channel) {
+	_, problem := s.analizeStandup(channel.ChannelID, text)
+	if problem != "" {
+		s.platform.SendUserMessage(userID, fmt.Sprintf(s.Conf.Translate.DirectStandupProblem, channel.ChannelID, channel.ChannelName, problem))
+		return
+	}
+	if s.DB.SubmittedStandupToday(userID, channel.ChannelID) {
+		s.platform.SendUserMessage(userID, fmt.Sprintf(s.Conf.Translate.DirectStandupAlreadySubmitted, channel.ChannelID, channel.ChannelName))
+		return
+	}
+	messageID, err := s.platform.postMessage(channel.ChannelID, fmt.Sprintf(s.Conf.Translate.DirectStandupPosted, userID, text))
+	if err != nil {
+		logrus.Errorf("postMessage failed: %v", err)
+		s.platform.SendUserMessage(userID, s.Conf.Translate.StandupHandleCouldNotSaveStandup)
+		return
+	}
+	standup, err := s.DB.CreateStandup(model.Standup{
+		ChannelID: channel.ChannelID,
+		UserID:    userID,
+		Comment:   text,
+		MessageTS: messageID,
+		Sections:  s.standupSections(channel.ChannelID, text),
+	})
+	if err != nil {
+		logrus.Errorf("CreateStandup failed: %v", err)
+		s.platform.SendUserMessage(userID, s.Conf.Translate.StandupHandleCouldNotSaveStandup)
+		return
+	}
+	logrus.Infof("Standup created #id:%v\n", standup.ID)
+	s.platform.SendUserMessage(userID, fmt.Sprintf(s.Conf.Translate.DirectStandupSaved, channel.ChannelID, channel.ChannelName))
+}
@@ -0,0 +1,147 @@
+package chat
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/model"
+	"github.com/maddevsio/comedian/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePlatform records messages standups send
+type fakePlatform struct {
+	direct  []string
+	posted  []message
+	members map[string][]string
+}
+
+func (f *fakePlatform) SendEphemeralMessage(channel, user, message string) error {
+	return nil
+}
+
+func (f *fakePlatform) SendUserMessage(userID, message string) error {
+	f.direct = append(f.direct, message)
+	return nil
+}
+
+func (f *fakePlatform) markStandup(channelID, messageID string) {}
+
+func (f *fakePlatform) channelName(channelID string) (string, error) {
+	return channelID, nil
+}
+
+func (f *fakePlatform) channelMembers(channelID string) ([]string, error) {
+	return f.members[channelID], nil
+}
+
+func (f *fakePlatform) postMessage(channelID, text string) (string, error) {
+	id := fmt.Sprintf("msg%v", len(f.posted)+1)
+	f.posted = append(f.posted, message{ChannelID: channelID, Text: text, ID: id})
+	return id, nil
+}
+
+func TestHandleDirect(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	f := &fakePlatform{}
+	s := &standups{DB: storage.NewMemory(), Conf: config.Config{Translate: translation}, platform: f}
+	standup := "yesterday: fixed bugs, today: tests, problems: none"
+
+	s.handleDirect(message{ChannelID: "D1", UserID: "U1", Text: standup, ID: "1"})
+	assert.Equal(t, []string{translation.DirectStandupNoChannels}, f.direct)
+
+	for _, c := range []model.Channel{{ChannelID: "C1", ChannelName: "backend"}, {ChannelID: "C2", ChannelName: "frontend"}, {ChannelID: "C3", ChannelName: "management"}} {
+		_, err = s.DB.CreateChannel(c)
+		assert.NoError(t, err)
+	}
+	_, err = s.DB.CreateChannelMember(model.ChannelMember{UserID: "U1", ChannelID: "C1", RoleInChannel: "developer"})
+	assert.NoError(t, err)
+	_, err = s.DB.CreateChannelMember(model.ChannelMember{UserID: "U1", ChannelID: "C3", RoleInChannel: "pm"})
+	assert.NoError(t, err)
+
+	// the only channel needs no questions
+	f.direct = nil
+	s.handleDirect(message{ChannelID: "D1", UserID: "U1", Text: "hello", ID: "2"})
+	assert.Equal(t, []string{fmt.Sprintf(translation.DirectStandupProblem, "C1", "backend", translation.StandupHandleNoProblemsMentioned)}, f.direct)
+	assert.Equal(t, 0, len(f.posted))
+
+	f.direct = nil
+	s.handleDirect(message{ChannelID: "D1", UserID: "U1", Text: standup, ID: "3"})
+	assert.Equal(t, []string{fmt.Sprintf(translation.DirectStandupSaved, "C1", "backend")}, f.direct)
+	assert.Equal(t, []message{{ChannelID: "C1", Text: fmt.Sprintf(translation.DirectStandupPosted, "U1", standup), ID: "msg1"}}, f.posted)
+	saved, err := s.DB.SelectStandupByMessageTS("msg1")
+	assert.NoError(t, err)
+	assert.Equal(t, "U1", saved.UserID)
+	assert.Equal(t, "C1", saved.ChannelID)
+	assert.Equal(t, standup, saved.Comment)
+	assert.Equal(t, "none", saved.Sections[model.SectionBlockers])
+
+	f.direct = nil
+	s.handleDirect(message{ChannelID: "D1", UserID: "U1", Text: standup, ID: "4"})
+	assert.Equal(t, []string{translation.DirectStandupAllSubmitted}, f.direct)
+}
+
+func TestHandleDirectInSeveralChannels(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	f := &fakePlatform{}
+	s := &standups{DB: storage.NewMemory(), Conf: config.Config{Translate: translation}, platform: f}
+	standup := "yesterday: fixed bugs, today: tests, problems: none"
+
+	for _, c := range []model.Channel{{ChannelID: "C1", ChannelName: "backend"}, {ChannelID: "C2", ChannelName: "frontend"}, {ChannelID: "C3", ChannelName: "mobile"}} {
+		_, err = s.DB.CreateChannel(c)
+		assert.NoError(t, err)
+		_, err = s.DB.CreateChannelMember(model.ChannelMember{UserID: "U1", ChannelID: c.ChannelID, RoleInChannel: "developer"})
+		assert.NoError(t, err)
+	}
+
+	s.handleDirect(message{ChannelID: "D1", UserID: "U1", Text: standup, ID: "1"})
+	assert.Equal(t, []string{fmt.Sprintf(translation.DirectStandupChooseChannels, "1. <#C1|backend>\n2. <#C2|frontend>\n3. <#C3|mobile>")}, f.direct)
+	assert.Equal(t, 0, len(f.posted))
+
+	f.direct = nil
+	s.handleDirect(message{ChannelID: "D1", UserID: "U1", Text: "1, #Mobile 1", ID: "2"})
+	assert.Equal(t, []string{
+		fmt.Sprintf(translation.DirectStandupSaved, "C1", "backend"),
+		fmt.Sprintf(translation.DirectStandupSaved, "C3", "mobile"),
+	}, f.direct)
+	assert.Equal(t, 2, len(f.posted))
+	assert.Equal(t, "C1", f.posted[0].ChannelID)
+	assert.Equal(t, "C3", f.posted[1].ChannelID)
+	assert.True(t, s.DB.SubmittedStandupToday("U1", "C1"))
+	assert.False(t, s.DB.SubmittedStandupToday("U1", "C2"))
+	assert.True(t, s.DB.SubmittedStandupToday("U1", "C3"))
+
+	// the only channel left
+	f.direct = nil
+	s.handleDirect(message{ChannelID: "D1", UserID: "U1", Text: standup, ID: "3"})
+	assert.Equal(t, []string{fmt.Sprintf(translation.DirectStandupSaved, "C2", "frontend")}, f.direct)
+}
+
+func TestChooseChannels(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	f := &fakePlatform{}
+	s := &standups{DB: storage.NewMemory(), Conf: config.Config{Translate: translation}, platform: f}
+	standup := "yesterday: fixed bugs, today: tests, problems: none"
+	channels := []model.Channel{{ChannelID: "C1", ChannelName: "backend"}, {ChannelID: "C2", ChannelName: "frontend"}}
+
+	// no standup waits for channels
+	assert.False(t, s.chooseChannels(message{UserID: "U1", Text: "1"}))
+
+	s.direct = map[string]directStandup{"U1": {standup, channels}}
+	// a new standup replaces the waiting one
+	assert.False(t, s.chooseChannels(message{UserID: "U1", Text: "sorry, I meant another text"}))
+	assert.Equal(t, 0, len(s.direct))
+
+	s.direct["U1"] = directStandup{standup, channels}
+	assert.True(t, s.chooseChannels(message{UserID: "U1", Text: "all"}))
+	assert.Equal(t, 2, len(f.posted))
+
+	s.direct["U2"] = directStandup{standup, channels}
+	assert.True(t, s.chooseChannels(message{UserID: "U2", Text: "<#C2|frontend>"}))
+	assert.Equal(t, 3, len(f.posted))
+	assert.Equal(t, "C2", f.posted[2].ChannelID)
+}
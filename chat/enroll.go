@@ -0,0 +1,113 @@
+package chat
+
+import (
+	"github.com/maddevsio/comedian/model"
+	"github.com/sirupsen/logrus"
+)
+
+// EnrollMembers adds everyone in channel with auto-enroll as developers. Users excluded
+// from auto-enroll, bots and existing members are skipped. Returns number of added members
+func (s *standups) EnrollMembers(channelID string) (int, error) {
+	channel, err := s.DB.SelectChannel(channelID)
+	if err != nil {
+		return 0, err
+	}
+	userIDs, err := s.platform.channelMembers(channelID)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for _, userID := range userIDs {
+		if s.enroll(channel, userID) {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// enroll adds user to channel with auto-enroll as developer. Comedian does not keep bots
+// as users, so users it does not know are skipped
+func (s *standups) enroll(channel model.Channel, userID string) bool {
+	if !channel.AutoEnroll || !channel.Active() || s.excluded(channel.ChannelID, userID) {
+		return false
+	}
+	if _, err := s.DB.SelectUser(userID); err != nil {
+		return false
+	}
+	if _, err := s.DB.FindChannelMemberByUserID(userID, channel.ChannelID); err == nil {
+		return false
+	}
+	_, err := s.DB.CreateChannelMember(model.ChannelMember{
+		UserID:        userID,
+		ChannelID:     channel.ChannelID,
+		RoleInChannel: "developer",
+	})
+	if err != nil {
+		logrus.Errorf("CreateChannelMember failed: %v", err)
+		return false
+	}
+	logrus.Infof("%v is enrolled in %v", userID, channel.ChannelName)
+	return true
+}
+
+func (s *standups) excluded(channelID, userID string) bool {
+	excluded, err := s.DB.ListAutoEnrollExclusions(channelID)
+	if err != nil {
+		logrus.Errorf("ListAutoEnrollExclusions failed: %v", err)
+		return true
+	}
+	for _, id := range excluded {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// handleMemberJoined saves channel Comedian is in and enrolls users joining channel with
+// auto-enroll. When Comedian itself joins, everyone in the channel is enrolled
+func (s *standups) handleMemberJoined(channelID, userID string, isComedian bool) {
+	s.handleJoin(channelID)
+	channel, err := s.DB.SelectChannel(channelID)
+	if err != nil || !channel.AutoEnroll {
+		return
+	}
+	if isComedian {
+		added, err := s.EnrollMembers(channelID)
+		if err != nil {
+			logrus.Errorf("EnrollMembers failed: %v", err)
+			return
+		}
+		logrus.Infof("%v members are enrolled in %v", added, channel.ChannelName)
+		return
+	}
+	s.enroll(channel, userID)
+}
+
+// handleMemberLeft removes user who left channel with auto-enroll from standupers
+// together with their timetable
+func (s *standups) handleMemberLeft(channelID, userID string) {
+	channel, err := s.DB.SelectChannel(channelID)
+	if err != nil || !channel.AutoEnroll {
+		return
+	}
+	member, err := s.DB.FindChannelMemberByUserID(userID, channelID)
+	if err != nil {
+		return
+	}
+	err = s.removeMember(member)
+	if err != nil {
+		logrus.Errorf("DeleteChannelMember failed: %v", err)
+		return
+	}
+	s.ScheduleChanged()
+	logrus.Infof("%v left %v and is not a standuper any more", userID, channel.ChannelName)
+}
+
+// removeMember deletes member of channel with timetable of the member
+func (s *standups) removeMember(member model.ChannelMember) error {
+	if tt, err := s.DB.SelectTimeTable(member.ID); err == nil {
+		s.DB.DeleteTimeTable(tt.ID)
+	}
+	return s.DB.DeleteChannelMember(member.UserID, member.ChannelID)
+}
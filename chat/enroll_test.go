@@ -0,0 +1,62 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/model"
+	"github.com/maddevsio/comedian/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoEnroll(t *testing.T) {
+	f := &fakePlatform{members: map[string][]string{"C1": {"BOT", "U1", "U2", "U3"}}}
+	s := &standups{DB: storage.NewMemory(), Conf: config.Config{AutoEnroll: true}, platform: f}
+	for _, id := range []string{"U1", "U2", "U3", "U4"} {
+		_, err := s.DB.CreateUser(model.User{UserID: id, UserName: id})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, s.DB.ExcludeFromAutoEnroll("C1", "U3"))
+	_, err := s.DB.CreateChannelMember(model.ChannelMember{UserID: "U2", ChannelID: "C1", RoleInChannel: "pm"})
+	assert.NoError(t, err)
+
+	// everyone but bots, excluded users and existing members is added when Comedian joins
+	s.handleMemberJoined("C1", "BOT", true)
+	members, err := s.DB.ListChannelMembers("C1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(members))
+	u1, err := s.DB.FindChannelMemberByUserID("U1", "C1")
+	assert.NoError(t, err)
+	assert.Equal(t, "developer", u1.RoleInChannel)
+	u2, err := s.DB.FindChannelMemberByUserID("U2", "C1")
+	assert.NoError(t, err)
+	assert.Equal(t, "pm", u2.RoleInChannel)
+
+	s.handleMemberJoined("C1", "U4", false)
+	_, err = s.DB.FindChannelMemberByUserID("U4", "C1")
+	assert.NoError(t, err)
+	s.handleMemberJoined("C1", "U3", false)
+	_, err = s.DB.FindChannelMemberByUserID("U3", "C1")
+	assert.Error(t, err)
+
+	_, err = s.DB.CreateTimeTable(model.TimeTable{ChannelMemberID: u1.ID, Monday: 12345})
+	assert.NoError(t, err)
+	s.handleMemberLeft("C1", "U1")
+	_, err = s.DB.FindChannelMemberByUserID("U1", "C1")
+	assert.Error(t, err)
+	assert.False(t, s.DB.MemberHasTimeTable(u1.ID))
+
+	// channels without auto-enroll are managed by hand
+	s.Conf.AutoEnroll = false
+	f.members["C2"] = []string{"U1"}
+	s.handleMemberJoined("C2", "BOT", true)
+	s.handleMemberJoined("C2", "U4", false)
+	members, err = s.DB.ListChannelMembers("C2")
+	assert.NoError(t, err)
+	assert.Empty(t, members)
+	_, err = s.DB.CreateChannelMember(model.ChannelMember{UserID: "U4", ChannelID: "C2", RoleInChannel: "developer"})
+	assert.NoError(t, err)
+	s.handleMemberLeft("C2", "U4")
+	_, err = s.DB.FindChannelMemberByUserID("U4", "C2")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,256 @@
+package chat
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/maddevsio/comedian/model"
+	"github.com/nlopes/slack"
+	"github.com/sirupsen/logrus"
+)
+
+// Slack signs requests with this version of signature
+const signatureVersion = "v0"
+
+// RequestMaxAge is how old a signed request may be, older ones are considered replayed
+const RequestMaxAge = 5 * time.Minute
+
+// eventsAuthBaseDelay and eventsAuthMaxDelay are the first and the longest pauses before
+// asking Slack again which user Comedian is
+var (
+	eventsAuthBaseDelay = 5 * time.Second
+	eventsAuthMaxDelay  = 5 * time.Minute
+)
+
+// Types of Events API callbacks
+const (
+	EventURLVerification = "url_verification"
+	EventCallback        = "event_callback"
+)
+
+// EventsAPIRequest is a request Slack sends to Events API endpoint
+type EventsAPIRequest struct {
+	Token     string          `json:"token"`
+	TeamID    string          `json:"team_id"`
+	Type      string          `json:"type"`
+	Challenge string          `json:"challenge"`
+	EventID   string          `json:"event_id"`
+	EventTime int64           `json:"event_time"`
+	Event     json.RawMessage `json:"event"`
+}
+
+// eventType is a part of every inner event
+type eventType struct {
+	Type string `json:"type"`
+}
+
+// channelStatusEvents are events which change status of channel to the one they map to.
+// Comedian receives channel_left and group_left when it is removed from channel
+var channelStatusEvents = map[string]string{
+	"channel_archive":   model.ChannelArchived,
+	"group_archive":     model.ChannelArchived,
+	"channel_unarchive": model.ChannelActive,
+	"group_unarchive":   model.ChannelActive,
+	"channel_deleted":   model.ChannelDeleted,
+	"group_deleted":     model.ChannelDeleted,
+	"channel_left":      model.ChannelLeft,
+	"group_left":        model.ChannelLeft,
+}
+
+// renameEvent is channel_rename or group_rename event. slack.ChannelRenameEvent can not
+// be used because it expects creation time as a string while Slack sends a number
+type renameEvent struct {
+	Type    string `json:"type"`
+	Channel struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"channel"`
+}
+
+func init() {
+	// RTM delivers these events with the same structs Events API ones are parsed to
+	slack.EventMapping["channel_rename"] = renameEvent{}
+	slack.EventMapping["group_rename"] = renameEvent{}
+	for eventType := range channelStatusEvents {
+		slack.EventMapping[eventType] = slack.ChannelInfoEvent{}
+	}
+}
+
+// VerifyRequest checks X-Slack-Signature of request body signed with signing secret
+func VerifyRequest(signingSecret string, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	signature := header.Get("X-Slack-Signature")
+	if timestamp == "" || signature == "" {
+		return errors.New("request is not signed")
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("wrong request timestamp %v", timestamp)
+	}
+	age := now.Sub(time.Unix(ts, 0))
+	if age > RequestMaxAge || age < -RequestMaxAge {
+		return fmt.Errorf("request timestamp %v is out of allowed window", timestamp)
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(signingSecret, timestamp, body))) {
+		return errors.New("wrong request signature")
+	}
+	return nil
+}
+
+// Sign returns X-Slack-Signature of body sent at timestamp
+func Sign(signingSecret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte(signatureVersion + ":" + timestamp + ":"))
+	mac.Write(body)
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SeenIDs remembers recently seen IDs (event IDs, request signatures) to detect redelivered
+// or replayed requests
+type SeenIDs struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// Add returns false if id was already seen during ttl
+func (e *SeenIDs) Add(id string, now time.Time, ttl time.Duration) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.seen == nil {
+		e.seen = map[string]time.Time{}
+	}
+	for seenID, seen := range e.seen {
+		if now.Sub(seen) > ttl {
+			delete(e.seen, seenID)
+		}
+	}
+	if _, ok := e.seen[id]; ok {
+		return false
+	}
+	e.seen[id] = now
+	return true
+}
+
+// Remove forgets id
+func (e *SeenIDs) Remove(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.seen, id)
+}
+
+// PushEvent queues event received by Events API endpoint. Events redelivered by Slack
+// are skipped. Error means event could not be queued and Slack should retry it later
+func (s *Slack) PushEvent(req EventsAPIRequest) error {
+	if req.EventID != "" && !s.eventIDs.Add(req.EventID, time.Now(), time.Hour) {
+		logrus.Infof("slack: event %v was already received", req.EventID)
+		return nil
+	}
+	select {
+	case s.events <- req:
+		return nil
+	default:
+		s.eventIDs.Remove(req.EventID)
+		return fmt.Errorf("events queue is full, event %v is not handled", req.EventID)
+	}
+}
+
+// listenEvents handles events received by Events API endpoint
+func (s *Slack) listenEvents() {
+	botUserID := s.eventsBotUserID()
+	for req := range s.events {
+		s.handleEvent(req, botUserID)
+	}
+}
+
+// eventsBotUserID returns mention of Comedian. Slack is asked until it answers, waiting
+// longer after every failure; events are kept in queue meanwhile
+func (s *Slack) eventsBotUserID() string {
+	delay := eventsAuthBaseDelay
+	for {
+		auth, err := s.API.AuthTest()
+		if err == nil {
+			return fmt.Sprintf("<@%s>", auth.UserID)
+		}
+		logrus.Errorf("slack: AuthTest failed, retrying in %v: %v", delay, err)
+		time.Sleep(delay)
+		delay *= 2
+		if delay > eventsAuthMaxDelay {
+			delay = eventsAuthMaxDelay
+		}
+	}
+}
+
+func (s *Slack) handleEvent(req EventsAPIRequest, botUserID string) {
+	var t eventType
+	err := json.Unmarshal(req.Event, &t)
+	if err != nil {
+		logrus.Errorf("slack: could not parse event %v: %v", req.EventID, err)
+		return
+	}
+	switch t.Type {
+	case "message":
+		ev := &slack.MessageEvent{}
+		err = json.Unmarshal(req.Event, ev)
+		if err != nil {
+			logrus.Errorf("slack: could not parse message event %v: %v", req.EventID, err)
+			return
+		}
+		s.handleMessage(ev, botUserID)
+	case "member_joined_channel":
+		ev := &slack.MemberJoinedChannelEvent{}
+		err = json.Unmarshal(req.Event, ev)
+		if err != nil {
+			logrus.Errorf("slack: could not parse member_joined_channel event %v: %v", req.EventID, err)
+			return
+		}
+		isComedian := fmt.Sprintf("<@%s>", ev.User) == botUserID
+		s.handleMemberJoined(ev.Channel, ev.User, isComedian)
+		if isComedian {
+			s.offerSetup(ev.Channel, ev.Inviter)
+		}
+	case "member_left_channel":
+		ev := &slack.MemberLeftChannelEvent{}
+		err = json.Unmarshal(req.Event, ev)
+		if err != nil {
+			logrus.Errorf("slack: could not parse member_left_channel event %v: %v", req.EventID, err)
+			return
+		}
+		s.handleMemberLeft(ev.Channel, ev.User)
+	case "team_join", "user_change":
+		ev := &slack.UserChangeEvent{}
+		err = json.Unmarshal(req.Event, ev)
+		if err != nil {
+			logrus.Errorf("slack: could not parse %v event %v: %v", t.Type, req.EventID, err)
+			return
+		}
+		s.syncUser(ev.User)
+	case "channel_rename", "group_rename":
+		ev := &renameEvent{}
+		err = json.Unmarshal(req.Event, ev)
+		if err != nil {
+			logrus.Errorf("slack: could not parse %v event %v: %v", t.Type, req.EventID, err)
+			return
+		}
+		s.handleRename(ev.Channel.ID, ev.Channel.Name)
+	default:
+		status, ok := channelStatusEvents[t.Type]
+		if !ok {
+			return
+		}
+		ev := &slack.ChannelInfoEvent{}
+		err = json.Unmarshal(req.Event, ev)
+		if err != nil {
+			logrus.Errorf("slack: could not parse %v event %v: %v", t.Type, req.EventID, err)
+			return
+		}
+		s.setChannelStatus(ev.Channel, status)
+	}
+}
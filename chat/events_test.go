@@ -0,0 +1,198 @@
+package chat
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/model"
+	"github.com/maddevsio/comedian/storage"
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/assert"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+func TestVerifyRequest(t *testing.T) {
+	now := time.Date(2018, 10, 1, 10, 0, 0, 0, time.UTC)
+	body := []byte(`{"type":"event_callback"}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	header := func(timestamp, signature string) http.Header {
+		h := http.Header{}
+		h.Set("X-Slack-Request-Timestamp", timestamp)
+		h.Set("X-Slack-Signature", signature)
+		return h
+	}
+	old := strconv.FormatInt(now.Add(-6*time.Minute).Unix(), 10)
+
+	testCases := []struct {
+		header http.Header
+		body   []byte
+		valid  bool
+	}{
+		{header(timestamp, Sign("secret", timestamp, body)), body, true},
+		{header(timestamp, Sign("secret", timestamp, body)), []byte(`{"type":"changed"}`), false},
+		{header(timestamp, Sign("other", timestamp, body)), body, false},
+		{header(old, Sign("secret", old, body)), body, false},
+		{header("yesterday", Sign("secret", "yesterday", body)), body, false},
+		{http.Header{}, body, false},
+	}
+	for i, tt := range testCases {
+		err := VerifyRequest("secret", tt.header, tt.body, now)
+		assert.Equal(t, tt.valid, err == nil, "case %v: %v", i, err)
+	}
+}
+
+func TestPushEvent(t *testing.T) {
+	s, err := NewSlack(config.Config{DatabaseURL: "memory://"})
+	assert.NoError(t, err)
+
+	standup, err := s.DB.CreateStandup(model.Standup{
+		ChannelID: "testChannel",
+		UserID:    "testUser",
+		Comment:   "<@BOTID> Yesterday, today, problems",
+		MessageTS: "1500000",
+	})
+	assert.NoError(t, err)
+
+	req := EventsAPIRequest{
+		Type:    EventCallback,
+		EventID: "Ev1",
+		Event:   json.RawMessage(`{"type":"message","subtype":"message_deleted","channel":"testChannel","deleted_ts":"1500000"}`),
+	}
+	assert.NoError(t, s.PushEvent(req))
+	// retried delivery is skipped
+	assert.NoError(t, s.PushEvent(req))
+	assert.Equal(t, 1, len(s.events))
+
+	s.handleEvent(<-s.events, "<@BOTID>")
+	deleted, err := s.DB.SelectDeletedStandup("testUser", "testChannel", time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, standup.ID, deleted.ID)
+
+	for i := 0; i < cap(s.events); i++ {
+		assert.NoError(t, s.PushEvent(EventsAPIRequest{EventID: "Ev" + strconv.Itoa(i+2)}))
+	}
+	assert.Error(t, s.PushEvent(EventsAPIRequest{EventID: "EvFull"}))
+	<-s.events
+	// event which did not fit into queue can be redelivered
+	assert.NoError(t, s.PushEvent(EventsAPIRequest{EventID: "EvFull"}))
+}
+
+func TestEventsBotUserID(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	defer func(delay time.Duration) { eventsAuthBaseDelay = delay }(eventsAuthBaseDelay)
+	eventsAuthBaseDelay = time.Millisecond
+
+	calls := 0
+	httpmock.RegisterResponder("POST", "https://slack.com/api/auth.test", func(req *http.Request) (*http.Response, error) {
+		calls++
+		if calls < 3 {
+			return httpmock.NewStringResponse(200, `{"ok": false, "error": "service_unavailable"}`), nil
+		}
+		return httpmock.NewStringResponse(200, `{"ok": true, "user_id": "BOTID"}`), nil
+	})
+
+	s := NewWorkspaceSlack(config.Config{TeamID: "team1", SlackToken: "token"}, storage.NewMemory())
+	// events are handled once Slack answers, failures do not stop the receiver
+	assert.Equal(t, "<@BOTID>", s.eventsBotUserID())
+	assert.Equal(t, 3, calls)
+}
+
+func TestChannelLifecycleEvents(t *testing.T) {
+	s := NewWorkspaceSlack(config.Config{TeamID: "team1", SlackToken: "token"}, storage.NewMemory())
+	_, err := s.DB.CreateChannel(model.Channel{ChannelName: "general", ChannelID: "C1"})
+	assert.NoError(t, err)
+
+	event := func(data string) {
+		s.handleEvent(EventsAPIRequest{Type: EventCallback, Event: json.RawMessage(data)}, "<@BOTID>")
+	}
+	status := func() string {
+		channel, err := s.DB.SelectChannel("C1")
+		assert.NoError(t, err)
+		return channel.Status
+	}
+
+	event(`{"type":"channel_rename","channel":{"id":"C1","name":"backend","created":1360782804}}`)
+	id, err := s.DB.GetChannelID("backend")
+	assert.NoError(t, err)
+	assert.Equal(t, "C1", id)
+	_, err = s.DB.GetChannelID("general")
+	assert.Error(t, err)
+
+	event(`{"type":"channel_archive","channel":"C1","user":"U1"}`)
+	assert.Equal(t, model.ChannelArchived, status())
+	event(`{"type":"channel_unarchive","channel":"C1","user":"U1"}`)
+	assert.Equal(t, model.ChannelActive, status())
+
+	// Comedian is removed from channel and invited back
+	event(`{"type":"channel_left","channel":"C1"}`)
+	assert.Equal(t, model.ChannelLeft, status())
+	s.handleJoin("C1")
+	assert.Equal(t, model.ChannelActive, status())
+
+	event(`{"type":"channel_deleted","channel":"C1"}`)
+	assert.Equal(t, model.ChannelDeleted, status())
+	event(`{"type":"channel_unarchive","channel":"C1"}`)
+	s.handleJoin("C1")
+	assert.Equal(t, model.ChannelDeleted, status())
+
+	// RTM parses the events the same way
+	assert.Equal(t, renameEvent{}, slack.EventMapping["group_rename"])
+	assert.Equal(t, slack.ChannelInfoEvent{}, slack.EventMapping["group_archive"])
+}
+
+func TestUserEvents(t *testing.T) {
+	s := NewWorkspaceSlack(config.Config{TeamID: "team1", SlackToken: "token"}, storage.NewMemory())
+	event := func(data string) {
+		s.handleEvent(EventsAPIRequest{Type: EventCallback, Event: json.RawMessage(data)}, "<@BOTID>")
+	}
+
+	event(`{"type":"team_join","user":{"id":"U1","name":"john","is_admin":true,"tz":"Asia/Bishkek"}}`)
+	event(`{"type":"team_join","user":{"id":"B1","name":"helper","is_bot":true}}`)
+	user, err := s.DB.SelectUser("U1")
+	assert.NoError(t, err)
+	assert.Equal(t, "john", user.UserName)
+	assert.Equal(t, "Asia/Bishkek", user.TimeZone)
+	assert.True(t, user.IsAdmin())
+	_, err = s.DB.SelectUser("B1")
+	assert.Error(t, err)
+
+	event(`{"type":"user_change","user":{"id":"U1","name":"john.doe","tz":"Europe/Berlin"}}`)
+	user, err = s.DB.SelectUser("U1")
+	assert.NoError(t, err)
+	assert.Equal(t, "john.doe", user.UserName)
+	assert.Equal(t, "Europe/Berlin", user.TimeZone)
+	assert.True(t, user.IsAdmin(), "role set in Comedian is kept")
+
+	_, err = s.DB.CreateChannel(model.Channel{ChannelName: "general", ChannelID: "C1"})
+	assert.NoError(t, err)
+	member, err := s.DB.CreateChannelMember(model.ChannelMember{UserID: "U1", ChannelID: "C1"})
+	assert.NoError(t, err)
+	deadline := time.Date(2019, 1, 7, 10, 0, 0, 0, model.Location("Europe/Berlin")).Unix()
+	tt, err := s.DB.CreateTimeTable(model.TimeTable{ChannelMemberID: member.ID})
+	assert.NoError(t, err)
+	tt.Monday = deadline
+	_, err = s.DB.UpdateTimeTable(tt)
+	assert.NoError(t, err)
+
+	// deadlines keep their local time when user moves to another time zone
+	event(`{"type":"user_change","user":{"id":"U1","name":"john.doe","tz":"Asia/Bishkek"}}`)
+	tt, err = s.DB.SelectTimeTable(member.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "10:00", time.Unix(tt.Monday, 0).In(model.Location("Asia/Bishkek")).Format("15:04"))
+
+	// deactivated user is not reminded any more
+	event(`{"type":"user_change","user":{"id":"U1","name":"john.doe","deleted":true}}`)
+	_, err = s.DB.SelectUser("U1")
+	assert.Error(t, err)
+	_, err = s.DB.FindChannelMemberByUserID("U1", "C1")
+	assert.Error(t, err)
+	tts, err := s.DB.ListTimeTablesForDay("monday")
+	assert.NoError(t, err)
+	assert.Empty(t, tts)
+}
@@ -0,0 +1,434 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jasonlvhit/gocron"
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/model"
+	"github.com/maddevsio/comedian/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// mattermostReconnectDelay is a pause before reconnecting to Mattermost WebSocket
+var mattermostReconnectDelay = 10 * time.Second
+
+// Mattermost collects standups in self-hosted Mattermost. Messages are sent with
+// REST API v4 and received from WebSocket API
+type Mattermost struct {
+	standups
+	client    *http.Client
+	botUserID string
+	botName   string
+}
+
+type mattermostUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Roles    string `json:"roles"`
+	DeleteAt int64  `json:"delete_at"`
+	IsBot    bool   `json:"is_bot"`
+	// Timezone has automaticTimezone, manualTimezone and useAutomaticTimezone keys
+	Timezone map[string]string `json:"timezone"`
+}
+
+// timeZone returns IANA time zone user has chosen in Mattermost
+func (u mattermostUser) timeZone() string {
+	if u.Timezone["useAutomaticTimezone"] == "false" {
+		return u.Timezone["manualTimezone"]
+	}
+	return u.Timezone["automaticTimezone"]
+}
+
+type mattermostPost struct {
+	ID        string                 `json:"id,omitempty"`
+	ChannelID string                 `json:"channel_id"`
+	UserID    string                 `json:"user_id,omitempty"`
+	Message   string                 `json:"message"`
+	Type      string                 `json:"type,omitempty"`
+	Props     map[string]interface{} `json:"props,omitempty"`
+}
+
+type mattermostChannel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type mattermostEvent struct {
+	Event     string                 `json:"event"`
+	Data      map[string]interface{} `json:"data"`
+	Broadcast mattermostBroadcast    `json:"broadcast"`
+}
+
+// mattermostBroadcast tells whom event is sent to
+type mattermostBroadcast struct {
+	ChannelID string `json:"channel_id"`
+	TeamID    string `json:"team_id"`
+	UserID    string `json:"user_id"`
+}
+
+// mattermostError is returned by Mattermost API on failed requests
+type mattermostError struct {
+	StatusCode int
+	Message    string `json:"message"`
+	// RetryAfter is how long Mattermost asks to wait when requests are rate limited
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *mattermostError) Error() string {
+	return fmt.Sprintf("mattermost: %v %v", e.StatusCode, e.Message)
+}
+
+// NewMattermost creates Mattermost handler for team conf.TeamID
+func NewMattermost(conf config.Config, db storage.Storage) *Mattermost {
+	m := &Mattermost{client: &http.Client{Timeout: 30 * time.Second}}
+	m.platform = m
+	m.Conf = conf
+	m.DB = db.ForTeam(conf.TeamID)
+	m.outbox = newOutbox(m.DB, conf, m)
+	return m
+}
+
+// Run listens to Mattermost events and reconnects when connection is lost.
+// Returns if Mattermost does not accept the token
+func (m *Mattermost) Run() {
+	err := m.authenticate()
+	if err != nil {
+		logrus.Errorf("mattermost: authentication failed: %v", err)
+		return
+	}
+
+	m.UpdateUsersList()
+	m.SendUserMessage(m.Conf.ManagerSlackUserID, m.Conf.Translate.HelloManager)
+
+	gocron.Every(10).Minutes().Do(m.FillStandupsForNonReporters)
+	gocron.Every(1).Day().At("23:55").Do(m.UpdateUsersList)
+	m.runOutbox()
+
+	for {
+		err := m.listen()
+		logrus.Errorf("mattermost: connection lost: %v", err)
+		time.Sleep(mattermostReconnectDelay)
+		err = m.authenticate()
+		if e, ok := err.(*mattermostError); ok && e.StatusCode == http.StatusUnauthorized {
+			logrus.Errorf("mattermost: token is not accepted: %v", err)
+			return
+		}
+	}
+}
+
+// authenticate finds out which user Comedian is
+func (m *Mattermost) authenticate() error {
+	me := mattermostUser{}
+	err := m.api(http.MethodGet, "/users/me", nil, &me)
+	if err != nil {
+		return err
+	}
+	m.botUserID = me.ID
+	m.botName = me.Username
+	return nil
+}
+
+// listen handles events received from WebSocket until connection is closed
+func (m *Mattermost) listen() error {
+	u, err := url.Parse(strings.TrimRight(m.Conf.MattermostURL, "/") + "/api/v4/websocket")
+	if err != nil {
+		return err
+	}
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+m.Conf.MattermostToken)
+	conn, _, err := websocket.DefaultDialer.Dial(u.String(), header)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for {
+		var ev mattermostEvent
+		err := conn.ReadJSON(&ev)
+		if err != nil {
+			return err
+		}
+		m.handleEvent(ev)
+	}
+}
+
+func (m *Mattermost) handleEvent(ev mattermostEvent) {
+	switch ev.Event {
+	case "posted", "post_edited", "post_deleted":
+		data, _ := ev.Data["post"].(string)
+		var post mattermostPost
+		err := json.Unmarshal([]byte(data), &post)
+		if err != nil {
+			logrus.Errorf("mattermost: could not parse %v event: %v", ev.Event, err)
+			return
+		}
+		// system messages have their own types, e.g. system_join_channel
+		if post.UserID == m.botUserID || post.Type != "" {
+			return
+		}
+		msg := message{post.ChannelID, post.UserID, post.Message, post.ID}
+		switch ev.Event {
+		case "posted":
+			if channelType, _ := ev.Data["channel_type"].(string); channelType == "D" {
+				m.handleDirect(msg)
+				return
+			}
+			m.handlePosted(msg, "@"+m.botName)
+		case "post_edited":
+			m.handleEdited(msg, "@"+m.botName)
+		case "post_deleted":
+			m.handleDeleted(post.ID)
+		}
+	case "user_added":
+		userID, _ := ev.Data["user_id"].(string)
+		m.handleMemberJoined(ev.Broadcast.ChannelID, userID, userID == m.botUserID)
+	case "user_removed":
+		// removed user gets the event with channel, channel members get it with removed user
+		userID, _ := ev.Data["user_id"].(string)
+		channelID, _ := ev.Data["channel_id"].(string)
+		switch {
+		case userID == m.botUserID:
+			m.setChannelStatus(ev.Broadcast.ChannelID, model.ChannelLeft)
+		case ev.Broadcast.UserID == m.botUserID && channelID != "":
+			m.setChannelStatus(channelID, model.ChannelLeft)
+		case userID != "":
+			m.handleMemberLeft(ev.Broadcast.ChannelID, userID)
+		}
+	}
+}
+
+// api calls Mattermost REST API method and decodes its response into result
+func (m *Mattermost) api(method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(m.Conf.MattermostURL, "/")+"/api/v4"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.Conf.MattermostToken)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		e := &mattermostError{StatusCode: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(e)
+		if seconds, err := strconv.Atoi(resp.Header.Get("X-Ratelimit-Reset")); err == nil {
+			e.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return e
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// SendMessage posts a message in a specified channel visible for everyone
+func (m *Mattermost) SendMessage(channel, message string, attachments []Attachment) error {
+	return m.outbox.send(model.OutgoingMessage{Kind: model.MessageToChannel, ChannelID: channel, Text: message}, attachments)
+}
+
+func (m *Mattermost) deliverMessage(channel, message string, attachments []Attachment) error {
+	post := mattermostPost{ChannelID: channel, Message: m.format(message)}
+	if len(attachments) > 0 {
+		post.Props = map[string]interface{}{"attachments": m.attachments(attachments)}
+	}
+	err := m.api(http.MethodPost, "/posts", post, nil)
+	if err != nil {
+		logrus.Errorf("mattermost: create post failed: %v\n", err)
+	}
+	return err
+}
+
+func (m *Mattermost) postMessage(channelID, text string) (string, error) {
+	post := mattermostPost{}
+	err := m.api(http.MethodPost, "/posts", mattermostPost{ChannelID: channelID, Message: m.format(text)}, &post)
+	return post.ID, err
+}
+
+// SendEphemeralMessage posts a message in a specified channel which is visible only for selected user
+func (m *Mattermost) SendEphemeralMessage(channel, user, message string) error {
+	return m.outbox.send(model.OutgoingMessage{Kind: model.MessageEphemeral, ChannelID: channel, UserID: user, Text: message}, nil)
+}
+
+func (m *Mattermost) deliverEphemeralMessage(channel, user, message string) error {
+	err := m.api(http.MethodPost, "/posts/ephemeral", map[string]interface{}{
+		"user_id": user,
+		"post":    mattermostPost{ChannelID: channel, Message: m.format(message)},
+	}, nil)
+	if err != nil {
+		logrus.Errorf("mattermost: create ephemeral post failed: %v\n", err)
+	}
+	return err
+}
+
+// SendUserMessage sends direct message to user
+func (m *Mattermost) SendUserMessage(userID, message string) error {
+	return m.outbox.send(model.OutgoingMessage{Kind: model.MessageToUser, UserID: userID, Text: message}, nil)
+}
+
+func (m *Mattermost) deliverUserMessage(userID, message string) error {
+	if m.botUserID == "" {
+		err := m.authenticate()
+		if err != nil {
+			return err
+		}
+	}
+	channel := mattermostChannel{}
+	err := m.api(http.MethodPost, "/channels/direct", []string{m.botUserID, userID}, &channel)
+	if err != nil {
+		return err
+	}
+	return m.deliverMessage(channel.ID, message, nil)
+}
+
+func (m *Mattermost) markStandup(channelID, messageID string) {
+	err := m.api(http.MethodPost, "/reactions", map[string]string{
+		"user_id":    m.botUserID,
+		"post_id":    messageID,
+		"emoji_name": "heavy_check_mark",
+	}, nil)
+	if err != nil {
+		logrus.Errorf("mattermost: save reaction failed: %v\n", err)
+	}
+}
+
+func (m *Mattermost) channelName(channelID string) (string, error) {
+	channel := mattermostChannel{}
+	err := m.api(http.MethodGet, "/channels/"+channelID, nil, &channel)
+	return channel.Name, err
+}
+
+func (m *Mattermost) channelMembers(channelID string) ([]string, error) {
+	userIDs := []string{}
+	for page := 0; ; page++ {
+		members := []struct {
+			UserID string `json:"user_id"`
+		}{}
+		err := m.api(http.MethodGet, fmt.Sprintf("/channels/%v/members?page=%v&per_page=200", channelID, page), nil, &members)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			userIDs = append(userIDs, member.UserID)
+		}
+		if len(members) < 200 {
+			return userIDs, nil
+		}
+	}
+}
+
+//UpdateUsersList updates users of the team
+func (m *Mattermost) UpdateUsersList() {
+	for page := 0; ; page++ {
+		path := fmt.Sprintf("/users?page=%v&per_page=200", page)
+		if m.Conf.TeamID != "" {
+			path += "&in_team=" + url.QueryEscape(m.Conf.TeamID)
+		}
+		users := []mattermostUser{}
+		err := m.api(http.MethodGet, path, nil, &users)
+		if err != nil {
+			logrus.Errorf("mattermost: get users failed: %v", err)
+			return
+		}
+		for _, user := range users {
+			if user.IsBot || user.ID == m.botUserID {
+				continue
+			}
+			m.updateUser(user.ID, user.Username, user.timeZone(), strings.Contains(user.Roles, "system_admin"), user.DeleteAt > 0)
+		}
+		if len(users) < 200 {
+			break
+		}
+	}
+	logrus.Info("Users list updated successfully")
+}
+
+var slackMarkup = regexp.MustCompile(`<([@#!])([^>|]+)(?:\|([^>]*))?>`)
+
+// format turns Slack markup of Comedian messages into Mattermost one: <@ID> becomes
+// @username, <#ID|name> becomes ~name
+func (m *Mattermost) format(text string) string {
+	return slackMarkup.ReplaceAllStringFunc(text, func(s string) string {
+		parts := slackMarkup.FindStringSubmatch(s)
+		kind, id, label := parts[1], parts[2], parts[3]
+		switch kind {
+		case "@":
+			if user, err := m.DB.SelectUser(id); err == nil {
+				return "@" + user.UserName
+			}
+			if label != "" {
+				return "@" + label
+			}
+			return "@" + id
+		case "#":
+			if label != "" {
+				return "~" + label
+			}
+			if channel, err := m.DB.SelectChannel(id); err == nil {
+				return "~" + channel.ChannelName
+			}
+			return "~" + id
+		}
+		// <!date^...|fallback>, <!here>
+		if label != "" {
+			return label
+		}
+		return "@" + id
+	})
+}
+
+// mattermostColors are hex values of Slack color names
+var mattermostColors = map[string]string{
+	"good":    "#2eb886",
+	"warning": "#daa038",
+	"danger":  "#a30200",
+}
+
+func (m *Mattermost) attachments(attachments []Attachment) []Attachment {
+	result := []Attachment{}
+	for _, a := range attachments {
+		if color, ok := mattermostColors[a.Color]; ok {
+			a.Color = color
+		}
+		a.Text = m.format(a.Text)
+		fields := []AttachmentField{}
+		for _, f := range a.Fields {
+			f.Value = m.format(f.Value)
+			fields = append(fields, f)
+		}
+		a.Fields = fields
+		// Mattermost buttons call integrations instead of Comedian's interactivity URL
+		a.CallbackID = ""
+		a.Actions = nil
+		result = append(result, a)
+	}
+	return result
+}
+
+// EscapeMentions turns Mattermost mentions in slash command text into the form commands
+// expect: @username becomes <@ID|username>, ~channel becomes #channel
+func (m *Mattermost) EscapeMentions(text string) string {
+	return m.escapeMentions(text)
+}
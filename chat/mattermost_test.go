@@ -0,0 +1,227 @@
+package chat
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/model"
+	"github.com/maddevsio/comedian/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMattermost serves the part of Mattermost API Comedian uses
+type fakeMattermost struct {
+	mu        sync.Mutex
+	posts     []mattermostPost
+	ephemeral []mattermostPost
+	reactions []string
+	events    []mattermostEvent
+}
+
+func (f *fakeMattermost) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid or expired session"})
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api/v4")
+	if path == "/websocket" {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		for _, ev := range f.events {
+			conn.WriteJSON(ev)
+		}
+		conn.Close()
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case path == "/users/me":
+		json.NewEncoder(w).Encode(mattermostUser{ID: "botid", Username: "comedian"})
+	case path == "/users":
+		users := []mattermostUser{}
+		if r.URL.Query().Get("page") == "0" && r.URL.Query().Get("in_team") == "team1" {
+			users = []mattermostUser{
+				{ID: "botid", Username: "comedian"},
+				{ID: "adminid", Username: "admin", Roles: "system_user system_admin", Timezone: map[string]string{"useAutomaticTimezone": "true", "automaticTimezone": "Asia/Bishkek"}},
+				{ID: "userid", Username: "user", Roles: "system_user", Timezone: map[string]string{"useAutomaticTimezone": "false", "automaticTimezone": "Asia/Bishkek", "manualTimezone": "Europe/Berlin"}},
+				{ID: "helperid", Username: "helper", IsBot: true},
+			}
+		}
+		json.NewEncoder(w).Encode(users)
+	case path == "/posts":
+		var post mattermostPost
+		json.NewDecoder(r.Body).Decode(&post)
+		f.posts = append(f.posts, post)
+		json.NewEncoder(w).Encode(post)
+	case path == "/posts/ephemeral":
+		var req struct {
+			UserID string         `json:"user_id"`
+			Post   mattermostPost `json:"post"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		req.Post.UserID = req.UserID
+		f.ephemeral = append(f.ephemeral, req.Post)
+		json.NewEncoder(w).Encode(req.Post)
+	case path == "/channels/direct":
+		var ids []string
+		json.NewDecoder(r.Body).Decode(&ids)
+		json.NewEncoder(w).Encode(mattermostChannel{ID: "dm_" + strings.Join(ids, "_")})
+	case path == "/reactions":
+		var reaction map[string]string
+		json.NewDecoder(r.Body).Decode(&reaction)
+		f.reactions = append(f.reactions, reaction["post_id"]+":"+reaction["emoji_name"])
+		json.NewEncoder(w).Encode(reaction)
+	case strings.HasPrefix(path, "/channels/"):
+		id := strings.TrimPrefix(path, "/channels/")
+		json.NewEncoder(w).Encode(mattermostChannel{ID: id, Name: "name-of-" + id})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func postEvent(event string, post mattermostPost) mattermostEvent {
+	data, _ := json.Marshal(post)
+	return mattermostEvent{Event: event, Data: map[string]interface{}{"post": string(data)}}
+}
+
+func newTestMattermost(t *testing.T, f *fakeMattermost) (*Mattermost, *httptest.Server) {
+	server := httptest.NewServer(f)
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	conf := config.Config{
+		Platform:        config.PlatformMattermost,
+		TeamID:          "team1",
+		MattermostURL:   server.URL + "/",
+		MattermostToken: "token",
+		Translate:       translation,
+	}
+	return NewMattermost(conf, storage.NewMemory()), server
+}
+
+func TestMattermostSendMessages(t *testing.T) {
+	f := &fakeMattermost{}
+	m, server := newTestMattermost(t, f)
+	defer server.Close()
+
+	_, err := m.DB.CreateUser(model.User{UserID: "userid", UserName: "user"})
+	assert.NoError(t, err)
+
+	err = m.SendMessage("channel1", "Hello, <@userid> and <@unknown>! See <#C1|general>", []Attachment{
+		{Color: "good", Text: "<@userid> in #general", Fields: []AttachmentField{{Value: "<@userid> did well"}}},
+	})
+	assert.NoError(t, err)
+	err = m.SendEphemeralMessage("channel1", "userid", "Only for <@userid>")
+	assert.NoError(t, err)
+	err = m.SendUserMessage("userid", "Hello!")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, len(f.posts))
+	assert.Equal(t, "channel1", f.posts[0].ChannelID)
+	assert.Equal(t, "Hello, @user and @unknown! See ~general", f.posts[0].Message)
+	attachments := f.posts[0].Props["attachments"].([]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"color":  "#2eb886",
+		"text":   "@user in #general",
+		"fields": []interface{}{map[string]interface{}{"title": "", "value": "@user did well", "short": false}},
+	}, attachments[0])
+	assert.Equal(t, []mattermostPost{{ChannelID: "channel1", UserID: "userid", Message: "Only for @user"}}, f.ephemeral)
+	assert.Equal(t, "dm_botid_userid", f.posts[1].ChannelID)
+	assert.Equal(t, "Hello!", f.posts[1].Message)
+
+	m.Conf.MattermostToken = "wrong"
+	err = m.SendMessage("channel1", "Hello", nil)
+	assert.Equal(t, &mattermostError{StatusCode: http.StatusUnauthorized, Message: "Invalid or expired session"}, err)
+}
+
+func TestMattermostUpdateUsersList(t *testing.T) {
+	m, server := newTestMattermost(t, &fakeMattermost{})
+	defer server.Close()
+	assert.NoError(t, m.authenticate())
+
+	m.UpdateUsersList()
+	users, err := m.DB.ListUsers()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(users))
+	admin, err := m.DB.SelectUser("adminid")
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", admin.Role)
+	assert.Equal(t, "Asia/Bishkek", admin.TimeZone)
+	user, err := m.DB.SelectUser("userid")
+	assert.NoError(t, err)
+	assert.Equal(t, "user", user.UserName)
+	assert.Equal(t, "Europe/Berlin", user.TimeZone)
+	assert.Equal(t, "", user.Role)
+	assert.Equal(t, "team1", user.TeamID)
+}
+
+func TestMattermostListen(t *testing.T) {
+	standup := "@comedian yesterday: fixed bugs, today: tests, problems: none"
+	f := &fakeMattermost{events: []mattermostEvent{
+		{Event: "user_added", Data: map[string]interface{}{"user_id": "botid"}, Broadcast: mattermostBroadcast{ChannelID: "channel1"}},
+		postEvent("posted", mattermostPost{ID: "post0", ChannelID: "channel1", UserID: "botid", Message: standup}),
+		postEvent("posted", mattermostPost{ID: "post1", ChannelID: "channel1", UserID: "userid", Message: "just chatting"}),
+		postEvent("posted", mattermostPost{ID: "post2", ChannelID: "channel1", UserID: "userid", Message: standup}),
+		postEvent("post_edited", mattermostPost{ID: "post2", ChannelID: "channel1", UserID: "userid", Message: standup + " at all"}),
+		postEvent("post_deleted", mattermostPost{ID: "post2", ChannelID: "channel1", UserID: "userid"}),
+	}}
+	m, server := newTestMattermost(t, f)
+	defer server.Close()
+	assert.NoError(t, m.authenticate())
+
+	assert.Error(t, m.listen())
+
+	channel, err := m.DB.SelectChannel("channel1")
+	assert.NoError(t, err)
+	assert.Equal(t, "name-of-channel1", channel.ChannelName)
+
+	_, err = m.DB.SelectStandupByMessageTS("post2")
+	assert.Error(t, err)
+	deleted, err := m.DB.SelectDeletedStandup("userid", "channel1", time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, standup+" at all", deleted.Comment)
+	assert.Equal(t, "none at all", deleted.Sections[model.SectionBlockers])
+
+	assert.Equal(t, []string{"post2:heavy_check_mark"}, f.reactions)
+	assert.Equal(t, 2, len(f.ephemeral))
+	assert.Equal(t, m.Conf.Translate.StandupHandleCreatedStandup, f.ephemeral[0].Message)
+	assert.Equal(t, m.Conf.Translate.StandupHandleUpdatedStandup, f.ephemeral[1].Message)
+}
+
+func TestMattermostRunWithWrongToken(t *testing.T) {
+	m, server := newTestMattermost(t, &fakeMattermost{})
+	defer server.Close()
+	m.Conf.MattermostToken = "wrong"
+	// returns instead of reconnecting forever
+	m.Run()
+}
+
+func TestEscapeMentions(t *testing.T) {
+	m, server := newTestMattermost(t, &fakeMattermost{})
+	defer server.Close()
+	_, err := m.DB.CreateUser(model.User{UserID: "userid", UserName: "john.doe"})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		text    string
+		escaped string
+	}{
+		{"@john.doe / pm", "<@userid|john.doe> / pm"},
+		{"~town-square @john.doe 2018-01-01 2018-01-31", "#town-square <@userid|john.doe> 2018-01-01 2018-01-31"},
+		{"@unknown", "@unknown"},
+		{"john@example.com", "john@example.com"},
+	}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.escaped, m.EscapeMentions(tt.text))
+	}
+}
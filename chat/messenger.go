@@ -0,0 +1,70 @@
+package chat
+
+import (
+	"fmt"
+
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/storage"
+)
+
+// Messenger is a chat platform Comedian collects standups in. Notifier, reporter and
+// REST API work with every platform through it
+type Messenger interface {
+	// Run listens to messages of the platform until connection is lost
+	Run()
+	// SendMessage posts a message in a specified channel visible for everyone
+	SendMessage(channel, message string, attachments []Attachment) error
+	// SendEphemeralMessage posts a message in a specified channel which is visible only for selected user
+	SendEphemeralMessage(channel, user, message string) error
+	// SendUserMessage sends direct message to user
+	SendUserMessage(userID, message string) error
+	// EnrollMembers adds everyone in channel with auto-enroll as developers, returns number of added members
+	EnrollMembers(channelID string) (int, error)
+	// ScheduleChanged tells notifier that deadlines or timetables of workspace have changed
+	ScheduleChanged()
+	// ScheduleChanges receives a value when deadlines or timetables of workspace change
+	ScheduleChanges() <-chan struct{}
+	// Storage returns storage of messenger's workspace
+	Storage() storage.Storage
+	// Config returns configuration of messenger's workspace
+	Config() config.Config
+}
+
+// Attachment is attached to messages with reports. Slack and Mattermost show it the same way,
+// Telegram adds it to message text
+type Attachment struct {
+	Color  string            `json:"color,omitempty"`
+	Text   string            `json:"text,omitempty"`
+	Fields []AttachmentField `json:"fields,omitempty"`
+	// CallbackID and Actions make interactive attachments, only Slack supports them
+	CallbackID string             `json:"callback_id,omitempty"`
+	Actions    []AttachmentAction `json:"actions,omitempty"`
+}
+
+// AttachmentField is a titled value of attachment
+type AttachmentField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// AttachmentAction is a button of attachment
+type AttachmentAction struct {
+	Name  string `json:"name"`
+	Text  string `json:"text"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// NewMessenger creates messenger of the platform workspace conf.TeamID is in
+func NewMessenger(conf config.Config, db storage.Storage) (Messenger, error) {
+	switch conf.Platform {
+	case "", config.PlatformSlack:
+		return NewWorkspaceSlack(conf, db), nil
+	case config.PlatformMattermost:
+		return NewMattermost(conf, db), nil
+	case config.PlatformTelegram:
+		return NewTelegram(conf, db), nil
+	}
+	return nil, fmt.Errorf("unknown platform %v", conf.Platform)
+}
@@ -0,0 +1,344 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maddevsio/comedian/model"
+	"github.com/maddevsio/comedian/storage"
+	"github.com/nlopes/slack"
+	"github.com/sirupsen/logrus"
+)
+
+// Types of interactions Slack sends when users click buttons or submit modals
+const (
+	InteractionMessage        = "interactive_message"
+	InteractionBlockActions   = "block_actions"
+	InteractionViewSubmission = "view_submission"
+)
+
+// CallbackWriteStandup is callback ID of "Write standup" button and of standup modal
+const CallbackWriteStandup = "write_standup"
+
+// Interaction is a payload Slack sends to interactivity request URL
+type Interaction struct {
+	Type       string `json:"type"`
+	CallbackID string `json:"callback_id"`
+	TriggerID  string `json:"trigger_id"`
+	Team       struct {
+		ID string `json:"id"`
+	} `json:"team"`
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Channel struct {
+		ID string `json:"id"`
+	} `json:"channel"`
+	Actions []InteractionAction `json:"actions"`
+	View    View                `json:"view"`
+	// Message is the message with clicked Block Kit button
+	Message struct {
+		TS     string  `json:"ts"`
+		Text   string  `json:"text"`
+		Blocks []Block `json:"blocks"`
+	} `json:"message"`
+}
+
+// InteractionAction is a clicked button. Attachment buttons have names, Block Kit ones have action IDs
+type InteractionAction struct {
+	Name     string `json:"name"`
+	ActionID string `json:"action_id"`
+	BlockID  string `json:"block_id"`
+	Value    string `json:"value"`
+}
+
+// View is a Block Kit modal
+type View struct {
+	Type            string      `json:"type"`
+	CallbackID      string      `json:"callback_id,omitempty"`
+	PrivateMetadata string      `json:"private_metadata,omitempty"`
+	Title           *TextObject `json:"title,omitempty"`
+	Submit          *TextObject `json:"submit,omitempty"`
+	Close           *TextObject `json:"close,omitempty"`
+	Blocks          []Block     `json:"blocks,omitempty"`
+	State           *ViewState  `json:"state,omitempty"`
+}
+
+// ViewState keeps values of modal inputs by block ID and action ID
+type ViewState struct {
+	Values map[string]map[string]ViewStateValue `json:"values"`
+}
+
+// ViewStateValue is a value of modal input, pickers and checkboxes keep what is selected
+type ViewStateValue struct {
+	Value                string   `json:"value"`
+	SelectedTime         string   `json:"selected_time"`
+	SelectedOptions      []Option `json:"selected_options"`
+	SelectedUsers        []string `json:"selected_users"`
+	SelectedConversation string   `json:"selected_conversation"`
+}
+
+// TextObject is a text of Block Kit element
+type TextObject struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Block is a Block Kit layout block
+type Block struct {
+	Type     string        `json:"type"`
+	BlockID  string        `json:"block_id,omitempty"`
+	Text     *TextObject   `json:"text,omitempty"`
+	Label    *TextObject   `json:"label,omitempty"`
+	Element  *BlockElement `json:"element,omitempty"`
+	Hint     *TextObject   `json:"hint,omitempty"`
+	Optional bool          `json:"optional,omitempty"`
+	// Elements are text objects of context blocks and buttons of actions blocks
+	Elements []interface{} `json:"elements,omitempty"`
+}
+
+// BlockElement is an interactive element of a block
+type BlockElement struct {
+	Type         string      `json:"type"`
+	ActionID     string      `json:"action_id"`
+	Text         *TextObject `json:"text,omitempty"`
+	Value        string      `json:"value,omitempty"`
+	Multiline    bool        `json:"multiline,omitempty"`
+	InitialValue string      `json:"initial_value,omitempty"`
+	// Options are choices of checkboxes
+	Options             []Option `json:"options,omitempty"`
+	InitialOptions      []Option `json:"initial_options,omitempty"`
+	InitialTime         string   `json:"initial_time,omitempty"`
+	InitialUsers        []string `json:"initial_users,omitempty"`
+	InitialConversation string   `json:"initial_conversation,omitempty"`
+}
+
+// Option is a choice of checkboxes
+type Option struct {
+	Text  *TextObject `json:"text"`
+	Value string      `json:"value"`
+}
+
+func plainText(text string) *TextObject {
+	return &TextObject{Type: "plain_text", Text: text}
+}
+
+// answerActionID is action ID of answer inputs, blocks are identified by question number
+const answerActionID = "answer"
+
+// standupForm returns questions standup modal asks in the channel
+func (s *Slack) standupForm(channelID string) []model.StandupQuestion {
+	questions, err := s.DB.ListStandupQuestions(channelID)
+	if err != nil {
+		logrus.Errorf("ListStandupQuestions failed: %v", err)
+	}
+	if len(questions) > 0 {
+		return questions
+	}
+	return []model.StandupQuestion{
+		{Question: s.Conf.Translate.StandupModalYesterday, Required: true},
+		{Question: s.Conf.Translate.StandupModalToday, Required: true},
+		{Question: s.Conf.Translate.StandupModalProblems, Required: true},
+	}
+}
+
+// todayStandup returns standup user has already written in channel today in their time zone
+func (s *Slack) todayStandup(userID, channelID string) (model.Standup, error) {
+	now := time.Now().In(storage.MemberLocation(s.DB, userID, channelID))
+	return s.DB.SelectStandupsFiltered(userID, channelID, model.StartOfDay(now), now)
+}
+
+// OpenStandupModal opens modal with a field per standup question of the channel.
+// Standup written today is shown in the fields to be edited
+func (s *Slack) OpenStandupModal(triggerID, channelID, userID string) error {
+	standup, err := s.todayStandup(userID, channelID)
+	sections := model.StandupSections{}
+	if err == nil {
+		sections = s.standupSections(channelID, standup.Comment)
+		if standup.Sections != nil {
+			sections = standup.Sections
+		}
+	}
+	view := View{
+		Type:            "modal",
+		CallbackID:      CallbackWriteStandup,
+		PrivateMetadata: channelID,
+		Title:           plainText(s.Conf.Translate.StandupModalTitle),
+		Submit:          plainText(s.Conf.Translate.StandupModalSubmit),
+		Close:           plainText(s.Conf.Translate.StandupModalClose),
+	}
+	for i, q := range s.standupForm(channelID) {
+		view.Blocks = append(view.Blocks, Block{
+			Type:     "input",
+			BlockID:  fmt.Sprintf("q%v", i),
+			Label:    plainText(q.Question),
+			Optional: !q.Required,
+			Element: &BlockElement{
+				Type:         "plain_text_input",
+				ActionID:     answerActionID,
+				Multiline:    true,
+				InitialValue: sections[model.SectionOf(q.Question)],
+			},
+		})
+	}
+	return s.callAPI("views.open", map[string]interface{}{"trigger_id": triggerID, "view": view})
+}
+
+// SubmitStandupModal posts standup written in modal to the channel on behalf of the user
+// and saves it in background. Returns errors of modal fields if some required questions are not answered
+func (s *Slack) SubmitStandupModal(i Interaction) map[string]string {
+	channelID := i.View.PrivateMetadata
+	userID := i.User.ID
+	fieldErrors := map[string]string{}
+	lines := []string{}
+	sections := model.StandupSections{}
+	for n, q := range s.standupForm(channelID) {
+		blockID := fmt.Sprintf("q%v", n)
+		answer := ""
+		if i.View.State != nil {
+			answer = strings.TrimSpace(i.View.State.Values[blockID][answerActionID].Value)
+		}
+		if answer == "" {
+			if q.Required {
+				fieldErrors[blockID] = s.Conf.Translate.StandupModalEmptyAnswer
+			}
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("*%v*\n%v", q.Question, answer))
+		sections[model.SectionOf(q.Question)] = answer
+	}
+	if len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+	text := strings.Join(lines, "\n")
+
+	// Slack waits for the answer to submission for 3 seconds only, so standup is posted later
+	s.WG.Add(1)
+	go func() {
+		defer s.WG.Done()
+		s.saveModalStandup(channelID, userID, text, sections)
+	}()
+	return nil
+}
+
+// saveModalStandup posts standup written in modal or updates today's one of the user
+func (s *Slack) saveModalStandup(channelID, userID, text string, sections model.StandupSections) {
+	standup, err := s.todayStandup(userID, channelID)
+	if err == nil && standup.Comment != "" {
+		s.updateModalStandup(standup, text, sections)
+		return
+	}
+
+	params := slack.PostMessageParameters{}
+	user, err := s.API.GetUserInfo(userID)
+	if err != nil {
+		logrus.Errorf("slack: GetUserInfo failed: %v\n", err)
+	} else {
+		params.Username = user.RealName
+		if params.Username == "" {
+			params.Username = user.Name
+		}
+		params.IconURL = user.Profile.Image48
+	}
+	_, ts, err := s.API.PostMessage(channelID, text, params)
+	if err != nil {
+		logrus.Errorf("slack: PostMessage failed: %v\n", err)
+		s.SendEphemeralMessage(channelID, userID, s.Conf.Translate.StandupHandleCouldNotSaveStandup)
+		return
+	}
+	standup, err = s.DB.CreateStandup(model.Standup{
+		ChannelID: channelID,
+		UserID:    userID,
+		Comment:   text,
+		MessageTS: ts,
+		Sections:  sections,
+	})
+	if err != nil {
+		logrus.Errorf("CreateStandup failed: %v", err)
+		s.SendEphemeralMessage(channelID, userID, s.Conf.Translate.StandupHandleCouldNotSaveStandup)
+		return
+	}
+	logrus.Infof("Standup created #id:%v\n", standup.ID)
+	s.markStandup(channelID, ts)
+	s.SendEphemeralMessage(channelID, userID, s.Conf.Translate.StandupHandleCreatedStandup)
+}
+
+// updateModalStandup saves standup edited in modal and updates its message
+func (s *Slack) updateModalStandup(standup model.Standup, text string, sections model.StandupSections) {
+	if standup.Comment != text {
+		_, err := s.DB.AddToStandupHistory(model.StandupEditHistory{
+			StandupID:     standup.ID,
+			StandupText:   standup.Comment,
+			AfterDeadline: s.editedAfterDeadline(standup, time.Now()),
+		})
+		if err != nil {
+			logrus.Errorf("AddToStandupHistory failed: %v", err)
+		}
+	}
+	standup.Comment = text
+	standup.Sections = sections
+	st, err := s.DB.UpdateStandup(standup)
+	if err != nil {
+		logrus.Errorf("UpdateStandup failed: %v", err)
+		s.SendEphemeralMessage(standup.ChannelID, standup.UserID, s.Conf.Translate.StandupHandleCouldNotSaveStandup)
+		return
+	}
+	logrus.Infof("Standup updated #id:%v\n", st.ID)
+	// messages written by users cannot be updated by Comedian, their standups are saved anyway
+	_, _, _, err = s.API.UpdateMessage(standup.ChannelID, standup.MessageTS, text)
+	if err != nil {
+		logrus.Errorf("slack: UpdateMessage failed: %v\n", err)
+	}
+	s.SendEphemeralMessage(standup.ChannelID, standup.UserID, s.Conf.Translate.StandupHandleUpdatedStandup)
+}
+
+// callAPI calls Slack Web API method which vendored client does not support
+func (s *Slack) callAPI(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, slack.SLACK_API+method, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.Conf.SlackToken)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return &slack.RateLimitedError{RetryAfter: time.Duration(seconds) * time.Second}
+	}
+	if resp.StatusCode >= 500 {
+		return &slackStatusError{resp.StatusCode}
+	}
+	var r struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	if err != nil {
+		return fmt.Errorf("slack: could not decode %v response: %v", method, err)
+	}
+	if !r.OK {
+		return fmt.Errorf("slack: %v failed: %v", method, r.Error)
+	}
+	return nil
+}
+
+// StandupButton is attached to standup reminders, it opens standup modal
+func StandupButton(channelID, text string) Attachment {
+	return Attachment{
+		CallbackID: CallbackWriteStandup,
+		Actions:    []AttachmentAction{{Name: CallbackWriteStandup, Text: text, Type: "button", Value: channelID}},
+	}
+}
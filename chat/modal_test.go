@@ -0,0 +1,147 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/model"
+	"github.com/maddevsio/comedian/storage"
+	"github.com/stretchr/testify/assert"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+func modalSubmission(channelID, userID string, answers ...string) Interaction {
+	i := Interaction{Type: InteractionViewSubmission}
+	i.User.ID = userID
+	i.View = View{CallbackID: CallbackWriteStandup, PrivateMetadata: channelID, State: &ViewState{Values: map[string]map[string]ViewStateValue{}}}
+	for n, answer := range answers {
+		i.View.State.Values[fmt.Sprintf("q%v", n)] = map[string]ViewStateValue{"answer": {Value: answer}}
+	}
+	return i
+}
+
+func TestStandupModal(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var view View
+	httpmock.RegisterResponder("POST", "https://slack.com/api/views.open", func(req *http.Request) (*http.Response, error) {
+		var params struct {
+			TriggerID string `json:"trigger_id"`
+			View      View   `json:"view"`
+		}
+		json.NewDecoder(req.Body).Decode(&params)
+		assert.Equal(t, "trigger1", params.TriggerID)
+		view = params.View
+		return httpmock.NewStringResponse(200, `{"ok": true}`), nil
+	})
+	posted := []url.Values{}
+	httpmock.RegisterResponder("POST", "https://slack.com/api/chat.postMessage", func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		form, _ := url.ParseQuery(string(body))
+		posted = append(posted, form)
+		return httpmock.NewStringResponse(200, `{"ok": true, "channel": "CHAN1", "ts": "1500000000.000100"}`), nil
+	})
+	updated := []url.Values{}
+	httpmock.RegisterResponder("POST", "https://slack.com/api/chat.update", func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		form, _ := url.ParseQuery(string(body))
+		updated = append(updated, form)
+		return httpmock.NewStringResponse(200, `{"ok": true, "channel": "CHAN1", "ts": "1500000000.000100"}`), nil
+	})
+	httpmock.RegisterResponder("POST", "https://slack.com/api/users.info", httpmock.NewStringResponder(200, `{"ok": true, "user": {"id": "USER1", "name": "john", "real_name": "John Doe", "profile": {"image_48": "https://example.com/john.png"}}}`))
+	httpmock.RegisterResponder("POST", "https://slack.com/api/reactions.add", httpmock.NewStringResponder(200, `{"ok": true}`))
+	httpmock.RegisterResponder("POST", "https://slack.com/api/chat.postEphemeral", httpmock.NewStringResponder(200, `{"ok": true}`))
+
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	s := NewWorkspaceSlack(config.Config{TeamID: "team1", SlackToken: "token", Translate: translation}, storage.NewMemory())
+
+	assert.NoError(t, s.OpenStandupModal("trigger1", "CHAN1", "USER1"))
+	assert.Equal(t, "modal", view.Type)
+	assert.Equal(t, "CHAN1", view.PrivateMetadata)
+	assert.Equal(t, 3, len(view.Blocks))
+	assert.Equal(t, "Yesterday", view.Blocks[0].Label.Text)
+	assert.Equal(t, "", view.Blocks[0].Element.InitialValue)
+
+	fieldErrors := s.SubmitStandupModal(modalSubmission("CHAN1", "USER1", "fixed bugs", " ", ""))
+	assert.Equal(t, map[string]string{"q1": translation.StandupModalEmptyAnswer, "q2": translation.StandupModalEmptyAnswer}, fieldErrors)
+	assert.Equal(t, 0, len(posted))
+
+	fieldErrors = s.SubmitStandupModal(modalSubmission("CHAN1", "USER1", "fixed bugs", "tests", "none"))
+	assert.Equal(t, 0, len(fieldErrors))
+	s.WG.Wait()
+	assert.Equal(t, 1, len(posted))
+	assert.Equal(t, "CHAN1", posted[0].Get("channel"))
+	assert.Equal(t, "*Yesterday*\nfixed bugs\n*Today*\ntests\n*Problems*\nnone", posted[0].Get("text"))
+	assert.Equal(t, "John Doe", posted[0].Get("username"))
+	assert.Equal(t, "https://example.com/john.png", posted[0].Get("icon_url"))
+
+	standup, err := s.DB.SelectStandupByMessageTS("1500000000.000100")
+	assert.NoError(t, err)
+	assert.Equal(t, "USER1", standup.UserID)
+	assert.Equal(t, "CHAN1", standup.ChannelID)
+	assert.Equal(t, model.StandupSections{"yesterday": "fixed bugs", "today": "tests", "blockers": "none"}, standup.Sections)
+
+	// the second modal of the day edits the standup
+	assert.NoError(t, s.OpenStandupModal("trigger1", "CHAN1", "USER1"))
+	assert.Equal(t, "fixed bugs", view.Blocks[0].Element.InitialValue)
+	assert.Equal(t, "none", view.Blocks[2].Element.InitialValue)
+
+	fieldErrors = s.SubmitStandupModal(modalSubmission("CHAN1", "USER1", "fixed bugs", "more tests", "none"))
+	assert.Equal(t, 0, len(fieldErrors))
+	s.WG.Wait()
+	assert.Equal(t, 1, len(posted))
+	assert.Equal(t, 1, len(updated))
+	assert.Equal(t, "1500000000.000100", updated[0].Get("ts"))
+	standup, err = s.DB.SelectStandupByMessageTS("1500000000.000100")
+	assert.NoError(t, err)
+	assert.Equal(t, "more tests", standup.Sections["today"])
+	history, err := s.DB.ListStandupHistory(standup.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(history))
+
+	// deleting the message deletes the standup
+	s.handleDeleted("1500000000.000100")
+	_, err = s.DB.SelectStandupByMessageTS("1500000000.000100")
+	assert.Error(t, err)
+}
+
+func TestStandupModalWithChannelQuestions(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var view View
+	httpmock.RegisterResponder("POST", "https://slack.com/api/views.open", func(req *http.Request) (*http.Response, error) {
+		var params struct {
+			View View `json:"view"`
+		}
+		json.NewDecoder(req.Body).Decode(&params)
+		view = params.View
+		return httpmock.NewStringResponse(200, `{"ok": false, "error": "expired_trigger_id"}`), nil
+	})
+
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	s := NewWorkspaceSlack(config.Config{TeamID: "team1", SlackToken: "token", Translate: translation}, storage.NewMemory())
+	_, err = s.DB.CreateStandupQuestion(model.StandupQuestion{ChannelID: "CHAN1", Question: "Done", Required: true})
+	assert.NoError(t, err)
+	_, err = s.DB.CreateStandupQuestion(model.StandupQuestion{ChannelID: "CHAN1", Question: "Mood"})
+	assert.NoError(t, err)
+
+	err = s.OpenStandupModal("trigger1", "CHAN1", "USER1")
+	assert.EqualError(t, err, "slack: views.open failed: expired_trigger_id")
+	assert.Equal(t, 2, len(view.Blocks))
+	assert.Equal(t, "Done", view.Blocks[0].Label.Text)
+	assert.False(t, view.Blocks[0].Optional)
+	assert.Equal(t, "Mood", view.Blocks[1].Label.Text)
+	assert.True(t, view.Blocks[1].Optional)
+
+	fieldErrors := s.SubmitStandupModal(modalSubmission("CHAN1", "USER1", ""))
+	assert.Equal(t, map[string]string{"q0": translation.StandupModalEmptyAnswer}, fieldErrors)
+}
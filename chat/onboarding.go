@@ -0,0 +1,382 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maddevsio/comedian/model"
+	"github.com/sirupsen/logrus"
+)
+
+// ActionSetupChannel is action ID of "Set up standups" button Comedian sends to user who
+// invited it to channel. CallbackSetupChannel is callback ID of setup modal the button opens
+const (
+	ActionSetupChannel   = "setup_channel"
+	CallbackSetupChannel = "setup_channel"
+)
+
+// Block IDs of setup modal inputs, action IDs of inputs are the same
+const (
+	setupDeadline   = "deadline"
+	setupDays       = "days"
+	setupQuestions  = "questions"
+	setupMembers    = "members"
+	setupAutoEnroll = "auto_enroll"
+	setupReports    = "reports"
+)
+
+// setupDefaultDeadline is a deadline setup modal suggests for channels without one
+const setupDefaultDeadline = "10:00"
+
+// weekdays are values of working days checkboxes
+var weekdays = []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+// offerSetup sends user who invited Comedian to channel a button which opens setup of
+// standups in the channel. Super admin gets it if inviter is unknown. Channels which
+// have standup deadline are set up already and are skipped
+func (s *Slack) offerSetup(channelID, inviterID string) {
+	channel, err := s.DB.SelectChannel(channelID)
+	if err != nil || channel.StandupTime != 0 {
+		return
+	}
+	if inviterID == "" {
+		inviterID = s.Conf.ManagerSlackUserID
+	}
+	if inviterID == "" {
+		return
+	}
+	text := fmt.Sprintf(s.Conf.Translate.SetupOffer, channelID)
+	// messages posted to user ID go to direct messages with Comedian
+	err = s.SendBlocks(inviterID, text, []Block{SectionBlock(text), ButtonBlock(ActionSetupChannel, s.Conf.Translate.SetupButton, channelID)})
+	if err != nil {
+		logrus.Errorf("slack: could not offer setup of %v to %v: %v", channelID, inviterID, err)
+	}
+}
+
+func (s *Slack) weekdayOptions() []Option {
+	names := strings.Split(s.Conf.Translate.SetupWeekdays, ",")
+	options := []Option{}
+	for i, day := range weekdays {
+		name := day
+		if i < len(names) {
+			name = strings.TrimSpace(names[i])
+		}
+		options = append(options, Option{Text: plainText(name), Value: day})
+	}
+	return options
+}
+
+// setupMembers returns users setup modal suggests to enroll: members of channel if it
+// has some, everyone in the channel Comedian knows otherwise
+func (s *Slack) setupMembers(channelID string) []string {
+	users := []string{}
+	members, err := s.DB.ListChannelMembers(channelID)
+	if err != nil {
+		logrus.Errorf("ListChannelMembers failed: %v", err)
+	}
+	for _, m := range members {
+		users = append(users, m.UserID)
+	}
+	if len(users) > 0 {
+		return users
+	}
+	userIDs, err := s.channelMembers(channelID)
+	if err != nil {
+		logrus.Errorf("slack: channelMembers failed: %v", err)
+	}
+	for _, userID := range userIDs {
+		if _, err := s.DB.SelectUser(userID); err == nil {
+			users = append(users, userID)
+		}
+	}
+	return users
+}
+
+// OpenSetupModal opens modal which sets up deadline, working days, questions, standupers
+// and report channel of channel. Current settings of the channel are filled in
+func (s *Slack) OpenSetupModal(triggerID, channelID string) error {
+	channel, err := s.DB.SelectChannel(channelID)
+	if err != nil {
+		return err
+	}
+	deadline := setupDefaultDeadline
+	if channel.StandupTime != 0 {
+		deadline = time.Unix(channel.StandupTime, 0).In(channel.Location()).Format("15:04")
+	}
+	questions, err := s.DB.ListStandupQuestions(channelID)
+	if err != nil {
+		logrus.Errorf("ListStandupQuestions failed: %v", err)
+	}
+	lines := []string{}
+	for _, q := range questions {
+		lines = append(lines, q.Text())
+	}
+	days := s.weekdayOptions()
+	workingDays := []Option{}
+	for _, day := range days {
+		if inList(day.Value, strings.Split(channel.Days(), ",")) {
+			workingDays = append(workingDays, day)
+		}
+	}
+	autoEnroll := Option{Text: plainText(s.Conf.Translate.SetupAutoEnrollOption), Value: setupAutoEnroll}
+	autoEnrollElement := &BlockElement{Type: "checkboxes", ActionID: setupAutoEnroll, Options: []Option{autoEnroll}}
+	if channel.AutoEnroll {
+		autoEnrollElement.InitialOptions = []Option{autoEnroll}
+	}
+	view := View{
+		Type:            "modal",
+		CallbackID:      CallbackSetupChannel,
+		PrivateMetadata: channelID,
+		Title:           plainText(s.Conf.Translate.SetupTitle),
+		Submit:          plainText(s.Conf.Translate.SetupSubmit),
+		Close:           plainText(s.Conf.Translate.StandupModalClose),
+		Blocks: []Block{
+			{
+				Type:    "input",
+				BlockID: setupDeadline,
+				Label:   plainText(s.Conf.Translate.SetupDeadline),
+				Element: &BlockElement{Type: "timepicker", ActionID: setupDeadline, InitialTime: deadline},
+			},
+			{
+				Type:    "input",
+				BlockID: setupDays,
+				Label:   plainText(s.Conf.Translate.SetupDays),
+				Element: &BlockElement{Type: "checkboxes", ActionID: setupDays, Options: days, InitialOptions: workingDays},
+			},
+			{
+				Type:     "input",
+				BlockID:  setupQuestions,
+				Label:    plainText(s.Conf.Translate.SetupQuestions),
+				Hint:     plainText(s.Conf.Translate.SetupQuestionsHint),
+				Optional: true,
+				Element:  &BlockElement{Type: "plain_text_input", ActionID: setupQuestions, Multiline: true, InitialValue: strings.Join(lines, "\n")},
+			},
+			{
+				Type:     "input",
+				BlockID:  setupMembers,
+				Label:    plainText(s.Conf.Translate.SetupMembers),
+				Optional: true,
+				Element:  &BlockElement{Type: "multi_users_select", ActionID: setupMembers, InitialUsers: s.setupMembers(channelID)},
+			},
+			{
+				Type:     "input",
+				BlockID:  setupAutoEnroll,
+				Label:    plainText(s.Conf.Translate.SetupAutoEnroll),
+				Optional: true,
+				Element:  autoEnrollElement,
+			},
+			{
+				Type:    "input",
+				BlockID: setupReports,
+				Label:   plainText(s.Conf.Translate.SetupReports),
+				Element: &BlockElement{Type: "conversations_select", ActionID: setupReports, InitialConversation: channel.ReportChannel()},
+			},
+		},
+	}
+	return s.callAPI("views.open", map[string]interface{}{"trigger_id": triggerID, "view": view})
+}
+
+// setupValue returns value of setup modal field
+func setupValue(i Interaction, blockID string) ViewStateValue {
+	if i.View.State == nil {
+		return ViewStateValue{}
+	}
+	return i.View.State.Values[blockID][blockID]
+}
+
+// SubmitSetupModal saves settings of channel from setup modal in background and tells user
+// who submitted it how the channel is set up. Returns errors of modal fields if some of them are wrong
+func (s *Slack) SubmitSetupModal(i Interaction) map[string]string {
+	channelID := i.View.PrivateMetadata
+	value := func(blockID string) ViewStateValue {
+		return setupValue(i, blockID)
+	}
+
+	fieldErrors := map[string]string{}
+	clock, err := time.Parse("15:04", value(setupDeadline).SelectedTime)
+	if err != nil {
+		fieldErrors[setupDeadline] = err.Error()
+	}
+	days := []string{}
+	for _, o := range value(setupDays).SelectedOptions {
+		days = append(days, o.Value)
+	}
+	if len(days) == 0 {
+		fieldErrors[setupDays] = s.Conf.Translate.SetupNoDays
+	}
+	questions := []model.StandupQuestion{}
+	for _, line := range strings.Split(value(setupQuestions).Value, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		q, err := model.ParseStandupQuestion(channelID, line)
+		if err != nil {
+			fieldErrors[setupQuestions] = fmt.Sprintf(s.Conf.Translate.SetupWrongQuestion, strings.TrimSpace(line))
+			break
+		}
+		questions = append(questions, q)
+	}
+	if len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+
+	// Slack waits for the answer to submission for 3 seconds only, so channel is set up later
+	s.WG.Add(1)
+	go func() {
+		defer s.WG.Done()
+		s.setupChannel(i, clock, days, questions)
+	}()
+	return nil
+}
+
+// setupChannel saves settings of channel submitted in setup modal
+func (s *Slack) setupChannel(i Interaction, clock time.Time, days []string, questions []model.StandupQuestion) {
+	channelID := i.View.PrivateMetadata
+	value := func(blockID string) ViewStateValue {
+		return setupValue(i, blockID)
+	}
+	channel, err := s.DB.SelectChannel(channelID)
+	if err != nil {
+		logrus.Errorf("SelectChannel failed: %v", err)
+		s.SendUserMessage(i.User.ID, s.Conf.Translate.SomethingWentWrong)
+		return
+	}
+	// deadline is picked in time zone of the user who sets channel up unless channel has its own
+	if channel.TimeZone == "" {
+		user, err := s.DB.SelectUser(i.User.ID)
+		if err == nil {
+			channel.TimeZone = user.TimeZone
+		}
+	}
+	now := time.Now().In(channel.Location())
+	// standup time is kept as today's time like /standup_time_set does
+	deadline := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	err = s.DB.CreateStandupTime(deadline.Unix(), channelID)
+	if err != nil {
+		logrus.Errorf("CreateStandupTime failed: %v", err)
+		s.SendUserMessage(i.User.ID, s.Conf.Translate.SomethingWentWrong)
+		return
+	}
+	s.setupQuestions(channelID, questions)
+	standupers := s.setupStandupers(channelID, value(setupMembers).SelectedUsers)
+
+	channel.WorkingDays, _ = model.ParseWorkingDays(strings.Join(days, ","))
+	channel.AutoEnroll = len(value(setupAutoEnroll).SelectedOptions) > 0
+	channel.ReportChannelID = value(setupReports).SelectedConversation
+	if channel.ReportChannelID == channelID {
+		channel.ReportChannelID = ""
+	}
+	channel, err = s.DB.UpdateChannel(channel)
+	if err != nil {
+		logrus.Errorf("UpdateChannel failed: %v", err)
+	}
+	if channel.AutoEnroll {
+		s.excludeNotPicked(channelID, value(setupMembers).SelectedUsers)
+	}
+	s.ScheduleChanged()
+
+	s.SendUserMessage(i.User.ID, fmt.Sprintf(s.Conf.Translate.SetupDone, channelID, deadline.Format("15:04"), standupers, channel.ReportChannel()))
+}
+
+// setupQuestions makes questions of channel the same as in setup modal. Questions which
+// are not changed are kept
+func (s *Slack) setupQuestions(channelID string, questions []model.StandupQuestion) {
+	existing, err := s.DB.ListStandupQuestions(channelID)
+	if err != nil {
+		logrus.Errorf("ListStandupQuestions failed: %v", err)
+		return
+	}
+	kept := map[string]bool{}
+	for _, q := range existing {
+		kept[q.Text()] = false
+	}
+	for _, q := range questions {
+		if _, ok := kept[q.Text()]; ok {
+			kept[q.Text()] = true
+			continue
+		}
+		_, err := s.DB.CreateStandupQuestion(q)
+		if err != nil {
+			logrus.Errorf("CreateStandupQuestion failed: %v", err)
+		}
+	}
+	for _, q := range existing {
+		if kept[q.Text()] {
+			continue
+		}
+		err := s.DB.DeleteStandupQuestion(q.ID)
+		if err != nil {
+			logrus.Errorf("DeleteStandupQuestion failed: %v", err)
+		}
+	}
+}
+
+// setupStandupers adds picked users to channel as developers and removes members who are not
+// picked any more. Users Comedian does not know, bots among them, are skipped. Returns number
+// of standupers of the channel
+func (s *Slack) setupStandupers(channelID string, userIDs []string) int {
+	for _, userID := range userIDs {
+		if _, err := s.DB.SelectUser(userID); err != nil {
+			continue
+		}
+		if _, err := s.DB.FindChannelMemberByUserID(userID, channelID); err == nil {
+			continue
+		}
+		_, err := s.DB.CreateChannelMember(model.ChannelMember{
+			UserID:        userID,
+			ChannelID:     channelID,
+			RoleInChannel: "developer",
+		})
+		if err != nil {
+			logrus.Errorf("CreateChannelMember failed: %v", err)
+		}
+	}
+	members, err := s.DB.ListChannelMembers(channelID)
+	if err != nil {
+		logrus.Errorf("ListChannelMembers failed: %v", err)
+	}
+	standupers := 0
+	for _, m := range members {
+		if inList(m.UserID, userIDs) {
+			standupers++
+			continue
+		}
+		err := s.removeMember(m)
+		if err != nil {
+			logrus.Errorf("DeleteChannelMember failed: %v", err)
+		}
+	}
+	return standupers
+}
+
+// excludeNotPicked excludes people in channel who are not picked as standupers from
+// auto-enroll, so that they are not enrolled later
+func (s *Slack) excludeNotPicked(channelID string, picked []string) {
+	userIDs, err := s.channelMembers(channelID)
+	if err != nil {
+		logrus.Errorf("slack: channelMembers failed: %v", err)
+		return
+	}
+	for _, userID := range userIDs {
+		if inList(userID, picked) {
+			continue
+		}
+		if _, err := s.DB.FindChannelMemberByUserID(userID, channelID); err == nil {
+			continue
+		}
+		err := s.DB.ExcludeFromAutoEnroll(channelID, userID)
+		if err != nil {
+			logrus.Errorf("ExcludeFromAutoEnroll failed: %v", err)
+		}
+	}
+}
+
+func inList(item string, list []string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
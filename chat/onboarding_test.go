@@ -0,0 +1,148 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/model"
+	"github.com/maddevsio/comedian/storage"
+	"github.com/stretchr/testify/assert"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+func TestSetupChannel(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var offer struct {
+		Channel string  `json:"channel"`
+		Blocks  []Block `json:"blocks"`
+	}
+	directMessages := []string{}
+	httpmock.RegisterResponder("POST", "https://slack.com/api/chat.postMessage", func(req *http.Request) (*http.Response, error) {
+		if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+			json.NewDecoder(req.Body).Decode(&offer)
+		} else {
+			req.ParseForm()
+			directMessages = append(directMessages, req.Form.Get("text"))
+		}
+		return httpmock.NewStringResponse(200, `{"ok": true}`), nil
+	})
+	httpmock.RegisterResponder("POST", "https://slack.com/api/im.open",
+		httpmock.NewStringResponder(200, `{"ok": true, "channel": {"id": "D1"}}`))
+	httpmock.RegisterResponder("POST", "https://slack.com/api/conversations.members",
+		httpmock.NewStringResponder(200, `{"ok": true, "members": ["BOT", "U1", "U2", "U3"], "response_metadata": {"next_cursor": ""}}`))
+	var opened struct {
+		TriggerID string `json:"trigger_id"`
+		View      View   `json:"view"`
+	}
+	httpmock.RegisterResponder("POST", "https://slack.com/api/views.open", func(req *http.Request) (*http.Response, error) {
+		json.NewDecoder(req.Body).Decode(&opened)
+		return httpmock.NewStringResponse(200, `{"ok": true}`), nil
+	})
+
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	s := NewWorkspaceSlack(config.Config{TeamID: "team1", SlackToken: "token", Translate: translation}, storage.NewMemory())
+	_, err = s.DB.CreateChannel(model.Channel{ChannelName: "general", ChannelID: "C1"})
+	assert.NoError(t, err)
+	for _, id := range []string{"U1", "U2", "U3"} {
+		_, err := s.DB.CreateUser(model.User{UserID: id, UserName: id, TimeZone: "Europe/Berlin"})
+		assert.NoError(t, err)
+	}
+	_, err = s.DB.CreateStandupQuestion(model.StandupQuestion{ChannelID: "C1", Question: "Old question", Required: true})
+	assert.NoError(t, err)
+	// U3 is a standuper with own deadlines before setup
+	u3, err := s.DB.CreateChannelMember(model.ChannelMember{UserID: "U3", ChannelID: "C1", RoleInChannel: "developer"})
+	assert.NoError(t, err)
+	_, err = s.DB.CreateTimeTable(model.TimeTable{ChannelMemberID: u3.ID, Monday: 12345})
+	assert.NoError(t, err)
+
+	// user who invited Comedian gets a button which opens setup
+	s.offerSetup("C1", "U1")
+	assert.Equal(t, "U1", offer.Channel)
+	assert.Equal(t, 2, len(offer.Blocks))
+	button := offer.Blocks[1].Elements[0].(map[string]interface{})
+	assert.Equal(t, ActionSetupChannel, button["action_id"])
+	assert.Equal(t, "C1", button["value"])
+
+	assert.NoError(t, s.OpenSetupModal("trigger", "C1"))
+	assert.Equal(t, "trigger", opened.TriggerID)
+	assert.Equal(t, CallbackSetupChannel, opened.View.CallbackID)
+	assert.Equal(t, "C1", opened.View.PrivateMetadata)
+	assert.Equal(t, 6, len(opened.View.Blocks))
+	assert.Equal(t, setupDefaultDeadline, opened.View.Blocks[0].Element.InitialTime)
+	assert.Equal(t, 5, len(opened.View.Blocks[1].Element.InitialOptions))
+	assert.Equal(t, "Old question", opened.View.Blocks[2].Element.InitialValue)
+	// standupers of the channel are picked already
+	assert.Equal(t, []string{"U3"}, opened.View.Blocks[3].Element.InitialUsers)
+	assert.Equal(t, "C1", opened.View.Blocks[5].Element.InitialConversation)
+
+	var i Interaction
+	submission := func(days, questions string) {
+		assert.NoError(t, json.Unmarshal([]byte(fmt.Sprintf(`{
+			"type": "view_submission",
+			"user": {"id": "U1"},
+			"view": {"callback_id": "setup_channel", "private_metadata": "C1", "state": {"values": {
+				"deadline": {"deadline": {"type": "timepicker", "selected_time": "11:30"}},
+				"days": {"days": {"type": "checkboxes", "selected_options": [%v]}},
+				"questions": {"questions": {"type": "plain_text_input", "value": %q}},
+				"members": {"members": {"type": "multi_users_select", "selected_users": ["U1", "U2", "BOT"]}},
+				"auto_enroll": {"auto_enroll": {"type": "checkboxes", "selected_options": [{"value": "auto_enroll"}]}},
+				"reports": {"reports": {"type": "conversations_select", "selected_conversation": "C9"}}
+			}}}
+		}`, days, questions)), &i))
+	}
+
+	submission(``, "")
+	assert.Equal(t, map[string]string{setupDays: translation.SetupNoDays}, s.SubmitSetupModal(i))
+	submission(`{"value": "mon"}`, "What is done? / done / optional / wrong")
+	assert.Equal(t, map[string]string{setupQuestions: fmt.Sprintf(translation.SetupWrongQuestion, "What is done? / done / optional / wrong")}, s.SubmitSetupModal(i))
+
+	submission(`{"value": "mon"}, {"value": "sat"}`, "What is done? / done\nBlockers / / optional")
+	assert.Nil(t, s.SubmitSetupModal(i))
+	s.WG.Wait()
+
+	channel, err := s.DB.SelectChannel("C1")
+	assert.NoError(t, err)
+	// deadline is picked in time zone of the user who sets channel up
+	assert.Equal(t, "Europe/Berlin", channel.TimeZone)
+	assert.Equal(t, "11:30", time.Unix(channel.StandupTime, 0).In(channel.Location()).Format("15:04"))
+	assert.True(t, channel.AutoEnroll)
+	assert.Equal(t, "C9", channel.ReportChannel())
+
+	questions, err := s.DB.ListStandupQuestions("C1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(questions))
+	assert.Equal(t, "done", questions[0].Keywords)
+	assert.False(t, questions[1].Required)
+
+	// bots are not enrolled, people not picked are kept from auto-enroll
+	members, err := s.DB.ListChannelMembers("C1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(members))
+	excluded, err := s.DB.ListAutoEnrollExclusions("C1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"U3"}, excluded)
+	// U3 is not picked any more, so U3 is not a standuper and has no deadlines
+	for _, m := range members {
+		assert.NotEqual(t, "U3", m.UserID)
+	}
+	assert.False(t, s.DB.MemberHasTimeTable(u3.ID))
+
+	// channel deadline is on working days, standupers do not need timetables
+	assert.Equal(t, "mon,sat", channel.WorkingDays)
+	assert.False(t, s.DB.MemberHasTimeTable(members[0].ID))
+
+	assert.Equal(t, []string{fmt.Sprintf(translation.SetupDone, "C1", "11:30", 2, "C9")}, directMessages)
+
+	// set up channels are not offered setup again
+	offer.Channel = ""
+	s.offerSetup("C1", "U1")
+	assert.Equal(t, "", offer.Channel)
+}
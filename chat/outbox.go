@@ -0,0 +1,314 @@
+package chat
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jasonlvhit/gocron"
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/model"
+	"github.com/maddevsio/comedian/storage"
+	"github.com/nlopes/slack"
+	"github.com/sirupsen/logrus"
+)
+
+// outboxPollInterval is how often outbox looks for messages to retry
+var outboxPollInterval = time.Second
+
+const (
+	// outboxMaxAttempts is how many times a message is tried before it is given up
+	outboxMaxAttempts = 8
+	// outboxBaseDelay is a delay before the first retry, it doubles with every next one
+	outboxBaseDelay = 5 * time.Second
+	outboxMaxDelay  = 10 * time.Minute
+	// outboxLease is how long message being delivered is not tried by others, it is
+	// retried after that if delivery is lost, e.g. Comedian stops meanwhile
+	outboxLease = 5 * time.Minute
+	// outboxKeepSent is how long delivered messages are kept in storage
+	outboxKeepSent = 7 * 24 * time.Hour
+)
+
+// deliverer sends messages to chat platform right away
+type deliverer interface {
+	deliverMessage(channel, message string, attachments []Attachment) error
+	deliverEphemeralMessage(channel, user, message string) error
+	deliverUserMessage(userID, message string) error
+}
+
+// outbox keeps messages of workspace in storage until they are delivered, so that
+// messages survive rate limits, network errors and restarts. Identical messages
+// waiting for delivery are sent once. Failed messages are reported to super admin
+type outbox struct {
+	db        storage.Storage
+	conf      config.Config
+	deliverer deliverer
+
+	mu sync.Mutex
+	// pausedUntil is when chat platform accepts messages again after rate limiting
+	pausedUntil time.Time
+}
+
+func newOutbox(db storage.Storage, conf config.Config, d deliverer) *outbox {
+	return &outbox{db: db, conf: conf, deliverer: d}
+}
+
+// send puts message to outbox and tries to deliver it at once unless messages are
+// rate limited. Returns error only if message can not be delivered at all
+func (o *outbox) send(msg model.OutgoingMessage, attachments []Attachment) error {
+	if attachments != nil {
+		data, err := json.Marshal(attachments)
+		if err != nil {
+			return err
+		}
+		msg.Attachments = string(data)
+	}
+	msg.DedupKey = dedupKey(msg)
+
+	now := time.Now()
+	pausedUntil := o.paused()
+	// the message is leased for the attempt right now, it is retried later only if this attempt is lost
+	msg.NextAttempt = now.Add(outboxLease)
+	if pausedUntil.After(now) {
+		msg.NextAttempt = pausedUntil
+	}
+	created, err := o.db.CreateOutgoingMessage(msg)
+	if err != nil {
+		// storage does not keep identical messages waiting together
+		if _, findErr := o.db.FindPendingMessage(msg.DedupKey); findErr == nil {
+			logrus.Infof("outbox: identical message to %v%v is waiting for delivery already", msg.ChannelID, msg.UserID)
+			return nil
+		}
+		logrus.Errorf("outbox: CreateOutgoingMessage failed, delivering without outbox: %v", err)
+		return o.deliver(msg)
+	}
+	msg = created
+	if pausedUntil.After(now) {
+		return nil
+	}
+	return o.attempt(msg)
+}
+
+func dedupKey(msg model.OutgoingMessage) string {
+	h := sha256.New()
+	for _, field := range []string{msg.Kind, msg.ChannelID, msg.UserID, msg.Text, msg.Attachments, msg.Blocks} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (o *outbox) paused() time.Time {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.pausedUntil
+}
+
+func (o *outbox) pause(until time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if until.After(o.pausedUntil) {
+		o.pausedUntil = until
+	}
+}
+
+func (o *outbox) deliver(msg model.OutgoingMessage) error {
+	switch msg.Kind {
+	case model.MessageEphemeral:
+		return o.deliverer.deliverEphemeralMessage(msg.ChannelID, msg.UserID, msg.Text)
+	case model.MessageToUser:
+		return o.deliverer.deliverUserMessage(msg.UserID, msg.Text)
+	}
+	if msg.Blocks != "" {
+		d, ok := o.deliverer.(blockDeliverer)
+		if !ok {
+			return errors.New("chat platform does not support Block Kit")
+		}
+		return d.deliverBlocks(msg.ChannelID, msg.Text, json.RawMessage(msg.Blocks))
+	}
+	var attachments []Attachment
+	if msg.Attachments != "" {
+		err := json.Unmarshal([]byte(msg.Attachments), &attachments)
+		if err != nil {
+			return err
+		}
+	}
+	return o.deliverer.deliverMessage(msg.ChannelID, msg.Text, attachments)
+}
+
+// attempt delivers message and schedules the next attempt if delivery failed.
+// Returns error if message is given up
+func (o *outbox) attempt(msg model.OutgoingMessage) error {
+	err := o.deliver(msg)
+	now := time.Now()
+	if err == nil {
+		msg.SentAt = &now
+		msg.LastError = ""
+	} else {
+		msg.LastError = err.Error()
+		retryAfter, retry := retryDelay(err)
+		switch {
+		case retry && retryAfter > 0:
+			// rate limits are not failures of the message, it waits as long as asked
+			o.pause(now.Add(retryAfter))
+			msg.NextAttempt = now.Add(retryAfter)
+		case retry && msg.Attempts+1 < outboxMaxAttempts:
+			msg.Attempts++
+			msg.NextAttempt = now.Add(backoffDelay(msg.Attempts))
+		default:
+			msg.Attempts++
+			msg.FailedAt = &now
+		}
+		logrus.Errorf("outbox: delivery of message #%v failed: %v", msg.ID, err)
+	}
+	_, updateErr := o.db.UpdateOutgoingMessage(msg)
+	if updateErr != nil {
+		logrus.Errorf("outbox: UpdateOutgoingMessage failed: %v", updateErr)
+	}
+	if msg.FailedAt != nil {
+		return err
+	}
+	return nil
+}
+
+func backoffDelay(attempts int) time.Duration {
+	delay := outboxBaseDelay
+	for i := 1; i < attempts && delay < outboxMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > outboxMaxDelay {
+		return outboxMaxDelay
+	}
+	return delay
+}
+
+// retryDelay shows if delivery should be retried and how long chat platform asks to wait
+func retryDelay(err error) (time.Duration, bool) {
+	switch e := err.(type) {
+	case *slack.RateLimitedError:
+		return atLeastSecond(e.RetryAfter), true
+	case *mattermostError:
+		if e.StatusCode == http.StatusTooManyRequests {
+			return atLeastSecond(e.RetryAfter), true
+		}
+		return 0, e.StatusCode >= 500
+	case *telegramError:
+		if e.ErrorCode == http.StatusTooManyRequests {
+			return atLeastSecond(time.Duration(e.Parameters.RetryAfter) * time.Second), true
+		}
+		// error code is 0 when Telegram is not reached
+		return 0, e.ErrorCode == 0 || e.ErrorCode >= 500
+	case interface{ HTTPStatusCode() int }:
+		return 0, e.HTTPStatusCode() >= 500
+	case net.Error:
+		return 0, true
+	}
+	return 0, false
+}
+
+func atLeastSecond(d time.Duration) time.Duration {
+	if d < time.Second {
+		return time.Second
+	}
+	return d
+}
+
+// runOutbox retries delivery of outgoing messages, reports failed ones to super admin
+// every hour and deletes messages delivered long ago every day
+func (s *standups) runOutbox() {
+	gocron.Every(1).Hour().Do(s.outbox.reportFailures)
+	gocron.Every(1).Day().At("04:00").Do(s.outbox.cleanUp)
+	go s.outbox.run()
+}
+
+// run retries delivery of messages until process is stopped
+func (o *outbox) run() {
+	for {
+		time.Sleep(outboxPollInterval)
+		o.flush(time.Now())
+	}
+}
+
+// flush delivers messages which should be sent by the time
+func (o *outbox) flush(now time.Time) {
+	pausedUntil := o.paused()
+	if pausedUntil.After(now) {
+		return
+	}
+	messages, err := o.db.ListDueMessages(now)
+	if err != nil {
+		logrus.Errorf("outbox: ListDueMessages failed: %v", err)
+		return
+	}
+	for _, msg := range messages {
+		claimed, err := o.db.ClaimOutgoingMessage(msg.ID, now, now.Add(outboxLease))
+		if err != nil {
+			logrus.Errorf("outbox: ClaimOutgoingMessage failed: %v", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		o.attempt(msg)
+		// the rest waits if messages got rate limited
+		if !o.paused().Equal(pausedUntil) {
+			return
+		}
+	}
+}
+
+// reportFailures sends one message about all undelivered messages to super admin
+func (o *outbox) reportFailures() {
+	failures, err := o.db.ListUnreportedFailures()
+	if err != nil {
+		logrus.Errorf("outbox: ListUnreportedFailures failed: %v", err)
+		return
+	}
+	if len(failures) == 0 {
+		return
+	}
+	lines := []string{}
+	for _, msg := range failures {
+		to := fmt.Sprintf("<#%v>", msg.ChannelID)
+		if msg.Kind != model.MessageToChannel {
+			to = fmt.Sprintf("<@%v>", msg.UserID)
+		}
+		lines = append(lines, fmt.Sprintf("• %v \"%v\": %v", to, shorten(msg.Text, 50), msg.LastError))
+	}
+	// the report does not go through outbox, otherwise it could report itself
+	err = o.deliverer.deliverUserMessage(o.conf.ManagerSlackUserID, fmt.Sprintf(o.conf.Translate.OutboxFailuresDigest, len(failures), strings.Join(lines, "\n")))
+	if err != nil {
+		logrus.Errorf("outbox: could not report failures: %v", err)
+		return
+	}
+	for _, msg := range failures {
+		msg.Reported = true
+		_, err := o.db.UpdateOutgoingMessage(msg)
+		if err != nil {
+			logrus.Errorf("outbox: UpdateOutgoingMessage failed: %v", err)
+		}
+	}
+}
+
+func shorten(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length]) + "…"
+}
+
+// cleanUp deletes messages delivered long ago
+func (o *outbox) cleanUp() {
+	err := o.db.DeleteSentMessages(time.Now().Add(-outboxKeepSent))
+	if err != nil {
+		logrus.Errorf("outbox: DeleteSentMessages failed: %v", err)
+	}
+}
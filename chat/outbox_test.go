@@ -0,0 +1,190 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/model"
+	"github.com/maddevsio/comedian/storage"
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDeliverer delivers messages to a list and fails with errors from the queue
+type fakeDeliverer struct {
+	delivered []string
+	errors    []error
+	// delivering is called during every delivery
+	delivering func()
+}
+
+func (f *fakeDeliverer) deliver(text string) error {
+	if f.delivering != nil {
+		f.delivering()
+	}
+	if len(f.errors) > 0 {
+		err := f.errors[0]
+		f.errors = f.errors[1:]
+		if err != nil {
+			return err
+		}
+	}
+	f.delivered = append(f.delivered, text)
+	return nil
+}
+
+func (f *fakeDeliverer) deliverMessage(channel, message string, attachments []Attachment) error {
+	for _, a := range attachments {
+		message += "|" + a.Text
+	}
+	return f.deliver(channel + ":" + message)
+}
+
+func (f *fakeDeliverer) deliverEphemeralMessage(channel, user, message string) error {
+	return f.deliver(channel + ":" + user + ":" + message)
+}
+
+func (f *fakeDeliverer) deliverUserMessage(userID, message string) error {
+	return f.deliver(userID + ":" + message)
+}
+
+func TestOutboxSend(t *testing.T) {
+	f := &fakeDeliverer{}
+	db := storage.NewMemory()
+	o := newOutbox(db, config.Config{}, f)
+
+	assert.NoError(t, o.send(model.OutgoingMessage{Kind: model.MessageToChannel, ChannelID: "C1", Text: "hello"}, []Attachment{{Text: "report"}}))
+	assert.NoError(t, o.send(model.OutgoingMessage{Kind: model.MessageEphemeral, ChannelID: "C1", UserID: "U1", Text: "psst"}, nil))
+	assert.NoError(t, o.send(model.OutgoingMessage{Kind: model.MessageToUser, UserID: "U1", Text: "hi"}, nil))
+	assert.Equal(t, []string{"C1:hello|report", "C1:U1:psst", "U1:hi"}, f.delivered)
+
+	// delivered messages are not sent again
+	o.flush(time.Now().Add(time.Hour))
+	assert.Equal(t, 3, len(f.delivered))
+	// identical message is sent again once the previous one is delivered
+	assert.NoError(t, o.send(model.OutgoingMessage{Kind: model.MessageToUser, UserID: "U1", Text: "hi"}, nil))
+	assert.Equal(t, 4, len(f.delivered))
+
+	o.cleanUp()
+	due, err := db.ListDueMessages(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(due))
+	assert.NoError(t, db.DeleteSentMessages(time.Now().Add(time.Minute)))
+	_, err = db.FindPendingMessage(dedupKey(model.OutgoingMessage{Kind: model.MessageToUser, UserID: "U1", Text: "hi"}))
+	assert.Error(t, err)
+}
+
+func TestOutboxSlowDelivery(t *testing.T) {
+	f := &fakeDeliverer{}
+	db := storage.NewMemory()
+	o := newOutbox(db, config.Config{}, f)
+
+	// message being delivered is not picked up by retries, however long delivery takes
+	f.delivering = func() {
+		f.delivering = nil
+		o.flush(time.Now().Add(time.Minute))
+	}
+	assert.NoError(t, o.send(model.OutgoingMessage{Kind: model.MessageToChannel, ChannelID: "C1", Text: "slow"}, nil))
+	assert.Equal(t, []string{"C1:slow"}, f.delivered)
+
+	// lost attempts are retried once the lease is over
+	msg, err := db.CreateOutgoingMessage(model.OutgoingMessage{Kind: model.MessageToChannel, ChannelID: "C1", Text: "lost", DedupKey: "lost", NextAttempt: time.Now().Add(outboxLease)})
+	assert.NoError(t, err)
+	o.flush(time.Now())
+	assert.Equal(t, 1, len(f.delivered))
+	o.flush(msg.NextAttempt)
+	assert.Equal(t, []string{"C1:slow", "C1:lost"}, f.delivered)
+}
+
+func TestOutboxRateLimit(t *testing.T) {
+	f := &fakeDeliverer{errors: []error{&slack.RateLimitedError{RetryAfter: 30 * time.Second}}}
+	db := storage.NewMemory()
+	o := newOutbox(db, config.Config{}, f)
+
+	assert.NoError(t, o.send(model.OutgoingMessage{Kind: model.MessageToChannel, ChannelID: "C1", Text: "first"}, nil))
+	assert.Equal(t, 0, len(f.delivered))
+	assert.True(t, o.paused().After(time.Now().Add(29*time.Second)))
+
+	// messages wait while rate limited, identical ones are sent once
+	assert.NoError(t, o.send(model.OutgoingMessage{Kind: model.MessageToChannel, ChannelID: "C1", Text: "second"}, nil))
+	assert.NoError(t, o.send(model.OutgoingMessage{Kind: model.MessageToChannel, ChannelID: "C1", Text: "second"}, nil))
+	o.flush(time.Now())
+	assert.Equal(t, 0, len(f.delivered))
+
+	o.flush(time.Now().Add(31 * time.Second))
+	assert.Equal(t, []string{"C1:first", "C1:second"}, f.delivered)
+	failures, err := db.ListUnreportedFailures()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(failures))
+}
+
+func TestOutboxRetries(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	errs := []error{}
+	for i := 0; i < outboxMaxAttempts; i++ {
+		errs = append(errs, &mattermostError{StatusCode: 502, Message: "bad gateway"})
+	}
+	f := &fakeDeliverer{errors: errs}
+	db := storage.NewMemory()
+	o := newOutbox(db, config.Config{ManagerSlackUserID: "ADMIN", Translate: translation}, f)
+
+	// network errors are retried with growing delays
+	assert.NoError(t, o.send(model.OutgoingMessage{Kind: model.MessageToChannel, ChannelID: "C1", Text: "report"}, nil))
+	now := time.Now()
+	for i := 1; i < outboxMaxAttempts; i++ {
+		now = now.Add(backoffDelay(i) + time.Second)
+		o.flush(now)
+	}
+	assert.Equal(t, 0, len(f.delivered))
+
+	// permanent errors are not retried and are returned right away
+	f.errors = []error{errors.New("channel_not_found")}
+	assert.EqualError(t, o.send(model.OutgoingMessage{Kind: model.MessageEphemeral, ChannelID: "C2", UserID: "U1", Text: "psst"}, nil), "channel_not_found")
+
+	failures, err := db.ListUnreportedFailures()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(failures))
+	assert.Equal(t, outboxMaxAttempts, failures[0].Attempts)
+
+	// all failures are reported in one message
+	o.reportFailures()
+	assert.Equal(t, []string{"ADMIN:" + fmt.Sprintf(translation.OutboxFailuresDigest, 2,
+		"• <#C1> \"report\": mattermost: 502 bad gateway\n• <@U1> \"psst\": channel_not_found")}, f.delivered)
+	failures, err = db.ListUnreportedFailures()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(failures))
+	o.reportFailures()
+	assert.Equal(t, 1, len(f.delivered))
+}
+
+func TestRetryDelay(t *testing.T) {
+	testCases := []struct {
+		err   error
+		delay time.Duration
+		retry bool
+	}{
+		{&slack.RateLimitedError{RetryAfter: 3 * time.Second}, 3 * time.Second, true},
+		{&slack.RateLimitedError{}, time.Second, true},
+		{&mattermostError{StatusCode: 429, RetryAfter: 10 * time.Second}, 10 * time.Second, true},
+		{&mattermostError{StatusCode: 503}, 0, true},
+		{&mattermostError{StatusCode: 403}, 0, false},
+		{&telegramError{ErrorCode: 429, Parameters: struct {
+			RetryAfter int `json:"retry_after"`
+		}{5}}, 5 * time.Second, true},
+		{&telegramError{Description: "sendMessage request failed"}, 0, true},
+		{&telegramError{ErrorCode: 400, Description: "chat not found"}, 0, false},
+		{errors.New("channel_not_found"), 0, false},
+	}
+	for _, tt := range testCases {
+		delay, retry := retryDelay(tt.err)
+		assert.Equal(t, tt.delay, delay, tt.err.Error())
+		assert.Equal(t, tt.retry, retry, tt.err.Error())
+	}
+	assert.Equal(t, outboxBaseDelay, backoffDelay(1))
+	assert.Equal(t, 2*outboxBaseDelay, backoffDelay(2))
+	assert.Equal(t, outboxMaxDelay, backoffDelay(20))
+}
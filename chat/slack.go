@@ -2,9 +2,8 @@ package chat
 
 import (
 	"fmt"
-	"strconv"
+	"strings"
 	"sync"
-	"time"
 
 	"github.com/jasonlvhit/gocron"
 	"github.com/maddevsio/comedian/config"
@@ -12,8 +11,6 @@ import (
 	"github.com/maddevsio/comedian/storage"
 	"github.com/nlopes/slack"
 	"github.com/sirupsen/logrus"
-
-	"strings"
 )
 
 var (
@@ -24,38 +21,56 @@ var (
 
 // Slack struct used for storing and communicating with slack api
 type Slack struct {
-	API  *slack.Client
-	RTM  *slack.RTM
-	WG   sync.WaitGroup
-	DB   *storage.MySQL
-	Conf config.Config
+	standups
+	API *slack.Client
+	RTM *slack.RTM
+	WG  sync.WaitGroup
+
+	// events are received by Events API endpoint when Events API transport is used
+	events   chan EventsAPIRequest
+	eventIDs SeenIDs
 }
 
 // NewSlack creates a new copy of slack handler
 func NewSlack(conf config.Config) (*Slack, error) {
-	db, err := storage.NewMySQL(conf)
+	db, err := storage.New(conf)
 	if err != nil {
-		logrus.Errorf("slack: NewMySQL failed: %v\n", err)
+		logrus.Errorf("slack: storage.New failed: %v\n", err)
 		return nil, err
 	}
 
+	return NewWorkspaceSlack(conf, db), nil
+}
+
+// NewWorkspaceSlack creates slack handler for workspace conf.TeamID. Storage may be
+// shared with other workspaces, handler sees entries of its own workspace only
+func NewWorkspaceSlack(conf config.Config, db storage.Storage) *Slack {
 	s := &Slack{}
+	s.platform = s
 	s.Conf = conf
 	s.API = slack.New(conf.SlackToken)
 	s.RTM = s.API.NewRTM()
-	s.DB = db
-	return s, nil
+	s.events = make(chan EventsAPIRequest, 100)
+	s.DB = db.ForTeam(conf.TeamID)
+	s.outbox = newOutbox(s.DB, conf, s)
+	return s
 }
 
-// Run runs a listener loop for slack
+// Run runs a listener loop for slack. Events come either from RTM connection or
+// from Events API endpoint depending on COMEDIAN_SLACK_TRANSPORT
 func (s *Slack) Run() {
 
 	s.UpdateUsersList()
 	s.SendUserMessage(s.Conf.ManagerSlackUserID, s.Conf.Translate.HelloManager)
 
-	gocron.Every(1).Day().At("23:50").Do(s.FillStandupsForNonReporters)
+	gocron.Every(10).Minutes().Do(s.FillStandupsForNonReporters)
 	gocron.Every(1).Day().At("23:55").Do(s.UpdateUsersList)
-	gocron.Start()
+	s.runOutbox()
+
+	if s.Conf.SlackTransport == config.TransportEvents {
+		s.listenEvents()
+		return
+	}
 
 	s.WG.Add(1)
 	go s.RTM.ManageConnection()
@@ -67,174 +82,85 @@ func (s *Slack) Run() {
 			botUserID := fmt.Sprintf("<@%s>", s.RTM.GetInfo().User.ID)
 			s.handleMessage(ev, botUserID)
 		case *slack.MemberJoinedChannelEvent:
-			s.handleJoin(ev.Channel)
+			isComedian := ev.User == s.RTM.GetInfo().User.ID
+			s.handleMemberJoined(ev.Channel, ev.User, isComedian)
+			if isComedian {
+				s.offerSetup(ev.Channel, ev.Inviter)
+			}
+		case *slack.MemberLeftChannelEvent:
+			s.handleMemberLeft(ev.Channel, ev.User)
+		case *slack.TeamJoinEvent:
+			s.syncUser(ev.User)
+		case *slack.UserChangeEvent:
+			s.syncUser(ev.User)
+		case *renameEvent:
+			s.handleRename(ev.Channel.ID, ev.Channel.Name)
+		case *slack.ChannelInfoEvent:
+			s.setChannelStatus(ev.Channel, channelStatusEvents[msg.Type])
 		case *slack.InvalidAuthEvent:
 			return
 		}
 	}
 }
 
-func (s *Slack) handleJoin(channelID string) {
-	_, err := s.DB.SelectChannel(channelID)
-	if err != nil {
-		logrus.Error("No such channel found! Will create one!")
-		channel, err := s.API.GetConversationInfo(channelID, true)
-		if err != nil {
-			logrus.Errorf("GetConversationInfo failed: %v", err)
-		}
-		createdChannel, err := s.DB.CreateChannel(model.Channel{
-			ChannelName: channel.Name,
-			ChannelID:   channel.ID,
-			StandupTime: int64(0),
-		})
-		if err != nil {
-			logrus.Errorf("CreateChannel failed: %v", err)
-			return
-		}
-		logrus.Infof("New Channel Created: %v", createdChannel)
-	}
-}
-
 func (s *Slack) handleMessage(msg *slack.MessageEvent, botUserID string) {
 	switch msg.SubType {
 	case typeMessage:
-		if !strings.Contains(msg.Msg.Text, botUserID) && !strings.Contains(msg.Msg.Text, "#standup") {
-			return
-		}
-		messageIsStandup, problem := s.analizeStandup(msg.Msg.Text)
-		if problem != "" {
-			s.SendEphemeralMessage(msg.Channel, msg.User, problem)
-			return
-		}
-		if messageIsStandup {
-			if s.DB.SubmittedStandupToday(msg.User, msg.Channel) {
-				s.SendEphemeralMessage(msg.Channel, msg.User, s.Conf.Translate.StandupHandleOneDayOneStandup)
-				return
-			}
-			standup, err := s.DB.CreateStandup(model.Standup{
-				ChannelID: msg.Channel,
-				UserID:    msg.User,
-				Comment:   msg.Msg.Text,
-				MessageTS: msg.Msg.Timestamp,
-			})
-			if err != nil {
-				logrus.Errorf("CreateStandup failed: %v", err)
-				errorReportToManager := fmt.Sprintf("I could not save standup for user %s in channel %s because of the following reasons: %v", msg.User, msg.Channel, err)
-				s.SendUserMessage(s.Conf.ManagerSlackUserID, errorReportToManager)
-				s.SendEphemeralMessage(msg.Channel, msg.User, s.Conf.Translate.StandupHandleCouldNotSaveStandup)
-				return
-			}
-			logrus.Infof("Standup created #id:%v\n", standup.ID)
-			item := slack.ItemRef{msg.Channel, msg.Msg.Timestamp, "", ""}
-			time.Sleep(2 * time.Second)
-			s.API.AddReaction("heavy_check_mark", item)
-			s.SendEphemeralMessage(msg.Channel, msg.User, s.Conf.Translate.StandupHandleCreatedStandup)
+		// IDs of direct message channels start with D
+		if strings.HasPrefix(msg.Channel, "D") {
+			s.handleDirect(message{msg.Channel, msg.User, msg.Msg.Text, msg.Msg.Timestamp})
 			return
 		}
+		s.handlePosted(message{msg.Channel, msg.User, msg.Msg.Text, msg.Msg.Timestamp}, botUserID)
 	case typeEditMessage:
-		if !strings.Contains(msg.SubMessage.Text, botUserID) && !strings.Contains(msg.SubMessage.Text, "#standup") {
-			return
-		}
-		standup, err := s.DB.SelectStandupByMessageTS(msg.SubMessage.Timestamp)
-		if err != nil {
-			messageIsStandup, problem := s.analizeStandup(msg.SubMessage.Text)
-			if problem != "" {
-				s.SendEphemeralMessage(msg.Channel, msg.SubMessage.User, problem)
-				return
-			}
-			if messageIsStandup {
-				if s.DB.SubmittedStandupToday(msg.SubMessage.User, msg.Channel) {
-					s.SendEphemeralMessage(msg.Channel, msg.SubMessage.User, s.Conf.Translate.StandupHandleOneDayOneStandup)
-					return
-				}
-				logrus.Infof("CreateStandup while updating text ChannelID (%v), UserID (%v), Comment (%v), TimeStamp (%v)", msg.Channel, msg.SubMessage.User, msg.SubMessage.Text, msg.SubMessage.Timestamp)
-				standup, err := s.DB.CreateStandup(model.Standup{
-					ChannelID: msg.Channel,
-					UserID:    msg.SubMessage.User,
-					Comment:   msg.SubMessage.Text,
-					MessageTS: msg.SubMessage.Timestamp,
-				})
-				if err != nil {
-					logrus.Errorf("CreateStandup while updating text failed: %v", err)
-					errorReportToManager := fmt.Sprintf("I could not create standup while updating msg for user %s in channel %s because of the following reasons: %v", msg.SubMessage.User, msg.Channel, err)
-					s.SendUserMessage(s.Conf.ManagerSlackUserID, errorReportToManager)
-					s.SendEphemeralMessage(msg.Channel, msg.SubMessage.User, s.Conf.Translate.StandupHandleCouldNotSaveStandup)
-					return
-				}
-				logrus.Infof("Standup created #id:%v\n", standup.ID)
-				item := slack.ItemRef{msg.Channel, msg.SubMessage.Timestamp, "", ""}
-				time.Sleep(2 * time.Second)
-				s.API.AddReaction("heavy_check_mark", item)
-				s.SendEphemeralMessage(msg.Channel, msg.SubMessage.User, s.Conf.Translate.StandupHandleCreatedStandup)
-				return
-			}
-		}
-
-		messageIsStandup, problem := s.analizeStandup(msg.SubMessage.Text)
-		if problem != "" {
-			s.SendEphemeralMessage(msg.Channel, msg.SubMessage.User, problem)
-			return
-		}
-		if messageIsStandup {
-			standup.Comment = msg.SubMessage.Text
-			st, _ := s.DB.UpdateStandup(standup)
-			logrus.Infof("Standup updated #id:%v\n", st.ID)
-			time.Sleep(2 * time.Second)
-			s.SendEphemeralMessage(msg.Channel, msg.SubMessage.User, s.Conf.Translate.StandupHandleUpdatedStandup)
-			return
-		}
-
+		s.handleEdited(message{msg.Channel, msg.SubMessage.User, msg.SubMessage.Text, msg.SubMessage.Timestamp}, botUserID)
 	case typeDeleteMessage:
-		standup, err := s.DB.SelectStandupByMessageTS(msg.DeletedTimestamp)
-		if err != nil {
-			logrus.Errorf("SelectStandupByMessageTS failed: %v", err)
-		}
-		s.DB.DeleteStandup(standup.ID)
-		logrus.Infof("Standup deleted #id:%v\n", standup.ID)
+		s.handleDeleted(msg.DeletedTimestamp)
 	}
 }
 
-func (s *Slack) analizeStandup(message string) (bool, string) {
-	message = strings.ToLower(message)
-	mentionsProblem := false
-	problemKeys := []string{"problem", "difficult", "stuck", "question", "issue", "block", "проблем", "трудност", "затрдуднени", "вопрос"}
-	for _, problem := range problemKeys {
-		if strings.Contains(message, problem) {
-			mentionsProblem = true
-		}
-	}
-	if !mentionsProblem {
-		return false, s.Conf.Translate.StandupHandleNoProblemsMentioned
-	}
+func (s *Slack) markStandup(channelID, messageID string) {
+	item := slack.ItemRef{channelID, messageID, "", ""}
+	s.API.AddReaction("heavy_check_mark", item)
+}
 
-	mentionsYesterdayWork := false
-	yesterdayWorkKeys := []string{"yesterday", "friday", "completed", "вчера", "пятниц", "делал", "сделано"}
-	for _, work := range yesterdayWorkKeys {
-		if strings.Contains(message, work) {
-			mentionsYesterdayWork = true
-		}
-	}
-	if !mentionsYesterdayWork {
-		return false, s.Conf.Translate.StandupHandleNoYesterdayWorkMentioned
+func (s *Slack) channelName(channelID string) (string, error) {
+	channel, err := s.API.GetConversationInfo(channelID, true)
+	if err != nil {
+		return "", err
 	}
+	return channel.Name, nil
+}
 
-	mentionsTodayPlans := false
-	todayPlansKeys := []string{"today", "going", "plan", "сегодня", "собираюсь", "план"}
-	for _, plan := range todayPlansKeys {
-		if strings.Contains(message, plan) {
-			mentionsTodayPlans = true
+func (s *Slack) channelMembers(channelID string) ([]string, error) {
+	members := []string{}
+	params := &slack.GetUsersInConversationParameters{ChannelID: channelID, Limit: 200}
+	for {
+		users, cursor, err := s.API.GetUsersInConversation(params)
+		if err != nil {
+			return nil, err
 		}
+		members = append(members, users...)
+		if cursor == "" {
+			return members, nil
+		}
+		params.Cursor = cursor
 	}
-	if !mentionsTodayPlans {
-		return false, s.Conf.Translate.StandupHandleNoTodayPlansMentioned
-	}
-	return true, ""
+}
+
+func (s *Slack) postMessage(channelID, text string) (string, error) {
+	_, ts, err := s.API.PostMessage(channelID, text, slack.PostMessageParameters{})
+	return ts, err
 }
 
 // SendMessage posts a message in a specified channel visible for everyone
-func (s *Slack) SendMessage(channel, message string, attachments []slack.Attachment) error {
+func (s *Slack) SendMessage(channel, message string, attachments []Attachment) error {
+	return s.outbox.send(model.OutgoingMessage{Kind: model.MessageToChannel, ChannelID: channel, Text: message}, attachments)
+}
+
+func (s *Slack) deliverMessage(channel, message string, attachments []Attachment) error {
 	_, _, err := s.API.PostMessage(channel, message, slack.PostMessageParameters{
-		Attachments: attachments,
+		Attachments: slackAttachments(attachments),
 	})
 	if err != nil {
 		logrus.Errorf("slack: PostMessage failed: %v\n", err)
@@ -243,8 +169,30 @@ func (s *Slack) SendMessage(channel, message string, attachments []slack.Attachm
 	return err
 }
 
+func slackAttachments(attachments []Attachment) []slack.Attachment {
+	if attachments == nil {
+		return nil
+	}
+	result := []slack.Attachment{}
+	for _, a := range attachments {
+		attachment := slack.Attachment{Color: a.Color, Text: a.Text, CallbackID: a.CallbackID}
+		for _, f := range a.Fields {
+			attachment.Fields = append(attachment.Fields, slack.AttachmentField{Title: f.Title, Value: f.Value, Short: f.Short})
+		}
+		for _, action := range a.Actions {
+			attachment.Actions = append(attachment.Actions, slack.AttachmentAction{Name: action.Name, Text: action.Text, Type: action.Type, Value: action.Value})
+		}
+		result = append(result, attachment)
+	}
+	return result
+}
+
 // SendEphemeralMessage posts a message in a specified channel which is visible only for selected user
 func (s *Slack) SendEphemeralMessage(channel, user, message string) error {
+	return s.outbox.send(model.OutgoingMessage{Kind: model.MessageEphemeral, ChannelID: channel, UserID: user, Text: message}, nil)
+}
+
+func (s *Slack) deliverEphemeralMessage(channel, user, message string) error {
 	_, err := s.API.PostEphemeral(
 		channel,
 		user,
@@ -259,18 +207,23 @@ func (s *Slack) SendEphemeralMessage(channel, user, message string) error {
 
 // SendUserMessage Direct Message specific user
 func (s *Slack) SendUserMessage(userID, message string) error {
+	return s.outbox.send(model.OutgoingMessage{Kind: model.MessageToUser, UserID: userID, Text: message}, nil)
+}
+
+func (s *Slack) deliverUserMessage(userID, message string) error {
 	_, _, channelID, err := s.API.OpenIMChannel(userID)
 	if err != nil {
 		return err
 	}
-	err = s.SendMessage(channelID, message, nil)
+	err = s.deliverMessage(channelID, message, nil)
 	if err != nil {
 		return err
 	}
 	return err
 }
 
-//UpdateUsersList updates users in workspace
+//UpdateUsersList updates users in workspace. Users are synced by team_join and user_change
+//events as well, full sync catches up with changes missed while Comedian was offline
 func (s *Slack) UpdateUsersList() {
 	users, err := s.API.GetUsers()
 	if err != nil {
@@ -278,71 +231,15 @@ func (s *Slack) UpdateUsersList() {
 		return
 	}
 	for _, user := range users {
-		if user.IsBot || user.Name == "slackbot" {
-			continue
-		}
-
-		u, err := s.DB.SelectUser(user.ID)
-		if err != nil {
-			if user.IsAdmin || user.IsOwner || user.IsPrimaryOwner {
-				s.DB.CreateUser(model.User{
-					UserName: user.Name,
-					UserID:   user.ID,
-					Role:     "admin",
-				})
-				continue
-			}
-			s.DB.CreateUser(model.User{
-				UserName: user.Name,
-				UserID:   user.ID,
-				Role:     "",
-			})
-		}
-		if user.Deleted {
-			s.DB.DeleteUser(u.ID)
-			cm, err := s.DB.FindMembersByUserID(u.UserID)
-			if err != nil {
-				continue
-			}
-			for _, member := range cm {
-				s.DB.DeleteChannelMember(member.UserID, member.ChannelID)
-				tt, err := s.DB.SelectTimeTable(member.ID)
-				if err != nil {
-					continue
-				}
-				s.DB.DeleteTimeTable(tt.ID)
-			}
-		}
+		s.syncUser(user)
 	}
 	logrus.Info("Users list updated successfully")
 }
 
-//FillStandupsForNonReporters fills standup entries with empty standups to later recognize
-//non reporters vs those who did not have to write standups
-func (s *Slack) FillStandupsForNonReporters() {
-	if int(time.Now().Weekday()) == 6 || int(time.Now().Weekday()) == 0 {
-		return
-	}
-	allUsers, err := s.DB.ListAllChannelMembers()
-	if err != nil {
+// syncUser saves new, renamed or deactivated user of the workspace
+func (s *Slack) syncUser(user slack.User) {
+	if user.IsBot || user.Name == "slackbot" {
 		return
 	}
-	for _, user := range allUsers {
-		if user.Created.Day() == time.Now().Day() {
-			continue
-		}
-		hasStandup := s.DB.SubmittedStandupToday(user.UserID, user.ChannelID)
-		if !hasStandup {
-			_, err := s.DB.CreateStandup(model.Standup{
-				ChannelID: user.ChannelID,
-				UserID:    user.UserID,
-				Comment:   "",
-				MessageTS: strconv.Itoa(int(time.Now().Unix())),
-			})
-			if err != nil {
-				errorReportToManager := fmt.Sprintf("I could not create empty standup for user %s in channel %s because of the following reasons: %v", user.UserID, user.ChannelID, err)
-				s.SendUserMessage(s.Conf.ManagerSlackUserID, errorReportToManager)
-			}
-		}
-	}
+	s.updateUser(user.ID, user.Name, user.TZ, user.IsAdmin || user.IsOwner || user.IsPrimaryOwner, user.Deleted)
 }
@@ -1,6 +1,7 @@
 package chat
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -8,6 +9,7 @@ import (
 
 	"github.com/maddevsio/comedian/config"
 	"github.com/maddevsio/comedian/model"
+	"github.com/maddevsio/comedian/storage"
 	"github.com/nlopes/slack"
 	"github.com/stretchr/testify/assert"
 	httpmock "gopkg.in/jarcoal/httpmock.v1"
@@ -46,13 +48,57 @@ func TestIsStandup(t *testing.T) {
 	s, err := NewSlack(c)
 	assert.NoError(t, err)
 	for _, tt := range testCases {
-		ok, _ := s.analizeStandup(tt.input)
+		ok, _ := s.analizeStandup("", tt.input)
 		if ok != tt.confirm {
 			t.Errorf("Test %s: \n input: %s,\n expected confirm: %v\n actual confirm: %v \n", tt.title, tt.input, tt.confirm, ok)
 		}
 	}
 }
 
+func TestIsStandupWithChannelQuestions(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	s, err := NewSlack(config.Config{DatabaseURL: "memory://", Translate: translation})
+	assert.NoError(t, err)
+
+	for _, q := range []model.StandupQuestion{
+		{ChannelID: "retro", Question: "Done", Keywords: "done, completed", Required: true},
+		{ChannelID: "retro", Question: "Doing", Keywords: "doing, working on", Required: true},
+		{ChannelID: "retro", Question: "Blockers", Required: true},
+		{ChannelID: "retro", Question: "Kudos", Keywords: "thanks", Required: false},
+	} {
+		_, err := s.DB.CreateStandupQuestion(q)
+		assert.NoError(t, err)
+	}
+
+	testCases := []struct {
+		title   string
+		input   string
+		confirm bool
+		problem string
+	}{
+		{"sections", "Done: login page\nDoing: signup page\nBlockers: none", true, ""},
+		{"keywords and bold header", "Completed login page, working on signup\n*blockers*: none", true, ""},
+		{"no blockers section", "Done: login page\nDoing: signup page\nno problems", false, fmt.Sprintf(translation.StandupHandleQuestionNotAnswered, "Blockers")},
+		{"keyword inside a word", "Undone: login page\nDoing: signup page\nBlockers: none", false, fmt.Sprintf(translation.StandupHandleQuestionNotAnswered, "Done")},
+		{"header inside a word", "Done: login page\nDoing: signup page\nBlockersome: none", false, fmt.Sprintf(translation.StandupHandleQuestionNotAnswered, "Blockers")},
+		{"default keywords only", "Yesterday fixed bugs, today plan to write tests, problems: none", false, fmt.Sprintf(translation.StandupHandleQuestionNotAnswered, "Done")},
+	}
+	for _, tt := range testCases {
+		ok, problem := s.analizeStandup("retro", tt.input)
+		assert.Equal(t, tt.confirm, ok, tt.title)
+		assert.Equal(t, tt.problem, problem, tt.title)
+	}
+
+	// channels without own questions use default ones
+	ok, problem := s.analizeStandup("other", "Yesterday fixed bugs, today plan to write tests, problems: none")
+	assert.True(t, ok)
+	assert.Equal(t, "", problem)
+	ok, problem = s.analizeStandup("other", "Done: login page\nDoing: signup page\nBlockers: none")
+	assert.False(t, ok)
+	assert.Equal(t, translation.StandupHandleNoYesterdayWorkMentioned, problem)
+}
+
 func TestSendMessage(t *testing.T) {
 	httpmock.Activate()
 	defer httpmock.DeactivateAndReset()
@@ -180,6 +226,99 @@ func TestHandleMessage(t *testing.T) {
 
 }
 
+func TestStandupEditHistory(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", "https://slack.com/api/chat.postEphemeral", httpmock.NewStringResponder(200, `{"ok": true}`))
+
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	s, err := NewSlack(config.Config{DatabaseURL: "memory://", Translate: translation})
+	assert.NoError(t, err)
+
+	channel, err := s.DB.CreateChannel(model.Channel{ChannelID: "testChannel", ChannelName: "test"})
+	assert.NoError(t, err)
+	standup, err := s.DB.CreateStandup(model.Standup{
+		ChannelID: channel.ChannelID,
+		UserID:    "testUser",
+		Comment:   "<@BOTID> Yesterday, today, problems",
+		MessageTS: "1500000",
+	})
+	assert.NoError(t, err)
+	standup, err = s.DB.SelectStandupByMessageTS("1500000")
+	assert.NoError(t, err)
+
+	// no deadline, so edit can not be late
+	assert.False(t, s.editedAfterDeadline(standup, time.Now().AddDate(0, 0, 1)))
+
+	day := standup.Created.Local()
+	deadline := time.Date(day.Year(), day.Month(), day.Day(), 10, 0, 0, 0, time.Local)
+	assert.NoError(t, s.DB.CreateStandupTime(deadline.Unix(), channel.ChannelID))
+	assert.False(t, s.editedAfterDeadline(standup, deadline.Add(-time.Minute)))
+	assert.True(t, s.editedAfterDeadline(standup, deadline.Add(time.Minute)))
+
+	// timetable deadline has priority over channel standup time
+	member, err := s.DB.CreateChannelMember(model.ChannelMember{UserID: "testUser", ChannelID: channel.ChannelID})
+	assert.NoError(t, err)
+	tt, err := s.DB.CreateTimeTable(model.TimeTable{ChannelMemberID: member.ID})
+	assert.NoError(t, err)
+	tt.Monday, tt.Tuesday, tt.Wednesday, tt.Thursday, tt.Friday, tt.Saturday, tt.Sunday = 0, 0, 0, 0, 0, 0, 0
+	_, err = s.DB.UpdateTimeTable(tt)
+	assert.NoError(t, err)
+	assert.False(t, s.editedAfterDeadline(standup, deadline.Add(time.Minute)))
+
+	msg := &slack.MessageEvent{
+		SubMessage: &slack.Msg{
+			User:      "testUser",
+			Text:      "<@BOTID> Yesterday, today, problems: none",
+			Timestamp: "1500000",
+		},
+	}
+	msg.Channel = channel.ChannelID
+	msg.SubType = typeEditMessage
+	s.handleMessage(msg, "<@BOTID>")
+
+	history, err := s.DB.ListStandupHistory(standup.ID)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(history)) {
+		assert.Equal(t, "<@BOTID> Yesterday, today, problems", history[0].StandupText)
+		assert.False(t, history[0].AfterDeadline)
+	}
+	standup, err = s.DB.SelectStandupByMessageTS("1500000")
+	assert.NoError(t, err)
+	assert.Equal(t, "<@BOTID> Yesterday, today, problems: none", standup.Comment)
+	assert.Equal(t, model.StandupSections{model.SectionBlockers: "none"}, standup.Sections)
+}
+
+func TestDeleteStandupMessage(t *testing.T) {
+	s, err := NewSlack(config.Config{DatabaseURL: "memory://"})
+	assert.NoError(t, err)
+
+	standup, err := s.DB.CreateStandup(model.Standup{
+		ChannelID: "testChannel",
+		UserID:    "testUser",
+		Comment:   "<@BOTID> Yesterday, today, problems",
+		MessageTS: "1500000",
+	})
+	assert.NoError(t, err)
+
+	msg := &slack.MessageEvent{}
+	msg.SubType = typeDeleteMessage
+	msg.Channel = "testChannel"
+	msg.DeletedTimestamp = "unknown"
+	s.handleMessage(msg, "<@BOTID>")
+
+	msg.DeletedTimestamp = "1500000"
+	s.handleMessage(msg, "<@BOTID>")
+
+	_, err = s.DB.SelectStandupByMessageTS("1500000")
+	assert.Error(t, err)
+	deleted, err := s.DB.SelectDeletedStandup("testUser", "testChannel", time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, standup.ID, deleted.ID)
+	assert.NotNil(t, deleted.DeletedAt)
+}
+
 func TestFillStandupsForNonReporters(t *testing.T) {
 	c, err := config.Get()
 	assert.NoError(t, err)
@@ -237,6 +376,96 @@ func TestFillStandupsForNonReporters(t *testing.T) {
 	assert.NoError(t, s.DB.DeleteChannelMember(su2.UserID, su2.ChannelID))
 }
 
+func TestFillStandupsInTimeZones(t *testing.T) {
+	defer monkey.UnpatchAll()
+	s := NewWorkspaceSlack(config.Config{TeamID: "team1", SlackToken: "token"}, storage.NewMemory())
+	d := time.Date(2018, 10, 1, 10, 0, 0, 0, time.UTC)
+	monkey.Patch(time.Now, func() time.Time { return d })
+	for userID, zone := range map[string]string{"U1": "Asia/Bishkek", "U2": "America/New_York"} {
+		_, err := s.DB.CreateUser(model.User{UserID: userID, UserName: userID, TimeZone: zone})
+		assert.NoError(t, err)
+		_, err = s.DB.CreateChannelMember(model.ChannelMember{UserID: userID, ChannelID: "C1"})
+		assert.NoError(t, err)
+	}
+	filled := func(userID string) bool {
+		standup, err := s.DB.SelectStandupsFiltered(userID, "C1", d.Add(-time.Hour), d.Add(time.Hour))
+		return err == nil && standup.Comment == ""
+	}
+
+	// Wednesday 23:55 in Bishkek is 13:55 in New York
+	d = time.Date(2018, 10, 10, 17, 55, 0, 0, time.UTC)
+	s.FillStandupsForNonReporters()
+	assert.True(t, filled("U1"))
+	assert.False(t, filled("U2"))
+
+	// Wednesday 23:55 in New York is Thursday morning in Bishkek
+	d = time.Date(2018, 10, 11, 3, 55, 0, 0, time.UTC)
+	s.FillStandupsForNonReporters()
+	assert.False(t, filled("U1"))
+	assert.True(t, filled("U2"))
+
+	// nobody is recorded as non reporter on holidays of the channel
+	_, err := s.DB.CreateHoliday(model.Holiday{ChannelID: "C1", Day: "2018-10-12", Name: "Founders Day"})
+	assert.NoError(t, err)
+	d = time.Date(2018, 10, 12, 17, 55, 0, 0, time.UTC)
+	s.FillStandupsForNonReporters()
+	assert.False(t, filled("U1"))
+
+	// nor are standupers on vacation
+	_, err = s.DB.CreateAbsence(model.Absence{UserID: "U1", FirstDay: "2018-10-15", LastDay: "2018-10-15"})
+	assert.NoError(t, err)
+	d = time.Date(2018, 10, 15, 17, 55, 0, 0, time.UTC)
+	s.FillStandupsForNonReporters()
+	assert.False(t, filled("U1"))
+	d = time.Date(2018, 10, 16, 17, 55, 0, 0, time.UTC)
+	s.FillStandupsForNonReporters()
+	assert.True(t, filled("U1"))
+}
+
+func TestFillMissedStandups(t *testing.T) {
+	defer monkey.UnpatchAll()
+	s := NewWorkspaceSlack(config.Config{TeamID: "team1", SlackToken: "token"}, storage.NewMemory())
+	d := time.Date(2018, 10, 1, 10, 0, 0, 0, time.UTC)
+	monkey.Patch(time.Now, func() time.Time { return d })
+	_, err := s.DB.CreateUser(model.User{UserID: "U1", UserName: "U1", TimeZone: "Asia/Bishkek"})
+	assert.NoError(t, err)
+	_, err = s.DB.CreateChannelMember(model.ChannelMember{UserID: "U1", ChannelID: "C1"})
+	assert.NoError(t, err)
+	loc, err := time.LoadLocation("Asia/Bishkek")
+	assert.NoError(t, err)
+	filled := func(day time.Time) int {
+		standups, err := s.DB.ListStandups()
+		assert.NoError(t, err)
+		count := 0
+		for _, standup := range standups {
+			if standup.Comment == "" && !standup.Created.Before(day) && standup.Created.Before(day.AddDate(0, 0, 1)) {
+				count++
+			}
+		}
+		return count
+	}
+
+	// Comedian was down at the end of Wednesday and came back on Thursday morning
+	d = time.Date(2018, 10, 11, 9, 0, 0, 0, loc)
+	s.FillStandupsForNonReporters()
+	assert.Equal(t, 1, filled(time.Date(2018, 10, 10, 0, 0, 0, 0, loc)))
+	assert.Equal(t, 0, filled(time.Date(2018, 10, 11, 0, 0, 0, 0, loc)))
+
+	// filling again creates no more standups
+	d = time.Date(2018, 10, 11, 23, 55, 0, 0, loc)
+	s.FillStandupsForNonReporters()
+	s.FillStandupsForNonReporters()
+	assert.Equal(t, 1, filled(time.Date(2018, 10, 10, 0, 0, 0, 0, loc)))
+	assert.Equal(t, 1, filled(time.Date(2018, 10, 11, 0, 0, 0, 0, loc)))
+
+	// standup written on the missed day is kept alone
+	_, err = s.DB.CreateStandup(model.Standup{ChannelID: "C1", UserID: "U1", Comment: "done", MessageTS: "1", Created: time.Date(2018, 10, 12, 12, 0, 0, 0, loc)})
+	assert.NoError(t, err)
+	d = time.Date(2018, 10, 13, 1, 0, 0, 0, loc)
+	s.FillStandupsForNonReporters()
+	assert.Equal(t, 0, filled(time.Date(2018, 10, 12, 0, 0, 0, 0, loc)))
+}
+
 func TestAutomaticActions(t *testing.T) {
 	c, err := config.Get()
 	assert.NoError(t, err)
@@ -0,0 +1,528 @@
+package chat
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/model"
+	"github.com/maddevsio/comedian/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// platform is what handling of standups needs from a chat platform
+type platform interface {
+	SendEphemeralMessage(channel, user, message string) error
+	SendUserMessage(userID, message string) error
+	// markStandup marks message which was saved as standup
+	markStandup(channelID, messageID string)
+	// channelName returns name of the channel
+	channelName(channelID string) (string, error)
+	// channelMembers returns IDs of users in the channel
+	channelMembers(channelID string) ([]string, error)
+	// postMessage posts a message in a channel and returns its ID. Message does not go
+	// through outbox, the ID is needed right away
+	postMessage(channelID, text string) (string, error)
+}
+
+// message is a message posted in a channel of any chat platform
+type message struct {
+	ChannelID string
+	UserID    string
+	Text      string
+	// ID is message timestamp in Slack or post ID in Mattermost
+	ID string
+}
+
+// standups handles standups regardless of chat platform they are written in
+type standups struct {
+	DB       storage.Storage
+	Conf     config.Config
+	platform platform
+	outbox   *outbox
+
+	mu sync.Mutex
+	// direct are standups written in direct messages which wait for users to choose channels
+	direct map[string]directStandup
+	// scheduleChanges wakes notifier up when deadlines or timetables change
+	scheduleChanges chan struct{}
+}
+
+func (s *standups) changes() chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.scheduleChanges == nil {
+		s.scheduleChanges = make(chan struct{}, 1)
+	}
+	return s.scheduleChanges
+}
+
+// ScheduleChanged tells notifier that deadlines or timetables of workspace have changed.
+// Changes made while notifier is busy are handled together
+func (s *standups) ScheduleChanged() {
+	select {
+	case s.changes() <- struct{}{}:
+	default:
+	}
+}
+
+// ScheduleChanges receives a value when deadlines or timetables of workspace change
+func (s *standups) ScheduleChanges() <-chan struct{} {
+	return s.changes()
+}
+
+// Storage returns storage of workspace
+func (s *standups) Storage() storage.Storage {
+	return s.DB
+}
+
+// Config returns configuration of workspace
+func (s *standups) Config() config.Config {
+	return s.Conf
+}
+
+func (s *standups) handleJoin(channelID string) {
+	channel, err := s.DB.SelectChannel(channelID)
+	// Comedian is invited back to channel it was removed from
+	if err == nil && channel.Status == model.ChannelLeft {
+		s.setChannelStatus(channelID, model.ChannelActive)
+	}
+	if err != nil {
+		logrus.Error("No such channel found! Will create one!")
+		name, err := s.platform.channelName(channelID)
+		if err != nil {
+			logrus.Errorf("channelName failed: %v", err)
+		}
+		createdChannel, err := s.DB.CreateChannel(model.Channel{
+			ChannelName: name,
+			ChannelID:   channelID,
+			StandupTime: int64(0),
+			AutoEnroll:  s.Conf.AutoEnroll,
+		})
+		if err != nil {
+			logrus.Errorf("CreateChannel failed: %v", err)
+			return
+		}
+		logrus.Infof("New Channel Created: %v", createdChannel)
+	}
+}
+
+// handleRename updates stored name of renamed channel, so that it is found by the new name
+func (s *standups) handleRename(channelID, name string) {
+	channel, err := s.DB.SelectChannel(channelID)
+	if err != nil || channel.ChannelName == name {
+		return
+	}
+	channel.ChannelName = name
+	_, err = s.DB.UpdateChannel(channel)
+	if err != nil {
+		logrus.Errorf("UpdateChannel failed: %v", err)
+		return
+	}
+	logrus.Infof("Channel %v is renamed to %v", channelID, name)
+}
+
+// setChannelStatus pauses or resumes reminders and reports of channel members when
+// channel is archived, unarchived or Comedian is removed from it. Deleted channels
+// are never resumed
+func (s *standups) setChannelStatus(channelID, status string) {
+	channel, err := s.DB.SelectChannel(channelID)
+	if err != nil || channel.Status == status || channel.Status == model.ChannelDeleted {
+		return
+	}
+	channel.Status = status
+	_, err = s.DB.UpdateChannel(channel)
+	if err != nil {
+		logrus.Errorf("UpdateChannel failed: %v", err)
+		return
+	}
+	s.ScheduleChanged()
+	logrus.Infof("Channel %v is %v", channel.ChannelName, status)
+}
+
+// isForComedian shows if message is addressed to Comedian
+func isForComedian(text, botMention string) bool {
+	return strings.Contains(text, botMention) || strings.Contains(text, "#standup")
+}
+
+// handlePosted saves new message addressed to Comedian as standup
+func (s *standups) handlePosted(msg message, botMention string) {
+	if !isForComedian(msg.Text, botMention) {
+		return
+	}
+	messageIsStandup, problem := s.analizeStandup(msg.ChannelID, msg.Text)
+	if problem != "" {
+		s.platform.SendEphemeralMessage(msg.ChannelID, msg.UserID, problem)
+		return
+	}
+	if messageIsStandup {
+		if s.DB.SubmittedStandupToday(msg.UserID, msg.ChannelID) {
+			s.platform.SendEphemeralMessage(msg.ChannelID, msg.UserID, s.Conf.Translate.StandupHandleOneDayOneStandup)
+			return
+		}
+		standup, err := s.DB.CreateStandup(model.Standup{
+			ChannelID: msg.ChannelID,
+			UserID:    msg.UserID,
+			Comment:   msg.Text,
+			MessageTS: msg.ID,
+			Sections:  s.standupSections(msg.ChannelID, msg.Text),
+		})
+		if err != nil {
+			logrus.Errorf("CreateStandup failed: %v", err)
+			errorReportToManager := fmt.Sprintf("I could not save standup for user %s in channel %s because of the following reasons: %v", msg.UserID, msg.ChannelID, err)
+			s.platform.SendUserMessage(s.Conf.ManagerSlackUserID, errorReportToManager)
+			s.platform.SendEphemeralMessage(msg.ChannelID, msg.UserID, s.Conf.Translate.StandupHandleCouldNotSaveStandup)
+			return
+		}
+		logrus.Infof("Standup created #id:%v\n", standup.ID)
+		time.Sleep(2 * time.Second)
+		s.platform.markStandup(msg.ChannelID, msg.ID)
+		s.platform.SendEphemeralMessage(msg.ChannelID, msg.UserID, s.Conf.Translate.StandupHandleCreatedStandup)
+	}
+}
+
+// handleEdited updates standup when its message is edited. Edited message which
+// became a standup is saved as a new one
+func (s *standups) handleEdited(msg message, botMention string) {
+	if !isForComedian(msg.Text, botMention) {
+		return
+	}
+	standup, err := s.DB.SelectStandupByMessageTS(msg.ID)
+	if err != nil {
+		messageIsStandup, problem := s.analizeStandup(msg.ChannelID, msg.Text)
+		if problem != "" {
+			s.platform.SendEphemeralMessage(msg.ChannelID, msg.UserID, problem)
+			return
+		}
+		if messageIsStandup {
+			if s.DB.SubmittedStandupToday(msg.UserID, msg.ChannelID) {
+				s.platform.SendEphemeralMessage(msg.ChannelID, msg.UserID, s.Conf.Translate.StandupHandleOneDayOneStandup)
+				return
+			}
+			logrus.Infof("CreateStandup while updating text ChannelID (%v), UserID (%v), Comment (%v), TimeStamp (%v)", msg.ChannelID, msg.UserID, msg.Text, msg.ID)
+			standup, err := s.DB.CreateStandup(model.Standup{
+				ChannelID: msg.ChannelID,
+				UserID:    msg.UserID,
+				Comment:   msg.Text,
+				MessageTS: msg.ID,
+				Sections:  s.standupSections(msg.ChannelID, msg.Text),
+			})
+			if err != nil {
+				logrus.Errorf("CreateStandup while updating text failed: %v", err)
+				errorReportToManager := fmt.Sprintf("I could not create standup while updating msg for user %s in channel %s because of the following reasons: %v", msg.UserID, msg.ChannelID, err)
+				s.platform.SendUserMessage(s.Conf.ManagerSlackUserID, errorReportToManager)
+				s.platform.SendEphemeralMessage(msg.ChannelID, msg.UserID, s.Conf.Translate.StandupHandleCouldNotSaveStandup)
+				return
+			}
+			logrus.Infof("Standup created #id:%v\n", standup.ID)
+			time.Sleep(2 * time.Second)
+			s.platform.markStandup(msg.ChannelID, msg.ID)
+			s.platform.SendEphemeralMessage(msg.ChannelID, msg.UserID, s.Conf.Translate.StandupHandleCreatedStandup)
+			return
+		}
+	}
+
+	messageIsStandup, problem := s.analizeStandup(msg.ChannelID, msg.Text)
+	if problem != "" {
+		s.platform.SendEphemeralMessage(msg.ChannelID, msg.UserID, problem)
+		return
+	}
+	if messageIsStandup {
+		if standup.Comment != msg.Text {
+			_, err := s.DB.AddToStandupHistory(model.StandupEditHistory{
+				StandupID:     standup.ID,
+				StandupText:   standup.Comment,
+				AfterDeadline: s.editedAfterDeadline(standup, time.Now()),
+			})
+			if err != nil {
+				logrus.Errorf("AddToStandupHistory failed: %v", err)
+			}
+		}
+		standup.Comment = msg.Text
+		standup.Sections = s.standupSections(msg.ChannelID, msg.Text)
+		st, _ := s.DB.UpdateStandup(standup)
+		logrus.Infof("Standup updated #id:%v\n", st.ID)
+		time.Sleep(2 * time.Second)
+		s.platform.SendEphemeralMessage(msg.ChannelID, msg.UserID, s.Conf.Translate.StandupHandleUpdatedStandup)
+	}
+}
+
+// handleDeleted marks standup deleted together with its message
+func (s *standups) handleDeleted(messageID string) {
+	standup, err := s.DB.SelectStandupByMessageTS(messageID)
+	if err != nil {
+		logrus.Errorf("SelectStandupByMessageTS failed: %v", err)
+		return
+	}
+	err = s.DB.MarkStandupDeleted(standup.ID)
+	if err != nil {
+		logrus.Errorf("MarkStandupDeleted failed: %v", err)
+		return
+	}
+	logrus.Infof("Standup marked as deleted #id:%v\n", standup.ID)
+}
+
+// standupDeadline returns deadline of a member on the selected day. Timetable deadline
+// has priority over channel standup time. 0 means there is no deadline
+func (s *standups) standupDeadline(userID, channelID string, day time.Time) int64 {
+	member, err := s.DB.FindChannelMemberByUserID(userID, channelID)
+	if err == nil {
+		tt, err := s.DB.SelectTimeTable(member.ID)
+		if err == nil {
+			return tt.ShowDeadlineOn(strings.ToLower(day.Weekday().String()))
+		}
+	}
+	standupTime, err := s.DB.GetChannelStandupTime(channelID)
+	if err != nil {
+		return 0
+	}
+	return standupTime
+}
+
+// editedAfterDeadline shows if standup is edited after the deadline of the day it was
+// submitted in time zone of the member
+func (s *standups) editedAfterDeadline(standup model.Standup, editTime time.Time) bool {
+	day := standup.Created.In(storage.MemberLocation(s.DB, standup.UserID, standup.ChannelID))
+	deadline := s.standupDeadline(standup.UserID, standup.ChannelID, day)
+	if deadline == 0 {
+		return false
+	}
+	return editTime.After(model.DeadlineOn(deadline, day))
+}
+
+// standupSections splits standup text into sections using standup questions of the channel
+func (s *standups) standupSections(channelID, text string) model.StandupSections {
+	questions, err := s.DB.ListStandupQuestions(channelID)
+	if err != nil {
+		logrus.Errorf("ListStandupQuestions failed: %v", err)
+	}
+	return model.ParseStandupSections(text, questions)
+}
+
+// analizeStandup checks if message answers all required standup questions of the channel.
+// Channels without own questions use the default ones: yesterday work, today plans and problems
+func (s *standups) analizeStandup(channelID, message string) (bool, string) {
+	questions, err := s.DB.ListStandupQuestions(channelID)
+	if err != nil {
+		logrus.Errorf("ListStandupQuestions failed: %v", err)
+	}
+	if len(questions) > 0 {
+		for _, q := range questions {
+			if q.Required && !q.IsAnsweredBy(message) {
+				return false, fmt.Sprintf(s.Conf.Translate.StandupHandleQuestionNotAnswered, q.Question)
+			}
+		}
+		return true, ""
+	}
+
+	message = strings.ToLower(message)
+	mentionsProblem := false
+	problemKeys := []string{"problem", "difficult", "stuck", "question", "issue", "block", "проблем", "трудност", "затрдуднени", "вопрос"}
+	for _, problem := range problemKeys {
+		if strings.Contains(message, problem) {
+			mentionsProblem = true
+		}
+	}
+	if !mentionsProblem {
+		return false, s.Conf.Translate.StandupHandleNoProblemsMentioned
+	}
+
+	mentionsYesterdayWork := false
+	yesterdayWorkKeys := []string{"yesterday", "friday", "completed", "вчера", "пятниц", "делал", "сделано"}
+	for _, work := range yesterdayWorkKeys {
+		if strings.Contains(message, work) {
+			mentionsYesterdayWork = true
+		}
+	}
+	if !mentionsYesterdayWork {
+		return false, s.Conf.Translate.StandupHandleNoYesterdayWorkMentioned
+	}
+
+	mentionsTodayPlans := false
+	todayPlansKeys := []string{"today", "going", "plan", "сегодня", "собираюсь", "план"}
+	for _, plan := range todayPlansKeys {
+		if strings.Contains(message, plan) {
+			mentionsTodayPlans = true
+		}
+	}
+	if !mentionsTodayPlans {
+		return false, s.Conf.Translate.StandupHandleNoTodayPlansMentioned
+	}
+	return true, ""
+}
+
+var mentions = regexp.MustCompile(`(^|\s)([@~])([A-Za-z0-9._-]+)`)
+
+// escapeMentions turns @username mentions of known users into <@ID|username> form
+// commands expect, ~channel becomes #channel
+func (s *standups) escapeMentions(text string) string {
+	return mentions.ReplaceAllStringFunc(text, func(mention string) string {
+		parts := mentions.FindStringSubmatch(mention)
+		space, kind, name := parts[1], parts[2], parts[3]
+		if kind == "~" {
+			return space + "#" + name
+		}
+		user, err := s.DB.SelectUserByUserName(name)
+		if err != nil {
+			return mention
+		}
+		return fmt.Sprintf("%v<@%v|%v>", space, user.UserID, user.UserName)
+	})
+}
+
+// updateUser saves user of the workspace and keeps their name and time zone up to date.
+// Deleted users are removed with their channel memberships and timetables
+func (s *standups) updateUser(userID, userName, timeZone string, isAdmin, deleted bool) {
+	if deleted {
+		err := s.DB.DeactivateUser(userID)
+		if err != nil {
+			logrus.Errorf("DeactivateUser failed: %v", err)
+			return
+		}
+		s.ScheduleChanged()
+		return
+	}
+	u, err := s.DB.SelectUser(userID)
+	if err != nil {
+		role := ""
+		if isAdmin {
+			role = "admin"
+		}
+		_, err = s.DB.CreateUser(model.User{
+			UserName: userName,
+			UserID:   userID,
+			Role:     role,
+			TimeZone: timeZone,
+		})
+		if err != nil {
+			logrus.Errorf("CreateUser failed: %v", err)
+		}
+		return
+	}
+	// platforms which do not tell time zones of users keep the ones users have
+	if timeZone == "" {
+		timeZone = u.TimeZone
+	}
+	if u.UserName == userName && u.TimeZone == timeZone {
+		return
+	}
+	moved := u.TimeZone != timeZone
+	if u.UserName != userName {
+		logrus.Infof("User %v is renamed to %v", userID, userName)
+	}
+	old := u
+	u.UserName = userName
+	u.TimeZone = timeZone
+	_, err = s.DB.UpdateUser(u)
+	if err != nil {
+		logrus.Errorf("UpdateUser failed: %v", err)
+		return
+	}
+	// deadlines of user's timetables keep their local time in new time zone
+	if moved {
+		s.moveTimeTables(old, u)
+		s.ScheduleChanged()
+	}
+}
+
+// moveTimeTables keeps hours and minutes of deadlines in user's timetables when time zone
+// of the user changes
+func (s *standups) moveTimeTables(old, u model.User) {
+	members, err := s.DB.FindMembersByUserID(u.UserID)
+	if err != nil {
+		logrus.Errorf("FindMembersByUserID failed: %v", err)
+		return
+	}
+	for _, m := range members {
+		channel, err := s.DB.SelectChannel(m.ChannelID)
+		if err != nil {
+			logrus.Errorf("SelectChannel failed: %v", err)
+			continue
+		}
+		err = storage.MoveTimeTable(s.DB, m.ID, model.MemberLocation(old, channel), model.MemberLocation(u, channel))
+		if err != nil {
+			logrus.Errorf("UpdateTimeTable failed: %v", err)
+		}
+	}
+}
+
+// fillTime is the time of day since which standups missed that day are filled
+const fillTime = 23*time.Hour + 50*time.Minute
+
+//FillStandupsForNonReporters fills standup entries with empty standups to later recognize
+//non reporters vs those who did not have to write standups. Standups are filled at the end
+//of the working day of each standuper in their time zone, so it runs every 10 minutes.
+//Filling is idempotent and the previous day is filled again, so a day is not lost when
+//Comedian was down or busy at its end
+func (s *standups) FillStandupsForNonReporters() {
+	allUsers, err := s.DB.ListAllChannelMembers()
+	if err != nil {
+		return
+	}
+	channels, err := s.DB.GetAllChannels()
+	if err != nil {
+		return
+	}
+	byID := map[string]model.Channel{}
+	for _, channel := range channels {
+		byID[channel.ChannelID] = channel
+	}
+	holidays := map[string][]model.Holiday{}
+	for _, user := range allUsers {
+		// channels Comedian does not know have default working days
+		channel, ok := byID[user.ChannelID]
+		if ok && !channel.Active() {
+			continue
+		}
+		if _, ok := holidays[user.ChannelID]; !ok {
+			holidays[user.ChannelID], err = s.DB.ListHolidays(user.ChannelID)
+			if err != nil {
+				logrus.Errorf("ListHolidays failed: %v", err)
+			}
+		}
+		now := time.Now().In(storage.MemberLocation(s.DB, user.UserID, user.ChannelID))
+		today := model.StartOfDay(now)
+		s.fillStandup(user, channel, holidays[user.ChannelID], today.AddDate(0, 0, -1))
+		if now.Sub(today) >= fillTime {
+			s.fillStandup(user, channel, holidays[user.ChannelID], today)
+		}
+	}
+}
+
+// fillStandup creates empty standup of channel member for the day starting at given time
+// if the member had to write standup that day, but did not
+func (s *standups) fillStandup(user model.ChannelMember, channel model.Channel, holidays []model.Holiday, day time.Time) {
+	if !channel.WorksOn(day) {
+		return
+	}
+	if _, ok := model.HolidayOn(holidays, day); ok {
+		return
+	}
+	if _, away := storage.AbsenceOn(s.DB, user.UserID, day); away {
+		return
+	}
+	// standupers who joined that day did not have to write standup
+	if !user.Created.Before(day) {
+		return
+	}
+	end := day.AddDate(0, 0, 1)
+	_, err := s.DB.SelectStandupsFiltered(user.UserID, user.ChannelID, day, end)
+	if err == nil {
+		return
+	}
+	_, err = s.DB.CreateStandup(model.Standup{
+		ChannelID: user.ChannelID,
+		UserID:    user.UserID,
+		Comment:   "",
+		MessageTS: strconv.Itoa(int(time.Now().Unix())),
+		Created:   end.Add(fillTime - 24*time.Hour),
+	})
+	if err != nil {
+		errorReportToManager := fmt.Sprintf("I could not create empty standup for user %s in channel %s because of the following reasons: %v", user.UserID, user.ChannelID, err)
+		s.platform.SendUserMessage(s.Conf.ManagerSlackUserID, errorReportToManager)
+	}
+}
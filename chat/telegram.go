@@ -0,0 +1,417 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jasonlvhit/gocron"
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/model"
+	"github.com/maddevsio/comedian/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// telegramRetryDelay is a pause before polling Telegram again after an error
+var telegramRetryDelay = 10 * time.Second
+
+// telegramPollTimeout is how long Telegram holds getUpdates request waiting for updates
+const telegramPollTimeout = 50
+
+// Telegram collects standups in Telegram group chats. Chats are channels, mentioning
+// the bot plays the role of #standup. Commands are the same as slash commands in Slack
+type Telegram struct {
+	standups
+	client    *http.Client
+	botUserID string
+	botName   string
+	offset    int64
+	// HandleCommand runs slash command sent as form of Slack command and returns the reply
+	HandleCommand func(form url.Values) string
+}
+
+type telegramUser struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	Username  string `json:"username"`
+}
+
+type telegramChat struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Username string `json:"username"`
+}
+
+type telegramMessage struct {
+	MessageID int64        `json:"message_id"`
+	From      telegramUser `json:"from"`
+	Chat      telegramChat `json:"chat"`
+	Text      string       `json:"text"`
+}
+
+type telegramChatMember struct {
+	Chat          telegramChat `json:"chat"`
+	NewChatMember struct {
+		User   telegramUser `json:"user"`
+		Status string       `json:"status"`
+	} `json:"new_chat_member"`
+}
+
+type telegramUpdate struct {
+	UpdateID      int64               `json:"update_id"`
+	Message       *telegramMessage    `json:"message"`
+	EditedMessage *telegramMessage    `json:"edited_message"`
+	MyChatMember  *telegramChatMember `json:"my_chat_member"`
+}
+
+// telegramError is returned by Telegram Bot API on failed requests
+type telegramError struct {
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		// RetryAfter is how many seconds Telegram asks to wait when requests are rate limited
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+func (e *telegramError) Error() string {
+	return fmt.Sprintf("telegram: %v %v", e.ErrorCode, e.Description)
+}
+
+// NewTelegram creates Telegram bot handler for workspace conf.TeamID
+func NewTelegram(conf config.Config, db storage.Storage) *Telegram {
+	t := &Telegram{client: &http.Client{Timeout: (telegramPollTimeout + 10) * time.Second}}
+	t.platform = t
+	t.Conf = conf
+	t.DB = db.ForTeam(conf.TeamID)
+	t.outbox = newOutbox(t.DB, conf, t)
+	return t
+}
+
+// Run polls Telegram for updates. Returns if Telegram does not accept the token
+func (t *Telegram) Run() {
+	err := t.authenticate()
+	if err != nil {
+		logrus.Errorf("telegram: authentication failed: %v", err)
+		return
+	}
+
+	t.SendUserMessage(t.Conf.ManagerSlackUserID, t.Conf.Translate.HelloManager)
+
+	gocron.Every(10).Minutes().Do(t.FillStandupsForNonReporters)
+	t.runOutbox()
+
+	for {
+		err := t.poll(telegramPollTimeout)
+		if err == nil {
+			continue
+		}
+		if e, ok := err.(*telegramError); ok && e.ErrorCode == http.StatusUnauthorized {
+			logrus.Errorf("telegram: token is not accepted: %v", err)
+			return
+		}
+		logrus.Errorf("telegram: getUpdates failed: %v", err)
+		time.Sleep(telegramRetryDelay)
+	}
+}
+
+// authenticate finds out which user Comedian is
+func (t *Telegram) authenticate() error {
+	me := telegramUser{}
+	err := t.api("getMe", nil, &me)
+	if err != nil {
+		return err
+	}
+	t.botUserID = strconv.FormatInt(me.ID, 10)
+	t.botName = me.Username
+	return nil
+}
+
+// poll handles one batch of updates
+func (t *Telegram) poll(timeout int) error {
+	updates := []telegramUpdate{}
+	err := t.api("getUpdates", map[string]interface{}{
+		"offset":          t.offset,
+		"timeout":         timeout,
+		"allowed_updates": []string{"message", "edited_message", "my_chat_member"},
+	}, &updates)
+	if err != nil {
+		return err
+	}
+	for _, u := range updates {
+		t.offset = u.UpdateID + 1
+		t.handleUpdate(u)
+	}
+	return nil
+}
+
+func (t *Telegram) handleUpdate(u telegramUpdate) {
+	switch {
+	case u.MyChatMember != nil:
+		member := u.MyChatMember.NewChatMember
+		if strconv.FormatInt(member.User.ID, 10) != t.botUserID {
+			return
+		}
+		switch member.Status {
+		case "member", "administrator":
+			t.handleMemberJoined(chatID(u.MyChatMember.Chat), t.botUserID, true)
+		case "left", "kicked":
+			t.setChannelStatus(chatID(u.MyChatMember.Chat), model.ChannelLeft)
+		}
+	case u.Message != nil:
+		msg := u.Message
+		if msg.From.IsBot || msg.Text == "" {
+			return
+		}
+		t.saveSender(*msg)
+		if strings.HasPrefix(msg.Text, "/") {
+			t.handleCommand(*msg)
+			return
+		}
+		if msg.Chat.Type == "private" {
+			t.handleDirect(t.message(*msg))
+			return
+		}
+		t.handlePosted(t.message(*msg), "@"+t.botName)
+	case u.EditedMessage != nil:
+		msg := u.EditedMessage
+		if msg.From.IsBot || msg.Chat.Type == "private" {
+			return
+		}
+		t.handleEdited(t.message(*msg), "@"+t.botName)
+	}
+}
+
+func chatID(chat telegramChat) string {
+	return strconv.FormatInt(chat.ID, 10)
+}
+
+// message turns Telegram message into a platform independent one. Message IDs
+// are unique within a chat only, so chat ID is a part of message ID
+func (t *Telegram) message(msg telegramMessage) message {
+	return message{
+		ChannelID: chatID(msg.Chat),
+		UserID:    strconv.FormatInt(msg.From.ID, 10),
+		Text:      msg.Text,
+		ID:        fmt.Sprintf("%v:%v", msg.Chat.ID, msg.MessageID),
+	}
+}
+
+// saveSender creates user and channel Comedian has not seen yet
+func (t *Telegram) saveSender(msg telegramMessage) {
+	userName := msg.From.Username
+	if userName == "" {
+		userName = msg.From.FirstName
+	}
+	t.updateUser(strconv.FormatInt(msg.From.ID, 10), userName, "", false, false)
+	if msg.Chat.Type != "private" {
+		t.handleJoin(chatID(msg.Chat))
+	}
+}
+
+// handleCommand runs command the same way as Slack slash command and replies with its result
+func (t *Telegram) handleCommand(msg telegramMessage) {
+	if t.HandleCommand == nil {
+		return
+	}
+	fields := strings.SplitN(msg.Text, " ", 2)
+	command := fields[0]
+	// in groups commands may be addressed to a bot: /timetable_show@comedian_bot
+	if i := strings.Index(command, "@"); i > 0 {
+		if !strings.EqualFold(command[i+1:], t.botName) {
+			return
+		}
+		command = command[:i]
+	}
+	text := ""
+	if len(fields) > 1 {
+		text = strings.TrimSpace(fields[1])
+	}
+	channelName, err := t.channelName(chatID(msg.Chat))
+	if err != nil {
+		logrus.Errorf("telegram: channelName failed: %v", err)
+	}
+	form := url.Values{}
+	form.Set("team_id", t.Conf.TeamID)
+	form.Set("user_id", strconv.FormatInt(msg.From.ID, 10))
+	form.Set("user_name", msg.From.Username)
+	form.Set("channel_id", chatID(msg.Chat))
+	form.Set("channel_name", channelName)
+	form.Set("command", command)
+	form.Set("text", t.escapeMentions(text))
+
+	reply := t.HandleCommand(form)
+	if reply != "" {
+		t.SendMessage(chatID(msg.Chat), reply, nil)
+	}
+}
+
+// api calls Telegram Bot API method and decodes its result
+func (t *Telegram) api(method string, params, result interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	u := fmt.Sprintf("%v/bot%v/%v", strings.TrimRight(t.Conf.TelegramURL, "/"), t.Conf.TelegramToken, method)
+	resp, err := t.client.Post(u, "application/json", bytes.NewReader(data))
+	if err != nil {
+		// error contains URL with the token. Error code 0 means Telegram is not reached
+		return &telegramError{Description: fmt.Sprintf("%v request failed", method)}
+	}
+	defer resp.Body.Close()
+	var r struct {
+		telegramError
+		OK     bool            `json:"ok"`
+		Result json.RawMessage `json:"result"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	if err != nil {
+		return fmt.Errorf("telegram: could not decode %v response: %v", method, err)
+	}
+	if !r.OK {
+		return &r.telegramError
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(r.Result, result)
+}
+
+// SendMessage posts a message in a specified chat. Attachments are added as text
+func (t *Telegram) SendMessage(channel, message string, attachments []Attachment) error {
+	return t.outbox.send(model.OutgoingMessage{Kind: model.MessageToChannel, ChannelID: channel, Text: message}, attachments)
+}
+
+func (t *Telegram) deliverMessage(channel, message string, attachments []Attachment) error {
+	text := t.format(message)
+	for _, a := range attachments {
+		text += "\n\n" + telegramColors[a.Color] + t.format(a.Text)
+		for _, f := range a.Fields {
+			if f.Title != "" {
+				text += "\n" + t.format(f.Title)
+			}
+			text += "\n" + t.format(f.Value)
+		}
+	}
+	err := t.api("sendMessage", map[string]interface{}{
+		"chat_id":                  channel,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	}, nil)
+	if err != nil {
+		logrus.Errorf("telegram: sendMessage failed: %v\n", err)
+	}
+	return err
+}
+
+func (t *Telegram) postMessage(channelID, text string) (string, error) {
+	msg := telegramMessage{}
+	err := t.api("sendMessage", map[string]interface{}{
+		"chat_id":                  channelID,
+		"text":                     t.format(text),
+		"disable_web_page_preview": true,
+	}, &msg)
+	if err != nil {
+		return "", err
+	}
+	return t.message(msg).ID, nil
+}
+
+// SendEphemeralMessage posts a message addressed to user in a specified chat. Telegram
+// has no messages visible for one member of a chat only
+func (t *Telegram) SendEphemeralMessage(channel, user, message string) error {
+	return t.outbox.send(model.OutgoingMessage{Kind: model.MessageEphemeral, ChannelID: channel, UserID: user, Text: message}, nil)
+}
+
+func (t *Telegram) deliverEphemeralMessage(channel, user, message string) error {
+	return t.deliverMessage(channel, fmt.Sprintf("<@%v> %v", user, message), nil)
+}
+
+// SendUserMessage sends message to private chat with user. User should start
+// the bot first, otherwise Telegram does not allow the bot to write
+func (t *Telegram) SendUserMessage(userID, message string) error {
+	return t.outbox.send(model.OutgoingMessage{Kind: model.MessageToUser, UserID: userID, Text: message}, nil)
+}
+
+func (t *Telegram) deliverUserMessage(userID, message string) error {
+	return t.deliverMessage(userID, message, nil)
+}
+
+func (t *Telegram) markStandup(channelID, messageID string) {
+	ids := strings.SplitN(messageID, ":", 2)
+	if len(ids) != 2 {
+		return
+	}
+	err := t.api("setMessageReaction", map[string]interface{}{
+		"chat_id":    ids[0],
+		"message_id": ids[1],
+		"reaction":   []map[string]string{{"type": "emoji", "emoji": "👍"}},
+	}, nil)
+	if err != nil {
+		logrus.Errorf("telegram: setMessageReaction failed: %v\n", err)
+	}
+}
+
+// channelName returns username of public chat or title of private one
+func (t *Telegram) channelName(channelID string) (string, error) {
+	chat := telegramChat{}
+	err := t.api("getChat", map[string]string{"chat_id": channelID}, &chat)
+	if err != nil {
+		return "", err
+	}
+	if chat.Username != "" {
+		return chat.Username, nil
+	}
+	return chat.Title, nil
+}
+
+// channelMembers is not supported, Bot API lists only administrators of chats
+func (t *Telegram) channelMembers(channelID string) ([]string, error) {
+	return nil, errors.New("telegram: bots can not list chat members")
+}
+
+// telegramColors stand for attachment colors
+var telegramColors = map[string]string{
+	"good":    "🟢 ",
+	"warning": "🟡 ",
+	"danger":  "🔴 ",
+}
+
+// format turns Slack markup of Comedian messages into plain text: <@ID> becomes
+// @username, <#ID|name> becomes #name
+func (t *Telegram) format(text string) string {
+	return slackMarkup.ReplaceAllStringFunc(text, func(s string) string {
+		parts := slackMarkup.FindStringSubmatch(s)
+		kind, id, label := parts[1], parts[2], parts[3]
+		switch kind {
+		case "@":
+			if user, err := t.DB.SelectUser(id); err == nil {
+				return "@" + user.UserName
+			}
+			if label != "" {
+				return "@" + label
+			}
+			return id
+		case "#":
+			if label != "" {
+				return "#" + label
+			}
+			if channel, err := t.DB.SelectChannel(id); err == nil {
+				return "#" + channel.ChannelName
+			}
+			return "#" + id
+		}
+		if label != "" {
+			return label
+		}
+		return ""
+	})
+}
@@ -0,0 +1,228 @@
+package chat
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/model"
+	"github.com/maddevsio/comedian/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTelegram serves the part of Telegram Bot API Comedian uses
+type fakeTelegram struct {
+	mu        sync.Mutex
+	sent      []map[string]interface{}
+	reactions []string
+	updates   []telegramUpdate
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.HasPrefix(r.URL.Path, "/bottoken/") {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(telegramError{ErrorCode: http.StatusUnauthorized, Description: "Unauthorized"})
+		return
+	}
+	params := map[string]interface{}{}
+	json.NewDecoder(r.Body).Decode(&params)
+	var result interface{}
+	switch strings.TrimPrefix(r.URL.Path, "/bottoken/") {
+	case "getMe":
+		result = telegramUser{ID: 100, IsBot: true, Username: "comedian_bot"}
+	case "getUpdates":
+		result = f.updates
+		f.updates = nil
+	case "getChat":
+		result = telegramChat{ID: -1, Type: "group", Title: "Developers"}
+	case "sendMessage":
+		f.sent = append(f.sent, params)
+		chatID, _ := strconv.ParseInt(params["chat_id"].(string), 10, 64)
+		result = telegramMessage{MessageID: int64(len(f.sent)), Chat: telegramChat{ID: chatID}, Text: params["text"].(string)}
+	case "setMessageReaction":
+		f.reactions = append(f.reactions, params["chat_id"].(string)+":"+params["message_id"].(string))
+		result = true
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(telegramError{ErrorCode: http.StatusNotFound, Description: "Not Found"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+}
+
+func newTestTelegram(t *testing.T, f *fakeTelegram) (*Telegram, *httptest.Server) {
+	server := httptest.NewServer(f)
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	conf := config.Config{
+		Platform:      config.PlatformTelegram,
+		TeamID:        "team1",
+		TelegramURL:   server.URL + "/",
+		TelegramToken: "token",
+		Translate:     translation,
+	}
+	return NewTelegram(conf, storage.NewMemory()), server
+}
+
+func groupMessage(id int64, from telegramUser, text string) *telegramMessage {
+	return &telegramMessage{MessageID: id, From: from, Chat: telegramChat{ID: -1, Type: "group", Title: "Developers"}, Text: text}
+}
+
+func TestTelegramSendMessages(t *testing.T) {
+	f := &fakeTelegram{}
+	tg, server := newTestTelegram(t, f)
+	defer server.Close()
+
+	_, err := tg.DB.CreateUser(model.User{UserID: "1", UserName: "john"})
+	assert.NoError(t, err)
+
+	err = tg.SendMessage("-1", "Hello, <@1> and <@unknown|jane>! See <#C1|general>", []Attachment{
+		{Color: "danger", Text: "<@1> is late", Fields: []AttachmentField{{Title: "Worklogs", Value: "2h"}}},
+	})
+	assert.NoError(t, err)
+	err = tg.SendEphemeralMessage("-1", "1", "Only for you")
+	assert.NoError(t, err)
+	err = tg.SendUserMessage("1", "Hello!")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 3, len(f.sent))
+	assert.Equal(t, "-1", f.sent[0]["chat_id"])
+	assert.Equal(t, "Hello, @john and @jane! See #general\n\n🔴 @john is late\nWorklogs\n2h", f.sent[0]["text"])
+	assert.Equal(t, "@john Only for you", f.sent[1]["text"])
+	assert.Equal(t, "1", f.sent[2]["chat_id"])
+
+	tg.Conf.TelegramToken = "wrong"
+	err = tg.SendMessage("-1", "Hello", nil)
+	assert.Equal(t, &telegramError{ErrorCode: http.StatusUnauthorized, Description: "Unauthorized"}, err)
+}
+
+func TestTelegramPoll(t *testing.T) {
+	user := telegramUser{ID: 1, FirstName: "John", Username: "john"}
+	standup := "@comedian_bot yesterday: fixed bugs, today: tests, problems: none"
+	f := &fakeTelegram{updates: []telegramUpdate{
+		{UpdateID: 1, MyChatMember: &telegramChatMember{Chat: telegramChat{ID: -1, Type: "group", Title: "Developers"}}},
+		{UpdateID: 2, Message: groupMessage(10, user, "just chatting")},
+		{UpdateID: 3, Message: groupMessage(11, telegramUser{ID: 2, IsBot: true}, standup)},
+		{UpdateID: 4, Message: groupMessage(12, user, standup)},
+		{UpdateID: 5, EditedMessage: groupMessage(12, user, standup+" at all")},
+	}}
+	f.updates[0].MyChatMember.NewChatMember.User = telegramUser{ID: 100, IsBot: true}
+	f.updates[0].MyChatMember.NewChatMember.Status = "member"
+	tg, server := newTestTelegram(t, f)
+	defer server.Close()
+	assert.NoError(t, tg.authenticate())
+
+	assert.NoError(t, tg.poll(0))
+	assert.Equal(t, int64(6), tg.offset)
+
+	channel, err := tg.DB.SelectChannel("-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Developers", channel.ChannelName)
+	john, err := tg.DB.SelectUser("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "john", john.UserName)
+
+	s, err := tg.DB.SelectStandupByMessageTS("-1:12")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", s.UserID)
+	assert.Equal(t, standup+" at all", s.Comment)
+	assert.Equal(t, "none at all", s.Sections[model.SectionBlockers])
+	_, err = tg.DB.SelectStandupByMessageTS("-1:10")
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{"-1:12"}, f.reactions)
+	assert.Equal(t, 2, len(f.sent))
+	assert.Equal(t, "@john "+tg.Conf.Translate.StandupHandleCreatedStandup, f.sent[0]["text"])
+	assert.Equal(t, "@john "+tg.Conf.Translate.StandupHandleUpdatedStandup, f.sent[1]["text"])
+
+	// members of group Comedian is removed from are not reminded
+	kicked := telegramUpdate{UpdateID: 6, MyChatMember: &telegramChatMember{Chat: telegramChat{ID: -1, Type: "group"}}}
+	kicked.MyChatMember.NewChatMember.User = telegramUser{ID: 100, IsBot: true}
+	kicked.MyChatMember.NewChatMember.Status = "kicked"
+	tg.handleUpdate(kicked)
+	channel, err = tg.DB.SelectChannel("-1")
+	assert.NoError(t, err)
+	assert.Equal(t, model.ChannelLeft, channel.Status)
+}
+
+func TestTelegramCommands(t *testing.T) {
+	user := telegramUser{ID: 1, Username: "john"}
+	f := &fakeTelegram{updates: []telegramUpdate{
+		{UpdateID: 1, Message: groupMessage(10, user, "/add@comedian_bot @jane / pm")},
+		{UpdateID: 2, Message: groupMessage(11, user, "/add@other_bot @jane")},
+		{UpdateID: 3, Message: groupMessage(12, user, "/helper")},
+	}}
+	tg, server := newTestTelegram(t, f)
+	defer server.Close()
+	assert.NoError(t, tg.authenticate())
+	_, err := tg.DB.CreateUser(model.User{UserID: "2", UserName: "jane"})
+	assert.NoError(t, err)
+
+	forms := []url.Values{}
+	tg.HandleCommand = func(form url.Values) string {
+		forms = append(forms, form)
+		if form.Get("command") == "/helper" {
+			return ""
+		}
+		return "Added <@2>"
+	}
+	assert.NoError(t, tg.poll(0))
+
+	assert.Equal(t, 2, len(forms))
+	assert.Equal(t, url.Values{
+		"team_id":      {"team1"},
+		"user_id":      {"1"},
+		"user_name":    {"john"},
+		"channel_id":   {"-1"},
+		"channel_name": {"Developers"},
+		"command":      {"/add"},
+		"text":         {"<@2|jane> / pm"},
+	}, forms[0])
+	assert.Equal(t, "/helper", forms[1].Get("command"))
+	assert.Equal(t, 1, len(f.sent))
+	assert.Equal(t, "Added @jane", f.sent[0]["text"])
+}
+
+func TestTelegramDirectStandup(t *testing.T) {
+	user := telegramUser{ID: 1, Username: "john"}
+	standup := "yesterday: fixed bugs, today: tests, problems: none"
+	f := &fakeTelegram{updates: []telegramUpdate{
+		{UpdateID: 1, Message: &telegramMessage{MessageID: 5, From: user, Chat: telegramChat{ID: 1, Type: "private"}, Text: standup}},
+	}}
+	tg, server := newTestTelegram(t, f)
+	defer server.Close()
+	assert.NoError(t, tg.authenticate())
+	_, err := tg.DB.CreateChannel(model.Channel{ChannelID: "-1", ChannelName: "Developers"})
+	assert.NoError(t, err)
+	_, err = tg.DB.CreateChannelMember(model.ChannelMember{UserID: "1", ChannelID: "-1", RoleInChannel: "developer"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, tg.poll(0))
+
+	assert.Equal(t, 2, len(f.sent))
+	assert.Equal(t, "-1", f.sent[0]["chat_id"])
+	assert.Equal(t, "Standup of @john:\n"+standup, f.sent[0]["text"])
+	assert.Equal(t, "1", f.sent[1]["chat_id"])
+	assert.Equal(t, "Your standup is posted in #Developers", f.sent[1]["text"])
+	saved, err := tg.DB.SelectStandupByMessageTS("-1:1")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", saved.UserID)
+}
+
+func TestTelegramRunWithWrongToken(t *testing.T) {
+	tg, server := newTestTelegram(t, &fakeTelegram{})
+	defer server.Close()
+	tg.Conf.TelegramToken = "wrong"
+	telegramRetryDelay = time.Millisecond
+	// returns instead of polling forever
+	tg.Run()
+}
@@ -4,23 +4,56 @@ import (
 	"github.com/kelseyhightower/envconfig"
 )
 
+// Ways Comedian receives Slack events
+const (
+	TransportRTM    = "rtm"
+	TransportEvents = "events"
+)
+
+// Chat platforms Comedian works in
+const (
+	PlatformSlack      = "slack"
+	PlatformMattermost = "mattermost"
+	PlatformTelegram   = "telegram"
+)
+
+// Formats of daily and weekly team reports
+const (
+	ReportFormatBlocks      = "blocks"
+	ReportFormatAttachments = "attachments"
+)
+
 // Config struct used for configuration of app with env variables
 type Config struct {
-	SlackToken            string `envconfig:"SLACK_TOKEN" required:"true"`
+	Platform              string `envconfig:"PLATFORM" default:"slack"`
+	SlackToken            string `envconfig:"SLACK_TOKEN"`
+	SlackTransport        string `envconfig:"SLACK_TRANSPORT" default:"rtm"`
+	SigningSecret         string `envconfig:"SLACK_SIGNING_SECRET" default:""`
+	TeamID                string `envconfig:"SLACK_TEAM_ID" default:""`
+	MattermostURL         string `envconfig:"MATTERMOST_URL" default:""`
+	MattermostToken       string `envconfig:"MATTERMOST_TOKEN" default:""`
+	CommandTokens         string `envconfig:"MATTERMOST_COMMAND_TOKENS" default:""`
+	TelegramToken         string `envconfig:"TELEGRAM_TOKEN" default:""`
+	TelegramURL           string `envconfig:"TELEGRAM_URL" default:"https://api.telegram.org"`
+	WorkspacesFile        string `envconfig:"WORKSPACES_FILE" default:""`
 	DatabaseURL           string `envconfig:"DATABASE" required:"true" default:"comedian:comedian@/comedian?parseTime=true"`
 	HTTPBindAddr          string `envconfig:"HTTP_BIND_ADDR" required:"true" default:"0.0.0.0:8080"`
 	NotifierInterval      int    `envconfig:"REMINDER_INTERVAL" required:"true" default:2`
-	ManagerSlackUserID    string `envconfig:"SUPER_ADMIN_ID" required:"true"`
-	ReportingChannel      string `envconfig:"REPORT_CHANNEL" required:"true"`
+	ManagerSlackUserID    string `envconfig:"SUPER_ADMIN_ID"`
+	ReportingChannel      string `envconfig:"REPORT_CHANNEL"`
 	ReportTime            string `envconfig:"REPORT_TIME" required:"true" default:"13:05"`
+	ReportFormat          string `envconfig:"REPORT_FORMAT" default:"blocks"`
+	AutoEnroll            bool   `envconfig:"AUTO_ENROLL" default:"false"`
 	Language              string `envconfig:"LANGUAGE" required:"true" default:"en_US"`
 	ReminderRepeatsMax    int    `envconfig:"MAX_REMINDERS" required:"true" default:5`
 	ReminderTime          int64  `envconfig:"WARNING_TIME" required:"true" default:5`
+	CatchUpTime           int64  `envconfig:"CATCH_UP_TIME" default:"60"`
 	TeamMonitoringEnabled bool   `envconfig:"ENABLE_COLLECTOR" required:"true" default:true`
 	CollectorURL          string `envconfig:"COLLECTOR_URL" required:"true"`
 	CollectorToken        string `envconfig:"COLLECTOR_TOKEN" required:"true"`
 	TeamDomain            string `envconfig:"SLACK_DOMAIN"`
 	SecretToken           string `envconfig:"SECRET_TOKEN" default:""`
+	AutoMigrate           bool   `envconfig:"AUTO_MIGRATE" default:"true"`
 	Translate             Translate
 }
 
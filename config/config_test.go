@@ -1,6 +1,7 @@
 package config
 
 import (
+	"io/ioutil"
 	"os"
 	"testing"
 
@@ -42,3 +43,108 @@ func TestConfig(t *testing.T) {
 	assert.Equal(t, conf.ReminderRepeatsMax, int(5))
 	assert.Equal(t, conf.ReminderTime, int64(10))
 }
+
+func TestWorkspaces(t *testing.T) {
+	c := Config{SlackToken: "token", ManagerSlackUserID: "admin", ReportingChannel: "reports", Language: "en_US"}
+	workspaces, err := c.Workspaces()
+	assert.NoError(t, err)
+	assert.Equal(t, []Config{c}, workspaces)
+
+	_, err = Config{}.Workspaces()
+	assert.Error(t, err)
+
+	file, err := ioutil.TempFile("", "workspaces")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(`[
+		{"team_id": "T1", "slack_token": "token1"},
+		{"team_id": "T2", "slack_token": "token2", "super_admin_id": "admin2", "report_channel": "reports2", "language": "ru_RU"}
+	]`)
+	assert.NoError(t, err)
+	file.Close()
+
+	c.WorkspacesFile = file.Name()
+	workspaces, err = c.Workspaces()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(workspaces))
+	assert.Equal(t, "T1", workspaces[0].TeamID)
+	assert.Equal(t, "token1", workspaces[0].SlackToken)
+	assert.Equal(t, "admin", workspaces[0].ManagerSlackUserID)
+	assert.Equal(t, "reports", workspaces[0].ReportingChannel)
+	assert.Equal(t, "T2", workspaces[1].TeamID)
+	assert.Equal(t, "admin2", workspaces[1].ManagerSlackUserID)
+	assert.Equal(t, "reports2", workspaces[1].ReportingChannel)
+	assert.Equal(t, "ru_RU", workspaces[1].Language)
+
+	ioutil.WriteFile(file.Name(), []byte(`[{"team_id": "T1", "slack_token": "token1"}, {"team_id": "T1", "slack_token": "token2"}]`), 0644)
+	_, err = c.Workspaces()
+	assert.Error(t, err)
+
+	ioutil.WriteFile(file.Name(), []byte(`[{"slack_token": "token1"}]`), 0644)
+	_, err = c.Workspaces()
+	assert.Error(t, err)
+
+	c.SlackTransport = TransportEvents
+	ioutil.WriteFile(file.Name(), []byte(`[{"team_id": "T1", "slack_token": "token1", "signing_secret": "secret1"}, {"team_id": "T2", "slack_token": "token2"}]`), 0644)
+	_, err = c.Workspaces()
+	assert.Error(t, err)
+
+	c.SigningSecret = "secret"
+	workspaces, err = c.Workspaces()
+	assert.NoError(t, err)
+	assert.Equal(t, "secret1", workspaces[0].SigningSecret)
+	assert.Equal(t, "secret", workspaces[1].SigningSecret)
+
+	c.WorkspacesFile = ""
+	c.SlackTransport = "socket"
+	_, err = c.Workspaces()
+	assert.Error(t, err)
+
+	c.SlackTransport = TransportRTM
+	c.WorkspacesFile = file.Name()
+	ioutil.WriteFile(file.Name(), []byte(`[
+		{"team_id": "T1", "slack_token": "token1"},
+		{"team_id": "team2", "platform": "mattermost", "mattermost_url": "https://chat.example.com", "mattermost_token": "token2", "command_tokens": "cmd1,cmd2"}
+	]`), 0644)
+	workspaces, err = c.Workspaces()
+	assert.NoError(t, err)
+	assert.Equal(t, PlatformMattermost, workspaces[1].Platform)
+	assert.Equal(t, "https://chat.example.com", workspaces[1].MattermostURL)
+	assert.Equal(t, "token2", workspaces[1].MattermostToken)
+	assert.Equal(t, "", workspaces[1].SlackToken)
+
+	ioutil.WriteFile(file.Name(), []byte(`[{"team_id": "team2", "platform": "mattermost", "mattermost_url": "https://chat.example.com"}]`), 0644)
+	_, err = c.Workspaces()
+	assert.Error(t, err)
+
+	ioutil.WriteFile(file.Name(), []byte(`[{"team_id": "team2", "platform": "teams", "slack_token": "token1"}]`), 0644)
+	_, err = c.Workspaces()
+	assert.Error(t, err)
+
+	_, err = Config{Platform: PlatformMattermost, MattermostURL: "https://chat.example.com", MattermostToken: "token", CommandTokens: "cmd1"}.Workspaces()
+	assert.NoError(t, err)
+	// commands of Mattermost can not be verified without their tokens
+	_, err = Config{Platform: PlatformMattermost, MattermostURL: "https://chat.example.com", MattermostToken: "token"}.Workspaces()
+	assert.Error(t, err)
+
+	ioutil.WriteFile(file.Name(), []byte(`[{"team_id": "contractors", "platform": "telegram", "telegram_token": "123:abc"}]`), 0644)
+	workspaces, err = c.Workspaces()
+	assert.NoError(t, err)
+	assert.Equal(t, PlatformTelegram, workspaces[0].Platform)
+	assert.Equal(t, "123:abc", workspaces[0].TelegramToken)
+
+	ioutil.WriteFile(file.Name(), []byte(`[{"team_id": "contractors", "platform": "telegram"}]`), 0644)
+	_, err = c.Workspaces()
+	assert.Error(t, err)
+
+	c.ReportFormat = ReportFormatBlocks
+	ioutil.WriteFile(file.Name(), []byte(`[{"team_id": "T1", "slack_token": "token1"}, {"team_id": "T2", "slack_token": "token2", "report_format": "attachments"}]`), 0644)
+	workspaces, err = c.Workspaces()
+	assert.NoError(t, err)
+	assert.Equal(t, ReportFormatBlocks, workspaces[0].ReportFormat)
+	assert.Equal(t, ReportFormatAttachments, workspaces[1].ReportFormat)
+
+	ioutil.WriteFile(file.Name(), []byte(`[{"team_id": "T1", "slack_token": "token1", "report_format": "pdf"}]`), 0644)
+	_, err = c.Workspaces()
+	assert.Error(t, err)
+}
@@ -135,6 +135,124 @@ type Translate struct {
 	SomethingWentWrong   string
 	HelpCommand          string
 	EmptyReportForSunday string
+
+	StandupHistoryNoStandup         string
+	StandupHistoryNotEdited         string
+	StandupHistoryHead              string
+	StandupHistoryOriginal          string
+	StandupHistoryEdit              string
+	StandupHistoryEditAfterDeadline string
+
+	UserDeletedStandup      string
+	StandupRestoreNoDeleted string
+	StandupRestoreHasActive string
+	StandupRestored         string
+
+	StandupHandleQuestionNotAnswered string
+	StandupQuestionsDefault          string
+	StandupQuestionsList             string
+	StandupQuestionsItem             string
+	StandupQuestionsItemOptional     string
+	StandupQuestionAdded             string
+	StandupQuestionRemoved           string
+	StandupQuestionsReset            string
+	StandupQuestionNotFound          string
+	StandupQuestionsUsage            string
+
+	ReportBlockersHead     string
+	UserBlockers           string
+	ReportPlanVsActualHead string
+	UserPlanVsActual       string
+	ReportNoPlan           string
+	ReportNotMentioned     string
+
+	StandupModalTitle        string
+	StandupModalSubmit       string
+	StandupModalClose        string
+	StandupModalYesterday    string
+	StandupModalToday        string
+	StandupModalProblems     string
+	StandupModalEmptyAnswer  string
+	StandupModalNotSupported string
+	WriteStandupButton       string
+
+	DirectStandupNoChannels       string
+	DirectStandupAllSubmitted     string
+	DirectStandupChooseChannels   string
+	DirectStandupProblem          string
+	DirectStandupAlreadySubmitted string
+	DirectStandupPosted           string
+	DirectStandupSaved            string
+
+	OutboxFailuresDigest string
+
+	ReportShowMore string
+
+	AutoEnrollOn            string
+	AutoEnrollOff           string
+	AutoEnrollExcluded      string
+	AutoEnrollTurnedOn      string
+	AutoEnrollTurnedOff     string
+	AutoEnrollExcludedUsers string
+	AutoEnrollIncludedUsers string
+	AutoEnrollUsage         string
+
+	SetupOffer            string
+	SetupButton           string
+	SetupTitle            string
+	SetupSubmit           string
+	SetupDeadline         string
+	SetupDays             string
+	SetupWeekdays         string
+	SetupNoDays           string
+	SetupQuestions        string
+	SetupQuestionsHint    string
+	SetupWrongQuestion    string
+	SetupMembers          string
+	SetupAutoEnroll       string
+	SetupAutoEnrollOption string
+	SetupReports          string
+	SetupDone             string
+
+	TimeZoneShow            string
+	TimeZoneShowDefault     string
+	TimeZoneSet             string
+	TimeZoneSetWithDeadline string
+	TimeZoneUnknown         string
+
+	HolidaysList           string
+	HolidaysItem           string
+	HolidaysItemWorkspace  string
+	HolidaysNone           string
+	HolidayAdded           string
+	HolidayRemoved         string
+	HolidayWrongDay        string
+	HolidaysImported       string
+	HolidaysImportFailed   string
+	HolidaysImporting      string
+	HolidaysWrongLink      string
+	HolidaysUsage          string
+	HolidayStandup         string
+	UserOnHoliday          string
+	UserOnHolidayInChannel string
+
+	VacationStandup         string
+	UserOnVacation          string
+	UserOnVacationInChannel string
+	VacationAdded           string
+	VacationCancelled       string
+	VacationShortened       string
+	VacationCancelWhich     string
+	VacationNotFound        string
+	VacationNone            string
+	VacationList            string
+	VacationItem            string
+	VacationWrongDays       string
+	VacationUsage           string
+
+	WorkingDaysShow  string
+	WorkingDaysSet   string
+	WorkingDaysWrong string
 }
 
 // GetTranslation sets translation files for config
@@ -253,6 +371,110 @@ func GetTranslation(lang string) (Translate, error) {
 		"SomethingWentWrong",
 		"HelpCommand",
 		"EmptyReportForSunday",
+		"StandupHistoryNoStandup",
+		"StandupHistoryNotEdited",
+		"StandupHistoryHead",
+		"StandupHistoryOriginal",
+		"StandupHistoryEdit",
+		"StandupHistoryEditAfterDeadline",
+		"UserDeletedStandup",
+		"StandupRestoreNoDeleted",
+		"StandupRestoreHasActive",
+		"StandupRestored",
+		"StandupHandleQuestionNotAnswered",
+		"StandupQuestionsDefault",
+		"StandupQuestionsList",
+		"StandupQuestionsItem",
+		"StandupQuestionsItemOptional",
+		"StandupQuestionAdded",
+		"StandupQuestionRemoved",
+		"StandupQuestionsReset",
+		"StandupQuestionNotFound",
+		"StandupQuestionsUsage",
+		"ReportBlockersHead",
+		"UserBlockers",
+		"ReportPlanVsActualHead",
+		"UserPlanVsActual",
+		"ReportNoPlan",
+		"ReportNotMentioned",
+		"StandupModalTitle",
+		"StandupModalSubmit",
+		"StandupModalClose",
+		"StandupModalYesterday",
+		"StandupModalToday",
+		"StandupModalProblems",
+		"StandupModalEmptyAnswer",
+		"StandupModalNotSupported",
+		"WriteStandupButton",
+		"DirectStandupNoChannels",
+		"DirectStandupAllSubmitted",
+		"DirectStandupChooseChannels",
+		"DirectStandupProblem",
+		"DirectStandupAlreadySubmitted",
+		"DirectStandupPosted",
+		"DirectStandupSaved",
+		"OutboxFailuresDigest",
+		"ReportShowMore",
+		"AutoEnrollOn",
+		"AutoEnrollOff",
+		"AutoEnrollExcluded",
+		"AutoEnrollTurnedOn",
+		"AutoEnrollTurnedOff",
+		"AutoEnrollExcludedUsers",
+		"AutoEnrollIncludedUsers",
+		"AutoEnrollUsage",
+		"SetupOffer",
+		"SetupButton",
+		"SetupTitle",
+		"SetupSubmit",
+		"SetupDeadline",
+		"SetupDays",
+		"SetupWeekdays",
+		"SetupNoDays",
+		"SetupQuestions",
+		"SetupQuestionsHint",
+		"SetupWrongQuestion",
+		"SetupMembers",
+		"SetupAutoEnroll",
+		"SetupAutoEnrollOption",
+		"SetupReports",
+		"SetupDone",
+		"TimeZoneShow",
+		"TimeZoneShowDefault",
+		"TimeZoneSet",
+		"TimeZoneSetWithDeadline",
+		"TimeZoneUnknown",
+		"HolidaysList",
+		"HolidaysItem",
+		"HolidaysItemWorkspace",
+		"HolidaysNone",
+		"HolidayAdded",
+		"HolidayRemoved",
+		"HolidayWrongDay",
+		"HolidaysImported",
+		"HolidaysImportFailed",
+		"HolidaysImporting",
+		"HolidaysWrongLink",
+		"HolidaysUsage",
+		"HolidayStandup",
+		"UserOnHoliday",
+		"UserOnHolidayInChannel",
+		"VacationStandup",
+		"UserOnVacation",
+		"UserOnVacationInChannel",
+		"VacationAdded",
+		"VacationCancelled",
+		"VacationShortened",
+		"VacationCancelWhich",
+		"VacationNotFound",
+		"VacationNone",
+		"VacationList",
+		"VacationItem",
+		"VacationWrongDays",
+		"VacationUsage",
+		"WorkingDaysShow",
+		"WorkingDaysSet",
+		"WorkingDaysWrong",
 	}
 
 	for _, t := range r {
@@ -383,6 +605,124 @@ func GetTranslation(lang string) (Translate, error) {
 		SomethingWentWrong:   m["SomethingWentWrong"],
 		HelpCommand:          m["HelpCommand"],
 		EmptyReportForSunday: m["EmptyReportForSunday"],
+
+		StandupHistoryNoStandup:         m["StandupHistoryNoStandup"],
+		StandupHistoryNotEdited:         m["StandupHistoryNotEdited"],
+		StandupHistoryHead:              m["StandupHistoryHead"],
+		StandupHistoryOriginal:          m["StandupHistoryOriginal"],
+		StandupHistoryEdit:              m["StandupHistoryEdit"],
+		StandupHistoryEditAfterDeadline: m["StandupHistoryEditAfterDeadline"],
+
+		UserDeletedStandup:      m["UserDeletedStandup"],
+		StandupRestoreNoDeleted: m["StandupRestoreNoDeleted"],
+		StandupRestoreHasActive: m["StandupRestoreHasActive"],
+		StandupRestored:         m["StandupRestored"],
+
+		StandupHandleQuestionNotAnswered: m["StandupHandleQuestionNotAnswered"],
+		StandupQuestionsDefault:          m["StandupQuestionsDefault"],
+		StandupQuestionsList:             m["StandupQuestionsList"],
+		StandupQuestionsItem:             m["StandupQuestionsItem"],
+		StandupQuestionsItemOptional:     m["StandupQuestionsItemOptional"],
+		StandupQuestionAdded:             m["StandupQuestionAdded"],
+		StandupQuestionRemoved:           m["StandupQuestionRemoved"],
+		StandupQuestionsReset:            m["StandupQuestionsReset"],
+		StandupQuestionNotFound:          m["StandupQuestionNotFound"],
+		StandupQuestionsUsage:            m["StandupQuestionsUsage"],
+
+		ReportBlockersHead:     m["ReportBlockersHead"],
+		UserBlockers:           m["UserBlockers"],
+		ReportPlanVsActualHead: m["ReportPlanVsActualHead"],
+		UserPlanVsActual:       m["UserPlanVsActual"],
+		ReportNoPlan:           m["ReportNoPlan"],
+		ReportNotMentioned:     m["ReportNotMentioned"],
+
+		StandupModalTitle:        m["StandupModalTitle"],
+		StandupModalSubmit:       m["StandupModalSubmit"],
+		StandupModalClose:        m["StandupModalClose"],
+		StandupModalYesterday:    m["StandupModalYesterday"],
+		StandupModalToday:        m["StandupModalToday"],
+		StandupModalProblems:     m["StandupModalProblems"],
+		StandupModalEmptyAnswer:  m["StandupModalEmptyAnswer"],
+		StandupModalNotSupported: m["StandupModalNotSupported"],
+		WriteStandupButton:       m["WriteStandupButton"],
+
+		DirectStandupNoChannels:       m["DirectStandupNoChannels"],
+		DirectStandupAllSubmitted:     m["DirectStandupAllSubmitted"],
+		DirectStandupChooseChannels:   m["DirectStandupChooseChannels"],
+		DirectStandupProblem:          m["DirectStandupProblem"],
+		DirectStandupAlreadySubmitted: m["DirectStandupAlreadySubmitted"],
+		DirectStandupPosted:           m["DirectStandupPosted"],
+		DirectStandupSaved:            m["DirectStandupSaved"],
+
+		OutboxFailuresDigest: m["OutboxFailuresDigest"],
+
+		ReportShowMore: m["ReportShowMore"],
+
+		AutoEnrollOn:            m["AutoEnrollOn"],
+		AutoEnrollOff:           m["AutoEnrollOff"],
+		AutoEnrollExcluded:      m["AutoEnrollExcluded"],
+		AutoEnrollTurnedOn:      m["AutoEnrollTurnedOn"],
+		AutoEnrollTurnedOff:     m["AutoEnrollTurnedOff"],
+		AutoEnrollExcludedUsers: m["AutoEnrollExcludedUsers"],
+		AutoEnrollIncludedUsers: m["AutoEnrollIncludedUsers"],
+		AutoEnrollUsage:         m["AutoEnrollUsage"],
+
+		SetupOffer:            m["SetupOffer"],
+		SetupButton:           m["SetupButton"],
+		SetupTitle:            m["SetupTitle"],
+		SetupSubmit:           m["SetupSubmit"],
+		SetupDeadline:         m["SetupDeadline"],
+		SetupDays:             m["SetupDays"],
+		SetupWeekdays:         m["SetupWeekdays"],
+		SetupNoDays:           m["SetupNoDays"],
+		SetupQuestions:        m["SetupQuestions"],
+		SetupQuestionsHint:    m["SetupQuestionsHint"],
+		SetupWrongQuestion:    m["SetupWrongQuestion"],
+		SetupMembers:          m["SetupMembers"],
+		SetupAutoEnroll:       m["SetupAutoEnroll"],
+		SetupAutoEnrollOption: m["SetupAutoEnrollOption"],
+		SetupReports:          m["SetupReports"],
+		SetupDone:             m["SetupDone"],
+
+		TimeZoneShow:            m["TimeZoneShow"],
+		TimeZoneShowDefault:     m["TimeZoneShowDefault"],
+		TimeZoneSet:             m["TimeZoneSet"],
+		TimeZoneSetWithDeadline: m["TimeZoneSetWithDeadline"],
+		TimeZoneUnknown:         m["TimeZoneUnknown"],
+
+		HolidaysList:           m["HolidaysList"],
+		HolidaysItem:           m["HolidaysItem"],
+		HolidaysItemWorkspace:  m["HolidaysItemWorkspace"],
+		HolidaysNone:           m["HolidaysNone"],
+		HolidayAdded:           m["HolidayAdded"],
+		HolidayRemoved:         m["HolidayRemoved"],
+		HolidayWrongDay:        m["HolidayWrongDay"],
+		HolidaysImported:       m["HolidaysImported"],
+		HolidaysImportFailed:   m["HolidaysImportFailed"],
+		HolidaysImporting:      m["HolidaysImporting"],
+		HolidaysWrongLink:      m["HolidaysWrongLink"],
+		HolidaysUsage:          m["HolidaysUsage"],
+		HolidayStandup:         m["HolidayStandup"],
+		UserOnHoliday:          m["UserOnHoliday"],
+		UserOnHolidayInChannel: m["UserOnHolidayInChannel"],
+
+		VacationStandup:         m["VacationStandup"],
+		UserOnVacation:          m["UserOnVacation"],
+		UserOnVacationInChannel: m["UserOnVacationInChannel"],
+		VacationAdded:           m["VacationAdded"],
+		VacationCancelled:       m["VacationCancelled"],
+		VacationShortened:       m["VacationShortened"],
+		VacationCancelWhich:     m["VacationCancelWhich"],
+		VacationNotFound:        m["VacationNotFound"],
+		VacationNone:            m["VacationNone"],
+		VacationList:            m["VacationList"],
+		VacationItem:            m["VacationItem"],
+		VacationWrongDays:       m["VacationWrongDays"],
+		VacationUsage:           m["VacationUsage"],
+
+		WorkingDaysShow:  m["WorkingDaysShow"],
+		WorkingDaysSet:   m["WorkingDaysSet"],
+		WorkingDaysWrong: m["WorkingDaysWrong"],
 	}
 
 	return t, nil
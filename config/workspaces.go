@@ -0,0 +1,160 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Workspace holds settings which differ between Slack workspaces served by one Comedian
+type Workspace struct {
+	TeamID             string `json:"team_id"`
+	Platform           string `json:"platform"`
+	SlackToken         string `json:"slack_token"`
+	MattermostURL      string `json:"mattermost_url"`
+	MattermostToken    string `json:"mattermost_token"`
+	CommandTokens      string `json:"command_tokens"`
+	TelegramToken      string `json:"telegram_token"`
+	SigningSecret      string `json:"signing_secret"`
+	ManagerSlackUserID string `json:"super_admin_id"`
+	ReportingChannel   string `json:"report_channel"`
+	ReportFormat       string `json:"report_format"`
+	Language           string `json:"language"`
+	TeamDomain         string `json:"slack_domain"`
+}
+
+// Workspaces returns configuration for every workspace. Without COMEDIAN_WORKSPACES_FILE
+// the only workspace is the one configured with env variables. Settings missing in
+// the file are taken from env variables
+func (c Config) Workspaces() ([]Config, error) {
+	if c.WorkspacesFile == "" {
+		if (c.Platform == "" || c.Platform == PlatformSlack) && c.SlackToken == "" {
+			return nil, fmt.Errorf("COMEDIAN_SLACK_TOKEN or COMEDIAN_WORKSPACES_FILE should be set")
+		}
+		if err := c.checkPlatform(); err != nil {
+			return nil, err
+		}
+		if err := c.checkReportFormat(); err != nil {
+			return nil, err
+		}
+		return []Config{c}, nil
+	}
+	data, err := ioutil.ReadFile(c.WorkspacesFile)
+	if err != nil {
+		return nil, err
+	}
+	var workspaces []Workspace
+	err = json.Unmarshal(data, &workspaces)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %v: %v", c.WorkspacesFile, err)
+	}
+	if len(workspaces) == 0 {
+		return nil, fmt.Errorf("%v has no workspaces", c.WorkspacesFile)
+	}
+	configs := []Config{}
+	teams := map[string]bool{}
+	for _, w := range workspaces {
+		if w.TeamID == "" {
+			return nil, fmt.Errorf("workspace should have team_id: %+v", w)
+		}
+		if teams[w.TeamID] {
+			return nil, fmt.Errorf("workspace %v is listed twice", w.TeamID)
+		}
+		teams[w.TeamID] = true
+
+		wc := c
+		wc.TeamID = w.TeamID
+		wc.SlackToken = w.SlackToken
+		if w.Platform != "" {
+			wc.Platform = w.Platform
+		}
+		if w.MattermostURL != "" {
+			wc.MattermostURL = w.MattermostURL
+		}
+		wc.MattermostToken = w.MattermostToken
+		wc.TelegramToken = w.TelegramToken
+		if w.CommandTokens != "" {
+			wc.CommandTokens = w.CommandTokens
+		}
+		if w.SigningSecret != "" {
+			wc.SigningSecret = w.SigningSecret
+		}
+		if (wc.Platform == "" || wc.Platform == PlatformSlack) && wc.SlackToken == "" {
+			return nil, fmt.Errorf("workspace %v should have slack_token", w.TeamID)
+		}
+		if err := wc.checkPlatform(); err != nil {
+			return nil, fmt.Errorf("workspace %v: %v", w.TeamID, err)
+		}
+		if w.ManagerSlackUserID != "" {
+			wc.ManagerSlackUserID = w.ManagerSlackUserID
+		}
+		if w.ReportingChannel != "" {
+			wc.ReportingChannel = w.ReportingChannel
+		}
+		if w.ReportFormat != "" {
+			wc.ReportFormat = w.ReportFormat
+		}
+		if err := wc.checkReportFormat(); err != nil {
+			return nil, fmt.Errorf("workspace %v: %v", w.TeamID, err)
+		}
+		if w.TeamDomain != "" {
+			wc.TeamDomain = w.TeamDomain
+		}
+		if w.Language != "" && w.Language != c.Language {
+			wc.Language = w.Language
+			wc.Translate, err = GetTranslation(w.Language)
+			if err != nil {
+				return nil, err
+			}
+		}
+		configs = append(configs, wc)
+	}
+	return configs, nil
+}
+
+// checkPlatform makes sure Comedian can connect to chat platform with configured settings
+func (c Config) checkPlatform() error {
+	switch c.Platform {
+	case "", PlatformSlack:
+		return c.checkTransport()
+	case PlatformMattermost:
+		if c.MattermostURL == "" || c.MattermostToken == "" {
+			return fmt.Errorf("COMEDIAN_MATTERMOST_URL and COMEDIAN_MATTERMOST_TOKEN should be set for Mattermost")
+		}
+		// Mattermost does not sign slash commands, their tokens tell them from forged ones
+		if strings.TrimSpace(strings.Replace(c.CommandTokens, ",", "", -1)) == "" {
+			return fmt.Errorf("COMEDIAN_MATTERMOST_COMMAND_TOKENS should be set for Mattermost")
+		}
+		return nil
+	case PlatformTelegram:
+		if c.TelegramToken == "" {
+			return fmt.Errorf("COMEDIAN_TELEGRAM_TOKEN should be set for Telegram")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown COMEDIAN_PLATFORM %q, should be %q, %q or %q", c.Platform, PlatformSlack, PlatformMattermost, PlatformTelegram)
+}
+
+// checkTransport makes sure Slack events can be received with configured transport
+func (c Config) checkTransport() error {
+	switch c.SlackTransport {
+	case "", TransportRTM:
+		return nil
+	case TransportEvents:
+		if c.SigningSecret == "" {
+			return fmt.Errorf("COMEDIAN_SLACK_SIGNING_SECRET should be set to receive Slack events")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown COMEDIAN_SLACK_TRANSPORT %q, should be %q or %q", c.SlackTransport, TransportRTM, TransportEvents)
+}
+
+// checkReportFormat makes sure team reports can be rendered in configured format
+func (c Config) checkReportFormat() error {
+	switch c.ReportFormat {
+	case "", ReportFormatBlocks, ReportFormatAttachments:
+		return nil
+	}
+	return fmt.Errorf("unknown COMEDIAN_REPORT_FORMAT %q, should be %q or %q", c.ReportFormat, ReportFormatBlocks, ReportFormatAttachments)
+}
@@ -1,6 +1,10 @@
 #!/bin/bash
-dockerize -wait tcp://db:3306 -timeout 90s
-
-echo "Running migrations"
-/goose -dir /migrations mysql $COMEDIAN_DATABASE up
+case "$COMEDIAN_DATABASE" in
+  postgres://*|postgresql://*)
+    dockerize -wait tcp://db:5432 -timeout 90s
+    ;;
+  *)
+    dockerize -wait tcp://db:3306 -timeout 90s
+    ;;
+esac
 /comedian $@
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"sync"
+
+	"github.com/jasonlvhit/gocron"
 	"github.com/maddevsio/comedian/api"
 	"github.com/maddevsio/comedian/chat"
 	"github.com/maddevsio/comedian/config"
 	"github.com/maddevsio/comedian/notifier"
 	"github.com/maddevsio/comedian/reporting"
+	"github.com/maddevsio/comedian/storage"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -15,27 +19,57 @@ func main() {
 		log.Fatal(err)
 	}
 
-	slack, err := chat.NewSlack(c)
+	workspaces, err := c.Workspaces()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	api, err := api.NewRESTAPI(slack)
+	db, err := storage.New(c)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	go func() { log.Fatal(api.Start()) }()
+	version, err := storage.PrepareSchema(db, c.AutoMigrate)
+	if err != nil {
+		log.Fatalf("Database schema version %v: %v", version, err)
+	}
+	log.Infof("Database schema version: %v", version)
 
-	r := reporting.NewReporter(slack)
-	go func() { r.Start() }()
+	messengers := []chat.Messenger{}
+	for _, w := range workspaces {
+		m, err := chat.NewMessenger(w, db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		messengers = append(messengers, m)
+	}
 
-	notifier, err := notifier.NewNotifier(slack)
+	api, err := api.NewRESTAPI(messengers...)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	go func() { log.Fatal(notifier.Start()) }()
+	go func() { log.Fatal(api.Start()) }()
+
+	var wg sync.WaitGroup
+	for _, m := range messengers {
+		r := reporting.NewReporter(m)
+		r.Start()
+
+		notifier, err := notifier.NewNotifier(m)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func() { log.Fatal(notifier.Start()) }()
+
+		wg.Add(1)
+		go func(m chat.Messenger) {
+			defer wg.Done()
+			m.Run()
+			log.Errorf("Workspace %v disconnected", m.Config().TeamID)
+		}(m)
+	}
+	gocron.Start()
 
-	slack.Run()
+	wg.Wait()
 }
@@ -0,0 +1,16 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- Entries created before workspaces were introduced belong to the workspace with empty ID
+ALTER TABLE `channels` ADD `team_id` VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE `users` ADD `team_id` VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE `channel_members` ADD `team_id` VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE `standups` ADD `team_id` VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE `timetables` ADD `team_id` VARCHAR(255) NOT NULL DEFAULT '';
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE `channels` DROP COLUMN `team_id`;
+ALTER TABLE `users` DROP COLUMN `team_id`;
+ALTER TABLE `channel_members` DROP COLUMN `team_id`;
+ALTER TABLE `standups` DROP COLUMN `team_id`;
+ALTER TABLE `timetables` DROP COLUMN `team_id`;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+
+CREATE TABLE `standup_questions` (
+    `id` INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `team_id` VARCHAR(255) NOT NULL DEFAULT '',
+    `channel_id` VARCHAR(255) NOT NULL,
+    `question` VARCHAR(255) NOT NULL,
+    `keywords` TEXT NOT NULL,
+    `required` BOOLEAN NOT NULL DEFAULT TRUE,
+    `created` DATETIME NOT NULL,
+    INDEX `standup_questions_channel_id_idx` (`team_id`, `channel_id`)
+);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+
+DROP TABLE `standup_questions`;
@@ -0,0 +1,28 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- Messages waiting to be delivered to chat, sent and failed ones are kept for failure reports
+
+CREATE TABLE `outbox` (
+    `id` INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `team_id` VARCHAR(255) NOT NULL DEFAULT '',
+    `kind` VARCHAR(20) NOT NULL,
+    `channel_id` VARCHAR(255) NOT NULL DEFAULT '',
+    `user_id` VARCHAR(255) NOT NULL DEFAULT '',
+    `text` TEXT NOT NULL,
+    `attachments` TEXT NOT NULL,
+    `dedup_key` VARCHAR(64) NOT NULL,
+    `attempts` INTEGER NOT NULL DEFAULT 0,
+    `next_attempt` DATETIME NOT NULL,
+    `last_error` TEXT NOT NULL,
+    `created` DATETIME NOT NULL,
+    `sent_at` DATETIME NULL,
+    `failed_at` DATETIME NULL,
+    `reported` BOOLEAN NOT NULL DEFAULT FALSE,
+    INDEX `outbox_next_attempt_idx` (`team_id`, `sent_at`, `failed_at`, `next_attempt`),
+    INDEX `outbox_dedup_key_idx` (`team_id`, `dedup_key`)
+);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+
+DROP TABLE `outbox`;
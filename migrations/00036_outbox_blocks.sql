@@ -0,0 +1,8 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- Block Kit blocks of message stored as JSON array, reports are sent with blocks
+ALTER TABLE `outbox` ADD `blocks` TEXT NOT NULL;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE `outbox` DROP COLUMN `blocks`;
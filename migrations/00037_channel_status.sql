@@ -0,0 +1,9 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- Status of channel in chat: active, archived, left (Comedian was removed) or deleted.
+-- Members of inactive channels are not reminded and not reported
+ALTER TABLE `channels` ADD `status` VARCHAR(20) NOT NULL DEFAULT 'active';
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE `channels` DROP COLUMN `status`;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- IANA time zones deadlines are kept in, server time zone is used if they are empty
+ALTER TABLE `channels` ADD `time_zone` VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE `users` ADD `time_zone` VARCHAR(64) NOT NULL DEFAULT '';
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE `users` DROP COLUMN `time_zone`;
+ALTER TABLE `channels` DROP COLUMN `time_zone`;
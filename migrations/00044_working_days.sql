@@ -0,0 +1,8 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- Days of channel deadline like "sun,mon,tue,wed,thu", Monday to Friday if they are empty
+ALTER TABLE `channels` ADD `working_days` VARCHAR(64) NOT NULL DEFAULT '';
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE `channels` DROP COLUMN `working_days`;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- Channel has one holiday on a day, the latest one is kept
+DELETE h FROM `holidays` h JOIN `holidays` newer
+    ON newer.team_id=h.team_id AND newer.channel_id=h.channel_id AND newer.day=h.day AND newer.id>h.id;
+DROP INDEX `holidays_channel_id_idx` ON `holidays`;
+CREATE UNIQUE INDEX `holidays_channel_id_idx` ON `holidays` (`team_id`, `channel_id`, `day`);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP INDEX `holidays_channel_id_idx` ON `holidays`;
+CREATE INDEX `holidays_channel_id_idx` ON `holidays` (`team_id`, `channel_id`, `day`);
@@ -0,0 +1,18 @@
+// Package migrations embeds database migrations into comedian binary.
+// Migrations are kept in goose format, MySQL ones are stored in the root
+// of the package and PostgreSQL ones in postgres directory
+package migrations
+
+import "embed"
+
+// Files contains all migrations files
+//
+//go:embed *.sql postgres/*.sql
+var Files embed.FS
+
+const (
+	// MySQL is a directory with MySQL migrations
+	MySQL = "."
+	// Postgres is a directory with PostgreSQL migrations
+	Postgres = "postgres"
+)
@@ -0,0 +1,70 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- PostgreSQL schema equivalent to MySQL migrations 00001-00029.
+-- Numbering continues from the MySQL history so both databases report the same version.
+
+CREATE TABLE standups (
+    id SERIAL PRIMARY KEY,
+    created TIMESTAMP WITH TIME ZONE NOT NULL,
+    modified TIMESTAMP WITH TIME ZONE NOT NULL,
+    channel_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    comment TEXT NOT NULL,
+    message_ts VARCHAR(255) NOT NULL
+);
+CREATE INDEX standups_created_idx ON standups (created, user_id);
+
+CREATE TABLE standup_edit_history (
+    id SERIAL PRIMARY KEY,
+    created TIMESTAMP WITH TIME ZONE NOT NULL,
+    standup_id VARCHAR(255) NOT NULL,
+    standup_text TEXT NOT NULL
+);
+CREATE INDEX standup_edit_history_standup_id_idx ON standup_edit_history (standup_id);
+
+CREATE TABLE channels (
+    id SERIAL PRIMARY KEY,
+    channel_name VARCHAR(255) NOT NULL,
+    channel_id VARCHAR(255) NOT NULL,
+    channel_standup_time BIGINT NOT NULL
+);
+
+CREATE TABLE users (
+    id SERIAL PRIMARY KEY,
+    user_name VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    role VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE channel_members (
+    id SERIAL PRIMARY KEY,
+    channel_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255),
+    standup_time BIGINT NOT NULL,
+    created TIMESTAMP WITH TIME ZONE,
+    role_in_channel VARCHAR(255) DEFAULT ''
+);
+
+CREATE TABLE timetables (
+    id SERIAL PRIMARY KEY,
+    channel_member_id INTEGER NOT NULL DEFAULT 0,
+    created TIMESTAMP WITH TIME ZONE NOT NULL,
+    modified TIMESTAMP WITH TIME ZONE NOT NULL,
+    monday BIGINT NOT NULL DEFAULT 0,
+    tuesday BIGINT NOT NULL DEFAULT 0,
+    wednesday BIGINT NOT NULL DEFAULT 0,
+    thursday BIGINT NOT NULL DEFAULT 0,
+    friday BIGINT NOT NULL DEFAULT 0,
+    saturday BIGINT NOT NULL DEFAULT 0,
+    sunday BIGINT NOT NULL DEFAULT 0
+);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+
+DROP TABLE timetables;
+DROP TABLE channel_members;
+DROP TABLE users;
+DROP TABLE channels;
+DROP TABLE standup_edit_history;
+DROP TABLE standups;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- Sections of standup text (yesterday, today, blockers, ...) stored as JSON object
+ALTER TABLE standups ADD sections TEXT NULL;
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE standups DROP COLUMN sections;
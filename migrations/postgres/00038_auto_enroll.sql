@@ -0,0 +1,17 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- Members of channels with auto-enroll are added and removed when they join and leave channel
+ALTER TABLE channels ADD auto_enroll BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE auto_enroll_exclusions (
+    id SERIAL PRIMARY KEY,
+    team_id VARCHAR(255) NOT NULL DEFAULT '',
+    channel_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL
+);
+CREATE INDEX auto_enroll_exclusions_channel_id_idx ON auto_enroll_exclusions (team_id, channel_id);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE auto_enroll_exclusions;
+ALTER TABLE channels DROP COLUMN auto_enroll;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- Reports on channel members are sent to report channel of channel, to the channel itself if it is empty
+ALTER TABLE channels ADD report_channel_id VARCHAR(255) NOT NULL DEFAULT '';
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+ALTER TABLE channels DROP COLUMN report_channel_id;
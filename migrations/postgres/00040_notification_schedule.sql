@@ -0,0 +1,19 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- Next fire times of deadline reminders, warnings and timetable reminders, so that reminders
+-- missed while Comedian was down are sent after restart
+
+CREATE TABLE notification_schedule (
+    id SERIAL PRIMARY KEY,
+    team_id VARCHAR(255) NOT NULL DEFAULT '',
+    kind VARCHAR(20) NOT NULL,
+    channel_id VARCHAR(255) NOT NULL DEFAULT '',
+    channel_member_id INTEGER NOT NULL DEFAULT 0,
+    fire_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+CREATE INDEX notification_schedule_fire_at_idx ON notification_schedule (team_id, fire_at);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+
+DROP TABLE notification_schedule;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- Days off of the workspace (empty channel_id) and of channels, members are neither reminded
+-- nor recorded as non reporters on them
+
+CREATE TABLE holidays (
+    id SERIAL PRIMARY KEY,
+    team_id VARCHAR(255) NOT NULL DEFAULT '',
+    channel_id VARCHAR(255) NOT NULL DEFAULT '',
+    day VARCHAR(10) NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT ''
+);
+CREATE INDEX holidays_channel_id_idx ON holidays (team_id, channel_id, day);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+
+DROP TABLE holidays;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- Periods users are away in all channels of the workspace, e.g. on vacation. They are
+-- neither reminded nor recorded as non reporters meanwhile
+
+CREATE TABLE absences (
+    id SERIAL PRIMARY KEY,
+    team_id VARCHAR(255) NOT NULL DEFAULT '',
+    user_id VARCHAR(255) NOT NULL,
+    first_day VARCHAR(10) NOT NULL,
+    last_day VARCHAR(10) NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT ''
+);
+CREATE INDEX absences_user_id_idx ON absences (team_id, user_id, first_day);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+
+DROP TABLE absences;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- Dedup key of message waiting for delivery, identical messages can not wait together
+ALTER TABLE outbox ADD pending_key VARCHAR(64) NULL;
+UPDATE outbox SET pending_key=dedup_key WHERE id IN (
+    SELECT MIN(id) FROM outbox WHERE sent_at IS NULL AND failed_at IS NULL GROUP BY team_id, dedup_key
+);
+CREATE UNIQUE INDEX outbox_pending_key_idx ON outbox (team_id, pending_key);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP INDEX outbox_pending_key_idx;
+ALTER TABLE outbox DROP COLUMN pending_key;
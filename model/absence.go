@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// AbsenceOn returns absence from the list which includes the day in time zone of the day
+func AbsenceOn(absences []Absence, day time.Time) (Absence, bool) {
+	date := day.Format(HolidayDay)
+	for _, a := range absences {
+		if a.FirstDay <= date && date <= a.LastDay {
+			return a, true
+		}
+	}
+	return Absence{}, false
+}
+
+// AwayAllDays returns true if every day of time period, in time zone of dateFrom, is a day
+// of absence from the list
+func AwayAllDays(absences []Absence, dateFrom, dateTo time.Time) bool {
+	last := dateTo.In(dateFrom.Location()).Format(HolidayDay)
+	for day := StartOfDay(dateFrom); day.Format(HolidayDay) <= last; day = day.AddDate(0, 0, 1) {
+		if _, ok := AbsenceOn(absences, day); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// HolidayDay is the layout of holiday dates
+const HolidayDay = "2006-01-02"
+
+// HolidayOn returns holiday from the list which is on the day in time zone of the day
+func HolidayOn(holidays []Holiday, day time.Time) (Holiday, bool) {
+	date := day.Format(HolidayDay)
+	for _, h := range holidays {
+		if h.Day == date {
+			return h, true
+		}
+	}
+	return Holiday{}, false
+}
@@ -3,7 +3,10 @@ package model
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/maddevsio/comedian/config"
 )
@@ -11,34 +14,50 @@ import (
 type (
 	// Standup model used for serialization/deserialization stored standups
 	Standup struct {
-		ID        int64     `db:"id" json:"id"`
-		Created   time.Time `db:"created" json:"created"`
-		Modified  time.Time `db:"modified" json:"modified"`
-		ChannelID string    `db:"channel_id" json:"channelId"`
-		UserID    string    `db:"user_id" json:"userId"`
-		Comment   string    `db:"comment" json:"comment"`
-		MessageTS string    `db:"message_ts" json:"message_ts"`
+		ID        int64           `db:"id" json:"id"`
+		TeamID    string          `db:"team_id" json:"team_id"`
+		Created   time.Time       `db:"created" json:"created"`
+		Modified  time.Time       `db:"modified" json:"modified"`
+		ChannelID string          `db:"channel_id" json:"channelId"`
+		UserID    string          `db:"user_id" json:"userId"`
+		Comment   string          `db:"comment" json:"comment"`
+		MessageTS string          `db:"message_ts" json:"message_ts"`
+		Sections  StandupSections `db:"sections" json:"sections"`
+		DeletedAt *time.Time      `db:"deleted_at" json:"deleted_at"`
 	}
 
 	// User model used for serialization/deserialization stored Users
 	User struct {
 		ID       int64  `db:"id" json:"id"`
+		TeamID   string `db:"team_id" json:"team_id"`
 		UserName string `db:"user_name" json:"user_name"`
 		UserID   string `db:"user_id" json:"user_id"`
 		Role     string `db:"role" json:"role"`
+		// TimeZone is IANA time zone of user synced from the chat profile
+		TimeZone string `db:"time_zone" json:"time_zone"`
 	}
 
 	// Channel model used for serialization/deserialization stored Channels
 	Channel struct {
 		ID          int64  `db:"id" json:"id"`
+		TeamID      string `db:"team_id" json:"team_id"`
 		ChannelName string `db:"channel_name" json:"channel_name"`
 		ChannelID   string `db:"channel_id" json:"channel_id"`
 		StandupTime int64  `db:"channel_standup_time" json:"time"`
+		Status      string `db:"status" json:"status"`
+		AutoEnroll  bool   `db:"auto_enroll" json:"auto_enroll"`
+		// ReportChannelID is where reports on channel members go, they go to the channel itself if it is empty
+		ReportChannelID string `db:"report_channel_id" json:"report_channel_id"`
+		// TimeZone is IANA time zone of the channel deadline, server time zone is used if it is empty
+		TimeZone string `db:"time_zone" json:"time_zone"`
+		// WorkingDays are days of channel deadline like "sun,mon,tue,wed,thu", Monday to Friday if it is empty
+		WorkingDays string `db:"working_days" json:"working_days"`
 	}
 
 	// ChannelMember model used for serialization/deserialization stored ChannelMembers
 	ChannelMember struct {
 		ID            int64     `db:"id" json:"id"`
+		TeamID        string    `db:"team_id" json:"team_id"`
 		UserID        string    `db:"user_id" json:"user_id"`
 		ChannelID     string    `db:"channel_id" json:"channel_id"`
 		RoleInChannel string    `db:"role_in_channel" json:"role_in_channel"`
@@ -49,6 +68,7 @@ type (
 	// TimeTable model used for serialization/deserialization stored timetables
 	TimeTable struct {
 		ID              int64     `db:"id" json:"id"`
+		TeamID          string    `db:"team_id" json:"team_id"`
 		ChannelMemberID int64     `db:"channel_member_id" json:"channel_member_id"`
 		Created         time.Time `db:"created" json:"created"`
 		Modified        time.Time `db:"modified" json:"modified"`
@@ -63,13 +83,117 @@ type (
 
 	// StandupEditHistory model used for serialization/deserialization stored standup edit history
 	StandupEditHistory struct {
-		ID          int64     `db:"id" json:"id"`
-		Created     time.Time `db:"created" json:"created"`
-		StandupID   int64     `db:"standup_id" json:"standupId"`
-		StandupText string    `db:"standuptext" json:"standuptext"`
+		ID            int64     `db:"id" json:"id"`
+		Created       time.Time `db:"created" json:"created"`
+		StandupID     int64     `db:"standup_id" json:"standupId"`
+		StandupText   string    `db:"standup_text" json:"standuptext"`
+		AfterDeadline bool      `db:"after_deadline" json:"after_deadline"`
+	}
+
+	// StandupQuestion model used for serialization/deserialization stored standup questions of channel
+	StandupQuestion struct {
+		ID        int64     `db:"id" json:"id"`
+		TeamID    string    `db:"team_id" json:"team_id"`
+		ChannelID string    `db:"channel_id" json:"channel_id"`
+		Question  string    `db:"question" json:"question"`
+		Keywords  string    `db:"keywords" json:"keywords"`
+		Required  bool      `db:"required" json:"required"`
+		Created   time.Time `db:"created" json:"created"`
+	}
+
+	// OutgoingMessage is a message waiting in outbox to be delivered to chat
+	OutgoingMessage struct {
+		ID        int64  `db:"id" json:"id"`
+		TeamID    string `db:"team_id" json:"team_id"`
+		Kind      string `db:"kind" json:"kind"`
+		ChannelID string `db:"channel_id" json:"channel_id"`
+		UserID    string `db:"user_id" json:"user_id"`
+		Text      string `db:"text" json:"text"`
+		// Attachments and Block Kit blocks are kept as JSON
+		Attachments string `db:"attachments" json:"attachments"`
+		Blocks      string `db:"blocks" json:"blocks"`
+		// DedupKey is the same for identical messages
+		DedupKey    string     `db:"dedup_key" json:"dedup_key"`
+		Attempts    int        `db:"attempts" json:"attempts"`
+		NextAttempt time.Time  `db:"next_attempt" json:"next_attempt"`
+		LastError   string     `db:"last_error" json:"last_error"`
+		Created     time.Time  `db:"created" json:"created"`
+		SentAt      *time.Time `db:"sent_at" json:"sent_at"`
+		FailedAt    *time.Time `db:"failed_at" json:"failed_at"`
+		Reported    bool       `db:"reported" json:"reported"`
+	}
+
+	// ScheduledNotification is the next time notifier reminds about deadline of channel
+	// or about deadline of channel member's timetable
+	ScheduledNotification struct {
+		ID              int64     `db:"id" json:"id"`
+		TeamID          string    `db:"team_id" json:"team_id"`
+		Kind            string    `db:"kind" json:"kind"`
+		ChannelID       string    `db:"channel_id" json:"channel_id"`
+		ChannelMemberID int64     `db:"channel_member_id" json:"channel_member_id"`
+		FireAt          time.Time `db:"fire_at" json:"fire_at"`
+	}
+
+	// Holiday is a day off of the workspace or, if ChannelID is set, of the channel
+	Holiday struct {
+		ID        int64  `db:"id" json:"id"`
+		TeamID    string `db:"team_id" json:"team_id"`
+		ChannelID string `db:"channel_id" json:"channel_id"`
+		// Day is the date of holiday written as HolidayDay
+		Day  string `db:"day" json:"day"`
+		Name string `db:"name" json:"name"`
+	}
+
+	// Absence is a period user is away, e.g. on vacation, in all channels of the workspace
+	Absence struct {
+		ID     int64  `db:"id" json:"id"`
+		TeamID string `db:"team_id" json:"team_id"`
+		UserID string `db:"user_id" json:"user_id"`
+		// FirstDay and LastDay are dates written as HolidayDay, both are days of absence
+		FirstDay string `db:"first_day" json:"first_day"`
+		LastDay  string `db:"last_day" json:"last_day"`
+		Reason   string `db:"reason" json:"reason"`
 	}
 )
 
+// Kinds of scheduled notifications. Warnings are sent before deadlines, reminders of
+// channels are sent to channel deadline, individual ones to deadline of timetable
+const (
+	NotifyChannelWarning     = "channel_warning"
+	NotifyChannelDeadline    = "channel_deadline"
+	NotifyIndividualWarning  = "member_warning"
+	NotifyIndividualDeadline = "member_deadline"
+)
+
+// Kinds of outgoing messages
+const (
+	MessageToChannel = "channel"
+	MessageEphemeral = "ephemeral"
+	MessageToUser    = "user"
+)
+
+// Statuses of channels. Comedian reminds and reports only active channels, archived and
+// left ones become active again when unarchived or when Comedian is invited back
+const (
+	ChannelActive   = "active"
+	ChannelArchived = "archived"
+	ChannelLeft     = "left"
+	ChannelDeleted  = "deleted"
+)
+
+// Active shows if members of channel are reminded and reported
+func (c Channel) Active() bool {
+	return c.Status == "" || c.Status == ChannelActive
+}
+
+// ReportChannel returns channel reports on channel members are sent to
+func (c Channel) ReportChannel() string {
+	if c.ReportChannelID != "" {
+		return c.ReportChannelID
+	}
+	return c.ChannelID
+}
+
 // Validate validates Standup struct
 func (c Standup) Validate() error {
 	if c.UserID == "" {
@@ -101,6 +225,132 @@ func (c StandupEditHistory) Validate() error {
 	return nil
 }
 
+// Validate validates StandupQuestion struct
+func (q StandupQuestion) Validate() error {
+	if q.ChannelID == "" {
+		err := errors.New("Channel cannot be empty")
+		return err
+	}
+	if strings.TrimSpace(q.Question) == "" {
+		err := errors.New("Question cannot be empty")
+		return err
+	}
+	return nil
+}
+
+// ParseStandupQuestion parses question of channel written as "question / keywords / optional",
+// keywords and "optional" may be omitted
+func ParseStandupQuestion(channelID, text string) (StandupQuestion, error) {
+	parts := strings.Split(text, "/")
+	q := StandupQuestion{
+		ChannelID: channelID,
+		Question:  strings.TrimSpace(parts[0]),
+		Required:  true,
+	}
+	if len(parts) > 1 {
+		q.Keywords = strings.TrimSpace(parts[1])
+	}
+	if len(parts) > 2 {
+		q.Required = strings.TrimSpace(parts[2]) != "optional"
+	}
+	if len(parts) > 3 {
+		return q, errors.New("Question has too many parts")
+	}
+	return q, q.Validate()
+}
+
+// Text returns question written the way ParseStandupQuestion parses it
+func (q StandupQuestion) Text() string {
+	text := q.Question
+	if q.Keywords != "" || !q.Required {
+		text += " / " + q.Keywords
+	}
+	if !q.Required {
+		text += " / optional"
+	}
+	return text
+}
+
+//KeywordList returns lowercased keywords of question, Keywords are separated by commas
+func (q StandupQuestion) KeywordList() []string {
+	keywords := []string{}
+	for _, k := range strings.Split(q.Keywords, ",") {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if k != "" {
+			keywords = append(keywords, k)
+		}
+	}
+	return keywords
+}
+
+//IsAnsweredBy shows if message mentions one of the question keywords
+//or has a line starting with the question itself (e.g. "Blockers: none").
+//Keywords and question match whole words only, "done" does not answer "undone"
+func (q StandupQuestion) IsAnsweredBy(message string) bool {
+	message = strings.ToLower(message)
+	for _, k := range q.KeywordList() {
+		if containsWord(message, k) {
+			return true
+		}
+	}
+	header := strings.ToLower(strings.TrimSpace(q.Question))
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimLeft(strings.TrimSpace(line), "*_-• ")
+		if strings.HasPrefix(line, header) && !isWordRune(strings.TrimPrefix(line, header), false) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsWord shows if word is found in text not as a part of a longer word
+func containsWord(text, word string) bool {
+	for i := strings.Index(text, word); i >= 0; {
+		if !isWordRune(text[:i], true) && !isWordRune(text[i+len(word):], false) {
+			return true
+		}
+		next := strings.Index(text[i+1:], word)
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return false
+}
+
+// isWordRune shows if the last (or the first) rune of text is a letter or a digit
+func isWordRune(text string, last bool) bool {
+	var r rune
+	var size int
+	if last {
+		r, size = utf8.DecodeLastRuneInString(text)
+	} else {
+		r, size = utf8.DecodeRuneInString(text)
+	}
+	return size > 0 && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// Validate validates OutgoingMessage struct
+func (m OutgoingMessage) Validate() error {
+	switch m.Kind {
+	case MessageToChannel:
+		if m.ChannelID == "" {
+			return errors.New("Channel cannot be empty")
+		}
+	case MessageEphemeral:
+		if m.ChannelID == "" || m.UserID == "" {
+			return errors.New("User/Channel cannot be empty")
+		}
+	case MessageToUser:
+		if m.UserID == "" {
+			return errors.New("User cannot be empty")
+		}
+	default:
+		return fmt.Errorf("Unknown message kind %v", m.Kind)
+	}
+	return nil
+}
+
 //IsAdmin returns user status
 func (u User) IsAdmin() bool {
 	if u.Role == "admin" {
@@ -109,36 +359,36 @@ func (u User) IsAdmin() bool {
 	return false
 }
 
-//Show shows timetable
-func (tt TimeTable) Show() string {
+//Show shows timetable with deadlines in the time zone
+func (tt TimeTable) Show(loc *time.Location) string {
 	c, _ := config.Get()
 	timeTableString := ""
 	if tt.Monday != 0 {
-		monday := time.Unix(tt.Monday, 0)
+		monday := time.Unix(tt.Monday, 0).In(loc)
 		timeTableString += fmt.Sprintf(c.Translate.TimetableShowMonday, monday.Hour(), monday.Minute())
 	}
 	if tt.Tuesday != 0 {
-		tuesday := time.Unix(tt.Tuesday, 0)
+		tuesday := time.Unix(tt.Tuesday, 0).In(loc)
 		timeTableString += fmt.Sprintf(c.Translate.TimetableShowTuesday, tuesday.Hour(), tuesday.Minute())
 	}
 	if tt.Wednesday != 0 {
-		wednesday := time.Unix(tt.Wednesday, 0)
+		wednesday := time.Unix(tt.Wednesday, 0).In(loc)
 		timeTableString += fmt.Sprintf(c.Translate.TimetableShowWednesday, wednesday.Hour(), wednesday.Minute())
 	}
 	if tt.Thursday != 0 {
-		thursday := time.Unix(tt.Thursday, 0)
+		thursday := time.Unix(tt.Thursday, 0).In(loc)
 		timeTableString += fmt.Sprintf(c.Translate.TimetableShowThursday, thursday.Hour(), thursday.Minute())
 	}
 	if tt.Friday != 0 {
-		friday := time.Unix(tt.Friday, 0)
+		friday := time.Unix(tt.Friday, 0).In(loc)
 		timeTableString += fmt.Sprintf(c.Translate.TimetableShowFriday, friday.Hour(), friday.Minute())
 	}
 	if tt.Saturday != 0 {
-		saturday := time.Unix(tt.Saturday, 0)
+		saturday := time.Unix(tt.Saturday, 0).In(loc)
 		timeTableString += fmt.Sprintf(c.Translate.TimetableShowSaturday, saturday.Hour(), saturday.Minute())
 	}
 	if tt.Sunday != 0 {
-		sunday := time.Unix(tt.Sunday, 0)
+		sunday := time.Unix(tt.Sunday, 0).In(loc)
 		timeTableString += fmt.Sprintf(c.Translate.TimetableShowSunday, sunday.Hour(), sunday.Minute())
 	}
 
@@ -0,0 +1,201 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Sections every standup is parsed into. Custom channel questions add their own sections
+const (
+	SectionYesterday = "yesterday"
+	SectionToday     = "today"
+	SectionBlockers  = "blockers"
+)
+
+// defaultSectionWords are words which start default sections
+var defaultSectionWords = map[string][]string{
+	SectionYesterday: {"yesterday", "friday", "completed", "done", "вчера", "пятниц", "сделано", "делал"},
+	SectionToday:     {"today", "going", "plan", "doing", "сегодня", "собираюсь", "план"},
+	SectionBlockers:  {"problem", "blocker", "block", "difficult", "stuck", "question", "issue", "проблем", "трудност", "затруднени", "вопрос"},
+}
+
+// StandupSections keeps standup text split by sections, e.g. "yesterday", "today", "blockers"
+type StandupSections map[string]string
+
+// Value stores sections in database as JSON
+func (s StandupSections) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads sections stored in database as JSON
+func (s *StandupSections) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("could not scan %T into StandupSections", src)
+	}
+	*s = nil
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, s)
+}
+
+type sectionWord struct {
+	word    []rune
+	section string
+}
+
+// SectionOf returns default section question belongs to (e.g. "Done" is "yesterday")
+// or lowercased question for custom ones
+func SectionOf(question string) string {
+	q := strings.ToLower(strings.TrimSpace(question))
+	for _, section := range []string{SectionYesterday, SectionToday, SectionBlockers} {
+		if strings.HasPrefix(q, section) {
+			return section
+		}
+		for _, word := range defaultSectionWords[section] {
+			if strings.HasPrefix(q, word) {
+				return section
+			}
+		}
+	}
+	return q
+}
+
+func sectionWords(questions []StandupQuestion) []sectionWord {
+	words := []sectionWord{}
+	for _, q := range questions {
+		section := SectionOf(q.Question)
+		words = append(words, sectionWord{[]rune(strings.ToLower(strings.TrimSpace(q.Question))), section})
+		for _, k := range q.KeywordList() {
+			words = append(words, sectionWord{[]rune(k), section})
+		}
+	}
+	for _, section := range []string{SectionYesterday, SectionToday, SectionBlockers} {
+		for _, word := range defaultSectionWords[section] {
+			words = append(words, sectionWord{[]rune(word), section})
+		}
+	}
+	// longer words win, channel questions win over defaults
+	sort.SliceStable(words, func(i, j int) bool { return len(words[i].word) > len(words[j].word) })
+	return words
+}
+
+func hasRunePrefix(s, prefix []rune) bool {
+	if len(prefix) == 0 || len(s) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if s[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// startsSection shows if word at position i starts a new line, sentence or list item
+func startsSection(text []rune, i int) bool {
+	if i > 0 && (unicode.IsLetter(text[i-1]) || unicode.IsDigit(text[i-1])) {
+		return false
+	}
+	j := i - 1
+	for j >= 0 && strings.ContainsRune(" \t*_-•", text[j]) {
+		j--
+	}
+	return j < 0 || strings.ContainsRune("\n.,;!?>)", text[j])
+}
+
+// sectionText cuts the header off section text: everything up to a colon
+// on the first line or the rest of the word which started section
+func sectionText(text []rune) string {
+	firstLine := len(text)
+	for i, r := range text {
+		if r == '\n' {
+			firstLine = i
+			break
+		}
+	}
+	cut := -1
+	for i := 0; i < firstLine && i < 30; i++ {
+		if text[i] == ':' {
+			// "Plans for today:", but not "Today I will fix: bugs"
+			if len(strings.Fields(string(text[:i]))) <= 3 {
+				cut = i + 1
+			}
+			break
+		}
+	}
+	if cut < 0 {
+		cut = 0
+		for cut < len(text) && unicode.IsLetter(text[cut]) {
+			cut++
+		}
+	}
+	return strings.TrimRight(strings.TrimLeft(string(text[cut:]), " \t\n*_:"), " \t\n,;*_")
+}
+
+// ParseStandupSections splits standup text into sections. Section starts with one of
+// its words at the beginning of a line, sentence or list item: "Yesterday: fixed bugs",
+// "today I plan to...". Channel questions add sections named after SectionOf(question)
+func ParseStandupSections(text string, questions []StandupQuestion) StandupSections {
+	original := []rune(text)
+	lower := make([]rune, len(original))
+	for i, r := range original {
+		lower[i] = unicode.ToLower(r)
+	}
+	words := sectionWords(questions)
+
+	type start struct {
+		pos     int
+		section string
+	}
+	starts := []start{}
+	for i := 0; i < len(lower); i++ {
+		if !unicode.IsLetter(lower[i]) || !startsSection(lower, i) {
+			continue
+		}
+		for _, w := range words {
+			if hasRunePrefix(lower[i:], w.word) {
+				starts = append(starts, start{i, w.section})
+				i += len(w.word) - 1
+				break
+			}
+		}
+	}
+
+	sections := StandupSections{}
+	for n, s := range starts {
+		end := len(original)
+		if n+1 < len(starts) {
+			end = starts[n+1].pos
+		}
+		content := sectionText(original[s.pos:end])
+		if content == "" {
+			continue
+		}
+		if sections[s.section] != "" {
+			content = sections[s.section] + "\n" + content
+		}
+		sections[s.section] = content
+	}
+	return sections
+}
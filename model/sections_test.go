@@ -0,0 +1,64 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStandupSections(t *testing.T) {
+	testCases := []struct {
+		title    string
+		text     string
+		sections StandupSections
+	}{
+		{"one line", "<@BOTID> Yesterday managed to get docker up and running, today will complete test #100, problems: I have multilang!",
+			StandupSections{SectionYesterday: "managed to get docker up and running", SectionToday: "will complete test #100", SectionBlockers: "I have multilang!"}},
+		{"headers on lines", "#standup\n*Yesterday:*\n- fixed login\n- reviewed PRs\n*Today:* signup page\n*Problems:* none",
+			StandupSections{SectionYesterday: "- fixed login\n- reviewed PRs", SectionToday: "signup page", SectionBlockers: "none"}},
+		{"colon inside text", "Yesterday: bugs. Today I will fix: the rest. Issues: no",
+			StandupSections{SectionYesterday: "bugs.", SectionToday: "I will fix: the rest.", SectionBlockers: "no"}},
+		{"russian", "Вчера: чинил сервер\nСегодня: деплой\nПроблемы: нет",
+			StandupSections{SectionYesterday: "чинил сервер", SectionToday: "деплой", SectionBlockers: "нет"}},
+		{"keywords inside sentences do not start sections", "Yesterday I was stuck with a plan, today going on",
+			StandupSections{SectionYesterday: "I was stuck with a plan", SectionToday: "going on"}},
+		{"no sections", "hello world", StandupSections{}},
+	}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.sections, ParseStandupSections(tt.text, nil), tt.title)
+	}
+
+	questions := []StandupQuestion{
+		{Question: "Done", Keywords: "shipped"},
+		{Question: "Doing"},
+		{Question: "Blockers"},
+		{Question: "Kudos", Keywords: "thanks"},
+	}
+	sections := ParseStandupSections("Done: login\nDoing: signup\nBlockers: none\nKudos: to Bob\nThanks Alice for review", questions)
+	assert.Equal(t, StandupSections{
+		SectionYesterday: "login",
+		SectionToday:     "signup",
+		SectionBlockers:  "none",
+		"kudos":          "to Bob\nAlice for review",
+	}, sections)
+}
+
+func TestStandupSectionsValue(t *testing.T) {
+	var s StandupSections
+	value, err := s.Value()
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	s = StandupSections{SectionToday: "tests"}
+	value, err = s.Value()
+	assert.NoError(t, err)
+
+	var scanned StandupSections
+	assert.NoError(t, scanned.Scan(value))
+	assert.Equal(t, s, scanned)
+	assert.NoError(t, scanned.Scan([]byte(`{"blockers":"none"}`)))
+	assert.Equal(t, StandupSections{SectionBlockers: "none"}, scanned)
+	assert.NoError(t, scanned.Scan(nil))
+	assert.Nil(t, scanned)
+	assert.Error(t, scanned.Scan(42))
+}
@@ -0,0 +1,65 @@
+package model
+
+import "time"
+
+// Location returns IANA time zone with the name. Server time zone is returned if the
+// name is empty or unknown
+func Location(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// Location returns time zone channel deadline is kept in
+func (c Channel) Location() *time.Location {
+	return Location(c.TimeZone)
+}
+
+// MemberLocation returns time zone of user in channel: user's own one if it is known,
+// channel's one otherwise
+func MemberLocation(u User, c Channel) *time.Location {
+	if u.TimeZone != "" {
+		return Location(u.TimeZone)
+	}
+	return c.Location()
+}
+
+// StartOfDay returns midnight of the day in time zone of the day
+func StartOfDay(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+}
+
+// DeadlineOn returns deadline on the day. Deadlines are kept as Unix time of any day,
+// only hours and minutes they have in time zone of the day matter
+func DeadlineOn(deadline int64, day time.Time) time.Time {
+	t := time.Unix(deadline, 0).In(day.Location())
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location())
+}
+
+// MoveDeadline returns deadline which has the same hours and minutes in time zone to as
+// deadline has in time zone from. Times typed in commands are parsed in server time zone
+func MoveDeadline(deadline int64, from, to *time.Location) int64 {
+	if deadline == 0 {
+		return 0
+	}
+	t := time.Unix(deadline, 0).In(from)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, to).Unix()
+}
+
+// MoveTimeTable returns timetable which deadlines have the same hours and minutes in time
+// zone to as they have in time zone from
+func MoveTimeTable(tt TimeTable, from, to *time.Location) TimeTable {
+	tt.Monday = MoveDeadline(tt.Monday, from, to)
+	tt.Tuesday = MoveDeadline(tt.Tuesday, from, to)
+	tt.Wednesday = MoveDeadline(tt.Wednesday, from, to)
+	tt.Thursday = MoveDeadline(tt.Thursday, from, to)
+	tt.Friday = MoveDeadline(tt.Friday, from, to)
+	tt.Saturday = MoveDeadline(tt.Saturday, from, to)
+	tt.Sunday = MoveDeadline(tt.Sunday, from, to)
+	return tt
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocation(t *testing.T) {
+	assert.Equal(t, time.Local, Location(""))
+	assert.Equal(t, time.Local, Location("Mars/Olympus_Mons"))
+	assert.Equal(t, "Europe/Berlin", Location("Europe/Berlin").String())
+
+	channel := Channel{TimeZone: "Asia/Bishkek"}
+	assert.Equal(t, "Asia/Bishkek", MemberLocation(User{}, channel).String())
+	assert.Equal(t, "America/New_York", MemberLocation(User{TimeZone: "America/New_York"}, channel).String())
+}
+
+func TestDeadlineOn(t *testing.T) {
+	berlin := Location("Europe/Berlin")
+	bishkek := Location("Asia/Bishkek")
+	// 10:00 in Berlin is 15:00 in Bishkek in winter
+	deadline := time.Date(2019, 1, 10, 10, 0, 0, 0, berlin).Unix()
+
+	summerDay := time.Date(2019, 7, 1, 23, 0, 0, 0, berlin)
+	assert.Equal(t, time.Date(2019, 7, 1, 10, 0, 0, 0, berlin), DeadlineOn(deadline, summerDay))
+	assert.Equal(t, time.Date(2019, 7, 2, 15, 0, 0, 0, bishkek), DeadlineOn(deadline, summerDay.In(bishkek)))
+
+	assert.Equal(t, time.Date(2019, 7, 1, 0, 0, 0, 0, bishkek), StartOfDay(time.Date(2019, 7, 1, 3, 30, 0, 0, bishkek)))
+}
+
+func TestMoveDeadline(t *testing.T) {
+	berlin := Location("Europe/Berlin")
+	assert.Equal(t, int64(0), MoveDeadline(0, time.Local, berlin))
+	typed := time.Date(2019, 1, 10, 9, 30, 0, 0, time.Local).Unix()
+	assert.Equal(t, "09:30", time.Unix(MoveDeadline(typed, time.Local, berlin), 0).In(berlin).Format("15:04"))
+}
+
+func TestMoveTimeTable(t *testing.T) {
+	berlin := Location("Europe/Berlin")
+	bishkek := Location("Asia/Bishkek")
+	deadline := time.Date(2019, 1, 10, 10, 0, 0, 0, berlin).Unix()
+	tt := MoveTimeTable(TimeTable{Monday: deadline, Friday: deadline}, berlin, bishkek)
+	assert.Equal(t, "10:00", time.Unix(tt.Monday, 0).In(bishkek).Format("15:04"))
+	assert.Equal(t, "10:00", time.Unix(tt.Friday, 0).In(bishkek).Format("15:04"))
+	assert.Equal(t, int64(0), tt.Tuesday)
+}
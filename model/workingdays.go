@@ -0,0 +1,87 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultWorkingDays are working days of channels which did not choose their own
+const DefaultWorkingDays = "mon,tue,wed,thu,fri"
+
+// weekdays are names of days of week in the order of time.Weekday: short English, short
+// Russian and full English ones
+var weekdays = [][]string{
+	{"sun", "вс", "sunday"}, {"mon", "пн", "monday"}, {"tue", "вт", "tuesday"}, {"wed", "ср", "wednesday"},
+	{"thu", "чт", "thursday"}, {"fri", "пт", "friday"}, {"sat", "сб", "saturday"},
+}
+
+// WorksOn shows if the day is a working day of channel. Weekday is taken in time zone of the day
+func (c Channel) WorksOn(day time.Time) bool {
+	return inDays(weekdays[day.Weekday()][0], c.Days())
+}
+
+// Days returns working days of channel
+func (c Channel) Days() string {
+	if c.WorkingDays == "" {
+		return DefaultWorkingDays
+	}
+	return c.WorkingDays
+}
+
+// ParseWorkingDays returns days written like "mon, wed, fri", "sun-thu" or "пн ср пт"
+// as working days of channel, Monday first. Returns false if there are words which
+// are not days of week
+func ParseWorkingDays(text string) (string, bool) {
+	picked := map[time.Weekday]bool{}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return r == ',' || r == ' ' || r == ';'
+	})
+	for _, word := range words {
+		ends := strings.SplitN(word, "-", 2)
+		first, ok := parseWeekday(ends[0])
+		if !ok {
+			return "", false
+		}
+		last := first
+		if len(ends) == 2 {
+			last, ok = parseWeekday(ends[1])
+			if !ok {
+				return "", false
+			}
+		}
+		// ranges like "fri-mon" go over the weekend
+		for day := first; ; day = (day + 1) % 7 {
+			picked[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	days := []string{}
+	for _, day := range []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday} {
+		if picked[day] {
+			days = append(days, weekdays[day][0])
+		}
+	}
+	return strings.Join(days, ","), len(days) > 0
+}
+
+// parseWeekday returns day of week written as "mon", "monday" or "пн". Full names may be
+// shortened, e.g. "tues", but not below three letters
+func parseWeekday(word string) (time.Weekday, bool) {
+	for day, names := range weekdays {
+		if word == names[1] || len(word) >= len(names[0]) && strings.HasPrefix(names[2], word) {
+			return time.Weekday(day), true
+		}
+	}
+	return time.Sunday, false
+}
+
+func inDays(day, days string) bool {
+	for _, d := range strings.Split(days, ",") {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
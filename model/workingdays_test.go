@@ -0,0 +1,52 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorksOn(t *testing.T) {
+	saturday := time.Date(2018, 10, 13, 10, 0, 0, 0, time.UTC)
+	sunday := saturday.AddDate(0, 0, 1)
+	monday := saturday.AddDate(0, 0, 2)
+
+	// Monday to Friday unless channel has its own working days
+	channel := Channel{}
+	assert.False(t, channel.WorksOn(saturday))
+	assert.False(t, channel.WorksOn(sunday))
+	assert.True(t, channel.WorksOn(monday))
+
+	channel.WorkingDays = "mon,tue,wed,thu,sun"
+	assert.False(t, channel.WorksOn(saturday))
+	assert.True(t, channel.WorksOn(sunday))
+	assert.True(t, channel.WorksOn(monday))
+	// Sunday evening in UTC is already Monday in Bishkek
+	assert.True(t, Channel{}.WorksOn(sunday.Add(10*time.Hour).In(Location("Asia/Bishkek"))))
+}
+
+func TestParseWorkingDays(t *testing.T) {
+	testCases := []struct {
+		text string
+		days string
+		ok   bool
+	}{
+		{"mon, wed, fri", "mon,wed,fri", true},
+		{"Friday Monday Wednesday", "mon,wed,fri", true},
+		{"sun-thu", "mon,tue,wed,thu,sun", true},
+		{"fri-mon", "mon,fri,sat,sun", true},
+		{"пн ср пт", "mon,wed,fri", true},
+		{"tues, thurs", "tue,thu", true},
+		{"mon, holiday", "", false},
+		{"monkey", "", false},
+		{"fridays", "", false},
+		{"mo", "", false},
+		{"", "", false},
+	}
+	for _, tc := range testCases {
+		days, ok := ParseWorkingDays(tc.text)
+		assert.Equal(t, tc.ok, ok, tc.text)
+		assert.Equal(t, tc.days, days, tc.text)
+	}
+}
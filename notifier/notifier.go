@@ -17,79 +17,17 @@ import (
 
 // Notifier struct is used to notify users about upcoming or skipped standups
 type Notifier struct {
-	s    *chat.Slack
+	s    chat.Messenger
 	db   storage.Storage
 	conf config.Config
 }
 
 // NewNotifier creates a new notifier
-func NewNotifier(slack *chat.Slack) (*Notifier, error) {
-	notifier := &Notifier{s: slack, db: slack.DB, conf: slack.Conf}
+func NewNotifier(m chat.Messenger) (*Notifier, error) {
+	notifier := &Notifier{s: m, db: m.Storage(), conf: m.Config()}
 	return notifier, nil
 }
 
-// Start starts all notifier treads
-func (n *Notifier) Start() error {
-	notificationForChannels := time.NewTicker(time.Second * 60).C
-	notificationForTimeTable := time.NewTicker(time.Second * 60).C
-	for {
-		select {
-		case <-notificationForChannels:
-			n.NotifyChannels()
-		case <-notificationForTimeTable:
-			n.NotifyIndividuals()
-		}
-	}
-}
-
-// NotifyChannels reminds users of channels about upcoming or missing standups
-func (n *Notifier) NotifyChannels() {
-	if int(time.Now().Weekday()) == 6 || int(time.Now().Weekday()) == 0 {
-		return
-	}
-	channels, err := n.db.GetChannels()
-	if err != nil {
-		logrus.Errorf("notifier: ListAllStandupTime failed: %v\n", err)
-		return
-	}
-	// For each standup time, if standup time is now, start reminder
-	for _, channel := range channels {
-		if channel.StandupTime == 0 {
-			continue
-		}
-		standupTime := time.Unix(channel.StandupTime, 0)
-		warningTime := time.Unix(channel.StandupTime-n.conf.ReminderTime*60, 0)
-		if time.Now().Hour() == warningTime.Hour() && time.Now().Minute() == warningTime.Minute() {
-			n.SendWarning(channel.ChannelID)
-		}
-		if time.Now().Hour() == standupTime.Hour() && time.Now().Minute() == standupTime.Minute() {
-			go n.SendChannelNotification(channel.ChannelID)
-		}
-	}
-}
-
-// NotifyIndividuals reminds users of channels about upcoming or missing standups
-func (n *Notifier) NotifyIndividuals() {
-	day := strings.ToLower(time.Now().Weekday().String())
-	tts, err := n.db.ListTimeTablesForDay(day)
-	if err != nil {
-		logrus.Errorf("ListTimeTablesForToday failed: %v", err)
-		return
-	}
-
-	for _, tt := range tts {
-		standupTime := time.Unix(tt.ShowDeadlineOn(day), 0)
-		warningTime := time.Unix(tt.ShowDeadlineOn(day)-n.conf.ReminderTime*60, 0)
-
-		if time.Now().Hour() == warningTime.Hour() && time.Now().Minute() == warningTime.Minute() {
-			n.SendIndividualWarning(tt.ChannelMemberID)
-		}
-		if time.Now().Hour() == standupTime.Hour() && time.Now().Minute() == standupTime.Minute() {
-			go n.SendIndividualNotification(tt.ChannelMemberID)
-		}
-	}
-}
-
 // SendWarning reminds users in chat about upcoming standups
 func (n *Notifier) SendWarning(channelID string) {
 	allNonReporters, err := n.getCurrentDayNonReporters(channelID)
@@ -110,7 +48,7 @@ func (n *Notifier) SendWarning(channelID string) {
 	for _, user := range nonReporters {
 		nonReportersIDs = append(nonReportersIDs, "<@"+user.UserID+">")
 	}
-	err = n.s.SendMessage(channelID, fmt.Sprintf(n.conf.Translate.NotifyUsersWarning, strings.Join(nonReportersIDs, ", "), n.conf.ReminderTime), nil)
+	err = n.s.SendMessage(channelID, fmt.Sprintf(n.conf.Translate.NotifyUsersWarning, strings.Join(nonReportersIDs, ", "), n.conf.ReminderTime), n.standupButton(channelID))
 	if err != nil {
 		logrus.Errorf("notifier: n.s.SendMessage failed: %v\n", err)
 		return
@@ -126,7 +64,7 @@ func (n *Notifier) SendIndividualWarning(channelMemberID int64) {
 	}
 	submittedStandup := n.db.SubmittedStandupToday(chm.UserID, chm.ChannelID)
 	if !submittedStandup {
-		err = n.s.SendMessage(chm.ChannelID, fmt.Sprintf(n.conf.Translate.IndividualStandupersWarning, chm.UserID, n.conf.ReminderTime), nil)
+		err = n.s.SendMessage(chm.ChannelID, fmt.Sprintf(n.conf.Translate.IndividualStandupersWarning, chm.UserID, n.conf.ReminderTime), n.standupButton(chm.ChannelID))
 		if err != nil {
 			logrus.Errorf("notifier: n.s.SendMessage failed: %v\n", err)
 			return
@@ -136,6 +74,14 @@ func (n *Notifier) SendIndividualWarning(channelMemberID int64) {
 	logrus.Infof("%v is not non reporter", chm.UserID)
 }
 
+// standupButton returns "Write standup" button for reminders. Standup modal is Slack only
+func (n *Notifier) standupButton(channelID string) []chat.Attachment {
+	if n.conf.Platform != "" && n.conf.Platform != config.PlatformSlack {
+		return nil
+	}
+	return []chat.Attachment{chat.StandupButton(channelID, n.conf.Translate.WriteStandupButton)}
+}
+
 //SendChannelNotification starts standup reminders and direct reminders to users
 func (n *Notifier) SendChannelNotification(channelID string) {
 	members, err := n.db.ListChannelMembers(channelID)
@@ -258,13 +204,24 @@ func (n *Notifier) SendIndividualNotification(channelMemberID int64) {
 	}
 }
 
-// getNonReporters returns a list of standupers that did not write standups
+// getCurrentDayNonReporters returns a list of standupers that did not write standups
+// today. Today of every standuper is taken in their own time zone, standupers away are skipped
 func (n *Notifier) getCurrentDayNonReporters(channelID string) ([]model.ChannelMember, error) {
-	timeFrom := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 0, 0, 0, 0, time.UTC)
-	nonReporters, err := n.db.GetNonReporters(channelID, timeFrom, time.Now())
-	if err != nil && err != errors.New("no rows in result set") {
-		logrus.Errorf("notifier: GetNonReporters failed: %v\n", err)
+	members, err := n.db.ListChannelMembers(channelID)
+	if err != nil {
+		logrus.Errorf("notifier: ListChannelMembers failed: %v\n", err)
 		return nil, err
 	}
+	nonReporters := []model.ChannelMember{}
+	for _, member := range members {
+		if member.RoleInChannel == "pm" || n.db.SubmittedStandupToday(member.UserID, channelID) {
+			continue
+		}
+		today := time.Now().In(storage.MemberLocation(n.db, member.UserID, channelID))
+		if _, away := storage.AbsenceOn(n.db, member.UserID, today); away {
+			continue
+		}
+		nonReporters = append(nonReporters, member)
+	}
 	return nonReporters, nil
 }
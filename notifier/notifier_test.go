@@ -2,6 +2,7 @@ package notifier
 
 import (
 	"fmt"
+	"os"
 	"testing"
 	"time"
 
@@ -13,8 +14,34 @@ import (
 	httpmock "gopkg.in/jarcoal/httpmock.v1"
 )
 
+// testConfig returns the configuration tests run with. Storage is kept in memory,
+// MySQL is tested only when COMEDIAN_TEST_MYSQL contains its DSN
+func testConfig() (config.Config, error) {
+	translation, err := config.GetTranslation("en_US")
+	if err != nil {
+		return config.Config{}, err
+	}
+	c := config.Config{
+		DatabaseURL:           "memory://",
+		NotifierInterval:      2,
+		ReportTime:            "13:05",
+		Language:              "en_US",
+		ReminderRepeatsMax:    5,
+		ReminderTime:          5,
+		CatchUpTime:           60,
+		TeamMonitoringEnabled: true,
+		CollectorURL:          "https://collector.test",
+		CollectorToken:        "token",
+		Translate:             translation,
+	}
+	if dsn := os.Getenv("COMEDIAN_TEST_MYSQL"); dsn != "" {
+		c.DatabaseURL = dsn
+	}
+	return c, nil
+}
+
 func TestNotifier(t *testing.T) {
-	c, err := config.Get()
+	c, err := testConfig()
 	c.ReminderRepeatsMax = 0
 	c.ReminderTime = 0
 	c.NotifierInterval = 0
@@ -57,7 +84,7 @@ func TestNotifier(t *testing.T) {
 
 	n.SendChannelNotification(channelID)
 
-	n.NotifyChannels()
+	n.tick(time.Now())
 
 	d = time.Date(2018, 1, 2, 9, 0, 0, 0, time.UTC)
 	monkey.Patch(time.Now, func() time.Time { return d })
@@ -99,7 +126,7 @@ func TestNotifier(t *testing.T) {
 }
 
 func TestCheckUser(t *testing.T) {
-	c, err := config.Get()
+	c, err := testConfig()
 	assert.NoError(t, err)
 	slack, err := chat.NewSlack(c)
 	assert.NoError(t, err)
@@ -194,16 +221,14 @@ func TestIndividualNotification(t *testing.T) {
 	httpmock.RegisterResponder("POST", "https://slack.com/api/chat.postMessage",
 		httpmock.NewStringResponder(200, `{"OK": true}`))
 
-	c, err := config.Get()
+	c, err := testConfig()
 	assert.NoError(t, err)
+	c.ReminderTime = 2
 	slack, err := chat.NewSlack(c)
 	assert.NoError(t, err)
 	n, err := NewNotifier(slack)
 	assert.NoError(t, err)
 
-	d := time.Date(2018, 10, 7, 10, 0, 0, 0, time.UTC)
-	monkey.Patch(time.Now, func() time.Time { return d })
-
 	user, err := n.db.CreateUser(model.User{
 		UserID:   "QWERTY123",
 		UserName: "chanName1",
@@ -228,31 +253,54 @@ func TestIndividualNotification(t *testing.T) {
 		ChannelMemberID: m.ID,
 	})
 	assert.NoError(t, err)
-	timeNow := time.Date(2018, 10, 7, 10, 0, 0, 0, time.UTC)
-	tt.Monday = timeNow.Unix()
-	tt.Tuesday = timeNow.Unix()
-	tt.Wednesday = timeNow.Unix()
-	tt.Thursday = timeNow.Unix()
-	tt.Friday = timeNow.Unix()
+	deadline := time.Date(2018, 10, 7, 16, 0, 0, 0, time.Local).Unix()
+	tt.Monday = deadline
+	tt.Wednesday = deadline
 
 	tt, err = n.db.UpdateTimeTable(tt)
 	assert.NoError(t, err)
 
-	d = time.Date(2018, 10, 9, 15, 58, 0, 0, time.UTC)
-	monkey.Patch(time.Now, func() time.Time { return d })
-	//send warning
-	n.NotifyIndividuals()
+	// Saturday
+	next := n.tick(time.Date(2018, 10, 13, 12, 0, 0, 0, time.Local))
+	assert.Equal(t, time.Date(2018, 10, 15, 15, 58, 0, 0, time.Local), next)
+	schedule, err := n.db.ListScheduledNotifications()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(schedule))
+	assert.Equal(t, model.NotifyIndividualWarning, schedule[0].Kind)
+	assert.Equal(t, m.ID, schedule[0].ChannelMemberID)
+	assert.Equal(t, model.NotifyIndividualDeadline, schedule[1].Kind)
+
+	// warning is sent when it is due, the next one is on Wednesday
+	next = n.tick(next)
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+	assert.Equal(t, time.Date(2018, 10, 15, 16, 0, 0, 0, time.Local), next)
+	schedule, err = n.db.ListScheduledNotifications()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2018, 10, 17, 15, 58, 0, 0, time.Local), schedule[1].FireAt)
 
-	d = time.Date(2018, 10, 9, 16, 0, 0, 0, time.UTC)
-	monkey.Patch(time.Now, func() time.Time { return d })
-	//send push
-	n.NotifyIndividuals()
+	// members are not reminded while they are away
+	absence, err := n.db.CreateAbsence(model.Absence{UserID: user.UserID, FirstDay: "2018-10-17", LastDay: "2018-10-19"})
+	assert.NoError(t, err)
+	n.tick(next.Add(-time.Second))
+	schedule, err = n.db.ListScheduledNotifications()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2018, 10, 22, 15, 58, 0, 0, time.Local), schedule[1].FireAt)
+	assert.NoError(t, n.db.DeleteAbsence(absence.ID))
+	n.tick(next.Add(-time.Second))
+	schedule, err = n.db.ListScheduledNotifications()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2018, 10, 17, 15, 58, 0, 0, time.Local), schedule[1].FireAt)
+
+	// notifications of deleted timetables are dropped
+	assert.NoError(t, n.db.DeleteTimeTable(tt.ID))
+	assert.True(t, n.tick(next.Add(-time.Second)).IsZero())
+	schedule, err = n.db.ListScheduledNotifications()
+	assert.NoError(t, err)
+	assert.Empty(t, schedule)
 
 	assert.NoError(t, n.db.DeleteUser(user.ID))
 	assert.NoError(t, n.db.DeleteChannel(channel.ID))
 	assert.NoError(t, n.db.DeleteChannelMember(user.UserID, channel.ChannelID))
-	assert.NoError(t, n.db.DeleteTimeTable(tt.ID))
-
 }
 
 func TestChannelsNotification(t *testing.T) {
@@ -261,17 +309,19 @@ func TestChannelsNotification(t *testing.T) {
 
 	httpmock.RegisterResponder("POST", "https://slack.com/api/chat.postMessage",
 		httpmock.NewStringResponder(200, `{"OK": true}`))
+	httpmock.RegisterResponder("POST", "https://slack.com/api/im.open",
+		httpmock.NewStringResponder(200, `{"OK": true}`))
 
-	c, err := config.Get()
+	c, err := testConfig()
 	assert.NoError(t, err)
+	c.ReminderTime = 2
+	c.CatchUpTime = 60
+	c.ReminderRepeatsMax = 0
 	slack, err := chat.NewSlack(c)
 	assert.NoError(t, err)
 	n, err := NewNotifier(slack)
 	assert.NoError(t, err)
 
-	d := time.Date(2018, 10, 7, 10, 0, 0, 0, time.UTC)
-	monkey.Patch(time.Now, func() time.Time { return d })
-
 	user, err := n.db.CreateUser(model.User{
 		UserID:   "QWERTY123",
 		UserName: "chanName1",
@@ -279,7 +329,7 @@ func TestChannelsNotification(t *testing.T) {
 	})
 	assert.NoError(t, err)
 
-	standupTime := time.Date(2018, 10, 7, 10, 0, 0, 0, time.UTC).Unix()
+	standupTime := time.Date(2018, 10, 7, 16, 0, 0, 0, time.Local).Unix()
 	channel, err := n.db.CreateChannel(model.Channel{
 		ChannelID:   "XYZ",
 		ChannelName: "chan",
@@ -296,19 +346,141 @@ func TestChannelsNotification(t *testing.T) {
 	})
 	assert.NoError(t, err)
 
-	d = time.Date(2018, 10, 9, 15, 58, 0, 0, time.UTC)
-	monkey.Patch(time.Now, func() time.Time { return d })
-	//send warning
-	n.NotifyChannels()
+	// Tuesday
+	next := n.tick(time.Date(2018, 10, 9, 15, 0, 0, 0, time.Local))
+	assert.Equal(t, time.Date(2018, 10, 9, 15, 58, 0, 0, time.Local), next)
+	schedule, err := n.db.ListScheduledNotifications()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(schedule))
+	assert.Equal(t, model.NotifyChannelWarning, schedule[0].Kind)
+	assert.Equal(t, model.NotifyChannelDeadline, schedule[1].Kind)
 
-	d = time.Date(2018, 10, 9, 16, 0, 0, 0, time.UTC)
-	monkey.Patch(time.Now, func() time.Time { return d })
-	//send push
-	n.NotifyChannels()
+	// warning is sent when it is due
+	next = n.tick(next)
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+	assert.Equal(t, time.Date(2018, 10, 9, 16, 0, 0, 0, time.Local), next)
+
+	// changed deadline is rescheduled
+	err = n.db.CreateStandupTime(time.Date(2018, 10, 7, 17, 0, 0, 0, time.Local).Unix(), channel.ChannelID)
+	assert.NoError(t, err)
+	next = n.tick(time.Date(2018, 10, 9, 15, 59, 0, 0, time.Local))
+	assert.Equal(t, time.Date(2018, 10, 9, 16, 58, 0, 0, time.Local), next)
+
+	// notifications missed while Comedian was down are sent after restart unless they are too late,
+	// deadlines are on weekdays only
+	assert.False(t, n.fire(schedule[0], schedule[0].FireAt.Add(3*time.Minute)))
+	assert.False(t, n.fire(schedule[1], schedule[1].FireAt.Add(61*time.Minute)))
+	next = n.tick(time.Date(2018, 10, 12, 17, 30, 0, 0, time.Local))
+	assert.Equal(t, time.Date(2018, 10, 15, 16, 58, 0, 0, time.Local), next)
+	schedule, err = n.db.ListScheduledNotifications()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2018, 10, 15, 17, 0, 0, 0, time.Local), schedule[1].FireAt)
+
+	// holidays of the channel and of the workspace are skipped
+	_, err = n.db.CreateHoliday(model.Holiday{ChannelID: channel.ChannelID, Day: "2018-10-15", Name: "Founders Day"})
+	assert.NoError(t, err)
+	_, err = n.db.CreateHoliday(model.Holiday{Day: "2018-10-16", Name: "Company Day"})
+	assert.NoError(t, err)
+	next = n.tick(time.Date(2018, 10, 12, 17, 30, 0, 0, time.Local))
+	assert.Equal(t, time.Date(2018, 10, 17, 16, 58, 0, 0, time.Local), next)
+	assert.NoError(t, n.db.DeleteHoliday(channel.ChannelID, "2018-10-15"))
+	assert.NoError(t, n.db.DeleteHoliday("", "2018-10-16"))
+
+	// archived channels are not reminded
+	channel.Status = model.ChannelArchived
+	_, err = n.db.UpdateChannel(channel)
+	assert.NoError(t, err)
+	assert.True(t, n.tick(next.Add(-time.Minute)).IsZero())
 
 	assert.NoError(t, n.db.DeleteUser(user.ID))
 	assert.NoError(t, n.db.DeleteChannel(channel.ID))
 	assert.NoError(t, n.db.DeleteChannelMember(m.UserID, m.ChannelID))
 	assert.NoError(t, n.db.DeleteStandupTime(channel.ChannelID))
+}
+
+func TestNotifierWithMemoryStorage(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	slack, err := chat.NewSlack(config.Config{
+		DatabaseURL:        "memory://",
+		ReminderRepeatsMax: 0,
+		Translate:          translation,
+	})
+	assert.NoError(t, err)
+	n, err := NewNotifier(slack)
+	assert.NoError(t, err)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://slack.com/api/chat.postMessage",
+		httpmock.NewStringResponder(200, `{"OK": true}`))
+
+	channel, err := n.db.CreateChannel(model.Channel{
+		ChannelID:   "QWERTY123",
+		ChannelName: "chanName",
+	})
+	assert.NoError(t, err)
+
+	for _, userID := range []string{"userID1", "userID2"} {
+		_, err := n.db.CreateChannelMember(model.ChannelMember{
+			UserID:    userID,
+			ChannelID: channel.ChannelID,
+		})
+		assert.NoError(t, err)
+	}
+	_, err = n.db.CreateChannelMember(model.ChannelMember{
+		UserID:        "userID3",
+		ChannelID:     channel.ChannelID,
+		RoleInChannel: "pm",
+	})
+	assert.NoError(t, err)
+
+	nonReporters, err := n.getCurrentDayNonReporters(channel.ChannelID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(nonReporters))
+
+	// standupers away are not non reporters
+	today := time.Now().Format(model.HolidayDay)
+	absence, err := n.db.CreateAbsence(model.Absence{UserID: "userID2", FirstDay: today, LastDay: today})
+	assert.NoError(t, err)
+	nonReporters, err = n.getCurrentDayNonReporters(channel.ChannelID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(nonReporters))
+	assert.NoError(t, n.db.DeleteAbsence(absence.ID))
+
+	n.SendWarning(channel.ChannelID)
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+
+	_, err = n.db.CreateStandup(model.Standup{
+		ChannelID: channel.ChannelID,
+		Comment:   "work hard",
+		UserID:    "userID1",
+		MessageTS: "qweasdzxc",
+	})
+	assert.NoError(t, err)
+
+	nonReporters, err = n.getCurrentDayNonReporters(channel.ChannelID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(nonReporters))
+	assert.Equal(t, "userID2", nonReporters[0].UserID)
+
+	_, err = n.db.CreateStandup(model.Standup{
+		ChannelID: channel.ChannelID,
+		Comment:   "hello world",
+		UserID:    "userID2",
+		MessageTS: "qweasd",
+	})
+	assert.NoError(t, err)
+
+	nonReporters, err = n.getCurrentDayNonReporters(channel.ChannelID)
+	assert.NoError(t, err)
+	assert.Empty(t, nonReporters)
+
+	n.SendWarning(channel.ChannelID)
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
 
+	// everyone submitted standups, so only "all done" message is sent
+	n.SendChannelNotification(channel.ChannelID)
+	assert.Equal(t, 2, httpmock.GetTotalCallCount())
 }
@@ -0,0 +1,236 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maddevsio/comedian/model"
+	"github.com/maddevsio/comedian/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// maxSleep is the longest notifier waits before looking at deadlines again, so that
+// changes which did not wake it up are picked up anyway
+var maxSleep = time.Hour
+
+// Start sends reminders missed while Comedian was down and then sends reminders when
+// they are due. Schedule is computed again as soon as deadlines or timetables change
+func (n *Notifier) Start() error {
+	for {
+		next := n.tick(time.Now())
+		wait := maxSleep
+		if !next.IsZero() && time.Until(next) < wait {
+			wait = time.Until(next)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-n.s.ScheduleChanges():
+			timer.Stop()
+		}
+	}
+}
+
+// tick sends notifications which are due by now and schedules the next ones.
+// Returns when the earliest scheduled notification is due
+func (n *Notifier) tick(now time.Time) time.Time {
+	schedule, err := n.db.ListScheduledNotifications()
+	if err != nil {
+		logrus.Errorf("notifier: ListScheduledNotifications failed: %v\n", err)
+		return time.Time{}
+	}
+	due := []model.ScheduledNotification{}
+	pending := []model.ScheduledNotification{}
+	for _, e := range schedule {
+		if e.FireAt.After(now) {
+			pending = append(pending, e)
+			continue
+		}
+		// notification is removed before it is sent, so that it is not sent twice if Comedian stops meanwhile
+		err := n.db.DeleteScheduledNotification(e.ID)
+		if err != nil {
+			logrus.Errorf("notifier: DeleteScheduledNotification failed: %v\n", err)
+			continue
+		}
+		due = append(due, e)
+	}
+	next := n.reschedule(now, pending)
+	for _, e := range due {
+		n.fire(e, now)
+	}
+	return next
+}
+
+// reschedule makes scheduled notifications match deadlines of channels and timetables.
+// Returns when the earliest of them is due
+func (n *Notifier) reschedule(now time.Time, schedule []model.ScheduledNotification) time.Time {
+	planned := n.plan(now)
+	for _, e := range schedule {
+		p, ok := planned[scheduleKey(e)]
+		if !ok {
+			err := n.db.DeleteScheduledNotification(e.ID)
+			if err != nil {
+				logrus.Errorf("notifier: DeleteScheduledNotification failed: %v\n", err)
+			}
+			continue
+		}
+		delete(planned, scheduleKey(e))
+		if e.FireAt.Equal(p.FireAt) {
+			continue
+		}
+		e.FireAt = p.FireAt
+		_, err := n.db.UpdateScheduledNotification(e)
+		if err != nil {
+			logrus.Errorf("notifier: UpdateScheduledNotification failed: %v\n", err)
+		}
+	}
+	for _, p := range planned {
+		_, err := n.db.CreateScheduledNotification(p)
+		if err != nil {
+			logrus.Errorf("notifier: CreateScheduledNotification failed: %v\n", err)
+		}
+	}
+
+	schedule, err := n.db.ListScheduledNotifications()
+	if err != nil || len(schedule) == 0 {
+		return time.Time{}
+	}
+	return schedule[0].FireAt
+}
+
+// plan returns the next notifications after now about deadlines of active channels on
+// their working days and about deadlines of timetables. Holidays of channels and days members are
+// away are skipped
+func (n *Notifier) plan(now time.Time) map[string]model.ScheduledNotification {
+	planned := map[string]model.ScheduledNotification{}
+	add := func(kind, channelID string, memberID int64, fireAt time.Time) {
+		if fireAt.IsZero() {
+			return
+		}
+		e := model.ScheduledNotification{Kind: kind, ChannelID: channelID, ChannelMemberID: memberID, FireAt: fireAt}
+		planned[scheduleKey(e)] = e
+	}
+	warning := time.Duration(n.conf.ReminderTime) * time.Minute
+	holidays := map[string][]model.Holiday{}
+	isHoliday := func(channelID string, day time.Time) bool {
+		if _, ok := holidays[channelID]; !ok {
+			list, err := n.db.ListHolidays(channelID)
+			if err != nil {
+				logrus.Errorf("notifier: ListHolidays failed: %v\n", err)
+			}
+			holidays[channelID] = list
+		}
+		_, ok := model.HolidayOn(holidays[channelID], day)
+		return ok
+	}
+
+	channels, err := n.db.GetChannels()
+	if err != nil {
+		logrus.Errorf("notifier: GetChannels failed: %v\n", err)
+	}
+	for _, channel := range channels {
+		if channel.StandupTime == 0 || !channel.Active() {
+			continue
+		}
+		channel := channel
+		deadlineOn := func(day time.Time) int64 {
+			if !channel.WorksOn(day) || isHoliday(channel.ChannelID, day) {
+				return 0
+			}
+			return channel.StandupTime
+		}
+		local := now.In(channel.Location())
+		add(model.NotifyChannelWarning, channel.ChannelID, 0, nextFire(local, warning, deadlineOn))
+		add(model.NotifyChannelDeadline, channel.ChannelID, 0, nextFire(local, 0, deadlineOn))
+	}
+
+	// timetables of a day have deadlines of the day only
+	timetables := map[int64]map[time.Weekday]int64{}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		dayName := strings.ToLower(day.String())
+		tts, err := n.db.ListTimeTablesForDay(dayName)
+		if err != nil {
+			logrus.Errorf("notifier: ListTimeTablesForDay failed: %v\n", err)
+			continue
+		}
+		for _, tt := range tts {
+			if timetables[tt.ChannelMemberID] == nil {
+				timetables[tt.ChannelMemberID] = map[time.Weekday]int64{}
+			}
+			timetables[tt.ChannelMemberID][day] = tt.ShowDeadlineOn(dayName)
+		}
+	}
+	for memberID, deadlines := range timetables {
+		member, err := n.db.SelectChannelMember(memberID)
+		if err != nil {
+			logrus.Errorf("notifier: SelectChannelMember failed: %v\n", err)
+			continue
+		}
+		absences, err := n.db.ListAbsences(member.UserID)
+		if err != nil {
+			logrus.Errorf("notifier: ListAbsences failed: %v\n", err)
+		}
+		deadlines, channelID := deadlines, member.ChannelID
+		deadlineOn := func(day time.Time) int64 {
+			if _, away := model.AbsenceOn(absences, day); away || isHoliday(channelID, day) {
+				return 0
+			}
+			return deadlines[day.Weekday()]
+		}
+		// timetable deadlines are kept in time zone of the member
+		local := now.In(storage.MemberLocation(n.db, member.UserID, member.ChannelID))
+		add(model.NotifyIndividualWarning, "", memberID, nextFire(local, warning, deadlineOn))
+		add(model.NotifyIndividualDeadline, "", memberID, nextFire(local, 0, deadlineOn))
+	}
+	return planned
+}
+
+// nextFire returns the first time after now which is the time of deadline on one of the
+// next days moved earlier by before. Days and deadlines are taken in time zone of now.
+// Days without deadline are zero
+func nextFire(now time.Time, before time.Duration, deadlineOn func(time.Time) int64) time.Time {
+	// the deadline of the same weekday next week is the latest to look at
+	for d := 0; d <= 8; d++ {
+		day := now.AddDate(0, 0, d)
+		deadline := deadlineOn(day)
+		if deadline == 0 {
+			continue
+		}
+		fireAt := model.DeadlineOn(deadline, day).Add(-before)
+		if fireAt.After(now) {
+			return fireAt
+		}
+	}
+	return time.Time{}
+}
+
+func scheduleKey(e model.ScheduledNotification) string {
+	return fmt.Sprintf("%v/%v/%v", e.Kind, e.ChannelID, e.ChannelMemberID)
+}
+
+// fire sends notification which is due. Notifications missed for longer than
+// COMEDIAN_CATCH_UP_TIME are skipped, so are warnings which are late for the deadline.
+// Returns false if notification is skipped
+func (n *Notifier) fire(e model.ScheduledNotification, now time.Time) bool {
+	late := now.Sub(e.FireAt)
+	catchUp := time.Duration(n.conf.CatchUpTime) * time.Minute
+	if warning := time.Duration(n.conf.ReminderTime) * time.Minute; (e.Kind == model.NotifyChannelWarning || e.Kind == model.NotifyIndividualWarning) && warning < catchUp {
+		catchUp = warning
+	}
+	if late > catchUp {
+		logrus.Infof("notifier: %v notification of %v%v scheduled at %v is skipped, it is %v late", e.Kind, e.ChannelID, e.ChannelMemberID, e.FireAt, late)
+		return false
+	}
+	switch e.Kind {
+	case model.NotifyChannelWarning:
+		n.SendWarning(e.ChannelID)
+	case model.NotifyChannelDeadline:
+		go n.SendChannelNotification(e.ChannelID)
+	case model.NotifyIndividualWarning:
+		n.SendIndividualWarning(e.ChannelMemberID)
+	case model.NotifyIndividualDeadline:
+		go n.SendIndividualNotification(e.ChannelMemberID)
+	}
+	return true
+}
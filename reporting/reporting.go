@@ -1,7 +1,10 @@
 package reporting
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/jasonlvhit/gocron"
@@ -11,13 +14,12 @@ import (
 	"github.com/maddevsio/comedian/storage"
 	"github.com/maddevsio/comedian/teammonitoring"
 	"github.com/maddevsio/comedian/utils"
-	"github.com/nlopes/slack"
 	"github.com/sirupsen/logrus"
 )
 
 //Reporter provides db and translation to functions
 type Reporter struct {
-	s    *chat.Slack
+	s    chat.Messenger
 	db   storage.Storage
 	conf config.Config
 }
@@ -35,8 +37,8 @@ type ReportBodyContent struct {
 }
 
 // NewReporter creates a new reporter instance
-func NewReporter(slack *chat.Slack) *Reporter {
-	reporter := &Reporter{s: slack, db: slack.DB, conf: slack.Conf}
+func NewReporter(m chat.Messenger) *Reporter {
+	reporter := &Reporter{s: m, db: m.Storage(), conf: m.Config()}
 	return reporter
 }
 
@@ -47,55 +49,89 @@ func (r *Reporter) Start() {
 
 }
 
-// teamReport generates report on users who submit standups
-func (r *Reporter) displayYesterdayTeamReport() {
-	var allReports []slack.Attachment
-
-	channels, err := r.db.GetAllChannels()
-	if err != nil {
-		logrus.Errorf("GetAllChannels failed: %v", err)
-		return
-	}
+// reportShownMembers is how many members Block Kit report shows, the rest are behind "Show more" button
+const reportShownMembers = 10
 
-	for _, channel := range channels {
-		var attachments []slack.Attachment
+// statusEmoji shows member status in Block Kit reports as attachment colors do in legacy ones
+var statusEmoji = map[string]string{
+	"good":    ":large_green_circle:",
+	"warning": ":large_yellow_circle:",
+	"danger":  ":red_circle:",
+}
 
-		channelMembers, err := r.db.ListChannelMembers(channel.ChannelID)
-		if err != nil {
-			logrus.Errorf("ListChannelMembers failed for channel %v: %v", channel.ChannelName, err)
-			continue
-		}
+// memberStatus is what team report says about member. Parts which are not shown are empty
+type memberStatus struct {
+	Worklogs string
+	Commits  string
+	Standup  string
+	Points   int
+	// DayOff is set if the day is not a working day of the channel
+	DayOff bool
+}
 
-		if len(channelMembers) == 0 {
-			logrus.Infof("Skip %v channel", channel.ChannelID)
-			continue
-		}
+// dailyFieldValue formats status as a field of legacy attachment
+func (m memberStatus) dailyFieldValue() string {
+	switch {
+	case m.Worklogs == "":
+		return fmt.Sprintf("%-10v\n", m.Standup)
+	case m.Commits == "":
+		return fmt.Sprintf("%-16v|%-10v|\n", m.Worklogs, m.Standup)
+	}
+	return fmt.Sprintf("%-16v|%-12v|%-10v|\n", m.Worklogs, m.Commits, m.Standup)
+}
 
-		for _, member := range channelMembers {
-			attachment := r.generateReportAttachment(member, channel)
-			if len(attachment.Fields) == 0 {
-				continue
-			}
-			attachment.Text = fmt.Sprintf(r.conf.Translate.IsRook, member.UserID, channel.ChannelName)
+// weeklyFieldValue formats status as a field of legacy attachment
+func (m memberStatus) weeklyFieldValue() string {
+	switch {
+	case m.Worklogs == "":
+		return ""
+	case m.Commits == "":
+		return fmt.Sprintf("%-16v|\n", m.Worklogs)
+	}
+	return fmt.Sprintf("%-16v|%-12v|\n", m.Worklogs, m.Commits)
+}
 
-			attachments = append(attachments, attachment)
+// parts returns parts of status which are shown
+func (m memberStatus) parts() []string {
+	parts := []string{}
+	for _, part := range []string{m.Worklogs, m.Commits, m.Standup} {
+		if strings.TrimSpace(part) != "" {
+			parts = append(parts, strings.TrimSpace(part))
 		}
-
-		r.s.SendMessage(channel.ChannelID, r.conf.Translate.ReportHeader, attachments)
-
-		allReports = append(allReports, attachments...)
 	}
+	return parts
+}
 
-	if len(allReports) == 0 {
-		return
+// memberReport is a line of team report about member of channel
+type memberReport struct {
+	UserID      string
+	ChannelName string
+	Color       string
+	FieldValue  string
+	Status      memberStatus
+}
+
+func (m memberReport) attachment(conf config.Config) chat.Attachment {
+	return chat.Attachment{
+		Color:  m.Color,
+		Text:   fmt.Sprintf(conf.Translate.IsRook, m.UserID, m.ChannelName),
+		Fields: []chat.AttachmentField{{Value: m.FieldValue, Short: false}},
 	}
+}
 
-	r.s.SendMessage(r.conf.ReportingChannel, r.conf.Translate.ReportHeader, allReports)
+// teamReport generates report on users who submit standups
+func (r *Reporter) displayYesterdayTeamReport() {
+	r.displayTeamReport(r.conf.Translate.ReportHeader, r.yesterdayMemberReport)
 }
 
 // teamReport generates report on users who submit standups
 func (r *Reporter) displayWeeklyTeamReport() {
-	var allReports []slack.Attachment
+	r.displayTeamReport(r.conf.Translate.ReportHeaderWeekly, r.weeklyMemberReport)
+}
+
+// displayTeamReport sends report on members of every channel to its report channel and report on all members to reporting channel
+func (r *Reporter) displayTeamReport(header string, report func(model.ChannelMember, model.Channel) (memberReport, bool)) {
+	var allReports []memberReport
 
 	channels, err := r.db.GetAllChannels()
 	if err != nil {
@@ -104,7 +140,12 @@ func (r *Reporter) displayWeeklyTeamReport() {
 	}
 
 	for _, channel := range channels {
-		var attachments []slack.Attachment
+		var reports []memberReport
+
+		if !channel.Active() {
+			logrus.Infof("Skip %v channel, it is %v", channel.ChannelID, channel.Status)
+			continue
+		}
 
 		channelMembers, err := r.db.ListChannelMembers(channel.ChannelID)
 		if err != nil {
@@ -118,31 +159,112 @@ func (r *Reporter) displayWeeklyTeamReport() {
 		}
 
 		for _, member := range channelMembers {
-			attachment := r.generateWeeklyReportAttachment(member, channel)
-			if len(attachment.Fields) == 0 {
+			memberReport, ok := report(member, channel)
+			if !ok {
 				continue
 			}
-			attachment.Text = fmt.Sprintf(r.conf.Translate.IsRook, member.UserID, channel.ChannelName)
-
-			attachments = append(attachments, attachment)
+			reports = append(reports, memberReport)
 		}
 
-		r.s.SendMessage(channel.ChannelID, r.conf.Translate.ReportHeaderWeekly, attachments)
+		r.sendTeamReport(channel.ReportChannel(), header, reports)
 
-		allReports = append(allReports, attachments...)
+		allReports = append(allReports, reports...)
 	}
 
 	if len(allReports) == 0 {
 		return
 	}
 
-	r.s.SendMessage(r.conf.ReportingChannel, r.conf.Translate.ReportHeaderWeekly, allReports)
+	r.sendTeamReport(r.conf.ReportingChannel, header, allReports)
+}
+
+// sendTeamReport sends report as Block Kit message if messenger supports it, legacy attachments otherwise
+func (r *Reporter) sendTeamReport(channelID, header string, reports []memberReport) {
+	blockSender, ok := r.s.(chat.BlockSender)
+	if !ok || r.conf.ReportFormat == config.ReportFormatAttachments {
+		var attachments []chat.Attachment
+		for _, report := range reports {
+			attachments = append(attachments, report.attachment(r.conf))
+		}
+		r.s.SendMessage(channelID, header, attachments)
+		return
+	}
+	err := blockSender.SendBlocks(channelID, header, r.reportBlocks(header, reports))
+	if err != nil {
+		logrus.Errorf("SendBlocks failed: %v", err)
+	}
+}
+
+// reportBlocks renders report with a section and a context block per member, members
+// with problems go first. Members beyond reportShownMembers are hidden behind "Show more" button
+func (r *Reporter) reportBlocks(header string, reports []memberReport) []chat.Block {
+	rank := map[string]int{"danger": 0, "warning": 1, "good": 2}
+	sorted := append([]memberReport{}, reports...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return rank[sorted[i].Color] < rank[sorted[j].Color]
+	})
+
+	blocks := []chat.Block{chat.HeaderBlock(header)}
+	hidden := []string{}
+	for i, report := range sorted {
+		title := strings.TrimSpace(statusEmoji[report.Color] + " " + fmt.Sprintf(r.conf.Translate.IsRook, report.UserID, report.ChannelName))
+		if i >= reportShownMembers {
+			hidden = append(hidden, fmt.Sprintf("%v: %v", title, strings.Join(report.Status.parts(), " · ")))
+			continue
+		}
+		blocks = append(blocks, chat.SectionBlock(title), chat.ContextBlock(report.Status.parts()...))
+	}
+	if len(hidden) > 0 {
+		blocks = append(blocks, chat.ShowMoreBlock(fmt.Sprintf(r.conf.Translate.ReportShowMore, len(hidden)), strings.Join(hidden, "\n")))
+	}
+	return blocks
+}
+
+func (r *Reporter) yesterdayMemberReport(member model.ChannelMember, channel model.Channel) (memberReport, bool) {
+	status, ok := r.yesterdayMemberStatus(member, channel)
+	if !ok || status.dailyFieldValue() == "" {
+		return memberReport{}, false
+	}
+	return memberReport{
+		UserID:      member.UserID,
+		ChannelName: channel.ChannelName,
+		Color:       dailyColor(status.Points, status.DayOff),
+		FieldValue:  status.dailyFieldValue(),
+		Status:      status,
+	}, true
 }
 
-func (r *Reporter) generateReportAttachment(member model.ChannelMember, project model.Channel) slack.Attachment {
+func (r *Reporter) weeklyMemberReport(member model.ChannelMember, channel model.Channel) (memberReport, bool) {
+	status := r.weeklyMemberStatus(member, channel)
+	//if there is nothing to show, do not create report
+	if status.weeklyFieldValue() == "" {
+		logrus.Infof("Noting to show on attachment on member: %v in %v. Skip!", member.UserID, member.ChannelID)
+		return memberReport{}, false
+	}
+	return memberReport{
+		UserID:      member.UserID,
+		ChannelName: channel.ChannelName,
+		Color:       weeklyColor(status.Points),
+		FieldValue:  status.weeklyFieldValue(),
+		Status:      status,
+	}, true
+}
 
-	startDate := time.Now().AddDate(0, 0, -1)
-	endDate := time.Now().AddDate(0, 0, -1)
+func (r *Reporter) generateReportAttachment(member model.ChannelMember, project model.Channel) chat.Attachment {
+	status, ok := r.yesterdayMemberStatus(member, project)
+	if !ok {
+		return chat.Attachment{}
+	}
+	return r.GenerateAttachment(status.dailyFieldValue(), status.Points, status.DayOff)
+}
+
+// yesterdayMemberStatus collects yesterday's data on member. Yesterday is taken in time
+// zone of the member. Returns false if member did not do anything on the day off of the
+// channel and there is nothing to report
+func (r *Reporter) yesterdayMemberStatus(member model.ChannelMember, project model.Channel) (memberStatus, bool) {
+	now := time.Now().In(storage.MemberLocation(r.db, member.UserID, member.ChannelID))
+	startDate := now.AddDate(0, 0, -1)
+	endDate := now.AddDate(0, 0, -1)
 
 	dataOnUser, dataOnUserInProject, collectorError := r.GetCollectorDataOnMember(member, project, startDate, endDate)
 
@@ -152,28 +274,50 @@ func (r *Reporter) generateReportAttachment(member model.ChannelMember, project
 		r.s.SendUserMessage(r.conf.ManagerSlackUserID, fail)
 	}
 
-	//if report is being made for weekends, and user did not do anywork on these days, generate no report
-	if int(time.Now().Weekday()) == 0 || int(time.Now().Weekday()) == 1 {
-		if dataOnUser.Worklogs == 0 && dataOnUser.TotalCommits == 0 {
-			logrus.Infof("User %v in %v did not do anything yesterday. Skip!", member.UserID, project.ChannelName)
-			return slack.Attachment{}
-		}
+	//if report is being made for days off, and user did not do anywork on these days, generate no report
+	dayOff := !project.WorksOn(startDate)
+	if dayOff && dataOnUser.Worklogs == 0 && dataOnUser.TotalCommits == 0 {
+		logrus.Infof("User %v in %v did not do anything yesterday. Skip!", member.UserID, project.ChannelName)
+		return memberStatus{}, false
 	}
 
-	startDateTime := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, time.Local)
-	endDateTime := time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 23, 59, 59, 0, time.Local)
+	startDateTime := model.StartOfDay(startDate)
+	endDateTime := model.StartOfDay(now).Add(-time.Second)
 
 	isNonReporter, err := r.db.IsNonReporter(member.UserID, member.ChannelID, startDateTime, endDateTime)
 	if err != nil {
 		logrus.Infof("User is non reporter failed: %v", err)
 	}
 
-	fieldValue, points := r.PrepareAttachment(member, dataOnUser, dataOnUserInProject, isNonReporter, collectorError)
+	// standup was not expected on holiday or vacation, so it is not a miss
+	if _, onHoliday := r.holidayOn(member.ChannelID, startDate); onHoliday && (isNonReporter || err != nil) {
+		status := r.dailyStatus(member, dataOnUser, dataOnUserInProject, false, collectorError)
+		status.Standup = r.conf.Translate.HolidayStandup
+		return status, true
+	}
+	if _, away := storage.AbsenceOn(r.db, member.UserID, startDate); away && (isNonReporter || err != nil) {
+		status := r.dailyStatus(member, dataOnUser, dataOnUserInProject, false, collectorError)
+		status.Standup = r.conf.Translate.VacationStandup
+		return status, true
+	}
+
+	status := r.dailyStatus(member, dataOnUser, dataOnUserInProject, isNonReporter, collectorError)
+	status.DayOff = dayOff
+	return status, true
+}
 
-	return r.GenerateAttachment(fieldValue, points)
+func (r *Reporter) generateWeeklyReportAttachment(member model.ChannelMember, project model.Channel) chat.Attachment {
+	report, ok := r.weeklyMemberReport(member, project)
+	if !ok {
+		return chat.Attachment{}
+	}
+	attachment := report.attachment(r.conf)
+	attachment.Text = ""
+	return attachment
 }
 
-func (r *Reporter) generateWeeklyReportAttachment(member model.ChannelMember, project model.Channel) slack.Attachment {
+// weeklyMemberStatus collects data on member for the last week
+func (r *Reporter) weeklyMemberStatus(member model.ChannelMember, project model.Channel) memberStatus {
 
 	startDate := time.Now().AddDate(0, 0, -7)
 	endDate := time.Now().AddDate(0, 0, -1)
@@ -186,34 +330,7 @@ func (r *Reporter) generateWeeklyReportAttachment(member model.ChannelMember, pr
 		r.s.SendUserMessage(r.conf.ManagerSlackUserID, fail)
 	}
 
-	fieldValue, points := r.PrepareWeeklyAttachment(member, dataOnUser, dataOnUserInProject, collectorError)
-
-	var attachment slack.Attachment
-	var attachmentFields []slack.AttachmentField
-
-	//if there is nothing to show, do not create attachment
-	if fieldValue == "" {
-		logrus.Infof("Noting to show on attachment on member: %v in %v. Skip!", member.UserID, member.ChannelID)
-		return attachment
-	}
-
-	attachmentFields = append(attachmentFields, slack.AttachmentField{
-		Value: fieldValue,
-		Short: false,
-	})
-
-	attachment.Text = ""
-	switch p := points; p {
-	case 0:
-		attachment.Color = "danger"
-	case 1:
-		attachment.Color = "warning"
-	case 2:
-		attachment.Color = "good"
-	}
-
-	attachment.Fields = attachmentFields
-	return attachment
+	return r.weeklyStatus(member, dataOnUser, dataOnUserInProject, collectorError)
 }
 
 func (r *Reporter) GetCollectorDataOnMember(member model.ChannelMember, project model.Channel, startDate, endDate time.Time) (teammonitoring.CollectorData, teammonitoring.CollectorData, error) {
@@ -235,6 +352,11 @@ func (r *Reporter) GetCollectorDataOnMember(member model.ChannelMember, project
 }
 
 func (r *Reporter) PrepareAttachment(user model.ChannelMember, dataOnUser, dataOnUserInProject teammonitoring.CollectorData, isNonReporter bool, collectorError error) (string, int) {
+	status := r.dailyStatus(user, dataOnUser, dataOnUserInProject, isNonReporter, collectorError)
+	return status.dailyFieldValue(), status.Points
+}
+
+func (r *Reporter) dailyStatus(user model.ChannelMember, dataOnUser, dataOnUserInProject teammonitoring.CollectorData, isNonReporter bool, collectorError error) memberStatus {
 	var worklogs, commits, standup, worklogsEmoji, worklogsTime string
 	var points int
 
@@ -274,22 +396,29 @@ func (r *Reporter) PrepareAttachment(user model.ChannelMember, dataOnUser, dataO
 		points++
 	}
 
-	// prepare field value and assign points
-	fieldValue := fmt.Sprintf("%-16v|%-12v|%-10v|\n", worklogs, commits, standup)
+	// prepare status and assign points
+	status := memberStatus{Worklogs: worklogs, Commits: commits, Standup: standup}
 
 	if user.RoleInChannel == "pm" || user.RoleInChannel == "designer" {
-		fieldValue = fmt.Sprintf("%-16v|%-10v|\n", worklogs, standup)
+		status.Commits = ""
 		points++
 	}
 
 	if r.conf.TeamMonitoringEnabled == false || collectorError != nil {
-		fieldValue = fmt.Sprintf("%-10v\n", standup)
+		status.Worklogs = ""
+		status.Commits = ""
 	}
 
-	return fieldValue, points
+	status.Points = points
+	return status
 }
 
 func (r *Reporter) PrepareWeeklyAttachment(user model.ChannelMember, dataOnUser, dataOnUserInProject teammonitoring.CollectorData, collectorError error) (string, int) {
+	status := r.weeklyStatus(user, dataOnUser, dataOnUserInProject, collectorError)
+	return status.weeklyFieldValue(), status.Points
+}
+
+func (r *Reporter) weeklyStatus(user model.ChannelMember, dataOnUser, dataOnUserInProject teammonitoring.CollectorData, collectorError error) memberStatus {
 	var worklogs, commits, worklogsEmoji, worklogsTime string
 	var points int
 
@@ -320,49 +449,69 @@ func (r *Reporter) PrepareWeeklyAttachment(user model.ChannelMember, dataOnUser,
 		points++
 	}
 
-	// prepare field value and assign points
-	fieldValue := fmt.Sprintf("%-16v|%-12v|\n", worklogs, commits)
+	// prepare status and assign points
+	status := memberStatus{Worklogs: worklogs, Commits: commits}
 
 	if user.RoleInChannel == "pm" || user.RoleInChannel == "designer" {
-		fieldValue = fmt.Sprintf("%-16v|\n", worklogs)
+		status.Commits = ""
 		points++
 	}
 
 	if r.conf.TeamMonitoringEnabled == false || collectorError != nil {
-		fieldValue = ""
+		status.Worklogs = ""
+		status.Commits = ""
 	}
 
-	return fieldValue, points
+	status.Points = points
+	return status
 }
 
-func (r *Reporter) GenerateAttachment(fieldValue string, points int) slack.Attachment {
-	var attachment slack.Attachment
-	var attachmentFields []slack.AttachmentField
+// GenerateAttachment makes attachment of yesterday report on member. Attachments on days off are always good
+func (r *Reporter) GenerateAttachment(fieldValue string, points int, dayOff bool) chat.Attachment {
+	var attachment chat.Attachment
+	var attachmentFields []chat.AttachmentField
 
 	//if there is nothing to show, do not create attachment
 	if fieldValue != "" {
-		attachmentFields = append(attachmentFields, slack.AttachmentField{
+		attachmentFields = append(attachmentFields, chat.AttachmentField{
 			Value: fieldValue,
 			Short: false,
 		})
 	}
 
 	attachment.Text = ""
-	switch p := points; p {
+	attachment.Color = dailyColor(points, dayOff)
+	attachment.Fields = attachmentFields
+	return attachment
+}
+
+// dailyColor returns color of member's line in yesterday report. Reports on days off are always good
+func dailyColor(points int, dayOff bool) string {
+	if dayOff {
+		return "good"
+	}
+	switch points {
 	case 0:
-		attachment.Color = "danger"
+		return "danger"
 	case 1, 2:
-		attachment.Color = "warning"
+		return "warning"
 	case 3:
-		attachment.Color = "good"
+		return "good"
 	}
+	return ""
+}
 
-	if int(time.Now().Weekday()) == 0 || int(time.Now().Weekday()) == 1 {
-		attachment.Color = "good"
+// weeklyColor returns color of member's line in weekly report
+func weeklyColor(points int) string {
+	switch points {
+	case 0:
+		return "danger"
+	case 1:
+		return "warning"
+	case 2:
+		return "good"
 	}
-
-	attachment.Fields = attachmentFields
-	return attachment
+	return ""
 }
 
 // StandupReportByProject creates a standup report for a specified period of time
@@ -383,17 +532,31 @@ func (r *Reporter) StandupReportByProject(channel model.Channel, dateFrom, dateT
 		}
 		dayInfo := ""
 		for _, member := range chanMembers {
+			if _, away := storage.AbsenceOn(r.db, member.UserID, dateFrom); away {
+				dayInfo += fmt.Sprintf(r.conf.Translate.UserOnVacation, member.UserID)
+				dayInfo += "================================================\n"
+				continue
+			}
 			if !r.db.MemberShouldBeTracked(member.ID, dateFrom) {
 				logrus.Infof("member should not be tracked: %v", member.UserID)
 				continue
 			}
 			userIsNonReporter, err := r.db.IsNonReporter(member.UserID, channel.ChannelID, dateFrom, dateTo)
+			deleted := r.deletedStandupNote(member.UserID, channel.ChannelID, dateFrom, dateTo)
+			holiday, onHoliday := r.holidayOn(channel.ChannelID, dateFrom)
 			if err != nil {
 				logrus.Errorf("reporting.go reportByProject IsNonReporter failed: %v", err)
-				continue
+				if deleted == "" && !onHoliday {
+					continue
+				}
+				userIsNonReporter = true
 			}
-			if userIsNonReporter {
+			if userIsNonReporter && onHoliday {
+				dayInfo += fmt.Sprintf(r.conf.Translate.UserOnHoliday, member.UserID, holiday.Name)
+				dayInfo += deleted
+			} else if userIsNonReporter {
 				dayInfo += fmt.Sprintf(r.conf.Translate.UserDidNotStandup, member.UserID)
+				dayInfo += deleted
 			} else {
 				standup, err := r.db.SelectStandupsFiltered(member.UserID, channel.ChannelID, dateFrom, dateTo)
 				if err != nil {
@@ -443,17 +606,34 @@ func (r *Reporter) StandupReportByUser(slackUserID string, dateFrom, dateTo time
 				logrus.Infof("FindChannelMemberByUserID failed: %v", err)
 				continue
 			}
+			if _, away := storage.AbsenceOn(r.db, slackUserID, dateFrom); away {
+				dayInfo += fmt.Sprintf(r.conf.Translate.UserOnVacationInChannel, channelName, slackUserID)
+				dayInfo += "================================================\n"
+				continue
+			}
 			if !r.db.MemberShouldBeTracked(member.ID, dateFrom) {
 				logrus.Infof("member should not be tracked: %v", slackUserID)
 				continue
 			}
 			userIsNonReporter, err := r.db.IsNonReporter(slackUserID, channel, dateFrom, dateTo)
+			deleted := r.deletedStandupNote(slackUserID, channel, dateFrom, dateTo)
+			holiday, onHoliday := r.holidayOn(channel, dateFrom)
 			if err != nil {
 				logrus.Errorf("reporting.go reportByUser IsNonReporter failed: %v", err)
-				continue
+				if deleted == "" && !onHoliday {
+					continue
+				}
+				userIsNonReporter = true
 			}
-			if userIsNonReporter {
+			if userIsNonReporter && onHoliday {
+				dayInfo += fmt.Sprintf(r.conf.Translate.UserOnHolidayInChannel, channelName, slackUserID, holiday.Name)
+				dayInfo += deleted
+			} else if userIsNonReporter {
 				dayInfo += fmt.Sprintf(r.conf.Translate.UserDidNotStandupInChannel, channelName, slackUserID)
+				if deleted != "" && !strings.HasSuffix(dayInfo, "\n") {
+					dayInfo += "\n"
+				}
+				dayInfo += deleted
 			} else {
 				standup, err := r.db.SelectStandupsFiltered(slackUserID, channel, dateFrom, dateTo)
 				if err != nil {
@@ -492,17 +672,32 @@ func (r *Reporter) StandupReportByProjectAndUser(channel model.Channel, slackUse
 			logrus.Infof("FindChannelMemberByUserID failed: %v", err)
 			continue
 		}
+		if _, away := storage.AbsenceOn(r.db, slackUserID, dateFrom); away {
+			text := fmt.Sprintf(r.conf.Translate.ReportDate, dateFrom.Format("2006-01-02"))
+			text += fmt.Sprintf(r.conf.Translate.UserOnVacation, slackUserID)
+			report.ReportBody = append(report.ReportBody, ReportBodyContent{dateFrom, text})
+			continue
+		}
 		if !r.db.MemberShouldBeTracked(member.ID, dateFrom) {
 			logrus.Infof("member should not be tracked: %v", slackUserID)
 			continue
 		}
 		userIsNonReporter, err := r.db.IsNonReporter(slackUserID, channel.ChannelID, dateFrom, dateTo)
+		deleted := r.deletedStandupNote(slackUserID, channel.ChannelID, dateFrom, dateTo)
+		holiday, onHoliday := r.holidayOn(channel.ChannelID, dateFrom)
 		if err != nil {
 			logrus.Errorf("reporting.go reportByProjectAndUser IsNonReporter failed: %v", err)
-			continue
+			if deleted == "" && !onHoliday {
+				continue
+			}
+			userIsNonReporter = true
 		}
-		if userIsNonReporter {
+		if userIsNonReporter && onHoliday {
+			dayInfo += fmt.Sprintf(r.conf.Translate.UserOnHoliday, slackUserID, holiday.Name)
+			dayInfo += deleted
+		} else if userIsNonReporter {
 			dayInfo += fmt.Sprintf(r.conf.Translate.UserDidNotStandup, slackUserID)
+			dayInfo += deleted
 			dayInfo += "\n"
 		} else {
 			standup, err := r.db.SelectStandupsFiltered(slackUserID, channel.ChannelID, dateFrom, dateTo)
@@ -522,3 +717,131 @@ func (r *Reporter) StandupReportByProjectAndUser(channel model.Channel, slackUse
 	}
 	return report, nil
 }
+
+// deletedStandupNote returns a note about standup which was submitted and deleted afterwards
+func (r *Reporter) deletedStandupNote(userID, channelID string, dateFrom, dateTo time.Time) string {
+	standup, err := r.db.SelectDeletedStandup(userID, channelID, dateFrom, dateTo)
+	if err != nil || standup.DeletedAt == nil {
+		return ""
+	}
+	return fmt.Sprintf(r.conf.Translate.UserDeletedStandup, userID, standup.DeletedAt.Local().Format("15:04"))
+}
+
+// holidayOn returns holiday channel has on the day. Returns false if the day is not a holiday
+func (r *Reporter) holidayOn(channelID string, day time.Time) (model.Holiday, bool) {
+	holidays, err := r.db.ListHolidays(channelID)
+	if err != nil {
+		logrus.Errorf("reporting: ListHolidays failed: %v", err)
+		return model.Holiday{}, false
+	}
+	return model.HolidayOn(holidays, day)
+}
+
+// standupSections returns sections of standup. Standups submitted before sections
+// were stored are parsed on the fly
+func (r *Reporter) standupSections(standup model.Standup) model.StandupSections {
+	if standup.Sections != nil {
+		return standup.Sections
+	}
+	questions, err := r.db.ListStandupQuestions(standup.ChannelID)
+	if err != nil {
+		logrus.Errorf("reporting: ListStandupQuestions failed: %v", err)
+	}
+	return model.ParseStandupSections(standup.Comment, questions)
+}
+
+// noBlockers shows if blockers section just says there are no blockers
+func noBlockers(blockers string) bool {
+	switch strings.Trim(strings.ToLower(blockers), " .!-") {
+	case "", "no", "none", "nope", "nothing", "no problems", "no blockers", "n/a", "нет", "нету", "никаких":
+		return true
+	}
+	return false
+}
+
+// BlockersReport creates a report on blockers mentioned in standups of the project for a specified period of time
+func (r *Reporter) BlockersReport(channel model.Channel, dateFrom, dateTo time.Time) (Report, error) {
+	report := Report{}
+	report.ReportHead = fmt.Sprintf(r.conf.Translate.ReportBlockersHead, channel.ChannelName, dateFrom.Format("2006-01-02"), dateTo.Format("2006-01-02"))
+	dateFromBegin, numberOfDays, err := utils.SetupDays(dateFrom, dateTo)
+	if err != nil {
+		return report, err
+	}
+	for day := 0; day <= numberOfDays; day++ {
+		dateFrom := dateFromBegin.Add(time.Duration(day*24) * time.Hour)
+		dateTo := dateFrom.Add(24 * time.Hour)
+		standups, err := r.db.SelectStandupsByChannelIDForPeriod(channel.ChannelID, dateFrom, dateTo)
+		if err != nil {
+			logrus.Errorf("reporting: SelectStandupsByChannelIDForPeriod failed: %v", err)
+			continue
+		}
+		dayInfo := ""
+		for _, standup := range standups {
+			blockers := r.standupSections(standup)[model.SectionBlockers]
+			if noBlockers(blockers) {
+				continue
+			}
+			dayInfo += fmt.Sprintf(r.conf.Translate.UserBlockers, standup.UserID, blockers)
+		}
+		if dayInfo != "" {
+			text := fmt.Sprintf(r.conf.Translate.ReportDate, dateFrom.Format("2006-01-02"))
+			text += dayInfo
+			report.ReportBody = append(report.ReportBody, ReportBodyContent{dateFrom, text})
+		}
+	}
+	return report, nil
+}
+
+// PlanVsActualReport creates a report which compares what members of the project reported as done
+// with what they planned in their previous standup
+func (r *Reporter) PlanVsActualReport(channel model.Channel, dateFrom, dateTo time.Time) (Report, error) {
+	report := Report{}
+	report.ReportHead = fmt.Sprintf(r.conf.Translate.ReportPlanVsActualHead, channel.ChannelName, dateFrom.Format("2006-01-02"), dateTo.Format("2006-01-02"))
+	dateFromBegin, numberOfDays, err := utils.SetupDays(dateFrom, dateTo)
+	if err != nil {
+		return report, err
+	}
+	for day := 0; day <= numberOfDays; day++ {
+		dateFrom := dateFromBegin.Add(time.Duration(day*24) * time.Hour)
+		dateTo := dateFrom.Add(24 * time.Hour)
+		standups, err := r.db.SelectStandupsByChannelIDForPeriod(channel.ChannelID, dateFrom, dateTo)
+		if err != nil {
+			logrus.Errorf("reporting: SelectStandupsByChannelIDForPeriod failed: %v", err)
+			continue
+		}
+		dayInfo := ""
+		for _, standup := range standups {
+			if standup.Comment == "" {
+				continue
+			}
+			done := r.standupSections(standup)[model.SectionYesterday]
+			if done == "" {
+				done = r.conf.Translate.ReportNotMentioned
+			}
+			plan := r.conf.Translate.ReportNoPlan
+			previous, err := r.previousStandup(standup.UserID, channel.ChannelID, dateFrom)
+			if err == nil && r.standupSections(previous)[model.SectionToday] != "" {
+				plan = r.standupSections(previous)[model.SectionToday]
+			}
+			dayInfo += fmt.Sprintf(r.conf.Translate.UserPlanVsActual, standup.UserID, plan, done)
+		}
+		if dayInfo != "" {
+			text := fmt.Sprintf(r.conf.Translate.ReportDate, dateFrom.Format("2006-01-02"))
+			text += dayInfo
+			report.ReportBody = append(report.ReportBody, ReportBodyContent{dateFrom, text})
+		}
+	}
+	return report, nil
+}
+
+// previousStandup returns the latest non empty standup of user submitted during a week before the day
+func (r *Reporter) previousStandup(userID, channelID string, day time.Time) (model.Standup, error) {
+	for i := 1; i <= 7; i++ {
+		dateFrom := day.AddDate(0, 0, -i)
+		standup, err := r.db.SelectStandupsFiltered(userID, channelID, dateFrom, dateFrom.Add(24*time.Hour))
+		if err == nil && standup.Comment != "" {
+			return standup, nil
+		}
+	}
+	return model.Standup{}, errors.New("no previous standup")
+}
@@ -1,8 +1,12 @@
 package reporting
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,11 +19,37 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// testConfig returns the configuration tests run with. Storage is kept in memory,
+// MySQL is tested only when COMEDIAN_TEST_MYSQL contains its DSN
+func testConfig() (config.Config, error) {
+	translation, err := config.GetTranslation("en_US")
+	if err != nil {
+		return config.Config{}, err
+	}
+	c := config.Config{
+		DatabaseURL:           "memory://",
+		NotifierInterval:      2,
+		ReportTime:            "13:05",
+		Language:              "en_US",
+		ReminderRepeatsMax:    5,
+		ReminderTime:          5,
+		CatchUpTime:           60,
+		TeamMonitoringEnabled: true,
+		CollectorURL:          "https://collector.test",
+		CollectorToken:        "token",
+		Translate:             translation,
+	}
+	if dsn := os.Getenv("COMEDIAN_TEST_MYSQL"); dsn != "" {
+		c.DatabaseURL = dsn
+	}
+	return c, nil
+}
+
 func TestStandupReportByProject(t *testing.T) {
 	d := time.Date(2018, 6, 5, 0, 0, 0, 0, time.UTC)
 	monkey.Patch(time.Now, func() time.Time { return d })
 
-	c, err := config.Get()
+	c, err := testConfig()
 	assert.NoError(t, err)
 	s, err := chat.NewSlack(c)
 	assert.NoError(t, err)
@@ -130,7 +160,7 @@ func TestStandupReportByProject(t *testing.T) {
 func TestStandupReportByUser(t *testing.T) {
 	d := time.Date(2018, 6, 5, 12, 0, 0, 0, time.UTC)
 	monkey.Patch(time.Now, func() time.Time { return d })
-	c, err := config.Get()
+	c, err := testConfig()
 	assert.NoError(t, err)
 	s, err := chat.NewSlack(c)
 	assert.NoError(t, err)
@@ -185,7 +215,7 @@ func TestStandupReportByUser(t *testing.T) {
 func TestStandupReportByProjectAndUser(t *testing.T) {
 	d := time.Date(2018, 6, 5, 12, 0, 0, 0, time.UTC)
 	monkey.Patch(time.Now, func() time.Time { return d })
-	c, err := config.Get()
+	c, err := testConfig()
 	assert.NoError(t, err)
 	s, err := chat.NewSlack(c)
 	assert.NoError(t, err)
@@ -230,7 +260,7 @@ func TestStandupReportByProjectAndUser(t *testing.T) {
 }
 
 func TestGetCollectorDataOnMember(t *testing.T) {
-	c, err := config.Get()
+	c, err := testConfig()
 	assert.NoError(t, err)
 	s, err := chat.NewSlack(c)
 	assert.NoError(t, err)
@@ -293,7 +323,7 @@ func TestGetCollectorDataOnMember(t *testing.T) {
 
 }
 func TestPrepareAttachment(t *testing.T) {
-	c, err := config.Get()
+	c, err := testConfig()
 	assert.NoError(t, err)
 	s, err := chat.NewSlack(c)
 	assert.NoError(t, err)
@@ -340,7 +370,7 @@ func TestPrepareAttachment(t *testing.T) {
 }
 
 func TestPrepareWeeklyAttachment(t *testing.T) {
-	c, err := config.Get()
+	c, err := testConfig()
 	assert.NoError(t, err)
 	s, err := chat.NewSlack(c)
 	assert.NoError(t, err)
@@ -383,15 +413,12 @@ func TestPrepareWeeklyAttachment(t *testing.T) {
 
 }
 func TestGenerateAttachment(t *testing.T) {
-	c, err := config.Get()
+	c, err := testConfig()
 	assert.NoError(t, err)
 	s, err := chat.NewSlack(c)
 	assert.NoError(t, err)
 	r := NewReporter(s)
 
-	d := time.Date(2018, 11, 9, 10, 0, 0, 0, time.UTC)
-	monkey.Patch(time.Now, func() time.Time { return d })
-
 	testCases := []struct {
 		fieldValue string
 		points     int
@@ -405,7 +432,7 @@ func TestGenerateAttachment(t *testing.T) {
 	}
 
 	for _, tt := range testCases {
-		attachment := r.GenerateAttachment(tt.fieldValue, tt.points)
+		attachment := r.GenerateAttachment(tt.fieldValue, tt.points, false)
 		assert.Equal(t, tt.color, attachment.Color)
 		if len(attachment.Fields) != 0 {
 			assert.Equal(t, tt.fieldValue, attachment.Fields[0].Value)
@@ -414,34 +441,7 @@ func TestGenerateAttachment(t *testing.T) {
 		}
 	}
 
-	d = time.Date(2018, 11, 4, 10, 0, 0, 0, time.UTC)
-	monkey.Patch(time.Now, func() time.Time { return d })
-
-	testCases = []struct {
-		fieldValue string
-		points     int
-		color      string
-	}{
-		{"worklogs: 4:30 :disappointed: | commits: 2 :tada: | standup :heavy_check_mark: |", 3, "good"},
-		{"worklogs: 4:30 :disappointed: | commits: 2 :tada: | standup :heavy_check_mark: |", 2, "good"},
-		{"worklogs: 4:30 :disappointed: | commits: 2 :tada: | standup :heavy_check_mark: |", 1, "good"},
-		{"worklogs: 4:30 :disappointed: | commits: 2 :tada: | standup :heavy_check_mark: |", 0, "good"},
-		{"", 0, "good"},
-	}
-
-	for _, tt := range testCases {
-		attachment := r.GenerateAttachment(tt.fieldValue, tt.points)
-		assert.Equal(t, tt.color, attachment.Color)
-		if len(attachment.Fields) != 0 {
-			assert.Equal(t, tt.fieldValue, attachment.Fields[0].Value)
-		} else {
-			assert.Equal(t, 0, len(attachment.Fields))
-		}
-	}
-
-	d = time.Date(2018, 11, 5, 10, 0, 0, 0, time.UTC)
-	monkey.Patch(time.Now, func() time.Time { return d })
-
+	// attachments on days off are always good
 	testCases = []struct {
 		fieldValue string
 		points     int
@@ -455,7 +455,7 @@ func TestGenerateAttachment(t *testing.T) {
 	}
 
 	for _, tt := range testCases {
-		attachment := r.GenerateAttachment(tt.fieldValue, tt.points)
+		attachment := r.GenerateAttachment(tt.fieldValue, tt.points, true)
 		assert.Equal(t, tt.color, attachment.Color)
 		if len(attachment.Fields) != 0 {
 			assert.Equal(t, tt.fieldValue, attachment.Fields[0].Value)
@@ -466,7 +466,7 @@ func TestGenerateAttachment(t *testing.T) {
 }
 
 func TestGenerateReportAttachment(t *testing.T) {
-	c, err := config.Get()
+	c, err := testConfig()
 	assert.NoError(t, err)
 	s, err := chat.NewSlack(c)
 	assert.NoError(t, err)
@@ -506,12 +506,26 @@ func TestGenerateReportAttachment(t *testing.T) {
 		assert.Equal(t, " standup :heavy_check_mark: \n", attachment.Fields[0].Value)
 	}
 
+	// Sunday is a working day of the channel working Sunday to Thursday, Friday is not
+	channel.WorkingDays = "mon,tue,wed,thu,sun"
+	d = time.Date(2018, 11, 12, 10, 0, 0, 0, time.UTC)
+	monkey.Patch(time.Now, func() time.Time { return d })
+
+	attachment = r.generateReportAttachment(channelMember, channel)
+	assert.Equal(t, "warning", attachment.Color)
+
+	d = time.Date(2018, 11, 10, 10, 0, 0, 0, time.UTC)
+	monkey.Patch(time.Now, func() time.Time { return d })
+
+	attachment = r.generateReportAttachment(channelMember, channel)
+	assert.Equal(t, "", attachment.Color)
+
 	r.db.DeleteChannel(channel.ID)
 	r.db.DeleteChannelMember(channelMember.UserID, channelMember.ChannelID)
 }
 
 func TestGenerateWeeklyReportAttachment(t *testing.T) {
-	c, err := config.Get()
+	c, err := testConfig()
 	assert.NoError(t, err)
 	s, err := chat.NewSlack(c)
 	assert.NoError(t, err)
@@ -544,3 +558,232 @@ func TestGenerateWeeklyReportAttachment(t *testing.T) {
 	r.db.DeleteChannel(channel.ID)
 	r.db.DeleteChannelMember(channelMember.UserID, channelMember.ChannelID)
 }
+
+func TestStandupReportWithMemoryStorage(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	s, err := chat.NewSlack(config.Config{
+		DatabaseURL: "memory://",
+		Translate:   translation,
+	})
+	assert.NoError(t, err)
+	r := NewReporter(s)
+
+	channel, err := r.db.CreateChannel(model.Channel{
+		ChannelName: "channame",
+		ChannelID:   "chanid",
+	})
+	assert.NoError(t, err)
+
+	dateTo := time.Now()
+	dateFrom := time.Now().AddDate(0, 0, -1)
+
+	report, err := r.StandupReportByProject(channel, dateFrom, dateTo)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(report.ReportBody))
+
+	member1, err := r.db.CreateChannelMember(model.ChannelMember{UserID: "userID1", ChannelID: channel.ChannelID})
+	assert.NoError(t, err)
+	member2, err := r.db.CreateChannelMember(model.ChannelMember{UserID: "userID2", ChannelID: channel.ChannelID})
+	assert.NoError(t, err)
+	member3, err := r.db.CreateChannelMember(model.ChannelMember{UserID: "userID3", ChannelID: channel.ChannelID})
+	assert.NoError(t, err)
+	// member with empty timetable is not tracked
+	_, err = r.db.CreateTimeTable(model.TimeTable{ChannelMemberID: member3.ID})
+	assert.NoError(t, err)
+
+	_, err = r.db.CreateStandup(model.Standup{
+		ChannelID: channel.ChannelID,
+		UserID:    member1.UserID,
+		Comment:   "my standup",
+		MessageTS: "123",
+	})
+	assert.NoError(t, err)
+	_, err = r.db.CreateStandup(model.Standup{
+		ChannelID: channel.ChannelID,
+		UserID:    member2.UserID,
+		Comment:   "",
+	})
+	assert.NoError(t, err)
+
+	report, err = r.StandupReportByProject(channel, dateFrom, dateTo)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(report.ReportBody))
+	text := report.ReportBody[0].Text
+	assert.Contains(t, text, fmt.Sprintf(translation.UserDidStandup, member1.UserID)+"my standup")
+	assert.Contains(t, text, fmt.Sprintf(translation.UserDidNotStandup, member2.UserID))
+	assert.NotContains(t, text, member3.UserID)
+
+	report, err = r.StandupReportByProjectAndUser(channel, member1.UserID, dateFrom, dateTo)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(report.ReportBody))
+	assert.Contains(t, report.ReportBody[0].Text, "my standup")
+
+	standup, err := r.db.SelectStandupByMessageTS("123")
+	assert.NoError(t, err)
+	assert.NoError(t, r.db.MarkStandupDeleted(standup.ID))
+	standup, err = r.db.SelectDeletedStandup(member1.UserID, channel.ChannelID, dateFrom, time.Now())
+	assert.NoError(t, err)
+	deleted := fmt.Sprintf(translation.UserDeletedStandup, member1.UserID, standup.DeletedAt.Local().Format("15:04"))
+
+	report, err = r.StandupReportByProject(channel, dateFrom, dateTo)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(report.ReportBody))
+	assert.Contains(t, report.ReportBody[0].Text, fmt.Sprintf(translation.UserDidNotStandup, member1.UserID)+deleted)
+	assert.NotContains(t, report.ReportBody[0].Text, "my standup")
+
+	report, err = r.StandupReportByUser(member1.UserID, dateFrom, dateTo)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(report.ReportBody))
+	assert.Contains(t, report.ReportBody[0].Text, deleted)
+
+	// missed standups on holidays are shown as holidays
+	_, err = r.db.CreateHoliday(model.Holiday{ChannelID: channel.ChannelID, Day: dateFrom.Format(model.HolidayDay), Name: "Founders Day"})
+	assert.NoError(t, err)
+	report, err = r.StandupReportByProject(channel, dateFrom, dateTo)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(report.ReportBody))
+	assert.Contains(t, report.ReportBody[0].Text, fmt.Sprintf(translation.UserOnHoliday, member2.UserID, "Founders Day"))
+	assert.NotContains(t, report.ReportBody[0].Text, member3.UserID)
+
+	report, err = r.StandupReportByUser(member1.UserID, dateFrom, dateTo)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(report.ReportBody))
+	assert.Contains(t, report.ReportBody[0].Text, fmt.Sprintf(translation.UserOnHolidayInChannel, channel.ChannelName, member1.UserID, "Founders Day"))
+
+	// members away are on vacation rather than non reporters
+	day := dateTo.Format(model.HolidayDay)
+	_, err = r.db.CreateAbsence(model.Absence{UserID: member2.UserID, FirstDay: day, LastDay: day})
+	assert.NoError(t, err)
+	report, err = r.StandupReportByProject(channel, dateTo, dateTo)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(report.ReportBody))
+	assert.Contains(t, report.ReportBody[0].Text, fmt.Sprintf(translation.UserOnVacation, member2.UserID))
+	assert.NotContains(t, report.ReportBody[0].Text, fmt.Sprintf(translation.UserDidNotStandup, member2.UserID))
+
+	report, err = r.StandupReportByProjectAndUser(channel, member2.UserID, dateTo, dateTo)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(report.ReportBody))
+	assert.Contains(t, report.ReportBody[0].Text, fmt.Sprintf(translation.UserOnVacation, member2.UserID))
+}
+
+func TestSectionsReports(t *testing.T) {
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	s, err := chat.NewSlack(config.Config{DatabaseURL: "memory://", Translate: translation})
+	assert.NoError(t, err)
+	r := NewReporter(s)
+
+	channel, err := r.db.CreateChannel(model.Channel{ChannelName: "channame", ChannelID: "chanid"})
+	assert.NoError(t, err)
+
+	createStandup := func(day time.Time, userID, comment string, sections model.StandupSections) {
+		monkey.Patch(time.Now, func() time.Time { return day })
+		_, err := r.db.CreateStandup(model.Standup{ChannelID: channel.ChannelID, UserID: userID, Comment: comment, Sections: sections, MessageTS: day.String() + userID})
+		assert.NoError(t, err)
+		monkey.Unpatch(time.Now)
+	}
+	d1 := time.Date(2018, 6, 4, 10, 0, 0, 0, time.UTC)
+	d2 := time.Date(2018, 6, 5, 10, 0, 0, 0, time.UTC)
+	createStandup(d1, "userid1", "yesterday: docs, today: fix bugs, problems: none", nil)
+	createStandup(d1, "userid2", "", nil)
+	createStandup(d2, "userid1", "Fixed bugs, waiting for review", model.StandupSections{model.SectionYesterday: "fixed bugs", model.SectionBlockers: "waiting for review"})
+	createStandup(d2, "userid2", "yesterday: tests, today: more tests", nil)
+
+	dateFrom := time.Date(2018, 6, 4, 0, 0, 0, 0, time.UTC)
+	dateTo := time.Date(2018, 6, 5, 0, 0, 0, 0, time.UTC)
+
+	report, err := r.BlockersReport(channel, dateFrom, dateTo)
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf(translation.ReportBlockersHead, "channame", "2018-06-04", "2018-06-05"), report.ReportHead)
+	assert.Equal(t, 1, len(report.ReportBody))
+	assert.Equal(t, fmt.Sprintf(translation.ReportDate, "2018-06-05")+fmt.Sprintf(translation.UserBlockers, "userid1", "waiting for review"), report.ReportBody[0].Text)
+
+	report, err = r.PlanVsActualReport(channel, dateFrom, dateTo)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(report.ReportBody))
+	assert.Equal(t, fmt.Sprintf(translation.ReportDate, "2018-06-04")+
+		fmt.Sprintf(translation.UserPlanVsActual, "userid1", translation.ReportNoPlan, "docs"), report.ReportBody[0].Text)
+	assert.Equal(t, fmt.Sprintf(translation.ReportDate, "2018-06-05")+
+		fmt.Sprintf(translation.UserPlanVsActual, "userid1", "fix bugs", "fixed bugs")+
+		fmt.Sprintf(translation.UserPlanVsActual, "userid2", translation.ReportNoPlan, "tests"), report.ReportBody[1].Text)
+}
+
+func TestTeamReportFormats(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var blocksMessage struct {
+		Channel string       `json:"channel"`
+		Blocks  []chat.Block `json:"blocks"`
+	}
+	httpmock.RegisterResponder("POST", "https://slack.com/api/chat.postMessage", func(req *http.Request) (*http.Response, error) {
+		if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+			json.NewDecoder(req.Body).Decode(&blocksMessage)
+		} else {
+			req.ParseForm()
+			blocksMessage.Channel = req.Form.Get("channel")
+			blocksMessage.Blocks = nil
+			assert.Contains(t, req.Form.Get("attachments"), "\"color\":\"danger\"")
+		}
+		return httpmock.NewStringResponse(200, `{"ok": true}`), nil
+	})
+
+	translation, err := config.GetTranslation("en_US")
+	assert.NoError(t, err)
+	s, err := chat.NewSlack(config.Config{DatabaseURL: "memory://", SlackToken: "token", Translate: translation})
+	assert.NoError(t, err)
+	r := NewReporter(s)
+
+	reports := []memberReport{}
+	for i := 0; i < 12; i++ {
+		reports = append(reports, memberReport{
+			UserID:      fmt.Sprintf("user%v", i),
+			ChannelName: "general",
+			Color:       "good",
+			FieldValue:  translation.HasStandup,
+			Status:      memberStatus{Standup: translation.HasStandup, Points: 3},
+		})
+	}
+	reports[11].Color = "danger"
+	reports[11].Status = memberStatus{Worklogs: fmt.Sprintf(translation.Worklogs, "1:00", ":angry:"), Standup: translation.NoStandup}
+
+	blocks := r.reportBlocks(translation.ReportHeader, reports)
+	// header, 10 members with context and "Show more" button
+	assert.Equal(t, 22, len(blocks))
+	assert.Equal(t, "header", blocks[0].Type)
+	assert.Equal(t, ":red_circle: <@user11> in #general", blocks[1].Text.Text)
+	assert.Equal(t, []interface{}{
+		chat.TextObject{Type: "mrkdwn", Text: "worklogs: 1:00 :angry:"},
+		chat.TextObject{Type: "mrkdwn", Text: "standup :x:"},
+	}, blocks[2].Elements)
+	assert.Equal(t, ":large_green_circle: <@user0> in #general", blocks[3].Text.Text)
+	button := blocks[21].Elements[0].(chat.BlockElement)
+	assert.Equal(t, chat.ActionShowMore, button.ActionID)
+	assert.Equal(t, fmt.Sprintf(translation.ReportShowMore, 2), button.Text.Text)
+	assert.Equal(t, ":large_green_circle: <@user9> in #general: standup :heavy_check_mark:\n:large_green_circle: <@user10> in #general: standup :heavy_check_mark:", button.Value)
+
+	r.sendTeamReport("C1", translation.ReportHeader, reports)
+	assert.Equal(t, "C1", blocksMessage.Channel)
+	assert.Equal(t, 22, len(blocksMessage.Blocks))
+
+	r.conf.ReportFormat = config.ReportFormatAttachments
+	r.sendTeamReport("C2", translation.ReportHeader, reports)
+	assert.Equal(t, "C2", blocksMessage.Channel)
+	assert.Equal(t, 0, len(blocksMessage.Blocks))
+}
+
+func TestMemberStatusFieldValue(t *testing.T) {
+	status := memberStatus{Worklogs: " worklogs: 8:00 :wink: ", Commits: " commits: 2 :tada: ", Standup: " standup :x: "}
+	assert.Equal(t, " worklogs: 8:00 :wink: | commits: 2 :tada: | standup :x: |\n", status.dailyFieldValue())
+	assert.Equal(t, " worklogs: 8:00 :wink: | commits: 2 :tada: |\n", status.weeklyFieldValue())
+	assert.Equal(t, []string{"worklogs: 8:00 :wink:", "commits: 2 :tada:", "standup :x:"}, status.parts())
+
+	status.Commits = ""
+	assert.Equal(t, " worklogs: 8:00 :wink: | standup :x: |\n", status.dailyFieldValue())
+	assert.Equal(t, " worklogs: 8:00 :wink: |\n", status.weeklyFieldValue())
+
+	status.Worklogs = ""
+	assert.Equal(t, " standup :x: \n", status.dailyFieldValue())
+	assert.Equal(t, "", status.weeklyFieldValue())
+}
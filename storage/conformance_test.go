@@ -0,0 +1,974 @@
+package storage
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// storageBackends returns every backend conformance suite runs against.
+// Memory storage is always tested, MySQL uses COMEDIAN_DATABASE as all other tests do,
+// PostgreSQL is tested only when COMEDIAN_TEST_POSTGRES contains its DSN
+func storageBackends(t *testing.T) map[string]Storage {
+	backends := map[string]Storage{
+		"memory": NewMemory(),
+	}
+
+	c, err := config.Get()
+	if !assert.NoError(t, err) {
+		return backends
+	}
+	mysql, err := NewMySQL(c)
+	if assert.NoError(t, err) {
+		backends["mysql"] = mysql
+	}
+
+	if dsn := os.Getenv("COMEDIAN_TEST_POSTGRES"); dsn != "" {
+		c.DatabaseURL = dsn
+		postgres, err := NewPostgres(c)
+		if assert.NoError(t, err) {
+			backends["postgres"] = postgres
+		}
+	}
+	return backends
+}
+
+func TestStorageConformance(t *testing.T) {
+	for name, db := range storageBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Standups", func(t *testing.T) { testStandups(t, db) })
+			t.Run("NonReporters", func(t *testing.T) { testNonReporters(t, db) })
+			t.Run("DeletedStandups", func(t *testing.T) { testDeletedStandups(t, db) })
+			t.Run("ChannelMembers", func(t *testing.T) { testChannelMembers(t, db) })
+			t.Run("Channels", func(t *testing.T) { testChannels(t, db) })
+			t.Run("Users", func(t *testing.T) { testUsers(t, db) })
+			t.Run("TimeTables", func(t *testing.T) { testTimeTables(t, db) })
+			t.Run("Workspaces", func(t *testing.T) { testWorkspaces(t, db) })
+			t.Run("StandupQuestions", func(t *testing.T) { testStandupQuestions(t, db) })
+			t.Run("NotificationSchedule", func(t *testing.T) { testNotificationSchedule(t, db) })
+			t.Run("Outbox", func(t *testing.T) { testOutbox(t, db) })
+			t.Run("TimeZones", func(t *testing.T) { testTimeZones(t, db) })
+			t.Run("Holidays", func(t *testing.T) { testHolidays(t, db) })
+			t.Run("Absences", func(t *testing.T) { testAbsences(t, db) })
+		})
+	}
+}
+
+func testStandups(t *testing.T, db Storage) {
+	channelID := "conformance-standups"
+	from := time.Now().UTC().Add(-time.Minute)
+
+	_, err := db.CreateStandup(model.Standup{ChannelID: channelID})
+	assert.Error(t, err)
+
+	assert.False(t, db.SubmittedStandupToday("userID1", channelID))
+
+	s, err := db.CreateStandup(model.Standup{
+		ChannelID: channelID,
+		UserID:    "userID1",
+		Comment:   "work hard",
+		MessageTS: "conformance-ts1",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotZero(t, s.ID)
+	defer db.DeleteStandup(s.ID)
+
+	assert.True(t, db.SubmittedStandupToday("userID1", channelID))
+
+	// empty standups of missed days are created back then
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Truncate(time.Second)
+	filled, err := db.CreateStandup(model.Standup{ChannelID: channelID, UserID: "userID2", MessageTS: "conformance-ts2", Created: yesterday})
+	if assert.NoError(t, err) {
+		defer db.DeleteStandup(filled.ID)
+		selected, err := db.SelectStandupsFiltered("userID2", channelID, yesterday.Add(-time.Minute), yesterday.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, filled.ID, selected.ID)
+	}
+
+	s.Comment = "work harder"
+	updated, err := db.UpdateStandup(s)
+	assert.NoError(t, err)
+	assert.Equal(t, "work harder", updated.Comment)
+	assert.Equal(t, s.ID, updated.ID)
+
+	selected, err := db.SelectStandupByMessageTS("conformance-ts1")
+	assert.NoError(t, err)
+	assert.Equal(t, s.ID, selected.ID)
+	assert.Equal(t, "work harder", selected.Comment)
+	assert.Nil(t, selected.Sections)
+
+	s.Sections = model.StandupSections{model.SectionToday: "work harder", model.SectionBlockers: "none"}
+	_, err = db.UpdateStandup(s)
+	assert.NoError(t, err)
+	selected, err = db.SelectStandupByMessageTS("conformance-ts1")
+	assert.NoError(t, err)
+	assert.Equal(t, s.Sections, selected.Sections)
+
+	_, err = db.SelectStandupByMessageTS("conformance-missing")
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	to := time.Now().UTC().Add(time.Minute)
+	standups, err := db.SelectStandupsByChannelIDForPeriod(channelID, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(standups))
+
+	standups, err = db.SelectStandupsByChannelIDForPeriod(channelID, from.AddDate(0, 0, -2), from.AddDate(0, 0, -1))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(standups))
+
+	filtered, err := db.SelectStandupsFiltered("userID1", channelID, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, s.ID, filtered.ID)
+
+	_, err = db.SelectStandupsFiltered("userID2", channelID, from, to)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	history, err := db.AddToStandupHistory(model.StandupEditHistory{
+		StandupID:   s.ID,
+		StandupText: "work hard",
+	})
+	assert.NoError(t, err)
+	assert.NotZero(t, history.ID)
+	_, err = db.AddToStandupHistory(model.StandupEditHistory{
+		StandupID:     s.ID,
+		StandupText:   "work harder",
+		AfterDeadline: true,
+	})
+	assert.NoError(t, err)
+
+	versions, err := db.ListStandupHistory(s.ID)
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(versions)) {
+		assert.Equal(t, "work hard", versions[0].StandupText)
+		assert.False(t, versions[0].AfterDeadline)
+		assert.Equal(t, "work harder", versions[1].StandupText)
+		assert.True(t, versions[1].AfterDeadline)
+	}
+
+	versions, err = db.ListStandupHistory(s.ID + 1000)
+	assert.NoError(t, err)
+	assert.Empty(t, versions)
+
+	_, err = db.AddToStandupHistory(model.StandupEditHistory{})
+	assert.Error(t, err)
+
+	assert.NoError(t, db.DeleteStandup(s.ID))
+	_, err = db.SelectStandupByMessageTS("conformance-ts1")
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func testNonReporters(t *testing.T, db Storage) {
+	channelID := "conformance-nonreporters"
+	from := time.Now().UTC().Add(-time.Minute)
+
+	members := []model.ChannelMember{
+		{UserID: "userID1", ChannelID: channelID},
+		{UserID: "userID2", ChannelID: channelID},
+		{UserID: "userID3", ChannelID: channelID, RoleInChannel: "pm"},
+	}
+	for i, m := range members {
+		member, err := db.CreateChannelMember(m)
+		if !assert.NoError(t, err) {
+			return
+		}
+		members[i] = member
+		defer db.DeleteChannelMember(member.UserID, member.ChannelID)
+	}
+
+	to := time.Now().UTC().Add(time.Minute)
+	nonReporters, err := db.GetNonReporters(channelID, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(nonReporters), "pm is never a non reporter")
+
+	_, err = db.IsNonReporter("userID1", channelID, from, to)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	s1, err := db.CreateStandup(model.Standup{ChannelID: channelID, UserID: "userID1", Comment: "done"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.DeleteStandup(s1.ID)
+	// empty standups are created by bot for those who did not submit standup
+	s2, err := db.CreateStandup(model.Standup{ChannelID: channelID, UserID: "userID2"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.DeleteStandup(s2.ID)
+
+	to = time.Now().UTC().Add(time.Minute)
+	isNonReporter, err := db.IsNonReporter("userID1", channelID, from, to)
+	assert.NoError(t, err)
+	assert.False(t, isNonReporter)
+
+	isNonReporter, err = db.IsNonReporter("userID2", channelID, from, to)
+	assert.NoError(t, err)
+	assert.True(t, isNonReporter)
+
+	nonReporters, err = db.GetNonReporters(channelID, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(nonReporters))
+
+	nonReporters, err = db.GetNonReporters(channelID, from.AddDate(0, 0, -2), from.AddDate(0, 0, -1))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(nonReporters))
+}
+
+func testDeletedStandups(t *testing.T, db Storage) {
+	channelID := "conformance-deleted"
+	from := time.Now().UTC().Add(-time.Minute)
+
+	member, err := db.CreateChannelMember(model.ChannelMember{UserID: "userID1", ChannelID: channelID})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.DeleteChannelMember(member.UserID, member.ChannelID)
+
+	s, err := db.CreateStandup(model.Standup{
+		ChannelID: channelID,
+		UserID:    "userID1",
+		Comment:   "work hard",
+		MessageTS: "conformance-deleted-ts",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.DeleteStandup(s.ID)
+
+	to := time.Now().UTC().Add(time.Minute)
+	_, err = db.SelectDeletedStandup("userID1", channelID, from, to)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	assert.NoError(t, db.MarkStandupDeleted(s.ID))
+
+	// deleted standups are treated as if they were never submitted
+	_, err = db.SelectStandupByMessageTS("conformance-deleted-ts")
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = db.SelectStandupsFiltered("userID1", channelID, from, to)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = db.IsNonReporter("userID1", channelID, from, to)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.False(t, db.SubmittedStandupToday("userID1", channelID))
+	standups, err := db.SelectStandupsByChannelIDForPeriod(channelID, from, to)
+	assert.NoError(t, err)
+	assert.Empty(t, standups)
+	nonReporters, err := db.GetNonReporters(channelID, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(nonReporters))
+
+	deleted, err := db.SelectDeletedStandup("userID1", channelID, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, s.ID, deleted.ID)
+	if assert.NotNil(t, deleted.DeletedAt) {
+		assert.WithinDuration(t, time.Now(), *deleted.DeletedAt, time.Minute)
+	}
+
+	restored, err := db.RestoreStandup(s.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, "work hard", restored.Comment)
+
+	selected, err := db.SelectStandupByMessageTS("conformance-deleted-ts")
+	assert.NoError(t, err)
+	assert.Equal(t, s.ID, selected.ID)
+	nonReporters, err = db.GetNonReporters(channelID, from, to)
+	assert.NoError(t, err)
+	assert.Empty(t, nonReporters)
+	_, err = db.SelectDeletedStandup("userID1", channelID, from, to)
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func testChannelMembers(t *testing.T, db Storage) {
+	channelID := "conformance-members"
+
+	_, err := db.CreateChannelMember(model.ChannelMember{})
+	assert.Error(t, err)
+
+	user, err := db.CreateUser(model.User{UserID: "conformance-user", UserName: "conformanceuser", Role: ""})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.DeleteUser(user.ID)
+
+	member, err := db.CreateChannelMember(model.ChannelMember{
+		UserID:        user.UserID,
+		ChannelID:     channelID,
+		RoleInChannel: "pm",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotZero(t, member.ID)
+	defer db.DeleteChannelMember(member.UserID, member.ChannelID)
+
+	selected, err := db.SelectChannelMember(member.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, member.UserID, selected.UserID)
+
+	selected, err = db.FindChannelMemberByUserID(user.UserID, channelID)
+	assert.NoError(t, err)
+	assert.Equal(t, member.ID, selected.ID)
+
+	selected, err = db.FindChannelMemberByUserName(user.UserName, channelID)
+	assert.NoError(t, err)
+	assert.Equal(t, member.ID, selected.ID)
+
+	_, err = db.FindChannelMemberByUserID(user.UserID, "conformance-missing")
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	members, err := db.FindMembersByUserID(user.UserID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(members))
+
+	channels, err := db.GetUserChannels(user.UserID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{channelID}, channels)
+
+	members, err = db.ListChannelMembers(channelID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(members))
+
+	members, err = db.ListChannelMembersByRole(channelID, "pm")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(members))
+
+	members, err = db.ListChannelMembersByRole(channelID, "developer")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(members))
+
+	assert.True(t, db.UserIsPMForProject(user.UserID, channelID))
+	assert.False(t, db.UserIsPMForProject(user.UserID, "conformance-missing"))
+
+	assert.NoError(t, db.DeleteChannelMember(user.UserID, channelID))
+	members, err = db.ListChannelMembers(channelID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(members))
+}
+
+func testChannels(t *testing.T, db Storage) {
+	channel, err := db.CreateChannel(model.Channel{
+		ChannelName: "conformancechan",
+		ChannelID:   "conformance-channel",
+		StandupTime: int64(12345),
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotZero(t, channel.ID)
+	defer db.DeleteChannel(channel.ID)
+
+	selected, err := db.SelectChannel(channel.ChannelID)
+	assert.NoError(t, err)
+	assert.Equal(t, channel.ChannelName, selected.ChannelName)
+	assert.Equal(t, int64(0), selected.StandupTime, "standup time is set separately")
+
+	_, err = db.SelectChannel("conformance-missing")
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	name, err := db.GetChannelName(channel.ChannelID)
+	assert.NoError(t, err)
+	assert.Equal(t, channel.ChannelName, name)
+
+	id, err := db.GetChannelID(channel.ChannelName)
+	assert.NoError(t, err)
+	assert.Equal(t, channel.ChannelID, id)
+
+	assert.NoError(t, db.CreateStandupTime(int64(12345), channel.ChannelID))
+	standupTime, err := db.GetChannelStandupTime(channel.ChannelID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12345), standupTime)
+
+	assert.NoError(t, db.UpdateChannelStandupTime(int64(54321), channel.ChannelID))
+	standupTime, err = db.GetChannelStandupTime(channel.ChannelID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(54321), standupTime)
+
+	standupTimes, err := db.ListAllStandupTime()
+	assert.NoError(t, err)
+	assert.Contains(t, standupTimes, int64(54321))
+
+	assert.NoError(t, db.DeleteStandupTime(channel.ChannelID))
+	standupTime, err = db.GetChannelStandupTime(channel.ChannelID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), standupTime)
+
+	_, err = db.GetChannelStandupTime("conformance-missing")
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	channels, err := db.GetAllChannels()
+	assert.NoError(t, err)
+	assert.True(t, containsChannel(channels, channel.ChannelID))
+
+	assert.Equal(t, model.ChannelActive, selected.Status)
+	selected.ChannelName = "conformancerenamed"
+	selected.Status = model.ChannelArchived
+	updated, err := db.UpdateChannel(selected)
+	assert.NoError(t, err)
+	assert.Equal(t, "conformancerenamed", updated.ChannelName)
+	assert.False(t, updated.Active())
+	id, err = db.GetChannelID("conformancerenamed")
+	assert.NoError(t, err)
+	assert.Equal(t, channel.ChannelID, id)
+	_, err = db.GetChannelID(channel.ChannelName)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	assert.Equal(t, channel.ChannelID, updated.ReportChannel())
+	updated.AutoEnroll = true
+	updated.ReportChannelID = "conformance-reports"
+	updated, err = db.UpdateChannel(updated)
+	assert.NoError(t, err)
+	assert.True(t, updated.AutoEnroll)
+	assert.Equal(t, "conformance-reports", updated.ReportChannel())
+
+	assert.Equal(t, model.DefaultWorkingDays, updated.Days())
+	updated.WorkingDays = "mon,wed,fri"
+	updated, err = db.UpdateChannel(updated)
+	assert.NoError(t, err)
+	assert.Equal(t, "mon,wed,fri", updated.WorkingDays)
+
+	assert.NoError(t, db.ExcludeFromAutoEnroll(channel.ChannelID, "conformance-bot"))
+	assert.NoError(t, db.ExcludeFromAutoEnroll(channel.ChannelID, "conformance-bot"))
+	assert.NoError(t, db.ExcludeFromAutoEnroll(channel.ChannelID, "conformance-observer"))
+	excluded, err := db.ListAutoEnrollExclusions(channel.ChannelID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"conformance-bot", "conformance-observer"}, excluded)
+	assert.NoError(t, db.IncludeInAutoEnroll(channel.ChannelID, "conformance-bot"))
+	assert.NoError(t, db.IncludeInAutoEnroll(channel.ChannelID, "conformance-observer"))
+	excluded, err = db.ListAutoEnrollExclusions(channel.ChannelID)
+	assert.NoError(t, err)
+	assert.Empty(t, excluded)
+
+	// new channel may take the name of deleted one
+	updated.Status = model.ChannelDeleted
+	_, err = db.UpdateChannel(updated)
+	assert.NoError(t, err)
+	_, err = db.GetChannelID("conformancerenamed")
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = db.UpdateChannel(model.Channel{ChannelID: "conformance-missing"})
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	assert.NoError(t, db.DeleteChannel(channel.ID))
+	channels, err = db.GetChannels()
+	assert.NoError(t, err)
+	assert.False(t, containsChannel(channels, channel.ChannelID))
+}
+
+func testTimeZones(t *testing.T, db Storage) {
+	channel, err := db.CreateChannel(model.Channel{ChannelName: "conformance", ChannelID: "conformance-zones", TimeZone: "Asia/Bishkek"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.DeleteChannel(channel.ID)
+	user, err := db.CreateUser(model.User{UserName: "conformancezones", UserID: "conformance-zones"})
+	assert.NoError(t, err)
+	defer db.DeleteUser(user.ID)
+
+	assert.Equal(t, "Asia/Bishkek", MemberLocation(db, user.UserID, channel.ChannelID).String())
+
+	channel.TimeZone = "Europe/Berlin"
+	channel, err = db.UpdateChannel(channel)
+	assert.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", channel.TimeZone)
+
+	user.TimeZone = "America/New_York"
+	user, err = db.UpdateUser(user)
+	assert.NoError(t, err)
+	assert.Equal(t, "America/New_York", user.TimeZone)
+	assert.Equal(t, "America/New_York", MemberLocation(db, user.UserID, channel.ChannelID).String())
+
+	assert.Equal(t, time.Local, MemberLocation(db, "conformance-missing", "conformance-missing"))
+}
+
+func testHolidays(t *testing.T, db Storage) {
+	channelID := "conformance-holidays"
+	_, err := db.CreateHoliday(model.Holiday{Day: "2019-01-01", Name: "New Year"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.DeleteHoliday("", "2019-01-01")
+	womensDay, err := db.CreateHoliday(model.Holiday{ChannelID: channelID, Day: "2019-03-08", Name: "Women's Day"})
+	assert.NoError(t, err)
+	defer db.DeleteHoliday(channelID, "2019-03-08")
+	_, err = db.CreateHoliday(model.Holiday{ChannelID: "conformance-other", Day: "2019-02-23", Name: "Other"})
+	assert.NoError(t, err)
+	defer db.DeleteHoliday("conformance-other", "2019-02-23")
+
+	// holiday on the same day is replaced
+	replaced, err := db.CreateHoliday(model.Holiday{ChannelID: channelID, Day: "2019-03-08", Name: "International Women's Day"})
+	assert.NoError(t, err)
+	assert.Equal(t, womensDay.ID, replaced.ID)
+
+	holidays, err := db.ListHolidays(channelID)
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(holidays)) {
+		assert.Equal(t, "New Year", holidays[0].Name)
+		assert.Equal(t, "", holidays[0].ChannelID)
+		assert.Equal(t, "International Women's Day", holidays[1].Name)
+	}
+
+	workspace, err := db.ListHolidays("")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(workspace))
+
+	// imported holidays replace the ones on the same days too
+	assert.NoError(t, db.ImportHolidays([]model.Holiday{
+		{ChannelID: channelID, Day: "2019-03-08", Name: "Women's Day"},
+		{ChannelID: channelID, Day: "2019-05-01", Name: "Labour Day"},
+	}))
+	defer db.DeleteHoliday(channelID, "2019-05-01")
+	holidays, err = db.ListHolidays(channelID)
+	assert.NoError(t, err)
+	if assert.Equal(t, 3, len(holidays)) {
+		assert.Equal(t, "Women's Day", holidays[1].Name)
+		assert.Equal(t, "Labour Day", holidays[2].Name)
+	}
+	assert.NoError(t, db.DeleteHoliday(channelID, "2019-05-01"))
+
+	assert.NoError(t, db.DeleteHoliday(channelID, "2019-03-08"))
+	holidays, err = db.ListHolidays(channelID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(holidays))
+}
+
+func testAbsences(t *testing.T, db Storage) {
+	channelID := "conformance-absences"
+	members := []model.ChannelMember{
+		{UserID: "conformance-away", ChannelID: channelID},
+		{UserID: "conformance-here", ChannelID: channelID},
+	}
+	for i, m := range members {
+		member, err := db.CreateChannelMember(m)
+		if !assert.NoError(t, err) {
+			return
+		}
+		members[i] = member
+		defer db.DeleteChannelMember(member.UserID, member.ChannelID)
+	}
+
+	absence, err := db.CreateAbsence(model.Absence{UserID: "conformance-away", FirstDay: "2018-06-25", LastDay: "2018-06-29", Reason: "vacation"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotZero(t, absence.ID)
+	defer db.DeleteAbsence(absence.ID)
+	earlier, err := db.CreateAbsence(model.Absence{UserID: "conformance-away", FirstDay: "2018-05-01", LastDay: "2018-05-01"})
+	assert.NoError(t, err)
+
+	absences, err := db.ListAbsences("conformance-away")
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(absences)) {
+		assert.Equal(t, "2018-05-01", absences[0].FirstDay)
+		assert.Equal(t, "vacation", absences[1].Reason)
+	}
+	earlier.LastDay = "2018-05-02"
+	_, err = db.UpdateAbsence(earlier)
+	assert.NoError(t, err)
+	absences, err = db.ListAbsences("conformance-away")
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(absences)) {
+		assert.Equal(t, "2018-05-02", absences[0].LastDay)
+	}
+	assert.NoError(t, db.DeleteAbsence(earlier.ID))
+	absences, err = db.ListAbsences("conformance-away")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(absences))
+
+	// members away are neither tracked nor non reporters
+	friday := time.Date(2018, 6, 29, 10, 0, 0, 0, time.UTC)
+	assert.False(t, db.MemberShouldBeTracked(members[0].ID, friday))
+	assert.True(t, db.MemberShouldBeTracked(members[1].ID, friday))
+	assert.True(t, db.MemberShouldBeTracked(members[0].ID, friday.AddDate(0, 0, 3)))
+
+	nonReporters, err := db.GetNonReporters(channelID, friday, friday.Add(time.Hour))
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(nonReporters)) {
+		assert.Equal(t, "conformance-here", nonReporters[0].UserID)
+	}
+	nonReporters, err = db.GetNonReporters(channelID, friday.AddDate(0, 0, 3), friday.AddDate(0, 0, 4))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(nonReporters))
+
+	// one day away does not excuse the other days of the period
+	oneDay, err := db.CreateAbsence(model.Absence{UserID: "conformance-here", FirstDay: "2018-06-27", LastDay: "2018-06-27"})
+	assert.NoError(t, err)
+	defer db.DeleteAbsence(oneDay.ID)
+	wednesday := time.Date(2018, 6, 27, 10, 0, 0, 0, time.UTC)
+	nonReporters, err = db.GetNonReporters(channelID, wednesday, wednesday.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(nonReporters))
+	nonReporters, err = db.GetNonReporters(channelID, wednesday.AddDate(0, 0, -1), wednesday.AddDate(0, 0, 1))
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(nonReporters)) {
+		assert.Equal(t, "conformance-here", nonReporters[0].UserID)
+	}
+}
+
+func testUsers(t *testing.T, db Storage) {
+	user, err := db.CreateUser(model.User{
+		UserName: "conformanceadmin",
+		UserID:   "conformance-admin",
+		Role:     "",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotZero(t, user.ID)
+	defer db.DeleteUser(user.ID)
+
+	user.Role = "admin"
+	updated, err := db.UpdateUser(user)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", updated.Role)
+
+	selected, err := db.SelectUser(user.UserID)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, selected.ID)
+	assert.True(t, selected.IsAdmin())
+
+	selected, err = db.SelectUserByUserName(user.UserName)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, selected.ID)
+
+	_, err = db.SelectUser("conformance-missing")
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	admins, err := db.ListAdmins()
+	assert.NoError(t, err)
+	assert.True(t, containsUser(admins, user.UserID))
+
+	users, err := db.ListUsers()
+	assert.NoError(t, err)
+	assert.True(t, containsUser(users, user.UserID))
+
+	user.UserName = "conformancerenamed"
+	updated, err = db.UpdateUser(user)
+	assert.NoError(t, err)
+	assert.Equal(t, "conformancerenamed", updated.UserName)
+	assert.Equal(t, "admin", updated.Role)
+
+	assert.NoError(t, db.DeleteUser(user.ID))
+	_, err = db.SelectUser(user.UserID)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	// deactivated user leaves all channels with timetables
+	user, err = db.CreateUser(model.User{UserName: "conformanceleaver", UserID: "conformance-leaver"})
+	assert.NoError(t, err)
+	defer db.DeleteUser(user.ID)
+	member, err := db.CreateChannelMember(model.ChannelMember{UserID: user.UserID, ChannelID: "conformance-users"})
+	assert.NoError(t, err)
+	defer db.DeleteChannelMember(member.UserID, member.ChannelID)
+	tt, err := db.CreateTimeTable(model.TimeTable{ChannelMemberID: member.ID})
+	assert.NoError(t, err)
+	defer db.DeleteTimeTable(tt.ID)
+
+	assert.NoError(t, db.DeactivateUser(user.UserID))
+	_, err = db.SelectUser(user.UserID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	members, err := db.FindMembersByUserID(user.UserID)
+	assert.NoError(t, err)
+	assert.Empty(t, members)
+	assert.False(t, db.MemberHasTimeTable(member.ID))
+	assert.NoError(t, db.DeactivateUser(user.UserID))
+}
+
+func testTimeTables(t *testing.T, db Storage) {
+	member, err := db.CreateChannelMember(model.ChannelMember{
+		UserID:    "conformance-tt",
+		ChannelID: "conformance-timetables",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.DeleteChannelMember(member.UserID, member.ChannelID)
+
+	monday := time.Date(2018, 6, 25, 10, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+
+	assert.False(t, db.MemberHasTimeTable(member.ID))
+	assert.True(t, db.MemberShouldBeTracked(member.ID, monday), "members without timetables are tracked every day")
+
+	_, err = db.SelectTimeTable(member.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	tt, err := db.CreateTimeTable(model.TimeTable{ChannelMemberID: member.ID})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotZero(t, tt.ID)
+	defer db.DeleteTimeTable(tt.ID)
+
+	assert.True(t, db.MemberHasTimeTable(member.ID))
+	assert.False(t, db.MemberShouldBeTracked(member.ID, monday), "empty timetable means member is not tracked")
+
+	tt.Monday = int64(12345)
+	tt, err = db.UpdateTimeTable(tt)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12345), tt.Monday)
+
+	selected, err := db.SelectTimeTable(member.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, tt.ID, selected.ID)
+	assert.Equal(t, int64(12345), selected.Monday)
+	assert.Equal(t, int64(0), selected.Tuesday)
+
+	assert.True(t, db.MemberShouldBeTracked(member.ID, monday))
+	assert.False(t, db.MemberShouldBeTracked(member.ID, tuesday))
+
+	tts, err := db.ListTimeTablesForDay("monday")
+	assert.NoError(t, err)
+	found := false
+	for _, tt := range tts {
+		if tt.ChannelMemberID == member.ID {
+			found = true
+			assert.Equal(t, int64(12345), tt.Monday)
+		}
+	}
+	assert.True(t, found)
+
+	tts, err = db.ListTimeTablesForDay("tuesday")
+	assert.NoError(t, err)
+	for _, tt := range tts {
+		assert.NotEqual(t, member.ID, tt.ChannelMemberID)
+	}
+
+	// members of archived channels are not reminded
+	channel, err := db.CreateChannel(model.Channel{ChannelName: "conformancett", ChannelID: member.ChannelID})
+	assert.NoError(t, err)
+	defer db.DeleteChannel(channel.ID)
+	channel.Status = model.ChannelArchived
+	_, err = db.UpdateChannel(channel)
+	assert.NoError(t, err)
+	tts, err = db.ListTimeTablesForDay("monday")
+	assert.NoError(t, err)
+	for _, tt := range tts {
+		assert.NotEqual(t, member.ID, tt.ChannelMemberID)
+	}
+
+	assert.NoError(t, db.DeleteTimeTable(tt.ID))
+	assert.False(t, db.MemberHasTimeTable(member.ID))
+}
+
+func containsChannel(channels []model.Channel, channelID string) bool {
+	for _, c := range channels {
+		if c.ChannelID == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+func containsUser(users []model.User, userID string) bool {
+	for _, u := range users {
+		if strings.EqualFold(u.UserID, userID) {
+			return true
+		}
+	}
+	return false
+}
+
+func testWorkspaces(t *testing.T, db Storage) {
+	team1, team2 := db.ForTeam("conformance-team1"), db.ForTeam("conformance-team2")
+	channelID, userID := "conformance-workspaces", "conformance-workspaces-user"
+	from := time.Now().UTC().Add(-time.Minute)
+
+	c1, err := team1.CreateChannel(model.Channel{ChannelName: "general", ChannelID: channelID})
+	assert.NoError(t, err)
+	assert.Equal(t, "conformance-team1", c1.TeamID)
+	defer team1.DeleteChannel(c1.ID)
+	c2, err := team2.CreateChannel(model.Channel{ChannelName: "general", ChannelID: channelID})
+	assert.NoError(t, err)
+	defer team2.DeleteChannel(c2.ID)
+
+	assert.NoError(t, team1.UpdateChannelStandupTime(int64(100), channelID))
+	st, err := team1.GetChannelStandupTime(channelID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), st)
+	st, err = team2.GetChannelStandupTime(channelID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), st)
+
+	u1, err := team1.CreateUser(model.User{UserName: "user", UserID: userID, Role: "admin"})
+	assert.NoError(t, err)
+	defer team1.DeleteUser(u1.ID)
+	_, err = team2.SelectUser(userID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	admins, err := team2.ListAdmins()
+	assert.NoError(t, err)
+	assert.Empty(t, admins)
+
+	m1, err := team1.CreateChannelMember(model.ChannelMember{UserID: userID, ChannelID: channelID})
+	assert.NoError(t, err)
+	defer team1.DeleteChannelMember(userID, channelID)
+	_, err = team2.FindChannelMemberByUserID(userID, channelID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	members, err := team2.ListChannelMembers(channelID)
+	assert.NoError(t, err)
+	assert.Empty(t, members)
+	// deleting member of another workspace does nothing
+	assert.NoError(t, team2.DeleteChannelMember(userID, channelID))
+	_, err = team1.FindChannelMemberByUserID(userID, channelID)
+	assert.NoError(t, err)
+
+	tt, err := team1.CreateTimeTable(model.TimeTable{ChannelMemberID: m1.ID})
+	assert.NoError(t, err)
+	defer team1.DeleteTimeTable(tt.ID)
+	tt.Monday = int64(100)
+	_, err = team1.UpdateTimeTable(tt)
+	assert.NoError(t, err)
+	tts, err := team2.ListTimeTablesForDay("monday")
+	assert.NoError(t, err)
+	assert.Empty(t, tts)
+
+	s, err := team1.CreateStandup(model.Standup{ChannelID: channelID, UserID: userID, Comment: "work", MessageTS: "conformance-workspaces-ts"})
+	assert.NoError(t, err)
+	defer team1.DeleteStandup(s.ID)
+	_, err = team2.SelectStandupByMessageTS("conformance-workspaces-ts")
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.False(t, team2.SubmittedStandupToday(userID, channelID))
+	assert.True(t, team1.SubmittedStandupToday(userID, channelID))
+	standups, err := team2.SelectStandupsByChannelIDForPeriod(channelID, from, time.Now().UTC().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Empty(t, standups)
+}
+
+func testStandupQuestions(t *testing.T, db Storage) {
+	channelID := "conformance-questions"
+
+	_, err := db.CreateStandupQuestion(model.StandupQuestion{ChannelID: channelID})
+	assert.Error(t, err)
+
+	questions, err := db.ListStandupQuestions(channelID)
+	assert.NoError(t, err)
+	assert.Empty(t, questions)
+
+	done, err := db.CreateStandupQuestion(model.StandupQuestion{ChannelID: channelID, Question: "Done", Keywords: "done, completed", Required: true})
+	assert.NoError(t, err)
+	assert.NotEqual(t, int64(0), done.ID)
+	blockers, err := db.CreateStandupQuestion(model.StandupQuestion{ChannelID: channelID, Question: "Blockers", Keywords: "blocker"})
+	assert.NoError(t, err)
+	other, err := db.ForTeam("conformance-questions-team").CreateStandupQuestion(model.StandupQuestion{ChannelID: channelID, Question: "Other"})
+	assert.NoError(t, err)
+	defer db.DeleteStandupQuestion(other.ID)
+
+	questions, err = db.ListStandupQuestions(channelID)
+	assert.NoError(t, err)
+	if !assert.Equal(t, 2, len(questions)) {
+		return
+	}
+	assert.Equal(t, done.ID, questions[0].ID)
+	assert.Equal(t, "Done", questions[0].Question)
+	assert.Equal(t, "done, completed", questions[0].Keywords)
+	assert.True(t, questions[0].Required)
+	assert.Equal(t, blockers.ID, questions[1].ID)
+	assert.False(t, questions[1].Required)
+
+	assert.NoError(t, db.DeleteStandupQuestion(done.ID))
+	assert.NoError(t, db.DeleteStandupQuestion(blockers.ID))
+	questions, err = db.ListStandupQuestions(channelID)
+	assert.NoError(t, err)
+	assert.Empty(t, questions)
+}
+
+func testNotificationSchedule(t *testing.T, db Storage) {
+	team := db.ForTeam("conformance-schedule")
+	fireAt := time.Date(2018, 10, 9, 16, 0, 0, 0, time.UTC)
+
+	deadline, err := team.CreateScheduledNotification(model.ScheduledNotification{Kind: model.NotifyChannelDeadline, ChannelID: "conformance-schedule", FireAt: fireAt})
+	assert.NoError(t, err)
+	assert.NotEqual(t, int64(0), deadline.ID)
+	defer team.DeleteScheduledNotification(deadline.ID)
+	warning, err := team.CreateScheduledNotification(model.ScheduledNotification{Kind: model.NotifyIndividualWarning, ChannelMemberID: 7, FireAt: fireAt.Add(-2 * time.Minute)})
+	assert.NoError(t, err)
+	defer team.DeleteScheduledNotification(warning.ID)
+	other, err := db.ForTeam("conformance-schedule-other").CreateScheduledNotification(model.ScheduledNotification{Kind: model.NotifyChannelDeadline, FireAt: fireAt})
+	assert.NoError(t, err)
+	defer db.ForTeam("conformance-schedule-other").DeleteScheduledNotification(other.ID)
+
+	schedule, err := team.ListScheduledNotifications()
+	assert.NoError(t, err)
+	if !assert.Equal(t, 2, len(schedule)) {
+		return
+	}
+	assert.Equal(t, warning.ID, schedule[0].ID)
+	assert.Equal(t, int64(7), schedule[0].ChannelMemberID)
+	assert.True(t, fireAt.Add(-2*time.Minute).Equal(schedule[0].FireAt))
+	assert.Equal(t, model.NotifyChannelDeadline, schedule[1].Kind)
+	assert.Equal(t, "conformance-schedule", schedule[1].ChannelID)
+
+	warning.FireAt = fireAt.Add(time.Hour)
+	_, err = team.UpdateScheduledNotification(warning)
+	assert.NoError(t, err)
+	schedule, err = team.ListScheduledNotifications()
+	assert.NoError(t, err)
+	assert.Equal(t, deadline.ID, schedule[0].ID)
+	assert.True(t, fireAt.Add(time.Hour).Equal(schedule[1].FireAt))
+
+	// deleting notification of another workspace does nothing
+	assert.NoError(t, team.DeleteScheduledNotification(other.ID))
+	assert.NoError(t, team.DeleteScheduledNotification(deadline.ID))
+	assert.NoError(t, team.DeleteScheduledNotification(warning.ID))
+	schedule, err = team.ListScheduledNotifications()
+	assert.NoError(t, err)
+	assert.Empty(t, schedule)
+	schedule, err = db.ForTeam("conformance-schedule-other").ListScheduledNotifications()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(schedule))
+}
+
+func testOutbox(t *testing.T, db Storage) {
+	team := db.ForTeam("conformance-outbox")
+	now := time.Now().Truncate(time.Second)
+	defer team.DeleteSentMessages(now.Add(time.Hour))
+
+	msg := model.OutgoingMessage{Kind: model.MessageToChannel, ChannelID: "C1", Text: "hello", DedupKey: "hello", NextAttempt: now}
+	first, err := team.CreateOutgoingMessage(msg)
+	assert.NoError(t, err)
+	// identical messages can not wait for delivery together, other workspaces have their own
+	_, err = team.CreateOutgoingMessage(msg)
+	assert.Error(t, err)
+	other, err := db.ForTeam("conformance-outbox-other").CreateOutgoingMessage(msg)
+	assert.NoError(t, err)
+	pending, err := team.FindPendingMessage("hello")
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, pending.ID)
+
+	// message is claimed once
+	claimed, err := team.ClaimOutgoingMessage(first.ID, now, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = team.ClaimOutgoingMessage(first.ID, now, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+	claimed, err = team.ClaimOutgoingMessage(other.ID, now, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+	due, err := team.ListDueMessages(now)
+	assert.NoError(t, err)
+	assert.Empty(t, due)
+	due, err = team.ListDueMessages(now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(due))
+
+	// delivered message does not keep identical ones from outbox
+	first.SentAt = &now
+	_, err = team.UpdateOutgoingMessage(first)
+	assert.NoError(t, err)
+	_, err = team.FindPendingMessage("hello")
+	assert.Error(t, err)
+	again, err := team.CreateOutgoingMessage(msg)
+	assert.NoError(t, err)
+
+	for _, m := range []model.OutgoingMessage{again, other} {
+		m.SentAt = &now
+		_, err = db.ForTeam(m.TeamID).UpdateOutgoingMessage(m)
+		assert.NoError(t, err)
+	}
+	db.ForTeam("conformance-outbox-other").DeleteSentMessages(now.Add(time.Hour))
+}
@@ -0,0 +1,1182 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maddevsio/comedian/model"
+	"github.com/sirupsen/logrus"
+)
+
+// Memory keeps all entries in memory. It follows the same semantics as SQL storages
+// (including sql.ErrNoRows for missing entries) and is used in tests and demos
+type Memory struct {
+	*memoryTables
+	teamID string
+}
+
+// memoryTables are shared by storages of all workspaces
+type memoryTables struct {
+	mu         sync.Mutex
+	lastID     map[string]int64
+	standups   []model.Standup
+	history    []model.StandupEditHistory
+	channels   []model.Channel
+	users      []model.User
+	members    []model.ChannelMember
+	timetables []model.TimeTable
+	questions  []model.StandupQuestion
+	outbox     []model.OutgoingMessage
+	exclusions []exclusion
+	schedule   []model.ScheduledNotification
+	holidays   []model.Holiday
+	absences   []model.Absence
+}
+
+// exclusion is a user excluded from auto-enroll in channel
+type exclusion struct {
+	teamID, channelID, userID string
+}
+
+// NewMemory creates a new empty in-memory storage
+func NewMemory() *Memory {
+	return &Memory{memoryTables: &memoryTables{lastID: map[string]int64{}}}
+}
+
+// ForTeam returns storage which keeps entries of the selected workspace only
+func (m *Memory) ForTeam(teamID string) Storage {
+	return &Memory{memoryTables: m.memoryTables, teamID: teamID}
+}
+
+func (m *memoryTables) nextID(table string) int64 {
+	m.lastID[table]++
+	return m.lastID[table]
+}
+
+func between(t, from, to time.Time) bool {
+	return !t.Before(from) && !t.After(to)
+}
+
+// CreateStandup creates standup entry in database
+func (m *Memory) CreateStandup(s model.Standup) (model.Standup, error) {
+	err := s.Validate()
+	if err != nil {
+		return s, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.ID = m.nextID("standups")
+	s.TeamID = m.teamID
+	stored := s
+	stored.Created = standupCreated(s)
+	stored.Modified = time.Now().UTC()
+	m.standups = append(m.standups, stored)
+	return s, nil
+}
+
+// UpdateStandup updates standup entry in database
+func (m *Memory) UpdateStandup(s model.Standup) (model.Standup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, standup := range m.standups {
+		if standup.ID == s.ID {
+			m.standups[i].Modified = time.Now().UTC()
+			m.standups[i].Comment = s.Comment
+			m.standups[i].MessageTS = s.MessageTS
+			m.standups[i].Sections = s.Sections
+			return m.standups[i], nil
+		}
+	}
+	return model.Standup{}, sql.ErrNoRows
+}
+
+// SelectStandupByMessageTS selects standup entry from database filtered by MessageTS parameter
+func (m *Memory) SelectStandupByMessageTS(messageTS string) (model.Standup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, standup := range m.standups {
+		if standup.TeamID == m.teamID && standup.MessageTS == messageTS && standup.DeletedAt == nil {
+			return standup, nil
+		}
+	}
+	return model.Standup{}, sql.ErrNoRows
+}
+
+// SelectStandupsByChannelIDForPeriod selects standup entrys by channel ID and time period from database
+func (m *Memory) SelectStandupsByChannelIDForPeriod(channelID string, dateStart, dateEnd time.Time) ([]model.Standup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := []model.Standup{}
+	for _, standup := range m.standups {
+		if standup.TeamID == m.teamID && standup.ChannelID == channelID && between(standup.Created, dateStart, dateEnd) && standup.DeletedAt == nil {
+			items = append(items, standup)
+		}
+	}
+	return items, nil
+}
+
+// SelectStandupsFiltered selects standup entrys by channel ID and time period from database
+func (m *Memory) SelectStandupsFiltered(userID, channelID string, dateStart, dateEnd time.Time) (model.Standup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, standup := range m.standups {
+		if standup.TeamID == m.teamID && standup.ChannelID == channelID && standup.UserID == userID && between(standup.Created, dateStart, dateEnd) && standup.DeletedAt == nil {
+			return standup, nil
+		}
+	}
+	return model.Standup{}, sql.ErrNoRows
+}
+
+// DeleteStandup deletes standup entry from database
+func (m *Memory) DeleteStandup(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	standups := []model.Standup{}
+	for _, standup := range m.standups {
+		if standup.ID != id {
+			standups = append(standups, standup)
+		}
+	}
+	m.standups = standups
+	return nil
+}
+
+// MarkStandupDeleted keeps standup entry in database, but marks it as deleted
+func (m *Memory) MarkStandupDeleted(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deletedAt := time.Now().UTC()
+	for i, standup := range m.standups {
+		if standup.ID == id {
+			m.standups[i].DeletedAt = &deletedAt
+		}
+	}
+	return nil
+}
+
+// RestoreStandup removes deleted mark from standup entry
+func (m *Memory) RestoreStandup(id int64) (model.Standup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, standup := range m.standups {
+		if standup.ID == id {
+			m.standups[i].DeletedAt = nil
+			return m.standups[i], nil
+		}
+	}
+	return model.Standup{}, sql.ErrNoRows
+}
+
+// SelectDeletedStandup selects the latest deleted standup of user in channel for time period
+func (m *Memory) SelectDeletedStandup(userID, channelID string, dateStart, dateEnd time.Time) (model.Standup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deleted *model.Standup
+	for i, standup := range m.standups {
+		if standup.DeletedAt == nil || standup.TeamID != m.teamID || standup.ChannelID != channelID || standup.UserID != userID || !between(standup.Created, dateStart, dateEnd) {
+			continue
+		}
+		if deleted == nil || !standup.DeletedAt.Before(*deleted.DeletedAt) {
+			deleted = &m.standups[i]
+		}
+	}
+	if deleted == nil {
+		return model.Standup{}, sql.ErrNoRows
+	}
+	return *deleted, nil
+}
+
+// CreateChannelMember creates comedian entry in database
+func (m *Memory) CreateChannelMember(s model.ChannelMember) (model.ChannelMember, error) {
+	err := s.Validate()
+	if err != nil {
+		return s, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.ID = m.nextID("channel_members")
+	s.TeamID = m.teamID
+	stored := s
+	stored.StandupTime = 0
+	stored.Created = time.Now()
+	m.members = append(m.members, stored)
+	return s, nil
+}
+
+//FindChannelMemberByUserID finds user in channel
+func (m *Memory) FindChannelMemberByUserID(userID, channelID string) (model.ChannelMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, member := range m.members {
+		if member.TeamID == m.teamID && member.UserID == userID && member.ChannelID == channelID {
+			return member, nil
+		}
+	}
+	return model.ChannelMember{}, sql.ErrNoRows
+}
+
+//FindMembersByUserID finds user in channel
+func (m *Memory) FindMembersByUserID(userID string) ([]model.ChannelMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var u []model.ChannelMember
+	for _, member := range m.members {
+		if member.TeamID == m.teamID && member.UserID == userID {
+			u = append(u, member)
+		}
+	}
+	return u, nil
+}
+
+//SelectChannelMember finds user in channel
+func (m *Memory) SelectChannelMember(id int64) (model.ChannelMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, member := range m.members {
+		if member.ID == id {
+			return member, nil
+		}
+	}
+	return model.ChannelMember{}, sql.ErrNoRows
+}
+
+//FindChannelMemberByUserName finds user in channel
+func (m *Memory) FindChannelMemberByUserName(userName, channelID string) (model.ChannelMember, error) {
+	user, err := m.SelectUserByUserName(userName)
+	if err != nil {
+		return model.ChannelMember{}, sql.ErrNoRows
+	}
+	return m.FindChannelMemberByUserID(user.UserID, channelID)
+}
+
+// ListAllChannelMembers returns array of standup entries from database
+func (m *Memory) ListAllChannelMembers() ([]model.ChannelMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := []model.ChannelMember{}
+	for _, member := range m.members {
+		if member.TeamID == m.teamID {
+			items = append(items, member)
+		}
+	}
+	return items, nil
+}
+
+//GetNonReporters returns a list of non reporters in selected time period. Members away
+//during the whole period are not non reporters
+func (m *Memory) GetNonReporters(channelID string, dateFrom, dateTo time.Time) ([]model.ChannelMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	reporters := map[string]bool{}
+	for _, standup := range m.standups {
+		if standup.TeamID == m.teamID && standup.ChannelID == channelID && between(standup.Created, dateFrom, dateTo) && standup.DeletedAt == nil {
+			reporters[standup.UserID] = true
+		}
+	}
+	nonReporters := []model.ChannelMember{}
+	for _, member := range m.members {
+		if member.TeamID == m.teamID && member.ChannelID == channelID && member.RoleInChannel != "pm" && !reporters[member.UserID] && !m.awayBetween(member.UserID, dateFrom, dateTo) {
+			nonReporters = append(nonReporters, member)
+		}
+	}
+	return nonReporters, nil
+}
+
+// awayBetween shows if user is away on every day of time period. Caller holds the lock
+func (m *Memory) awayBetween(userID string, dateFrom, dateTo time.Time) bool {
+	return model.AwayAllDays(m.userAbsences(userID), dateFrom, dateTo)
+}
+
+//SubmittedStandupToday shows if a user submitted standup today in their time zone
+func (m *Memory) SubmittedStandupToday(userID, channelID string) bool {
+	timeFrom := model.StartOfDay(time.Now().In(MemberLocation(m, userID, channelID)))
+	_, err := m.SelectStandupsFiltered(userID, channelID, timeFrom, time.Now())
+	if err != nil {
+		logrus.Infof("User '%v' did not write standup in channel '%v' today yet \n", userID, channelID)
+		return false
+	}
+	return true
+}
+
+// IsNonReporter returns true if user did not submit standup in time period, false othervise
+func (m *Memory) IsNonReporter(userID, channelID string, dateFrom, dateTo time.Time) (bool, error) {
+	standup, err := m.SelectStandupsFiltered(userID, channelID, dateFrom, dateTo)
+	if err != nil {
+		return false, err
+	}
+	if standup.Comment == "" {
+		return true, nil
+	}
+	return false, nil
+}
+
+// ListChannelMembers returns array of standup entries from database
+func (m *Memory) ListChannelMembers(channelID string) ([]model.ChannelMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := []model.ChannelMember{}
+	for _, member := range m.members {
+		if member.TeamID == m.teamID && member.ChannelID == channelID {
+			items = append(items, member)
+		}
+	}
+	return items, nil
+}
+
+// ListChannelMembersByRole returns array of channel members with selected role
+func (m *Memory) ListChannelMembersByRole(channelID, role string) ([]model.ChannelMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := []model.ChannelMember{}
+	for _, member := range m.members {
+		if member.TeamID == m.teamID && member.ChannelID == channelID && member.RoleInChannel == role {
+			items = append(items, member)
+		}
+	}
+	return items, nil
+}
+
+// DeleteChannelMember deletes channel_members entry from database
+func (m *Memory) DeleteChannelMember(userID, channelID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	members := []model.ChannelMember{}
+	for _, member := range m.members {
+		if member.TeamID != m.teamID || member.UserID != userID || member.ChannelID != channelID {
+			members = append(members, member)
+		}
+	}
+	m.members = members
+	return nil
+}
+
+// CreateStandupTime creates time entry in database
+func (m *Memory) CreateStandupTime(st int64, channelID string) error {
+	return m.UpdateChannelStandupTime(st, channelID)
+}
+
+// UpdateChannelStandupTime updates time entry in database
+func (m *Memory) UpdateChannelStandupTime(st int64, channelID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, channel := range m.channels {
+		if channel.TeamID == m.teamID && channel.ChannelID == channelID {
+			m.channels[i].StandupTime = st
+		}
+	}
+	return nil
+}
+
+// GetChannelStandupTime returns standup time entry from database
+func (m *Memory) GetChannelStandupTime(channelID string) (int64, error) {
+	channel, err := m.SelectChannel(channelID)
+	if err != nil {
+		return 0, err
+	}
+	return channel.StandupTime, nil
+}
+
+// ListAllStandupTime returns standup time entry for all channels from database
+func (m *Memory) ListAllStandupTime() ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deadlines := []int64{}
+	for _, channel := range m.channels {
+		if channel.TeamID == m.teamID && channel.StandupTime > 0 {
+			deadlines = append(deadlines, channel.StandupTime)
+		}
+	}
+	return deadlines, nil
+}
+
+// DeleteStandupTime deletes channels entry for channel from database
+func (m *Memory) DeleteStandupTime(channelID string) error {
+	return m.UpdateChannelStandupTime(0, channelID)
+}
+
+// AddToStandupHistory creates backup standup entry in standup_edit_history database
+func (m *Memory) AddToStandupHistory(s model.StandupEditHistory) (model.StandupEditHistory, error) {
+	err := s.Validate()
+	if err != nil {
+		return s, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.ID = m.nextID("standup_edit_history")
+	stored := s
+	stored.Created = time.Now().UTC()
+	m.history = append(m.history, stored)
+	return s, nil
+}
+
+// ListStandupHistory returns previous versions of standup from the oldest to the newest one
+func (m *Memory) ListStandupHistory(standupID int64) ([]model.StandupEditHistory, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := []model.StandupEditHistory{}
+	for _, h := range m.history {
+		if h.StandupID == standupID {
+			items = append(items, h)
+		}
+	}
+	return items, nil
+}
+
+// CreateStandupQuestion creates standup question of channel
+func (m *Memory) CreateStandupQuestion(q model.StandupQuestion) (model.StandupQuestion, error) {
+	err := q.Validate()
+	if err != nil {
+		return q, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q.ID = m.nextID("standup_questions")
+	q.TeamID = m.teamID
+	stored := q
+	stored.Created = time.Now().UTC()
+	m.questions = append(m.questions, stored)
+	return q, nil
+}
+
+// ListStandupQuestions returns standup questions of channel in the order they were added
+func (m *Memory) ListStandupQuestions(channelID string) ([]model.StandupQuestion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := []model.StandupQuestion{}
+	for _, q := range m.questions {
+		if q.TeamID == m.teamID && q.ChannelID == channelID {
+			items = append(items, q)
+		}
+	}
+	return items, nil
+}
+
+// DeleteStandupQuestion deletes standup question
+func (m *Memory) DeleteStandupQuestion(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	questions := []model.StandupQuestion{}
+	for _, q := range m.questions {
+		if q.ID != id {
+			questions = append(questions, q)
+		}
+	}
+	m.questions = questions
+	return nil
+}
+
+// CreateOutgoingMessage puts message to outbox
+func (m *Memory) CreateOutgoingMessage(msg model.OutgoingMessage) (model.OutgoingMessage, error) {
+	err := msg.Validate()
+	if err != nil {
+		return msg, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, stored := range m.outbox {
+		if stored.TeamID == m.teamID && stored.DedupKey == msg.DedupKey && stored.SentAt == nil && stored.FailedAt == nil {
+			return msg, fmt.Errorf("outbox: message %v is waiting for delivery already", msg.DedupKey)
+		}
+	}
+	msg.ID = m.nextID("outbox")
+	msg.TeamID = m.teamID
+	msg.Created = time.Now().UTC()
+	m.outbox = append(m.outbox, msg)
+	return msg, nil
+}
+
+// UpdateOutgoingMessage updates delivery state of outbox message
+func (m *Memory) UpdateOutgoingMessage(msg model.OutgoingMessage) (model.OutgoingMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, stored := range m.outbox {
+		if stored.ID == msg.ID {
+			stored.Attempts = msg.Attempts
+			stored.NextAttempt = msg.NextAttempt
+			stored.LastError = msg.LastError
+			stored.SentAt = msg.SentAt
+			stored.FailedAt = msg.FailedAt
+			stored.Reported = msg.Reported
+			m.outbox[i] = stored
+			return stored, nil
+		}
+	}
+	return msg, sql.ErrNoRows
+}
+
+// FindPendingMessage returns outbox message with the dedup key which is not delivered yet
+func (m *Memory) FindPendingMessage(dedupKey string) (model.OutgoingMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, msg := range m.outbox {
+		if msg.TeamID == m.teamID && msg.DedupKey == dedupKey && msg.SentAt == nil && msg.FailedAt == nil {
+			return msg, nil
+		}
+	}
+	return model.OutgoingMessage{}, sql.ErrNoRows
+}
+
+// ListDueMessages returns undelivered outbox messages which should be sent by the time, the oldest first
+func (m *Memory) ListDueMessages(t time.Time) ([]model.OutgoingMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := []model.OutgoingMessage{}
+	for _, msg := range m.outbox {
+		if msg.TeamID == m.teamID && msg.SentAt == nil && msg.FailedAt == nil && !msg.NextAttempt.After(t) {
+			items = append(items, msg)
+		}
+	}
+	return items, nil
+}
+
+// ClaimOutgoingMessage takes undelivered outbox message which is due by the time for delivery
+// until the other time, so that nobody else delivers it meanwhile. Returns false if message is taken
+func (m *Memory) ClaimOutgoingMessage(id int64, now, until time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, msg := range m.outbox {
+		if msg.TeamID == m.teamID && msg.ID == id && msg.SentAt == nil && msg.FailedAt == nil && !msg.NextAttempt.After(now) {
+			m.outbox[i].NextAttempt = until
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ListUnreportedFailures returns outbox messages which failed to be delivered and were not reported yet
+func (m *Memory) ListUnreportedFailures() ([]model.OutgoingMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := []model.OutgoingMessage{}
+	for _, msg := range m.outbox {
+		if msg.TeamID == m.teamID && msg.FailedAt != nil && !msg.Reported {
+			items = append(items, msg)
+		}
+	}
+	return items, nil
+}
+
+// DeleteSentMessages deletes outbox messages delivered before the time
+func (m *Memory) DeleteSentMessages(t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	outbox := []model.OutgoingMessage{}
+	for _, msg := range m.outbox {
+		if msg.TeamID == m.teamID && msg.SentAt != nil && msg.SentAt.Before(t) {
+			continue
+		}
+		outbox = append(outbox, msg)
+	}
+	m.outbox = outbox
+	return nil
+}
+
+// CreateScheduledNotification saves the next fire time of notification
+func (m *Memory) CreateScheduledNotification(n model.ScheduledNotification) (model.ScheduledNotification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n.ID = m.nextID("notification_schedule")
+	n.TeamID = m.teamID
+	m.schedule = append(m.schedule, n)
+	return n, nil
+}
+
+// UpdateScheduledNotification updates fire time of scheduled notification
+func (m *Memory) UpdateScheduledNotification(n model.ScheduledNotification) (model.ScheduledNotification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, stored := range m.schedule {
+		if stored.TeamID == m.teamID && stored.ID == n.ID {
+			m.schedule[i].FireAt = n.FireAt
+			return m.schedule[i], nil
+		}
+	}
+	return n, sql.ErrNoRows
+}
+
+// ListScheduledNotifications returns scheduled notifications of workspace, the earliest first
+func (m *Memory) ListScheduledNotifications() ([]model.ScheduledNotification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := []model.ScheduledNotification{}
+	for _, n := range m.schedule {
+		if n.TeamID == m.teamID {
+			items = append(items, n)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].FireAt.Before(items[j].FireAt) })
+	return items, nil
+}
+
+// DeleteScheduledNotification deletes scheduled notification
+func (m *Memory) DeleteScheduledNotification(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, n := range m.schedule {
+		if n.TeamID == m.teamID && n.ID == id {
+			m.schedule = append(m.schedule[:i], m.schedule[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// CreateHoliday saves holiday of channel, or of the workspace if channel is empty.
+// Holiday the channel already has on the day is replaced
+func (m *Memory) CreateHoliday(h model.Holiday) (model.Holiday, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h.TeamID = m.teamID
+	for i, holiday := range m.holidays {
+		if holiday.TeamID == m.teamID && holiday.ChannelID == h.ChannelID && holiday.Day == h.Day {
+			h.ID = holiday.ID
+			m.holidays[i] = h
+			return h, nil
+		}
+	}
+	h.ID = m.nextID("holidays")
+	h.TeamID = m.teamID
+	m.holidays = append(m.holidays, h)
+	return h, nil
+}
+
+// ImportHolidays saves holidays like CreateHoliday does
+func (m *Memory) ImportHolidays(holidays []model.Holiday) error {
+	for _, h := range holidays {
+		_, err := m.CreateHoliday(h)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteHoliday deletes holiday of channel, or of the workspace if channel is empty, on the day
+func (m *Memory) DeleteHoliday(channelID, day string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	holidays := []model.Holiday{}
+	for _, h := range m.holidays {
+		if h.TeamID == m.teamID && h.ChannelID == channelID && h.Day == day {
+			continue
+		}
+		holidays = append(holidays, h)
+	}
+	m.holidays = holidays
+	return nil
+}
+
+// ListHolidays returns holidays of the workspace and of the channel ordered by day
+func (m *Memory) ListHolidays(channelID string) ([]model.Holiday, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := []model.Holiday{}
+	for _, h := range m.holidays {
+		if h.TeamID == m.teamID && (h.ChannelID == "" || h.ChannelID == channelID) {
+			items = append(items, h)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Day < items[j].Day })
+	return items, nil
+}
+
+// CreateAbsence saves period user is away
+func (m *Memory) CreateAbsence(a model.Absence) (model.Absence, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a.ID = m.nextID("absences")
+	a.TeamID = m.teamID
+	m.absences = append(m.absences, a)
+	return a, nil
+}
+
+// UpdateAbsence updates days and reason of absence
+func (m *Memory) UpdateAbsence(a model.Absence) (model.Absence, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, absence := range m.absences {
+		if absence.TeamID == m.teamID && absence.ID == a.ID {
+			a.TeamID = absence.TeamID
+			a.UserID = absence.UserID
+			m.absences[i] = a
+			return a, nil
+		}
+	}
+	return a, sql.ErrNoRows
+}
+
+// DeleteAbsence deletes absence
+func (m *Memory) DeleteAbsence(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, a := range m.absences {
+		if a.TeamID == m.teamID && a.ID == id {
+			m.absences = append(m.absences[:i], m.absences[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// ListAbsences returns absences of user ordered by first day
+func (m *Memory) ListAbsences(userID string) ([]model.Absence, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.userAbsences(userID), nil
+}
+
+// userAbsences returns absences of user ordered by first day. Caller holds the lock
+func (m *Memory) userAbsences(userID string) []model.Absence {
+	items := []model.Absence{}
+	for _, a := range m.absences {
+		if a.TeamID == m.teamID && a.UserID == userID {
+			items = append(items, a)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].FirstDay < items[j].FirstDay })
+	return items
+}
+
+//GetAllChannels returns list of unique channels
+func (m *Memory) GetAllChannels() ([]model.Channel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	channels := []model.Channel{}
+	for _, channel := range m.channels {
+		if channel.TeamID == m.teamID {
+			channels = append(channels, channel)
+		}
+	}
+	return channels, nil
+}
+
+//GetUserChannels returns list of user's channels
+func (m *Memory) GetUserChannels(userID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	channels := []string{}
+	for _, member := range m.members {
+		if member.TeamID == m.teamID && member.UserID == userID {
+			channels = append(channels, member.ChannelID)
+		}
+	}
+	return channels, nil
+}
+
+//GetChannelName returns channel name
+func (m *Memory) GetChannelName(channelID string) (string, error) {
+	channel, err := m.SelectChannel(channelID)
+	if err != nil {
+		return "", err
+	}
+	return channel.ChannelName, nil
+}
+
+//GetChannelID returns ID of channel with the name, deleted channels are skipped
+func (m *Memory) GetChannelID(channelName string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, channel := range m.channels {
+		if channel.TeamID == m.teamID && channel.ChannelName == channelName && channel.Status != model.ChannelDeleted {
+			return channel.ChannelID, nil
+		}
+	}
+	return "", sql.ErrNoRows
+}
+
+// ListStandups returns array of standup entries from database
+// Helper function for testing
+func (m *Memory) ListStandups() ([]model.Standup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := []model.Standup{}
+	for _, standup := range m.standups {
+		if standup.TeamID == m.teamID {
+			items = append(items, standup)
+		}
+	}
+	return items, nil
+}
+
+// CreateChannel creates standup entry in database
+func (m *Memory) CreateChannel(c model.Channel) (model.Channel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c.ID = m.nextID("channels")
+	c.TeamID = m.teamID
+	if c.Status == "" {
+		c.Status = model.ChannelActive
+	}
+	stored := c
+	stored.StandupTime = 0
+	m.channels = append(m.channels, stored)
+	return c, nil
+}
+
+// SelectChannel selects Channel entry from database
+func (m *Memory) SelectChannel(channelID string) (model.Channel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, channel := range m.channels {
+		if channel.TeamID == m.teamID && channel.ChannelID == channelID {
+			return channel, nil
+		}
+	}
+	return model.Channel{}, sql.ErrNoRows
+}
+
+// GetChannels selects Channel entry from database
+func (m *Memory) GetChannels() ([]model.Channel, error) {
+	return m.GetAllChannels()
+}
+
+// UpdateChannel updates name, status, auto-enroll mode, report channel, time zone and working days of channel
+func (m *Memory) UpdateChannel(c model.Channel) (model.Channel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, channel := range m.channels {
+		if channel.TeamID == m.teamID && channel.ChannelID == c.ChannelID {
+			m.channels[i].ChannelName = c.ChannelName
+			m.channels[i].Status = c.Status
+			m.channels[i].AutoEnroll = c.AutoEnroll
+			m.channels[i].ReportChannelID = c.ReportChannelID
+			m.channels[i].TimeZone = c.TimeZone
+			m.channels[i].WorkingDays = c.WorkingDays
+			return m.channels[i], nil
+		}
+	}
+	return c, sql.ErrNoRows
+}
+
+// ExcludeFromAutoEnroll keeps user from being enrolled in channel automatically
+func (m *Memory) ExcludeFromAutoEnroll(channelID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := exclusion{m.teamID, channelID, userID}
+	for _, excluded := range m.exclusions {
+		if excluded == e {
+			return nil
+		}
+	}
+	m.exclusions = append(m.exclusions, e)
+	return nil
+}
+
+// IncludeInAutoEnroll removes user from auto-enroll exclusions of channel
+func (m *Memory) IncludeInAutoEnroll(channelID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	exclusions := []exclusion{}
+	for _, excluded := range m.exclusions {
+		if excluded != (exclusion{m.teamID, channelID, userID}) {
+			exclusions = append(exclusions, excluded)
+		}
+	}
+	m.exclusions = exclusions
+	return nil
+}
+
+// ListAutoEnrollExclusions returns IDs of users excluded from auto-enroll in channel
+func (m *Memory) ListAutoEnrollExclusions(channelID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	users := []string{}
+	for _, excluded := range m.exclusions {
+		if excluded.teamID == m.teamID && excluded.channelID == channelID {
+			users = append(users, excluded.userID)
+		}
+	}
+	return users, nil
+}
+
+// DeleteChannel deletes Channel entry from database
+func (m *Memory) DeleteChannel(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	channels := []model.Channel{}
+	for _, channel := range m.channels {
+		if channel.ID != id {
+			channels = append(channels, channel)
+		}
+	}
+	m.channels = channels
+	return nil
+}
+
+// CreateUser creates standup entry in database
+func (m *Memory) CreateUser(c model.User) (model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c.ID = m.nextID("users")
+	c.TeamID = m.teamID
+	m.users = append(m.users, c)
+	return c, nil
+}
+
+// UpdateUser updates role, name and time zone of user
+func (m *Memory) UpdateUser(c model.User) (model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, user := range m.users {
+		if user.ID == c.ID {
+			m.users[i].Role = c.Role
+			m.users[i].UserName = c.UserName
+			m.users[i].TimeZone = c.TimeZone
+			return m.users[i], nil
+		}
+	}
+	return model.User{}, sql.ErrNoRows
+}
+
+// SelectUser selects User entry from database
+func (m *Memory) SelectUser(userID string) (model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, user := range m.users {
+		if user.TeamID == m.teamID && user.UserID == userID {
+			return user, nil
+		}
+	}
+	return model.User{}, sql.ErrNoRows
+}
+
+// ListUsers selects User entries from database
+func (m *Memory) ListUsers() ([]model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var u []model.User
+	for _, user := range m.users {
+		if user.TeamID == m.teamID {
+			u = append(u, user)
+		}
+	}
+	return u, nil
+}
+
+// SelectUserByUserName selects User entry from database
+func (m *Memory) SelectUserByUserName(userName string) (model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, user := range m.users {
+		if user.TeamID == m.teamID && user.UserName == userName {
+			return user, nil
+		}
+	}
+	return model.User{}, sql.ErrNoRows
+}
+
+// DeleteUser deletes User entry from database
+func (m *Memory) DeleteUser(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	users := []model.User{}
+	for _, user := range m.users {
+		if user.ID != id {
+			users = append(users, user)
+		}
+	}
+	m.users = users
+	return nil
+}
+
+// DeactivateUser deletes user with their channel memberships and timetables in one transaction
+func (m *Memory) DeactivateUser(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	memberIDs := map[int64]bool{}
+	members := []model.ChannelMember{}
+	for _, member := range m.members {
+		if member.TeamID == m.teamID && member.UserID == userID {
+			memberIDs[member.ID] = true
+			continue
+		}
+		members = append(members, member)
+	}
+	timetables := []model.TimeTable{}
+	for _, tt := range m.timetables {
+		if !memberIDs[tt.ChannelMemberID] {
+			timetables = append(timetables, tt)
+		}
+	}
+	users := []model.User{}
+	for _, user := range m.users {
+		if user.TeamID != m.teamID || user.UserID != userID {
+			users = append(users, user)
+		}
+	}
+	m.members, m.timetables, m.users = members, timetables, users
+	return nil
+}
+
+// ListAdmins selects User entry from database
+func (m *Memory) ListAdmins() ([]model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var c []model.User
+	for _, user := range m.users {
+		if user.TeamID == m.teamID && user.Role == "admin" {
+			c = append(c, user)
+		}
+	}
+	return c, nil
+}
+
+// UserIsPMForProject returns true if user is a project's PM.
+func (m *Memory) UserIsPMForProject(userID, channelID string) bool {
+	member, err := m.FindChannelMemberByUserID(userID, channelID)
+	if err != nil {
+		return false
+	}
+	return member.RoleInChannel == "pm"
+}
+
+// CreateTimeTable creates tt entry in database
+func (m *Memory) CreateTimeTable(t model.TimeTable) (model.TimeTable, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t.ID = m.nextID("timetables")
+	t.TeamID = m.teamID
+	stored := model.TimeTable{
+		ID:              t.ID,
+		TeamID:          t.TeamID,
+		ChannelMemberID: t.ChannelMemberID,
+		Created:         time.Now(),
+		Modified:        time.Now(),
+	}
+	m.timetables = append(m.timetables, stored)
+	return t, nil
+}
+
+// UpdateTimeTable updates TimeTable entry in database
+func (m *Memory) UpdateTimeTable(t model.TimeTable) (model.TimeTable, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, tt := range m.timetables {
+		if tt.ID == t.ID {
+			t.ChannelMemberID = tt.ChannelMemberID
+			t.TeamID = tt.TeamID
+			t.Created = tt.Created
+			t.Modified = time.Now()
+			m.timetables[i] = t
+			return t, nil
+		}
+	}
+	return model.TimeTable{}, sql.ErrNoRows
+}
+
+// SelectTimeTable selects TimeTable entry from database
+func (m *Memory) SelectTimeTable(ChannelMemberID int64) (model.TimeTable, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tt := range m.timetables {
+		if tt.ChannelMemberID == ChannelMemberID {
+			return tt, nil
+		}
+	}
+	return model.TimeTable{}, sql.ErrNoRows
+}
+
+// DeleteTimeTable deletes TimeTable entry from database
+func (m *Memory) DeleteTimeTable(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	timetables := []model.TimeTable{}
+	for _, tt := range m.timetables {
+		if tt.ID != id {
+			timetables = append(timetables, tt)
+		}
+	}
+	m.timetables = timetables
+	return nil
+}
+
+//ListTimeTablesForDay returns list of chan members who has timetables, members of
+//inactive channels are skipped
+func (m *Memory) ListTimeTablesForDay(day string) ([]model.TimeTable, error) {
+	if !weekdays[day] {
+		return nil, fmt.Errorf("unknown day: %v", day)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var tts []model.TimeTable
+	for _, tt := range m.timetables {
+		deadline := tt.ShowDeadlineOn(day)
+		if tt.TeamID != m.teamID || deadline == 0 || !m.memberChannelActive(tt.ChannelMemberID) {
+			continue
+		}
+		// SQL storages select only channel_member_id and the day column
+		selected := model.TimeTable{ChannelMemberID: tt.ChannelMemberID}
+		selected = setDeadlineOn(selected, day, deadline)
+		tts = append(tts, selected)
+	}
+	return tts, nil
+}
+
+// memberChannelActive shows if channel of the member is not deactivated, m.mu must be locked
+func (m *Memory) memberChannelActive(memberID int64) bool {
+	for _, member := range m.members {
+		if member.ID != memberID {
+			continue
+		}
+		for _, channel := range m.channels {
+			if channel.TeamID == member.TeamID && channel.ChannelID == member.ChannelID {
+				return channel.Active()
+			}
+		}
+	}
+	return true
+}
+
+// MemberHasTimeTable returns true if member has timetable
+func (m *Memory) MemberHasTimeTable(id int64) bool {
+	_, err := m.SelectTimeTable(id)
+	return err == nil
+}
+
+//MemberShouldBeTracked returns true if member should be tracked
+func (m *Memory) MemberShouldBeTracked(id int64, date time.Time) bool {
+	member, err := m.SelectChannelMember(id)
+	if err == nil {
+		if _, away := AbsenceOn(m, member.UserID, date); away {
+			return false
+		}
+	}
+	tt, err := m.SelectTimeTable(id)
+	if err != nil {
+		return true
+	}
+	if tt.IsEmpty() {
+		return false
+	}
+	day := strings.ToLower(date.Weekday().String())
+	return tt.ShowDeadlineOn(day) != 0
+}
+
+var weekdays = map[string]bool{
+	"monday": true, "tuesday": true, "wednesday": true, "thursday": true,
+	"friday": true, "saturday": true, "sunday": true,
+}
+
+func setDeadlineOn(tt model.TimeTable, day string, deadline int64) model.TimeTable {
+	switch day {
+	case "monday":
+		tt.Monday = deadline
+	case "tuesday":
+		tt.Tuesday = deadline
+	case "wednesday":
+		tt.Wednesday = deadline
+	case "thursday":
+		tt.Thursday = deadline
+	case "friday":
+		tt.Friday = deadline
+	case "saturday":
+		tt.Saturday = deadline
+	case "sunday":
+		tt.Sunday = deadline
+	}
+	return tt
+}
@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/maddevsio/comedian/migrations"
+	"github.com/sirupsen/logrus"
+)
+
+// versionTable is compatible with goose, so databases migrated with goose before are picked up as is
+const versionTable = "goose_db_version"
+
+// ErrSchemaAhead is returned when database has migrations unknown to the binary
+var ErrSchemaAhead = errors.New("database schema is newer than this version of comedian supports")
+
+// Migrator is implemented by storages which keep their schema in versioned migrations
+type Migrator interface {
+	// SchemaVersion returns version of the latest migration applied to database
+	SchemaVersion() (int64, error)
+
+	// LatestSchemaVersion returns version of the latest migration embedded into binary
+	LatestSchemaVersion() (int64, error)
+
+	// Migrate applies all pending migrations
+	Migrate() error
+}
+
+// PrepareSchema makes sure database schema matches the binary. Pending migrations
+// are applied when autoMigrate is true. It returns current schema version
+func PrepareSchema(db Storage, autoMigrate bool) (int64, error) {
+	m, ok := db.(Migrator)
+	if !ok {
+		return 0, nil
+	}
+	if autoMigrate {
+		err := m.Migrate()
+		if err != nil {
+			return 0, err
+		}
+	}
+	version, err := m.SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+	latest, err := m.LatestSchemaVersion()
+	if err != nil {
+		return version, err
+	}
+	if version > latest {
+		return version, ErrSchemaAhead
+	}
+	if version < latest {
+		logrus.Warnf("Database schema version %v is behind the latest migration %v", version, latest)
+	}
+	return version, nil
+}
+
+type migration struct {
+	version int64
+	name    string
+	up      []string
+}
+
+// loadMigrations reads migrations from dir sorted by version
+func loadMigrations(dir string) ([]migration, error) {
+	files, err := fs.Glob(migrations.Files, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	ms := []migration{}
+	for _, file := range files {
+		name := path.Base(file)
+		version, err := strconv.ParseInt(strings.SplitN(name, "_", 2)[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %v has no version: %v", name, err)
+		}
+		data, err := fs.ReadFile(migrations.Files, file)
+		if err != nil {
+			return nil, err
+		}
+		up, err := parseMigration(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("migration %v: %v", name, err)
+		}
+		ms = append(ms, migration{version: version, name: name, up: up})
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].version < ms[j].version })
+	return ms, nil
+}
+
+// parseMigration returns statements of the Up section of goose migration
+func parseMigration(sql string) ([]string, error) {
+	statements := []string{}
+	up, inStatement := false, false
+	buf := ""
+	scanner := bufio.NewScanner(strings.NewReader(sql))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		switch trimmed {
+		case "-- +goose Up":
+			up = true
+			continue
+		case "-- +goose Down":
+			up = false
+			continue
+		case "-- +goose StatementBegin":
+			inStatement = true
+			continue
+		case "-- +goose StatementEnd":
+			inStatement = false
+			if up && strings.TrimSpace(buf) != "" {
+				statements = append(statements, strings.TrimSpace(buf))
+			}
+			buf = ""
+			continue
+		}
+		if !up || (strings.HasPrefix(trimmed, "--") && !inStatement) {
+			continue
+		}
+		buf += line + "\n"
+		if !inStatement && strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(buf))
+			buf = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(buf) != "" {
+		return nil, errors.New("statement is not terminated with semicolon")
+	}
+	return statements, nil
+}
+
+func latestVersion(dir string) (int64, error) {
+	ms, err := loadMigrations(dir)
+	if err != nil {
+		return 0, err
+	}
+	if len(ms) == 0 {
+		return 0, nil
+	}
+	return ms[len(ms)-1].version, nil
+}
+
+// schemaVersion returns current version the same way goose does:
+// the latest applied migration which was not rolled back afterwards
+func schemaVersion(conn *sqlx.DB) (int64, error) {
+	rows, err := conn.Query("SELECT version_id, is_applied FROM " + versionTable + " ORDER BY id DESC")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	skip := map[int64]bool{}
+	for rows.Next() {
+		var version int64
+		var applied bool
+		if err := rows.Scan(&version, &applied); err != nil {
+			return 0, err
+		}
+		if skip[version] {
+			continue
+		}
+		if applied {
+			return version, nil
+		}
+		skip[version] = true
+	}
+	return 0, rows.Err()
+}
+
+func ensureVersionTable(conn *sqlx.DB) error {
+	_, err := conn.Exec("SELECT 1 FROM " + versionTable)
+	if err == nil {
+		return nil
+	}
+	_, err = conn.Exec("CREATE TABLE " + versionTable + ` (
+		id serial NOT NULL,
+		version_id bigint NOT NULL,
+		is_applied boolean NOT NULL,
+		tstamp timestamp NULL default now(),
+		PRIMARY KEY(id)
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Exec(conn.Rebind("INSERT INTO "+versionTable+" (version_id, is_applied) VALUES (?, ?)"), 0, true)
+	return err
+}
+
+// migrationLock names advisory lock instances of Comedian take to migrate database one by one
+const migrationLock = "comedian_migrations"
+
+// migrationLockKey is the key of migrationLock in PostgreSQL, which advisory locks are numbered
+const migrationLockKey = 727274
+
+// migrationLockTimeout limits how long MySQL waits for migrations of another instance, in seconds
+const migrationLockTimeout = 600
+
+// lockMigrations takes advisory lock on the connection, so that instances started at the
+// same time do not apply the same migration twice. Returned function releases the lock
+func lockMigrations(ctx context.Context, c *sql.Conn, driver string) (func(), error) {
+	if driver == "postgres" {
+		_, err := c.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey)
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			c.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+		}, nil
+	}
+	var locked sql.NullInt64
+	err := c.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLock, migrationLockTimeout).Scan(&locked)
+	if err != nil {
+		return nil, err
+	}
+	if !locked.Valid || locked.Int64 != 1 {
+		return nil, errors.New("timed out waiting for migrations of another instance")
+	}
+	return func() {
+		c.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", migrationLock)
+	}, nil
+}
+
+// migrate applies migrations from dir which are newer than current schema version. Every
+// migration is applied in a transaction, but MySQL commits schema changes at once, so a
+// failed MySQL migration may be left half-applied and has to be fixed by hand
+func migrate(conn *sqlx.DB, dir string) error {
+	ctx := context.Background()
+	c, err := conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	unlock, err := lockMigrations(ctx, c, conn.DriverName())
+	if err != nil {
+		return fmt.Errorf("could not lock migrations: %v", err)
+	}
+	defer unlock()
+
+	err = ensureVersionTable(conn)
+	if err != nil {
+		return err
+	}
+	current, err := schemaVersion(conn)
+	if err != nil {
+		return err
+	}
+	ms, err := loadMigrations(dir)
+	if err != nil {
+		return err
+	}
+	for _, m := range ms {
+		if m.version <= current {
+			continue
+		}
+		tx, err := conn.Beginx()
+		if err != nil {
+			return err
+		}
+		for _, statement := range m.up {
+			if _, err := tx.Exec(statement); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %v failed: %v", m.name, err)
+			}
+		}
+		_, err = tx.Exec(tx.Rebind("INSERT INTO "+versionTable+" (version_id, is_applied) VALUES (?, ?)"), m.version, true)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+		logrus.Infof("Applied migration %v", m.name)
+	}
+	return nil
+}
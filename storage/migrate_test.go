@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMigration(t *testing.T) {
+	sql := `-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE ` + "`foo`" + ` (
+    ` + "`id`" + ` INTEGER NOT NULL
+);
+ALTER TABLE foo ADD bar INTEGER;
+-- +goose StatementBegin
+CREATE FUNCTION f() RETURNS INTEGER AS $$
+BEGIN
+    RETURN 1;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE foo;
+`
+	statements, err := parseMigration(sql)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(statements))
+	assert.Equal(t, "CREATE TABLE `foo` (\n    `id` INTEGER NOT NULL\n);", statements[0])
+	assert.Equal(t, "ALTER TABLE foo ADD bar INTEGER;", statements[1])
+	assert.Contains(t, statements[2], "RETURN 1;\nEND;")
+
+	_, err = parseMigration("-- +goose Up\nCREATE TABLE foo (id INTEGER)\n")
+	assert.Error(t, err)
+}
+
+func TestLoadMigrations(t *testing.T) {
+	for _, dir := range []string{migrations.MySQL, migrations.Postgres} {
+		ms, err := loadMigrations(dir)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ms)
+		for i, m := range ms {
+			assert.NotEmpty(t, m.up, m.name)
+			if i > 0 {
+				assert.True(t, ms[i-1].version < m.version, m.name)
+			}
+		}
+	}
+
+	mysqlLatest, err := latestVersion(migrations.MySQL)
+	assert.NoError(t, err)
+	postgresLatest, err := latestVersion(migrations.Postgres)
+	assert.NoError(t, err)
+	assert.Equal(t, mysqlLatest, postgresLatest, "MySQL and PostgreSQL schemas must have the same version")
+}
+
+func TestPrepareSchema(t *testing.T) {
+	version, err := PrepareSchema(NewMemory(), true)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), version)
+
+	c, err := config.Get()
+	assert.NoError(t, err)
+	db, err := NewMySQL(c)
+	assert.NoError(t, err)
+
+	version, err = PrepareSchema(db, true)
+	assert.NoError(t, err)
+	latest, err := db.LatestSchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, latest, version)
+
+	// running migrations again changes nothing, instances migrate one by one
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { errs <- db.Migrate() }()
+	}
+	assert.NoError(t, <-errs)
+	assert.NoError(t, <-errs)
+	version, err = db.SchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, latest, version)
+}
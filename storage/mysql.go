@@ -9,13 +9,15 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/migrations"
 	"github.com/maddevsio/comedian/model"
 	"github.com/sirupsen/logrus"
 )
 
 // MySQL provides api for work with mysql database
 type MySQL struct {
-	conn *sqlx.DB
+	conn   *sqlx.DB
+	teamID string
 }
 
 // NewMySQL creates a new instance of database API
@@ -31,6 +33,11 @@ func NewMySQL(c config.Config) (*MySQL, error) {
 	return m, nil
 }
 
+// ForTeam returns storage which keeps entries of the selected workspace only
+func (m *MySQL) ForTeam(teamID string) Storage {
+	return &MySQL{conn: m.conn, teamID: teamID}
+}
+
 // CreateStandup creates standup entry in database
 func (m *MySQL) CreateStandup(s model.Standup) (model.Standup, error) {
 	err := s.Validate()
@@ -38,8 +45,8 @@ func (m *MySQL) CreateStandup(s model.Standup) (model.Standup, error) {
 		return s, err
 	}
 	res, err := m.conn.Exec(
-		"INSERT INTO `standups` (created, modified, comment, channel_id, user_id, message_ts) VALUES (?, ?, ?, ?, ?, ?)",
-		time.Now().UTC(), time.Now().UTC(), s.Comment, s.ChannelID, s.UserID, s.MessageTS,
+		"INSERT INTO `standups` (team_id, created, modified, comment, channel_id, user_id, message_ts, sections) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		m.teamID, standupCreated(s), time.Now().UTC(), s.Comment, s.ChannelID, s.UserID, s.MessageTS, s.Sections,
 	)
 	if err != nil {
 		return s, err
@@ -49,6 +56,7 @@ func (m *MySQL) CreateStandup(s model.Standup) (model.Standup, error) {
 		return s, err
 	}
 	s.ID = id
+	s.TeamID = m.teamID
 
 	return s, nil
 }
@@ -56,8 +64,8 @@ func (m *MySQL) CreateStandup(s model.Standup) (model.Standup, error) {
 // UpdateStandup updates standup entry in database
 func (m *MySQL) UpdateStandup(s model.Standup) (model.Standup, error) {
 	_, err := m.conn.Exec(
-		"UPDATE `standups` SET modified=?, comment=?, message_ts=? WHERE id=?",
-		time.Now().UTC(), s.Comment, s.MessageTS, s.ID,
+		"UPDATE `standups` SET modified=?, comment=?, message_ts=?, sections=? WHERE id=?",
+		time.Now().UTC(), s.Comment, s.MessageTS, s.Sections, s.ID,
 	)
 	if err != nil {
 		return s, err
@@ -70,7 +78,7 @@ func (m *MySQL) UpdateStandup(s model.Standup) (model.Standup, error) {
 // SelectStandupByMessageTS selects standup entry from database filtered by MessageTS parameter
 func (m *MySQL) SelectStandupByMessageTS(messageTS string) (model.Standup, error) {
 	var s model.Standup
-	err := m.conn.Get(&s, "SELECT * FROM `standups` WHERE message_ts=?", messageTS)
+	err := m.conn.Get(&s, "SELECT * FROM `standups` WHERE team_id=? AND message_ts=? AND deleted_at IS NULL", m.teamID, messageTS)
 	if err != nil {
 		return s, err
 	}
@@ -80,16 +88,16 @@ func (m *MySQL) SelectStandupByMessageTS(messageTS string) (model.Standup, error
 // SelectStandupsByChannelIDForPeriod selects standup entrys by channel ID and time period from database
 func (m *MySQL) SelectStandupsByChannelIDForPeriod(channelID string, dateStart, dateEnd time.Time) ([]model.Standup, error) {
 	items := []model.Standup{}
-	err := m.conn.Select(&items, "SELECT * FROM `standups` WHERE channel_id=? AND created BETWEEN ? AND ?",
-		channelID, dateStart, dateEnd)
+	err := m.conn.Select(&items, "SELECT * FROM `standups` WHERE team_id=? AND channel_id=? AND created BETWEEN ? AND ? AND deleted_at IS NULL",
+		m.teamID, channelID, dateStart, dateEnd)
 	return items, err
 }
 
 // SelectStandupsFiltered selects standup entrys by channel ID and time period from database
 func (m *MySQL) SelectStandupsFiltered(userID, channelID string, dateStart, dateEnd time.Time) (model.Standup, error) {
 	items := model.Standup{}
-	err := m.conn.Get(&items, "SELECT * FROM `standups` WHERE channel_id=? AND user_id =? AND created BETWEEN ? AND ? limit 1",
-		channelID, userID, dateStart, dateEnd)
+	err := m.conn.Get(&items, "SELECT * FROM `standups` WHERE team_id=? AND channel_id=? AND user_id =? AND created BETWEEN ? AND ? AND deleted_at IS NULL limit 1",
+		m.teamID, channelID, userID, dateStart, dateEnd)
 	return items, err
 }
 
@@ -99,6 +107,31 @@ func (m *MySQL) DeleteStandup(id int64) error {
 	return err
 }
 
+// MarkStandupDeleted keeps standup entry in database, but marks it as deleted
+func (m *MySQL) MarkStandupDeleted(id int64) error {
+	_, err := m.conn.Exec("UPDATE `standups` SET deleted_at=? WHERE id=?", time.Now().UTC(), id)
+	return err
+}
+
+// RestoreStandup removes deleted mark from standup entry
+func (m *MySQL) RestoreStandup(id int64) (model.Standup, error) {
+	_, err := m.conn.Exec("UPDATE `standups` SET deleted_at=NULL WHERE id=?", id)
+	if err != nil {
+		return model.Standup{}, err
+	}
+	var s model.Standup
+	err = m.conn.Get(&s, "SELECT * FROM `standups` WHERE id=?", id)
+	return s, err
+}
+
+// SelectDeletedStandup selects the latest deleted standup of user in channel for time period
+func (m *MySQL) SelectDeletedStandup(userID, channelID string, dateStart, dateEnd time.Time) (model.Standup, error) {
+	s := model.Standup{}
+	err := m.conn.Get(&s, "SELECT * FROM `standups` WHERE team_id=? AND channel_id=? AND user_id=? AND created BETWEEN ? AND ? AND deleted_at IS NOT NULL ORDER BY deleted_at DESC limit 1",
+		m.teamID, channelID, userID, dateStart, dateEnd)
+	return s, err
+}
+
 // CreateChannelMember creates comedian entry in database
 func (m *MySQL) CreateChannelMember(s model.ChannelMember) (model.ChannelMember, error) {
 	err := s.Validate()
@@ -106,8 +139,8 @@ func (m *MySQL) CreateChannelMember(s model.ChannelMember) (model.ChannelMember,
 		return s, err
 	}
 	res, err := m.conn.Exec(
-		"INSERT INTO `channel_members` (user_id, channel_id, standup_time, role_in_channel, created) VALUES (?, ?,?, ?, ?)",
-		s.UserID, s.ChannelID, 0, s.RoleInChannel, time.Now())
+		"INSERT INTO `channel_members` (team_id, user_id, channel_id, standup_time, role_in_channel, created) VALUES (?, ?, ?,?, ?, ?)",
+		m.teamID, s.UserID, s.ChannelID, 0, s.RoleInChannel, time.Now())
 	if err != nil {
 		return s, err
 	}
@@ -116,6 +149,7 @@ func (m *MySQL) CreateChannelMember(s model.ChannelMember) (model.ChannelMember,
 		return s, err
 	}
 	s.ID = id
+	s.TeamID = m.teamID
 
 	return s, nil
 }
@@ -123,14 +157,14 @@ func (m *MySQL) CreateChannelMember(s model.ChannelMember) (model.ChannelMember,
 //FindChannelMemberByUserID finds user in channel
 func (m *MySQL) FindChannelMemberByUserID(userID, channelID string) (model.ChannelMember, error) {
 	var u model.ChannelMember
-	err := m.conn.Get(&u, "SELECT * FROM `channel_members` WHERE user_id=? AND channel_id=?", userID, channelID)
+	err := m.conn.Get(&u, "SELECT * FROM `channel_members` WHERE team_id=? AND user_id=? AND channel_id=?", m.teamID, userID, channelID)
 	return u, err
 }
 
 //FindMembersByUserID finds user in channel
 func (m *MySQL) FindMembersByUserID(userID string) ([]model.ChannelMember, error) {
 	var u []model.ChannelMember
-	err := m.conn.Select(&u, "SELECT * FROM `channel_members` WHERE user_id=?", userID)
+	err := m.conn.Select(&u, "SELECT * FROM `channel_members` WHERE team_id=? AND user_id=?", m.teamID, userID)
 	return u, err
 }
 
@@ -144,29 +178,33 @@ func (m *MySQL) SelectChannelMember(id int64) (model.ChannelMember, error) {
 //FindChannelMemberByUserName finds user in channel
 func (m *MySQL) FindChannelMemberByUserName(userName, channelID string) (model.ChannelMember, error) {
 	var u model.ChannelMember
-	err := m.conn.Get(&u, "SELECT * FROM `channel_members` WHERE user_id=(select user_id from users where user_name=?) and channel_id=?", userName, channelID)
+	err := m.conn.Get(&u, "SELECT * FROM `channel_members` WHERE team_id=? AND user_id=(select user_id from users where team_id=? and user_name=?) and channel_id=?", m.teamID, m.teamID, userName, channelID)
 	return u, err
 }
 
 // ListAllChannelMembers returns array of standup entries from database
 func (m *MySQL) ListAllChannelMembers() ([]model.ChannelMember, error) {
 	items := []model.ChannelMember{}
-	err := m.conn.Select(&items, "SELECT * FROM `channel_members`")
+	err := m.conn.Select(&items, "SELECT * FROM `channel_members` WHERE team_id=?", m.teamID)
 	return items, err
 }
 
-//GetNonReporters returns a list of non reporters in selected time period
+//GetNonReporters returns a list of non reporters in selected time period. Members away
+//during the whole period are not non reporters
 func (m *MySQL) GetNonReporters(channelID string, dateFrom, dateTo time.Time) ([]model.ChannelMember, error) {
 	nonReporters := []model.ChannelMember{}
-	err := m.conn.Select(&nonReporters, `SELECT * FROM channel_members where channel_id=? AND role_in_channel != 'pm' AND user_id NOT IN (SELECT user_id FROM standups where channel_id=? and created BETWEEN ? AND ?)`, channelID, channelID, dateFrom, dateTo)
-	return nonReporters, err
+	err := m.conn.Select(&nonReporters, `SELECT * FROM channel_members where team_id=? AND channel_id=? AND role_in_channel != 'pm' AND user_id NOT IN (SELECT user_id FROM standups where team_id=? and channel_id=? and created BETWEEN ? AND ? AND deleted_at IS NULL)`, m.teamID, channelID, m.teamID, channelID, dateFrom, dateTo)
+	if err != nil {
+		return nonReporters, err
+	}
+	return presentMembers(m, nonReporters, dateFrom, dateTo)
 }
 
-//SubmittedStandupToday shows if a user submitted standup today
+//SubmittedStandupToday shows if a user submitted standup today in their time zone
 func (m *MySQL) SubmittedStandupToday(userID, channelID string) bool {
-	timeFrom := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 0, 0, 0, 0, time.Local)
+	timeFrom := model.StartOfDay(time.Now().In(MemberLocation(m, userID, channelID)))
 	var standup string
-	err := m.conn.Get(&standup, `SELECT comment FROM standups where channel_id=? and user_id=? and created between ? and ?`, channelID, userID, timeFrom, time.Now())
+	err := m.conn.Get(&standup, `SELECT comment FROM standups where team_id=? and channel_id=? and user_id=? and created between ? and ? and deleted_at IS NULL`, m.teamID, channelID, userID, timeFrom, time.Now())
 	if err != nil {
 		logrus.Infof("User '%v' did not write standup in channel '%v' today yet \n", userID, channelID)
 		return false
@@ -177,9 +215,7 @@ func (m *MySQL) SubmittedStandupToday(userID, channelID string) bool {
 // IsNonReporter returns true if user did not submit standup in time period, false othervise
 func (m *MySQL) IsNonReporter(userID, channelID string, dateFrom, dateTo time.Time) (bool, error) {
 	var standup string
-	query := fmt.Sprintf("SELECT comment FROM standups where channel_id='%v' and user_id='%v' and created between '%v' and '%v'", channelID, userID, dateFrom, dateTo)
-	logrus.Infof("IsNonreporter Query: %s", query)
-	err := m.conn.Get(&standup, query)
+	err := m.conn.Get(&standup, "SELECT comment FROM `standups` WHERE team_id=? AND channel_id=? AND user_id=? AND created BETWEEN ? AND ? AND deleted_at IS NULL LIMIT 1", m.teamID, channelID, userID, dateFrom, dateTo)
 	if err != nil {
 		return false, err
 	}
@@ -192,25 +228,25 @@ func (m *MySQL) IsNonReporter(userID, channelID string, dateFrom, dateTo time.Ti
 // ListChannelMembers returns array of standup entries from database
 func (m *MySQL) ListChannelMembers(channelID string) ([]model.ChannelMember, error) {
 	items := []model.ChannelMember{}
-	err := m.conn.Select(&items, "SELECT * FROM `channel_members` WHERE channel_id=?", channelID)
+	err := m.conn.Select(&items, "SELECT * FROM `channel_members` WHERE team_id=? AND channel_id=?", m.teamID, channelID)
 	return items, err
 }
 
 func (m *MySQL) ListChannelMembersByRole(channelID, role string) ([]model.ChannelMember, error) {
 	items := []model.ChannelMember{}
-	err := m.conn.Select(&items, "SELECT * FROM `channel_members` WHERE channel_id=? and role_in_channel=?", channelID, role)
+	err := m.conn.Select(&items, "SELECT * FROM `channel_members` WHERE team_id=? AND channel_id=? and role_in_channel=?", m.teamID, channelID, role)
 	return items, err
 }
 
 // DeleteChannelMember deletes channel_members entry from database
 func (m *MySQL) DeleteChannelMember(userID, channelID string) error {
-	_, err := m.conn.Exec("DELETE FROM `channel_members` WHERE user_id=? AND channel_id=?", userID, channelID)
+	_, err := m.conn.Exec("DELETE FROM `channel_members` WHERE team_id=? AND user_id=? AND channel_id=?", m.teamID, userID, channelID)
 	return err
 }
 
 // CreateStandupTime creates time entry in database
 func (m *MySQL) CreateStandupTime(st int64, channelID string) error {
-	_, err := m.conn.Exec("UPDATE `channels` SET channel_standup_time=? WHERE channel_id=?", st, channelID)
+	_, err := m.conn.Exec("UPDATE `channels` SET channel_standup_time=? WHERE team_id=? AND channel_id=?", st, m.teamID, channelID)
 	if err != nil {
 		return err
 	}
@@ -219,7 +255,7 @@ func (m *MySQL) CreateStandupTime(st int64, channelID string) error {
 
 // UpdateChannelStandupTime updates time entry in database
 func (m *MySQL) UpdateChannelStandupTime(st int64, channelID string) error {
-	_, err := m.conn.Exec("UPDATE `channels` SET channel_standup_time=? WHERE channel_id=?", st, channelID)
+	_, err := m.conn.Exec("UPDATE `channels` SET channel_standup_time=? WHERE team_id=? AND channel_id=?", st, m.teamID, channelID)
 	if err != nil {
 		return err
 	}
@@ -229,20 +265,20 @@ func (m *MySQL) UpdateChannelStandupTime(st int64, channelID string) error {
 // GetChannelStandupTime returns standup time entry from database
 func (m *MySQL) GetChannelStandupTime(channelID string) (int64, error) {
 	var time int64
-	err := m.conn.Get(&time, "SELECT channel_standup_time FROM `channels` WHERE channel_id=?", channelID)
+	err := m.conn.Get(&time, "SELECT channel_standup_time FROM `channels` WHERE team_id=? AND channel_id=?", m.teamID, channelID)
 	return time, err
 }
 
 // ListAllStandupTime returns standup time entry for all channels from database
 func (m *MySQL) ListAllStandupTime() ([]int64, error) {
 	deadlines := []int64{}
-	err := m.conn.Select(&deadlines, "SELECT channel_standup_time FROM `channels` where channel_standup_time>0")
+	err := m.conn.Select(&deadlines, "SELECT channel_standup_time FROM `channels` where team_id=? AND channel_standup_time>0", m.teamID)
 	return deadlines, err
 }
 
 // DeleteStandupTime deletes channels entry for channel from database
 func (m *MySQL) DeleteStandupTime(channelID string) error {
-	_, err := m.conn.Exec("UPDATE `channels` SET channel_standup_time=0 WHERE channel_id=?", channelID)
+	_, err := m.conn.Exec("UPDATE `channels` SET channel_standup_time=0 WHERE team_id=? AND channel_id=?", m.teamID, channelID)
 	return err
 }
 
@@ -253,8 +289,8 @@ func (m *MySQL) AddToStandupHistory(s model.StandupEditHistory) (model.StandupEd
 		return s, err
 	}
 	res, err := m.conn.Exec(
-		"INSERT INTO `standup_edit_history` (created, standup_id, standup_text) VALUES (?, ?, ?)",
-		time.Now().UTC(), s.StandupID, s.StandupText)
+		"INSERT INTO `standup_edit_history` (created, standup_id, standup_text, after_deadline) VALUES (?, ?, ?, ?)",
+		time.Now().UTC(), s.StandupID, s.StandupText, s.AfterDeadline)
 	if err != nil {
 		return s, err
 	}
@@ -267,34 +303,276 @@ func (m *MySQL) AddToStandupHistory(s model.StandupEditHistory) (model.StandupEd
 	return s, nil
 }
 
+// ListStandupHistory returns previous versions of standup from the oldest to the newest one
+func (m *MySQL) ListStandupHistory(standupID int64) ([]model.StandupEditHistory, error) {
+	items := []model.StandupEditHistory{}
+	err := m.conn.Select(&items, "SELECT * FROM `standup_edit_history` WHERE standup_id=? ORDER BY id", standupID)
+	return items, err
+}
+
+// CreateStandupQuestion creates standup question of channel
+func (m *MySQL) CreateStandupQuestion(q model.StandupQuestion) (model.StandupQuestion, error) {
+	err := q.Validate()
+	if err != nil {
+		return q, err
+	}
+	res, err := m.conn.Exec(
+		"INSERT INTO `standup_questions` (team_id, channel_id, question, keywords, required, created) VALUES (?, ?, ?, ?, ?, ?)",
+		m.teamID, q.ChannelID, q.Question, q.Keywords, q.Required, time.Now().UTC())
+	if err != nil {
+		return q, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return q, err
+	}
+	q.ID = id
+	q.TeamID = m.teamID
+
+	return q, nil
+}
+
+// ListStandupQuestions returns standup questions of channel in the order they were added
+func (m *MySQL) ListStandupQuestions(channelID string) ([]model.StandupQuestion, error) {
+	items := []model.StandupQuestion{}
+	err := m.conn.Select(&items, "SELECT * FROM `standup_questions` WHERE team_id=? AND channel_id=? ORDER BY id", m.teamID, channelID)
+	return items, err
+}
+
+// DeleteStandupQuestion deletes standup question
+func (m *MySQL) DeleteStandupQuestion(id int64) error {
+	_, err := m.conn.Exec("DELETE FROM `standup_questions` WHERE id=?", id)
+	return err
+}
+
+// CreateOutgoingMessage puts message to outbox
+func (m *MySQL) CreateOutgoingMessage(msg model.OutgoingMessage) (model.OutgoingMessage, error) {
+	err := msg.Validate()
+	if err != nil {
+		return msg, err
+	}
+	msg.Created = time.Now().UTC()
+	res, err := m.conn.Exec(
+		"INSERT INTO `outbox` (team_id, kind, channel_id, user_id, text, attachments, blocks, dedup_key, pending_key, attempts, next_attempt, last_error, created) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		m.teamID, msg.Kind, msg.ChannelID, msg.UserID, msg.Text, msg.Attachments, msg.Blocks, msg.DedupKey, pendingKey(msg), msg.Attempts, msg.NextAttempt.UTC(), msg.LastError, msg.Created)
+	if err != nil {
+		return msg, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return msg, err
+	}
+	msg.ID = id
+	msg.TeamID = m.teamID
+
+	return msg, nil
+}
+
+// UpdateOutgoingMessage updates delivery state of outbox message
+func (m *MySQL) UpdateOutgoingMessage(msg model.OutgoingMessage) (model.OutgoingMessage, error) {
+	_, err := m.conn.Exec(
+		"UPDATE `outbox` SET attempts=?, next_attempt=?, last_error=?, sent_at=?, failed_at=?, reported=?, pending_key=? WHERE id=?",
+		msg.Attempts, msg.NextAttempt.UTC(), msg.LastError, msg.SentAt, msg.FailedAt, msg.Reported, pendingKey(msg), msg.ID)
+	return msg, err
+}
+
+// FindPendingMessage returns outbox message with the dedup key which is not delivered yet
+func (m *MySQL) FindPendingMessage(dedupKey string) (model.OutgoingMessage, error) {
+	msg := model.OutgoingMessage{}
+	err := m.conn.Get(&msg, "SELECT "+outboxColumns+" FROM `outbox` WHERE team_id=? AND pending_key=? LIMIT 1", m.teamID, dedupKey)
+	return msg, err
+}
+
+// ListDueMessages returns undelivered outbox messages which should be sent by the time, the oldest first
+func (m *MySQL) ListDueMessages(t time.Time) ([]model.OutgoingMessage, error) {
+	items := []model.OutgoingMessage{}
+	err := m.conn.Select(&items, "SELECT "+outboxColumns+" FROM `outbox` WHERE team_id=? AND sent_at IS NULL AND failed_at IS NULL AND next_attempt<=? ORDER BY id", m.teamID, t.UTC())
+	return items, err
+}
+
+// ClaimOutgoingMessage takes undelivered outbox message which is due by the time for delivery
+// until the other time, so that nobody else delivers it meanwhile. Returns false if message is taken
+func (m *MySQL) ClaimOutgoingMessage(id int64, now, until time.Time) (bool, error) {
+	res, err := m.conn.Exec("UPDATE `outbox` SET next_attempt=? WHERE team_id=? AND id=? AND sent_at IS NULL AND failed_at IS NULL AND next_attempt<=?", until.UTC(), m.teamID, id, now.UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ListUnreportedFailures returns outbox messages which failed to be delivered and were not reported yet
+func (m *MySQL) ListUnreportedFailures() ([]model.OutgoingMessage, error) {
+	items := []model.OutgoingMessage{}
+	err := m.conn.Select(&items, "SELECT "+outboxColumns+" FROM `outbox` WHERE team_id=? AND failed_at IS NOT NULL AND reported=FALSE ORDER BY id", m.teamID)
+	return items, err
+}
+
+// DeleteSentMessages deletes outbox messages delivered before the time
+func (m *MySQL) DeleteSentMessages(t time.Time) error {
+	_, err := m.conn.Exec("DELETE FROM `outbox` WHERE team_id=? AND sent_at<?", m.teamID, t.UTC())
+	return err
+}
+
+// CreateScheduledNotification saves the next fire time of notification
+func (m *MySQL) CreateScheduledNotification(n model.ScheduledNotification) (model.ScheduledNotification, error) {
+	res, err := m.conn.Exec(
+		"INSERT INTO `notification_schedule` (team_id, kind, channel_id, channel_member_id, fire_at) VALUES (?, ?, ?, ?, ?)",
+		m.teamID, n.Kind, n.ChannelID, n.ChannelMemberID, n.FireAt.UTC())
+	if err != nil {
+		return n, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return n, err
+	}
+	n.ID = id
+	n.TeamID = m.teamID
+
+	return n, nil
+}
+
+// UpdateScheduledNotification updates fire time of scheduled notification
+func (m *MySQL) UpdateScheduledNotification(n model.ScheduledNotification) (model.ScheduledNotification, error) {
+	_, err := m.conn.Exec("UPDATE `notification_schedule` SET fire_at=? WHERE team_id=? AND id=?", n.FireAt.UTC(), m.teamID, n.ID)
+	return n, err
+}
+
+// ListScheduledNotifications returns scheduled notifications of workspace, the earliest first
+func (m *MySQL) ListScheduledNotifications() ([]model.ScheduledNotification, error) {
+	items := []model.ScheduledNotification{}
+	err := m.conn.Select(&items, "SELECT * FROM `notification_schedule` WHERE team_id=? ORDER BY fire_at, id", m.teamID)
+	return items, err
+}
+
+// DeleteScheduledNotification deletes scheduled notification
+func (m *MySQL) DeleteScheduledNotification(id int64) error {
+	_, err := m.conn.Exec("DELETE FROM `notification_schedule` WHERE team_id=? AND id=?", m.teamID, id)
+	return err
+}
+
+// mysqlUpsertHoliday saves holiday replacing the one on the same day. LAST_INSERT_ID(id)
+// makes id of replaced holiday returned
+const mysqlUpsertHoliday = "INSERT INTO `holidays` (team_id, channel_id, day, name) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE name=VALUES(name), id=LAST_INSERT_ID(id)"
+
+// CreateHoliday saves holiday of channel, or of the workspace if channel is empty.
+// Holiday the channel already has on the day is replaced
+func (m *MySQL) CreateHoliday(h model.Holiday) (model.Holiday, error) {
+	res, err := m.conn.Exec(mysqlUpsertHoliday, m.teamID, h.ChannelID, h.Day, h.Name)
+	if err != nil {
+		return h, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return h, err
+	}
+	h.ID = id
+	h.TeamID = m.teamID
+
+	return h, nil
+}
+
+// ImportHolidays saves holidays like CreateHoliday does in one transaction
+func (m *MySQL) ImportHolidays(holidays []model.Holiday) error {
+	tx, err := m.conn.Beginx()
+	if err != nil {
+		return err
+	}
+	for _, h := range holidays {
+		_, err = tx.Exec(mysqlUpsertHoliday, m.teamID, h.ChannelID, h.Day, h.Name)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteHoliday deletes holiday of channel, or of the workspace if channel is empty, on the day
+func (m *MySQL) DeleteHoliday(channelID, day string) error {
+	_, err := m.conn.Exec("DELETE FROM `holidays` WHERE team_id=? AND channel_id=? AND day=?", m.teamID, channelID, day)
+	return err
+}
+
+// ListHolidays returns holidays of the workspace and of the channel ordered by day
+func (m *MySQL) ListHolidays(channelID string) ([]model.Holiday, error) {
+	items := []model.Holiday{}
+	err := m.conn.Select(&items, "SELECT * FROM `holidays` WHERE team_id=? AND (channel_id='' OR channel_id=?) ORDER BY day, id", m.teamID, channelID)
+	return items, err
+}
+
+// CreateAbsence saves period user is away
+func (m *MySQL) CreateAbsence(a model.Absence) (model.Absence, error) {
+	res, err := m.conn.Exec(
+		"INSERT INTO `absences` (team_id, user_id, first_day, last_day, reason) VALUES (?, ?, ?, ?, ?)",
+		m.teamID, a.UserID, a.FirstDay, a.LastDay, a.Reason)
+	if err != nil {
+		return a, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return a, err
+	}
+	a.ID = id
+	a.TeamID = m.teamID
+
+	return a, nil
+}
+
+// UpdateAbsence updates days and reason of absence
+func (m *MySQL) UpdateAbsence(a model.Absence) (model.Absence, error) {
+	_, err := m.conn.Exec(
+		"UPDATE `absences` SET first_day=?, last_day=?, reason=? WHERE team_id=? AND id=?",
+		a.FirstDay, a.LastDay, a.Reason, m.teamID, a.ID)
+	if err != nil {
+		return a, err
+	}
+	a.TeamID = m.teamID
+
+	return a, nil
+}
+
+// DeleteAbsence deletes absence
+func (m *MySQL) DeleteAbsence(id int64) error {
+	_, err := m.conn.Exec("DELETE FROM `absences` WHERE team_id=? AND id=?", m.teamID, id)
+	return err
+}
+
+// ListAbsences returns absences of user ordered by first day
+func (m *MySQL) ListAbsences(userID string) ([]model.Absence, error) {
+	items := []model.Absence{}
+	err := m.conn.Select(&items, "SELECT * FROM `absences` WHERE team_id=? AND user_id=? ORDER BY first_day, id", m.teamID, userID)
+	return items, err
+}
+
 //GetAllChannels returns list of unique channels
 func (m *MySQL) GetAllChannels() ([]model.Channel, error) {
 	channels := []model.Channel{}
-	err := m.conn.Select(&channels, "SELECT * FROM `channels`")
+	err := m.conn.Select(&channels, "SELECT * FROM `channels` WHERE team_id=?", m.teamID)
 	return channels, err
 }
 
 //GetUserChannels returns list of user's channels
 func (m *MySQL) GetUserChannels(userID string) ([]string, error) {
 	channels := []string{}
-	err := m.conn.Select(&channels, "SELECT channel_id FROM `channel_members` where user_id=?", userID)
+	err := m.conn.Select(&channels, "SELECT channel_id FROM `channel_members` where team_id=? AND user_id=?", m.teamID, userID)
 	return channels, err
 }
 
 //GetChannelName returns channel name
 func (m *MySQL) GetChannelName(channelID string) (string, error) {
 	var channelName string
-	err := m.conn.Get(&channelName, "SELECT channel_name FROM `channels` where channel_id=?", channelID)
+	err := m.conn.Get(&channelName, "SELECT channel_name FROM `channels` where team_id=? AND channel_id=?", m.teamID, channelID)
 	if err != nil {
 		return "", err
 	}
 	return channelName, err
 }
 
-//GetChannelID returns channel name
+//GetChannelID returns ID of channel with the name, deleted channels are skipped
 func (m *MySQL) GetChannelID(channelName string) (string, error) {
 	var channelID string
-	err := m.conn.Get(&channelID, "SELECT channel_id FROM `channels` where channel_name=?", channelName)
+	err := m.conn.Get(&channelID, "SELECT channel_id FROM `channels` where team_id=? AND channel_name=? AND status!=? LIMIT 1", m.teamID, channelName, model.ChannelDeleted)
 	if err != nil {
 		return "", err
 	}
@@ -305,15 +583,18 @@ func (m *MySQL) GetChannelID(channelName string) (string, error) {
 // Helper function for testing
 func (m *MySQL) ListStandups() ([]model.Standup, error) {
 	items := []model.Standup{}
-	err := m.conn.Select(&items, "SELECT * FROM `standups`")
+	err := m.conn.Select(&items, "SELECT * FROM `standups` WHERE team_id=?", m.teamID)
 	return items, err
 }
 
 // CreateChannel creates standup entry in database
 func (m *MySQL) CreateChannel(c model.Channel) (model.Channel, error) {
+	if c.Status == "" {
+		c.Status = model.ChannelActive
+	}
 	res, err := m.conn.Exec(
-		"INSERT INTO `channels` (channel_name, channel_id, channel_standup_time) VALUES (?, ?, ?)",
-		c.ChannelName, c.ChannelID, 0,
+		"INSERT INTO `channels` (team_id, channel_name, channel_id, channel_standup_time, status, auto_enroll, time_zone, working_days) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		m.teamID, c.ChannelName, c.ChannelID, 0, c.Status, c.AutoEnroll, c.TimeZone, c.WorkingDays,
 	)
 	if err != nil {
 		return c, err
@@ -323,6 +604,7 @@ func (m *MySQL) CreateChannel(c model.Channel) (model.Channel, error) {
 		return c, err
 	}
 	c.ID = id
+	c.TeamID = m.teamID
 
 	return c, nil
 }
@@ -330,7 +612,7 @@ func (m *MySQL) CreateChannel(c model.Channel) (model.Channel, error) {
 // SelectChannel selects Channel entry from database
 func (m *MySQL) SelectChannel(channelID string) (model.Channel, error) {
 	var c model.Channel
-	err := m.conn.Get(&c, "SELECT * FROM `channels` WHERE channel_id=?", channelID)
+	err := m.conn.Get(&c, "SELECT * FROM `channels` WHERE team_id=? AND channel_id=?", m.teamID, channelID)
 	if err != nil {
 		return c, err
 	}
@@ -340,13 +622,47 @@ func (m *MySQL) SelectChannel(channelID string) (model.Channel, error) {
 // GetChannels selects Channel entry from database
 func (m *MySQL) GetChannels() ([]model.Channel, error) {
 	var c []model.Channel
-	err := m.conn.Select(&c, "SELECT * FROM `channels`")
+	err := m.conn.Select(&c, "SELECT * FROM `channels` WHERE team_id=?", m.teamID)
 	if err != nil {
 		return c, err
 	}
 	return c, err
 }
 
+// UpdateChannel updates name, status, auto-enroll mode, report channel, time zone and working days of channel
+func (m *MySQL) UpdateChannel(c model.Channel) (model.Channel, error) {
+	_, err := m.conn.Exec(
+		"UPDATE `channels` SET channel_name=?, status=?, auto_enroll=?, report_channel_id=?, time_zone=?, working_days=? WHERE team_id=? AND channel_id=?",
+		c.ChannelName, c.Status, c.AutoEnroll, c.ReportChannelID, c.TimeZone, c.WorkingDays, m.teamID, c.ChannelID,
+	)
+	if err != nil {
+		return c, err
+	}
+	return m.SelectChannel(c.ChannelID)
+}
+
+// ExcludeFromAutoEnroll keeps user from being enrolled in channel automatically
+func (m *MySQL) ExcludeFromAutoEnroll(channelID, userID string) error {
+	_, err := m.conn.Exec(
+		"INSERT INTO `auto_enroll_exclusions` (team_id, channel_id, user_id) SELECT ?, ?, ? FROM DUAL WHERE NOT EXISTS (SELECT id FROM `auto_enroll_exclusions` WHERE team_id=? AND channel_id=? AND user_id=?)",
+		m.teamID, channelID, userID, m.teamID, channelID, userID,
+	)
+	return err
+}
+
+// IncludeInAutoEnroll removes user from auto-enroll exclusions of channel
+func (m *MySQL) IncludeInAutoEnroll(channelID, userID string) error {
+	_, err := m.conn.Exec("DELETE FROM `auto_enroll_exclusions` WHERE team_id=? AND channel_id=? AND user_id=?", m.teamID, channelID, userID)
+	return err
+}
+
+// ListAutoEnrollExclusions returns IDs of users excluded from auto-enroll in channel
+func (m *MySQL) ListAutoEnrollExclusions(channelID string) ([]string, error) {
+	users := []string{}
+	err := m.conn.Select(&users, "SELECT user_id FROM `auto_enroll_exclusions` WHERE team_id=? AND channel_id=? ORDER BY id", m.teamID, channelID)
+	return users, err
+}
+
 // DeleteChannel deletes Channel entry from database
 func (m *MySQL) DeleteChannel(id int64) error {
 	_, err := m.conn.Exec("DELETE FROM `channels` WHERE id=?", id)
@@ -356,8 +672,8 @@ func (m *MySQL) DeleteChannel(id int64) error {
 // CreateUser creates standup entry in database
 func (m *MySQL) CreateUser(c model.User) (model.User, error) {
 	res, err := m.conn.Exec(
-		"INSERT INTO `users` (user_name, user_id, role) VALUES (?, ?, ?)",
-		c.UserName, c.UserID, c.Role,
+		"INSERT INTO `users` (team_id, user_name, user_id, role, time_zone) VALUES (?, ?, ?, ?, ?)",
+		m.teamID, c.UserName, c.UserID, c.Role, c.TimeZone,
 	)
 	if err != nil {
 		return c, err
@@ -367,15 +683,16 @@ func (m *MySQL) CreateUser(c model.User) (model.User, error) {
 		return c, err
 	}
 	c.ID = id
+	c.TeamID = m.teamID
 
 	return c, nil
 }
 
-// UpdateUser updates User entry in database
+// UpdateUser updates role, name and time zone of user
 func (m *MySQL) UpdateUser(c model.User) (model.User, error) {
 	_, err := m.conn.Exec(
-		"UPDATE `users` SET role=? WHERE id=?",
-		c.Role, c.ID,
+		"UPDATE `users` SET role=?, user_name=?, time_zone=? WHERE id=?",
+		c.Role, c.UserName, c.TimeZone, c.ID,
 	)
 	if err != nil {
 		return c, err
@@ -388,7 +705,7 @@ func (m *MySQL) UpdateUser(c model.User) (model.User, error) {
 // SelectUser selects User entry from database
 func (m *MySQL) SelectUser(userID string) (model.User, error) {
 	var c model.User
-	err := m.conn.Get(&c, "SELECT * FROM `users` WHERE user_id=?", userID)
+	err := m.conn.Get(&c, "SELECT * FROM `users` WHERE team_id=? AND user_id=?", m.teamID, userID)
 	if err != nil {
 		return c, err
 	}
@@ -398,7 +715,7 @@ func (m *MySQL) SelectUser(userID string) (model.User, error) {
 // SelectUser selects User entry from database
 func (m *MySQL) ListUsers() ([]model.User, error) {
 	var u []model.User
-	err := m.conn.Select(&u, "SELECT * FROM `users`")
+	err := m.conn.Select(&u, "SELECT * FROM `users` WHERE team_id=?", m.teamID)
 	if err != nil {
 		return u, err
 	}
@@ -408,7 +725,7 @@ func (m *MySQL) ListUsers() ([]model.User, error) {
 // SelectUserByUserName selects User entry from database
 func (m *MySQL) SelectUserByUserName(userName string) (model.User, error) {
 	var c model.User
-	err := m.conn.Get(&c, "SELECT * FROM `users` WHERE user_name=?", userName)
+	err := m.conn.Get(&c, "SELECT * FROM `users` WHERE team_id=? AND user_name=?", m.teamID, userName)
 	if err != nil {
 		return c, err
 	}
@@ -421,10 +738,30 @@ func (m *MySQL) DeleteUser(id int64) error {
 	return err
 }
 
+// DeactivateUser deletes user with their channel memberships and timetables in one transaction
+func (m *MySQL) DeactivateUser(userID string) error {
+	tx, err := m.conn.Beginx()
+	if err != nil {
+		return err
+	}
+	for _, query := range []string{
+		"DELETE FROM `timetables` WHERE channel_member_id IN (SELECT id FROM `channel_members` WHERE team_id=? AND user_id=?)",
+		"DELETE FROM `channel_members` WHERE team_id=? AND user_id=?",
+		"DELETE FROM `users` WHERE team_id=? AND user_id=?",
+	} {
+		_, err = tx.Exec(query, m.teamID, userID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 // ListAdmins selects User entry from database
 func (m *MySQL) ListAdmins() ([]model.User, error) {
 	var c []model.User
-	err := m.conn.Select(&c, "SELECT * FROM `users` WHERE role='admin'")
+	err := m.conn.Select(&c, "SELECT * FROM `users` WHERE team_id=? AND role='admin'", m.teamID)
 	if err != nil {
 		return c, err
 	}
@@ -434,7 +771,7 @@ func (m *MySQL) ListAdmins() ([]model.User, error) {
 // UserIsPMForProject returns true if user is a project's PM.
 func (m *MySQL) UserIsPMForProject(userID, channelID string) bool {
 	var role string
-	err := m.conn.Get(&role, "SELECT role_in_channel FROM `channel_members` WHERE user_id=? AND channel_id=?", userID, channelID)
+	err := m.conn.Get(&role, "SELECT role_in_channel FROM `channel_members` WHERE team_id=? AND user_id=? AND channel_id=?", m.teamID, userID, channelID)
 	if err != nil {
 		return false
 	}
@@ -448,8 +785,8 @@ func (m *MySQL) UserIsPMForProject(userID, channelID string) bool {
 // CreateTimeTable creates tt entry in database
 func (m *MySQL) CreateTimeTable(t model.TimeTable) (model.TimeTable, error) {
 	res, err := m.conn.Exec(
-		"INSERT INTO `timetables` (channel_member_id, created, modified) VALUES (?, ?, ?)",
-		t.ChannelMemberID, time.Now(), time.Now())
+		"INSERT INTO `timetables` (team_id, channel_member_id, created, modified) VALUES (?, ?, ?, ?)",
+		m.teamID, t.ChannelMemberID, time.Now(), time.Now())
 	if err != nil {
 		return t, err
 	}
@@ -458,6 +795,7 @@ func (m *MySQL) CreateTimeTable(t model.TimeTable) (model.TimeTable, error) {
 		return t, err
 	}
 	t.ID = id
+	t.TeamID = m.teamID
 
 	return t, nil
 }
@@ -492,11 +830,12 @@ func (m *MySQL) DeleteTimeTable(id int64) error {
 	return err
 }
 
-//ListTimeTablesForDay returns list of chan members who has timetables
+//ListTimeTablesForDay returns list of chan members who has timetables, members of
+//inactive channels are skipped
 func (m *MySQL) ListTimeTablesForDay(day string) ([]model.TimeTable, error) {
 	var tt []model.TimeTable
-	query := fmt.Sprintf("select channel_member_id, %s from timetables where %s != 0", day, day)
-	err := m.conn.Select(&tt, query)
+	query := fmt.Sprintf("select t.channel_member_id, t.%s from timetables t left join channel_members cm on cm.id=t.channel_member_id left join channels c on c.team_id=cm.team_id and c.channel_id=cm.channel_id where t.team_id=? and t.%s != 0 and (c.status is null or c.status=?)", day, day)
+	err := m.conn.Select(&tt, query, m.teamID, model.ChannelActive)
 	if err != nil {
 		return tt, err
 	}
@@ -515,8 +854,14 @@ func (m *MySQL) MemberHasTimeTable(id int64) bool {
 
 //MemberShouldBeTracked returns true if member should be tracked
 func (m *MySQL) MemberShouldBeTracked(id int64, date time.Time) bool {
+	var absences int
+	err := m.conn.Get(&absences, "SELECT COUNT(*) FROM `absences` WHERE team_id=? AND user_id=(SELECT user_id FROM `channel_members` WHERE id=?) AND first_day <= ? AND last_day >= ?", m.teamID, id, date.Format(model.HolidayDay), date.Format(model.HolidayDay))
+	if err == nil && absences > 0 {
+		logrus.Infof("Member %v is away on %v! Do not track", id, date.Format(model.HolidayDay))
+		return false
+	}
 	var tt model.TimeTable
-	err := m.conn.Get(&tt, "SELECT * FROM `timetables` WHERE channel_member_id=?", id)
+	err = m.conn.Get(&tt, "SELECT * FROM `timetables` WHERE channel_member_id=?", id)
 	if err != nil {
 		logrus.Infof("User does not have a timetable: %v", err)
 		return true
@@ -535,3 +880,18 @@ func (m *MySQL) MemberShouldBeTracked(id int64, date time.Time) bool {
 
 	return false
 }
+
+// SchemaVersion returns version of the latest migration applied to database
+func (m *MySQL) SchemaVersion() (int64, error) {
+	return schemaVersion(m.conn)
+}
+
+// LatestSchemaVersion returns version of the latest migration embedded into binary
+func (m *MySQL) LatestSchemaVersion() (int64, error) {
+	return latestVersion(migrations.MySQL)
+}
+
+// Migrate applies all pending migrations
+func (m *MySQL) Migrate() error {
+	return migrate(m.conn, migrations.MySQL)
+}
@@ -0,0 +1,850 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	// This line is must for working PostgreSQL database
+	_ "github.com/lib/pq"
+	"github.com/maddevsio/comedian/config"
+	"github.com/maddevsio/comedian/migrations"
+	"github.com/maddevsio/comedian/model"
+	"github.com/sirupsen/logrus"
+)
+
+// Postgres provides api for work with postgresql database
+type Postgres struct {
+	conn   *sqlx.DB
+	teamID string
+}
+
+// NewPostgres creates a new instance of database API
+func NewPostgres(c config.Config) (*Postgres, error) {
+	conn, err := sqlx.Open("postgres", c.DatabaseURL)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetConnMaxLifetime(time.Second)
+	p := &Postgres{}
+	p.conn = conn
+
+	return p, nil
+}
+
+// ForTeam returns storage which keeps entries of the selected workspace only
+func (p *Postgres) ForTeam(teamID string) Storage {
+	return &Postgres{conn: p.conn, teamID: teamID}
+}
+
+// CreateStandup creates standup entry in database
+func (p *Postgres) CreateStandup(s model.Standup) (model.Standup, error) {
+	err := s.Validate()
+	if err != nil {
+		return s, err
+	}
+	err = p.conn.QueryRow(
+		"INSERT INTO standups (created, modified, comment, channel_id, user_id, message_ts, team_id, sections) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
+		standupCreated(s), time.Now().UTC(), s.Comment, s.ChannelID, s.UserID, s.MessageTS, p.teamID, s.Sections,
+	).Scan(&s.ID)
+	if err != nil {
+		return s, err
+	}
+	s.TeamID = p.teamID
+
+	return s, nil
+}
+
+// UpdateStandup updates standup entry in database
+func (p *Postgres) UpdateStandup(s model.Standup) (model.Standup, error) {
+	_, err := p.conn.Exec(
+		"UPDATE standups SET modified=$1, comment=$2, message_ts=$3, sections=$4 WHERE id=$5",
+		time.Now().UTC(), s.Comment, s.MessageTS, s.Sections, s.ID,
+	)
+	if err != nil {
+		return s, err
+	}
+	var i model.Standup
+	err = p.conn.Get(&i, "SELECT * FROM standups WHERE id=$1", s.ID)
+	return i, err
+}
+
+// SelectStandupByMessageTS selects standup entry from database filtered by MessageTS parameter
+func (p *Postgres) SelectStandupByMessageTS(messageTS string) (model.Standup, error) {
+	var s model.Standup
+	err := p.conn.Get(&s, "SELECT * FROM standups WHERE message_ts=$1 AND team_id=$2 AND deleted_at IS NULL", messageTS, p.teamID)
+	if err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// SelectStandupsByChannelIDForPeriod selects standup entrys by channel ID and time period from database
+func (p *Postgres) SelectStandupsByChannelIDForPeriod(channelID string, dateStart, dateEnd time.Time) ([]model.Standup, error) {
+	items := []model.Standup{}
+	err := p.conn.Select(&items, "SELECT * FROM standups WHERE channel_id=$1 AND created BETWEEN $2 AND $3 AND team_id=$4 AND deleted_at IS NULL",
+		channelID, dateStart, dateEnd, p.teamID)
+	return items, err
+}
+
+// SelectStandupsFiltered selects standup entrys by channel ID and time period from database
+func (p *Postgres) SelectStandupsFiltered(userID, channelID string, dateStart, dateEnd time.Time) (model.Standup, error) {
+	items := model.Standup{}
+	err := p.conn.Get(&items, "SELECT * FROM standups WHERE channel_id=$1 AND user_id=$2 AND created BETWEEN $3 AND $4 AND team_id=$5 AND deleted_at IS NULL LIMIT 1",
+		channelID, userID, dateStart, dateEnd, p.teamID)
+	return items, err
+}
+
+// DeleteStandup deletes standup entry from database
+func (p *Postgres) DeleteStandup(id int64) error {
+	_, err := p.conn.Exec("DELETE FROM standups WHERE id=$1", id)
+	return err
+}
+
+// MarkStandupDeleted keeps standup entry in database, but marks it as deleted
+func (p *Postgres) MarkStandupDeleted(id int64) error {
+	_, err := p.conn.Exec("UPDATE standups SET deleted_at=$1 WHERE id=$2", time.Now().UTC(), id)
+	return err
+}
+
+// RestoreStandup removes deleted mark from standup entry
+func (p *Postgres) RestoreStandup(id int64) (model.Standup, error) {
+	_, err := p.conn.Exec("UPDATE standups SET deleted_at=NULL WHERE id=$1", id)
+	if err != nil {
+		return model.Standup{}, err
+	}
+	var s model.Standup
+	err = p.conn.Get(&s, "SELECT * FROM standups WHERE id=$1", id)
+	return s, err
+}
+
+// SelectDeletedStandup selects the latest deleted standup of user in channel for time period
+func (p *Postgres) SelectDeletedStandup(userID, channelID string, dateStart, dateEnd time.Time) (model.Standup, error) {
+	s := model.Standup{}
+	err := p.conn.Get(&s, "SELECT * FROM standups WHERE channel_id=$1 AND user_id=$2 AND created BETWEEN $3 AND $4 AND team_id=$5 AND deleted_at IS NOT NULL ORDER BY deleted_at DESC LIMIT 1",
+		channelID, userID, dateStart, dateEnd, p.teamID)
+	return s, err
+}
+
+// CreateChannelMember creates comedian entry in database
+func (p *Postgres) CreateChannelMember(s model.ChannelMember) (model.ChannelMember, error) {
+	err := s.Validate()
+	if err != nil {
+		return s, err
+	}
+	err = p.conn.QueryRow(
+		"INSERT INTO channel_members (user_id, channel_id, standup_time, role_in_channel, created, team_id) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		s.UserID, s.ChannelID, 0, s.RoleInChannel, time.Now(), p.teamID,
+	).Scan(&s.ID)
+	if err != nil {
+		return s, err
+	}
+	s.TeamID = p.teamID
+
+	return s, nil
+}
+
+//FindChannelMemberByUserID finds user in channel
+func (p *Postgres) FindChannelMemberByUserID(userID, channelID string) (model.ChannelMember, error) {
+	var u model.ChannelMember
+	err := p.conn.Get(&u, "SELECT * FROM channel_members WHERE user_id=$1 AND channel_id=$2 AND team_id=$3", userID, channelID, p.teamID)
+	return u, err
+}
+
+//FindMembersByUserID finds user in channel
+func (p *Postgres) FindMembersByUserID(userID string) ([]model.ChannelMember, error) {
+	var u []model.ChannelMember
+	err := p.conn.Select(&u, "SELECT * FROM channel_members WHERE user_id=$1 AND team_id=$2", userID, p.teamID)
+	return u, err
+}
+
+//SelectChannelMember finds user in channel
+func (p *Postgres) SelectChannelMember(id int64) (model.ChannelMember, error) {
+	var u model.ChannelMember
+	err := p.conn.Get(&u, "SELECT * FROM channel_members WHERE id=$1", id)
+	return u, err
+}
+
+//FindChannelMemberByUserName finds user in channel
+func (p *Postgres) FindChannelMemberByUserName(userName, channelID string) (model.ChannelMember, error) {
+	var u model.ChannelMember
+	err := p.conn.Get(&u, "SELECT * FROM channel_members WHERE user_id=(SELECT user_id FROM users WHERE user_name=$1 AND team_id=$3 LIMIT 1) AND channel_id=$2 AND team_id=$3", userName, channelID, p.teamID)
+	return u, err
+}
+
+// ListAllChannelMembers returns array of standup entries from database
+func (p *Postgres) ListAllChannelMembers() ([]model.ChannelMember, error) {
+	items := []model.ChannelMember{}
+	err := p.conn.Select(&items, "SELECT * FROM channel_members WHERE team_id=$1", p.teamID)
+	return items, err
+}
+
+//GetNonReporters returns a list of non reporters in selected time period. Members away
+//during the whole period are not non reporters
+func (p *Postgres) GetNonReporters(channelID string, dateFrom, dateTo time.Time) ([]model.ChannelMember, error) {
+	nonReporters := []model.ChannelMember{}
+	err := p.conn.Select(&nonReporters, `SELECT * FROM channel_members WHERE channel_id=$1 AND team_id=$4 AND role_in_channel != 'pm' AND user_id NOT IN (SELECT user_id FROM standups WHERE channel_id=$1 AND team_id=$4 AND created BETWEEN $2 AND $3 AND deleted_at IS NULL)`, channelID, dateFrom, dateTo, p.teamID)
+	if err != nil {
+		return nonReporters, err
+	}
+	return presentMembers(p, nonReporters, dateFrom, dateTo)
+}
+
+//SubmittedStandupToday shows if a user submitted standup today in their time zone
+func (p *Postgres) SubmittedStandupToday(userID, channelID string) bool {
+	timeFrom := model.StartOfDay(time.Now().In(MemberLocation(p, userID, channelID)))
+	var standup string
+	err := p.conn.Get(&standup, `SELECT comment FROM standups WHERE channel_id=$1 AND user_id=$2 AND created BETWEEN $3 AND $4 AND team_id=$5 AND deleted_at IS NULL LIMIT 1`, channelID, userID, timeFrom, time.Now(), p.teamID)
+	if err != nil {
+		logrus.Infof("User '%v' did not write standup in channel '%v' today yet \n", userID, channelID)
+		return false
+	}
+	return true
+}
+
+// IsNonReporter returns true if user did not submit standup in time period, false othervise
+func (p *Postgres) IsNonReporter(userID, channelID string, dateFrom, dateTo time.Time) (bool, error) {
+	var standup string
+	err := p.conn.Get(&standup, `SELECT comment FROM standups WHERE channel_id=$1 AND user_id=$2 AND created BETWEEN $3 AND $4 AND team_id=$5 AND deleted_at IS NULL LIMIT 1`, channelID, userID, dateFrom, dateTo, p.teamID)
+	if err != nil {
+		return false, err
+	}
+	if standup == "" {
+		return true, nil
+	}
+	return false, nil
+}
+
+// ListChannelMembers returns array of standup entries from database
+func (p *Postgres) ListChannelMembers(channelID string) ([]model.ChannelMember, error) {
+	items := []model.ChannelMember{}
+	err := p.conn.Select(&items, "SELECT * FROM channel_members WHERE channel_id=$1 AND team_id=$2", channelID, p.teamID)
+	return items, err
+}
+
+// ListChannelMembersByRole returns array of channel members with selected role
+func (p *Postgres) ListChannelMembersByRole(channelID, role string) ([]model.ChannelMember, error) {
+	items := []model.ChannelMember{}
+	err := p.conn.Select(&items, "SELECT * FROM channel_members WHERE channel_id=$1 AND role_in_channel=$2 AND team_id=$3", channelID, role, p.teamID)
+	return items, err
+}
+
+// DeleteChannelMember deletes channel_members entry from database
+func (p *Postgres) DeleteChannelMember(userID, channelID string) error {
+	_, err := p.conn.Exec("DELETE FROM channel_members WHERE user_id=$1 AND channel_id=$2 AND team_id=$3", userID, channelID, p.teamID)
+	return err
+}
+
+// CreateStandupTime creates time entry in database
+func (p *Postgres) CreateStandupTime(st int64, channelID string) error {
+	_, err := p.conn.Exec("UPDATE channels SET channel_standup_time=$1 WHERE channel_id=$2 AND team_id=$3", st, channelID, p.teamID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateChannelStandupTime updates time entry in database
+func (p *Postgres) UpdateChannelStandupTime(st int64, channelID string) error {
+	_, err := p.conn.Exec("UPDATE channels SET channel_standup_time=$1 WHERE channel_id=$2 AND team_id=$3", st, channelID, p.teamID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetChannelStandupTime returns standup time entry from database
+func (p *Postgres) GetChannelStandupTime(channelID string) (int64, error) {
+	var time int64
+	err := p.conn.Get(&time, "SELECT channel_standup_time FROM channels WHERE channel_id=$1 AND team_id=$2", channelID, p.teamID)
+	return time, err
+}
+
+// ListAllStandupTime returns standup time entry for all channels from database
+func (p *Postgres) ListAllStandupTime() ([]int64, error) {
+	deadlines := []int64{}
+	err := p.conn.Select(&deadlines, "SELECT channel_standup_time FROM channels WHERE channel_standup_time>0 AND team_id=$1", p.teamID)
+	return deadlines, err
+}
+
+// DeleteStandupTime deletes channels entry for channel from database
+func (p *Postgres) DeleteStandupTime(channelID string) error {
+	_, err := p.conn.Exec("UPDATE channels SET channel_standup_time=0 WHERE channel_id=$1 AND team_id=$2", channelID, p.teamID)
+	return err
+}
+
+// AddToStandupHistory creates backup standup entry in standup_edit_history database
+func (p *Postgres) AddToStandupHistory(s model.StandupEditHistory) (model.StandupEditHistory, error) {
+	err := s.Validate()
+	if err != nil {
+		return s, err
+	}
+	err = p.conn.QueryRow(
+		"INSERT INTO standup_edit_history (created, standup_id, standup_text, after_deadline) VALUES ($1, $2, $3, $4) RETURNING id",
+		time.Now().UTC(), s.StandupID, s.StandupText, s.AfterDeadline,
+	).Scan(&s.ID)
+	if err != nil {
+		return s, err
+	}
+
+	return s, nil
+}
+
+// ListStandupHistory returns previous versions of standup from the oldest to the newest one
+func (p *Postgres) ListStandupHistory(standupID int64) ([]model.StandupEditHistory, error) {
+	items := []model.StandupEditHistory{}
+	err := p.conn.Select(&items, "SELECT * FROM standup_edit_history WHERE standup_id=$1 ORDER BY id", standupID)
+	return items, err
+}
+
+// CreateStandupQuestion creates standup question of channel
+func (p *Postgres) CreateStandupQuestion(q model.StandupQuestion) (model.StandupQuestion, error) {
+	err := q.Validate()
+	if err != nil {
+		return q, err
+	}
+	err = p.conn.QueryRow(
+		"INSERT INTO standup_questions (channel_id, question, keywords, required, created, team_id) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		q.ChannelID, q.Question, q.Keywords, q.Required, time.Now().UTC(), p.teamID,
+	).Scan(&q.ID)
+	if err != nil {
+		return q, err
+	}
+	q.TeamID = p.teamID
+
+	return q, nil
+}
+
+// ListStandupQuestions returns standup questions of channel in the order they were added
+func (p *Postgres) ListStandupQuestions(channelID string) ([]model.StandupQuestion, error) {
+	items := []model.StandupQuestion{}
+	err := p.conn.Select(&items, "SELECT * FROM standup_questions WHERE channel_id=$1 AND team_id=$2 ORDER BY id", channelID, p.teamID)
+	return items, err
+}
+
+// DeleteStandupQuestion deletes standup question
+func (p *Postgres) DeleteStandupQuestion(id int64) error {
+	_, err := p.conn.Exec("DELETE FROM standup_questions WHERE id=$1", id)
+	return err
+}
+
+// CreateOutgoingMessage puts message to outbox
+func (p *Postgres) CreateOutgoingMessage(msg model.OutgoingMessage) (model.OutgoingMessage, error) {
+	err := msg.Validate()
+	if err != nil {
+		return msg, err
+	}
+	msg.Created = time.Now().UTC()
+	err = p.conn.QueryRow(
+		"INSERT INTO outbox (kind, channel_id, user_id, text, attachments, blocks, dedup_key, pending_key, attempts, next_attempt, last_error, created, team_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id",
+		msg.Kind, msg.ChannelID, msg.UserID, msg.Text, msg.Attachments, msg.Blocks, msg.DedupKey, pendingKey(msg), msg.Attempts, msg.NextAttempt.UTC(), msg.LastError, msg.Created, p.teamID,
+	).Scan(&msg.ID)
+	if err != nil {
+		return msg, err
+	}
+	msg.TeamID = p.teamID
+
+	return msg, nil
+}
+
+// UpdateOutgoingMessage updates delivery state of outbox message
+func (p *Postgres) UpdateOutgoingMessage(msg model.OutgoingMessage) (model.OutgoingMessage, error) {
+	_, err := p.conn.Exec(
+		"UPDATE outbox SET attempts=$1, next_attempt=$2, last_error=$3, sent_at=$4, failed_at=$5, reported=$6, pending_key=$7 WHERE id=$8",
+		msg.Attempts, msg.NextAttempt.UTC(), msg.LastError, msg.SentAt, msg.FailedAt, msg.Reported, pendingKey(msg), msg.ID)
+	return msg, err
+}
+
+// FindPendingMessage returns outbox message with the dedup key which is not delivered yet
+func (p *Postgres) FindPendingMessage(dedupKey string) (model.OutgoingMessage, error) {
+	msg := model.OutgoingMessage{}
+	err := p.conn.Get(&msg, "SELECT "+outboxColumns+" FROM outbox WHERE team_id=$1 AND pending_key=$2 LIMIT 1", p.teamID, dedupKey)
+	return msg, err
+}
+
+// ListDueMessages returns undelivered outbox messages which should be sent by the time, the oldest first
+func (p *Postgres) ListDueMessages(t time.Time) ([]model.OutgoingMessage, error) {
+	items := []model.OutgoingMessage{}
+	err := p.conn.Select(&items, "SELECT "+outboxColumns+" FROM outbox WHERE team_id=$1 AND sent_at IS NULL AND failed_at IS NULL AND next_attempt<=$2 ORDER BY id", p.teamID, t.UTC())
+	return items, err
+}
+
+// ClaimOutgoingMessage takes undelivered outbox message which is due by the time for delivery
+// until the other time, so that nobody else delivers it meanwhile. Returns false if message is taken
+func (p *Postgres) ClaimOutgoingMessage(id int64, now, until time.Time) (bool, error) {
+	res, err := p.conn.Exec("UPDATE outbox SET next_attempt=$1 WHERE team_id=$2 AND id=$3 AND sent_at IS NULL AND failed_at IS NULL AND next_attempt<=$4", until.UTC(), p.teamID, id, now.UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ListUnreportedFailures returns outbox messages which failed to be delivered and were not reported yet
+func (p *Postgres) ListUnreportedFailures() ([]model.OutgoingMessage, error) {
+	items := []model.OutgoingMessage{}
+	err := p.conn.Select(&items, "SELECT "+outboxColumns+" FROM outbox WHERE team_id=$1 AND failed_at IS NOT NULL AND reported=FALSE ORDER BY id", p.teamID)
+	return items, err
+}
+
+// DeleteSentMessages deletes outbox messages delivered before the time
+func (p *Postgres) DeleteSentMessages(t time.Time) error {
+	_, err := p.conn.Exec("DELETE FROM outbox WHERE team_id=$1 AND sent_at<$2", p.teamID, t.UTC())
+	return err
+}
+
+// CreateScheduledNotification saves the next fire time of notification
+func (p *Postgres) CreateScheduledNotification(n model.ScheduledNotification) (model.ScheduledNotification, error) {
+	err := p.conn.QueryRow(
+		"INSERT INTO notification_schedule (kind, channel_id, channel_member_id, fire_at, team_id) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		n.Kind, n.ChannelID, n.ChannelMemberID, n.FireAt.UTC(), p.teamID,
+	).Scan(&n.ID)
+	if err != nil {
+		return n, err
+	}
+	n.TeamID = p.teamID
+
+	return n, nil
+}
+
+// UpdateScheduledNotification updates fire time of scheduled notification
+func (p *Postgres) UpdateScheduledNotification(n model.ScheduledNotification) (model.ScheduledNotification, error) {
+	_, err := p.conn.Exec("UPDATE notification_schedule SET fire_at=$1 WHERE id=$2 AND team_id=$3", n.FireAt.UTC(), n.ID, p.teamID)
+	return n, err
+}
+
+// ListScheduledNotifications returns scheduled notifications of workspace, the earliest first
+func (p *Postgres) ListScheduledNotifications() ([]model.ScheduledNotification, error) {
+	items := []model.ScheduledNotification{}
+	err := p.conn.Select(&items, "SELECT * FROM notification_schedule WHERE team_id=$1 ORDER BY fire_at, id", p.teamID)
+	return items, err
+}
+
+// DeleteScheduledNotification deletes scheduled notification
+func (p *Postgres) DeleteScheduledNotification(id int64) error {
+	_, err := p.conn.Exec("DELETE FROM notification_schedule WHERE id=$1 AND team_id=$2", id, p.teamID)
+	return err
+}
+
+// postgresUpsertHoliday saves holiday replacing the one on the same day
+const postgresUpsertHoliday = "INSERT INTO holidays (channel_id, day, name, team_id) VALUES ($1, $2, $3, $4) ON CONFLICT (team_id, channel_id, day) DO UPDATE SET name=EXCLUDED.name RETURNING id"
+
+// CreateHoliday saves holiday of channel, or of the workspace if channel is empty.
+// Holiday the channel already has on the day is replaced
+func (p *Postgres) CreateHoliday(h model.Holiday) (model.Holiday, error) {
+	err := p.conn.QueryRow(postgresUpsertHoliday, h.ChannelID, h.Day, h.Name, p.teamID).Scan(&h.ID)
+	if err != nil {
+		return h, err
+	}
+	h.TeamID = p.teamID
+
+	return h, nil
+}
+
+// ImportHolidays saves holidays like CreateHoliday does in one transaction
+func (p *Postgres) ImportHolidays(holidays []model.Holiday) error {
+	tx, err := p.conn.Beginx()
+	if err != nil {
+		return err
+	}
+	for _, h := range holidays {
+		_, err = tx.Exec(postgresUpsertHoliday, h.ChannelID, h.Day, h.Name, p.teamID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteHoliday deletes holiday of channel, or of the workspace if channel is empty, on the day
+func (p *Postgres) DeleteHoliday(channelID, day string) error {
+	_, err := p.conn.Exec("DELETE FROM holidays WHERE channel_id=$1 AND day=$2 AND team_id=$3", channelID, day, p.teamID)
+	return err
+}
+
+// ListHolidays returns holidays of the workspace and of the channel ordered by day
+func (p *Postgres) ListHolidays(channelID string) ([]model.Holiday, error) {
+	items := []model.Holiday{}
+	err := p.conn.Select(&items, "SELECT * FROM holidays WHERE team_id=$1 AND (channel_id='' OR channel_id=$2) ORDER BY day, id", p.teamID, channelID)
+	return items, err
+}
+
+// CreateAbsence saves period user is away
+func (p *Postgres) CreateAbsence(a model.Absence) (model.Absence, error) {
+	err := p.conn.QueryRow(
+		"INSERT INTO absences (user_id, first_day, last_day, reason, team_id) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		a.UserID, a.FirstDay, a.LastDay, a.Reason, p.teamID,
+	).Scan(&a.ID)
+	if err != nil {
+		return a, err
+	}
+	a.TeamID = p.teamID
+
+	return a, nil
+}
+
+// UpdateAbsence updates days and reason of absence
+func (p *Postgres) UpdateAbsence(a model.Absence) (model.Absence, error) {
+	_, err := p.conn.Exec(
+		"UPDATE absences SET first_day=$1, last_day=$2, reason=$3 WHERE id=$4 AND team_id=$5",
+		a.FirstDay, a.LastDay, a.Reason, a.ID, p.teamID)
+	if err != nil {
+		return a, err
+	}
+	a.TeamID = p.teamID
+
+	return a, nil
+}
+
+// DeleteAbsence deletes absence
+func (p *Postgres) DeleteAbsence(id int64) error {
+	_, err := p.conn.Exec("DELETE FROM absences WHERE id=$1 AND team_id=$2", id, p.teamID)
+	return err
+}
+
+// ListAbsences returns absences of user ordered by first day
+func (p *Postgres) ListAbsences(userID string) ([]model.Absence, error) {
+	items := []model.Absence{}
+	err := p.conn.Select(&items, "SELECT * FROM absences WHERE team_id=$1 AND user_id=$2 ORDER BY first_day, id", p.teamID, userID)
+	return items, err
+}
+
+//GetAllChannels returns list of unique channels
+func (p *Postgres) GetAllChannels() ([]model.Channel, error) {
+	channels := []model.Channel{}
+	err := p.conn.Select(&channels, "SELECT * FROM channels WHERE team_id=$1", p.teamID)
+	return channels, err
+}
+
+//GetUserChannels returns list of user's channels
+func (p *Postgres) GetUserChannels(userID string) ([]string, error) {
+	channels := []string{}
+	err := p.conn.Select(&channels, "SELECT channel_id FROM channel_members WHERE user_id=$1 AND team_id=$2", userID, p.teamID)
+	return channels, err
+}
+
+//GetChannelName returns channel name
+func (p *Postgres) GetChannelName(channelID string) (string, error) {
+	var channelName string
+	err := p.conn.Get(&channelName, "SELECT channel_name FROM channels WHERE channel_id=$1 AND team_id=$2", channelID, p.teamID)
+	if err != nil {
+		return "", err
+	}
+	return channelName, err
+}
+
+//GetChannelID returns ID of channel with the name, deleted channels are skipped
+func (p *Postgres) GetChannelID(channelName string) (string, error) {
+	var channelID string
+	err := p.conn.Get(&channelID, "SELECT channel_id FROM channels WHERE channel_name=$1 AND team_id=$2 AND status!=$3 LIMIT 1", channelName, p.teamID, model.ChannelDeleted)
+	if err != nil {
+		return "", err
+	}
+	return channelID, nil
+}
+
+// ListStandups returns array of standup entries from database
+// Helper function for testing
+func (p *Postgres) ListStandups() ([]model.Standup, error) {
+	items := []model.Standup{}
+	err := p.conn.Select(&items, "SELECT * FROM standups WHERE team_id=$1 ORDER BY id", p.teamID)
+	return items, err
+}
+
+// CreateChannel creates standup entry in database
+func (p *Postgres) CreateChannel(c model.Channel) (model.Channel, error) {
+	if c.Status == "" {
+		c.Status = model.ChannelActive
+	}
+	err := p.conn.QueryRow(
+		"INSERT INTO channels (channel_name, channel_id, channel_standup_time, team_id, status, auto_enroll, time_zone, working_days) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
+		c.ChannelName, c.ChannelID, 0, p.teamID, c.Status, c.AutoEnroll, c.TimeZone, c.WorkingDays,
+	).Scan(&c.ID)
+	if err != nil {
+		return c, err
+	}
+	c.TeamID = p.teamID
+
+	return c, nil
+}
+
+// SelectChannel selects Channel entry from database
+func (p *Postgres) SelectChannel(channelID string) (model.Channel, error) {
+	var c model.Channel
+	err := p.conn.Get(&c, "SELECT * FROM channels WHERE channel_id=$1 AND team_id=$2", channelID, p.teamID)
+	if err != nil {
+		return c, err
+	}
+	return c, err
+}
+
+// GetChannels selects Channel entry from database
+func (p *Postgres) GetChannels() ([]model.Channel, error) {
+	var c []model.Channel
+	err := p.conn.Select(&c, "SELECT * FROM channels WHERE team_id=$1", p.teamID)
+	if err != nil {
+		return c, err
+	}
+	return c, err
+}
+
+// UpdateChannel updates name, status, auto-enroll mode, report channel, time zone and working days of channel
+func (p *Postgres) UpdateChannel(c model.Channel) (model.Channel, error) {
+	_, err := p.conn.Exec(
+		"UPDATE channels SET channel_name=$1, status=$2, auto_enroll=$3, report_channel_id=$4, time_zone=$5, working_days=$6 WHERE channel_id=$7 AND team_id=$8",
+		c.ChannelName, c.Status, c.AutoEnroll, c.ReportChannelID, c.TimeZone, c.WorkingDays, c.ChannelID, p.teamID,
+	)
+	if err != nil {
+		return c, err
+	}
+	return p.SelectChannel(c.ChannelID)
+}
+
+// ExcludeFromAutoEnroll keeps user from being enrolled in channel automatically
+func (p *Postgres) ExcludeFromAutoEnroll(channelID, userID string) error {
+	_, err := p.conn.Exec(
+		"INSERT INTO auto_enroll_exclusions (team_id, channel_id, user_id) SELECT $1, $2, $3 WHERE NOT EXISTS (SELECT id FROM auto_enroll_exclusions WHERE team_id=$1 AND channel_id=$2 AND user_id=$3)",
+		p.teamID, channelID, userID,
+	)
+	return err
+}
+
+// IncludeInAutoEnroll removes user from auto-enroll exclusions of channel
+func (p *Postgres) IncludeInAutoEnroll(channelID, userID string) error {
+	_, err := p.conn.Exec("DELETE FROM auto_enroll_exclusions WHERE team_id=$1 AND channel_id=$2 AND user_id=$3", p.teamID, channelID, userID)
+	return err
+}
+
+// ListAutoEnrollExclusions returns IDs of users excluded from auto-enroll in channel
+func (p *Postgres) ListAutoEnrollExclusions(channelID string) ([]string, error) {
+	users := []string{}
+	err := p.conn.Select(&users, "SELECT user_id FROM auto_enroll_exclusions WHERE team_id=$1 AND channel_id=$2 ORDER BY id", p.teamID, channelID)
+	return users, err
+}
+
+// DeleteChannel deletes Channel entry from database
+func (p *Postgres) DeleteChannel(id int64) error {
+	_, err := p.conn.Exec("DELETE FROM channels WHERE id=$1", id)
+	return err
+}
+
+// CreateUser creates standup entry in database
+func (p *Postgres) CreateUser(c model.User) (model.User, error) {
+	err := p.conn.QueryRow(
+		"INSERT INTO users (user_name, user_id, role, team_id, time_zone) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		c.UserName, c.UserID, c.Role, p.teamID, c.TimeZone,
+	).Scan(&c.ID)
+	if err != nil {
+		return c, err
+	}
+	c.TeamID = p.teamID
+
+	return c, nil
+}
+
+// UpdateUser updates role, name and time zone of user
+func (p *Postgres) UpdateUser(c model.User) (model.User, error) {
+	_, err := p.conn.Exec(
+		"UPDATE users SET role=$1, user_name=$2, time_zone=$3 WHERE id=$4",
+		c.Role, c.UserName, c.TimeZone, c.ID,
+	)
+	if err != nil {
+		return c, err
+	}
+	var i model.User
+	err = p.conn.Get(&i, "SELECT * FROM users WHERE id=$1", c.ID)
+	return i, err
+}
+
+// SelectUser selects User entry from database
+func (p *Postgres) SelectUser(userID string) (model.User, error) {
+	var c model.User
+	err := p.conn.Get(&c, "SELECT * FROM users WHERE user_id=$1 AND team_id=$2 ORDER BY id LIMIT 1", userID, p.teamID)
+	if err != nil {
+		return c, err
+	}
+	return c, err
+}
+
+// ListUsers selects User entries from database
+func (p *Postgres) ListUsers() ([]model.User, error) {
+	var u []model.User
+	err := p.conn.Select(&u, "SELECT * FROM users WHERE team_id=$1", p.teamID)
+	if err != nil {
+		return u, err
+	}
+	return u, err
+}
+
+// SelectUserByUserName selects User entry from database
+func (p *Postgres) SelectUserByUserName(userName string) (model.User, error) {
+	var c model.User
+	err := p.conn.Get(&c, "SELECT * FROM users WHERE user_name=$1 AND team_id=$2 ORDER BY id LIMIT 1", userName, p.teamID)
+	if err != nil {
+		return c, err
+	}
+	return c, err
+}
+
+// DeleteUser deletes User entry from database
+func (p *Postgres) DeleteUser(id int64) error {
+	_, err := p.conn.Exec("DELETE FROM users WHERE id=$1", id)
+	return err
+}
+
+// DeactivateUser deletes user with their channel memberships and timetables in one transaction
+func (p *Postgres) DeactivateUser(userID string) error {
+	tx, err := p.conn.Beginx()
+	if err != nil {
+		return err
+	}
+	for _, query := range []string{
+		"DELETE FROM timetables WHERE channel_member_id IN (SELECT id FROM channel_members WHERE team_id=$1 AND user_id=$2)",
+		"DELETE FROM channel_members WHERE team_id=$1 AND user_id=$2",
+		"DELETE FROM users WHERE team_id=$1 AND user_id=$2",
+	} {
+		_, err = tx.Exec(query, p.teamID, userID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListAdmins selects User entry from database
+func (p *Postgres) ListAdmins() ([]model.User, error) {
+	var c []model.User
+	err := p.conn.Select(&c, "SELECT * FROM users WHERE role='admin' AND team_id=$1", p.teamID)
+	if err != nil {
+		return c, err
+	}
+	return c, err
+}
+
+// UserIsPMForProject returns true if user is a project's PM.
+func (p *Postgres) UserIsPMForProject(userID, channelID string) bool {
+	var role string
+	err := p.conn.Get(&role, "SELECT role_in_channel FROM channel_members WHERE user_id=$1 AND channel_id=$2 AND team_id=$3 LIMIT 1", userID, channelID, p.teamID)
+	if err != nil {
+		return false
+	}
+	logrus.Infof("Role in channel %v", role)
+	if role == "pm" {
+		return true
+	}
+	return false
+}
+
+// CreateTimeTable creates tt entry in database
+func (p *Postgres) CreateTimeTable(t model.TimeTable) (model.TimeTable, error) {
+	err := p.conn.QueryRow(
+		"INSERT INTO timetables (channel_member_id, created, modified, team_id) VALUES ($1, $2, $3, $4) RETURNING id",
+		t.ChannelMemberID, time.Now(), time.Now(), p.teamID,
+	).Scan(&t.ID)
+	if err != nil {
+		return t, err
+	}
+	t.TeamID = p.teamID
+
+	return t, nil
+}
+
+// UpdateTimeTable updates TimeTable entry in database
+func (p *Postgres) UpdateTimeTable(t model.TimeTable) (model.TimeTable, error) {
+	_, err := p.conn.Exec(
+		"UPDATE timetables SET modified=$1, monday=$2, tuesday=$3, wednesday=$4, thursday=$5, friday=$6, saturday=$7, sunday=$8 WHERE id=$9",
+		time.Now(), t.Monday, t.Tuesday, t.Wednesday, t.Thursday, t.Friday, t.Saturday, t.Sunday, t.ID,
+	)
+	if err != nil {
+		return t, err
+	}
+	var i model.TimeTable
+	err = p.conn.Get(&i, "SELECT * FROM timetables WHERE id=$1", t.ID)
+	return i, err
+}
+
+// SelectTimeTable selects TimeTable entry from database
+func (p *Postgres) SelectTimeTable(ChannelMemberID int64) (model.TimeTable, error) {
+	var c model.TimeTable
+	err := p.conn.Get(&c, "SELECT * FROM timetables WHERE channel_member_id=$1 LIMIT 1", ChannelMemberID)
+	if err != nil {
+		return c, err
+	}
+	return c, err
+}
+
+// DeleteTimeTable deletes TimeTable entry from database
+func (p *Postgres) DeleteTimeTable(id int64) error {
+	_, err := p.conn.Exec("DELETE FROM timetables WHERE id=$1", id)
+	return err
+}
+
+//ListTimeTablesForDay returns list of chan members who has timetables, members of
+//inactive channels are skipped
+func (p *Postgres) ListTimeTablesForDay(day string) ([]model.TimeTable, error) {
+	var tt []model.TimeTable
+	query := fmt.Sprintf("SELECT t.channel_member_id, t.%s FROM timetables t LEFT JOIN channel_members cm ON cm.id=t.channel_member_id LEFT JOIN channels c ON c.team_id=cm.team_id AND c.channel_id=cm.channel_id WHERE t.team_id=$1 AND t.%s != 0 AND (c.status IS NULL OR c.status=$2)", day, day)
+	err := p.conn.Select(&tt, query, p.teamID, model.ChannelActive)
+	if err != nil {
+		return tt, err
+	}
+	return tt, nil
+}
+
+// MemberHasTimeTable returns true if member has timetable
+func (p *Postgres) MemberHasTimeTable(id int64) bool {
+	var t int64
+	err := p.conn.Get(&t, "SELECT id FROM timetables WHERE channel_member_id=$1 LIMIT 1", id)
+	if err != nil {
+		return false
+	}
+	logrus.Infof("MemberHasTimeTable ID: %v", t)
+	return true
+}
+
+//MemberShouldBeTracked returns true if member should be tracked
+func (p *Postgres) MemberShouldBeTracked(id int64, date time.Time) bool {
+	var absences int
+	err := p.conn.Get(&absences, "SELECT COUNT(*) FROM absences WHERE team_id=$1 AND user_id=(SELECT user_id FROM channel_members WHERE id=$2) AND first_day <= $3 AND last_day >= $3", p.teamID, id, date.Format(model.HolidayDay))
+	if err == nil && absences > 0 {
+		logrus.Infof("Member %v is away on %v! Do not track", id, date.Format(model.HolidayDay))
+		return false
+	}
+	var tt model.TimeTable
+	err = p.conn.Get(&tt, "SELECT * FROM timetables WHERE channel_member_id=$1 LIMIT 1", id)
+	if err != nil {
+		logrus.Infof("User does not have a timetable: %v", err)
+		return true
+	}
+	if tt.IsEmpty() {
+		logrus.Infof("Timetable for %v is empty! Do not track", tt.ChannelMemberID)
+		return false
+	}
+	logrus.Infof("MemberHasTimeTable ID:%v not empty", tt.ID)
+
+	day := fmt.Sprintf("%v", date.Weekday())
+	logrus.Infof("Weekday: %s", day)
+	if tt.ShowDeadlineOn(strings.ToLower(day)) != 0 {
+		return true
+	}
+
+	return false
+}
+
+// SchemaVersion returns version of the latest migration applied to database
+func (p *Postgres) SchemaVersion() (int64, error) {
+	return schemaVersion(p.conn)
+}
+
+// LatestSchemaVersion returns version of the latest migration embedded into binary
+func (p *Postgres) LatestSchemaVersion() (int64, error) {
+	return latestVersion(migrations.Postgres)
+}
+
+// Migrate applies all pending migrations
+func (p *Postgres) Migrate() error {
+	return migrate(p.conn, migrations.Postgres)
+}
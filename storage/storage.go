@@ -1,14 +1,21 @@
 package storage
 
 import (
+	"strings"
 	"time"
 
+	"github.com/maddevsio/comedian/config"
 	"github.com/maddevsio/comedian/model"
 )
 
 // Storage is interface for all supported storages(e.g. MySQL, Postgresql)
 type Storage interface {
-	// CreateStandup creates standup entry in database
+	// ForTeam returns storage which keeps entries of the selected workspace only.
+	// Storages created with New belong to the workspace with empty ID
+	ForTeam(string) Storage
+
+	// CreateStandup creates standup entry in database. Standup is created now unless
+	// it has creation time already
 	CreateStandup(model.Standup) (model.Standup, error)
 
 	// UpdateStandup updates standup entry in database
@@ -26,6 +33,15 @@ type Storage interface {
 	// DeleteStandup deletes standup entry from database
 	DeleteStandup(int64) error
 
+	// MarkStandupDeleted keeps standup entry in database, but marks it as deleted
+	MarkStandupDeleted(int64) error
+
+	// RestoreStandup removes deleted mark from standup entry
+	RestoreStandup(int64) (model.Standup, error)
+
+	// SelectDeletedStandup selects the latest deleted standup of user in channel for time period
+	SelectDeletedStandup(string, string, time.Time, time.Time) (model.Standup, error)
+
 	// CreateChannelMember creates comedian entry in database
 	CreateChannelMember(model.ChannelMember) (model.ChannelMember, error)
 
@@ -44,7 +60,8 @@ type Storage interface {
 	// ListAllChannelMembers returns array of standup entries from database
 	ListAllChannelMembers() ([]model.ChannelMember, error)
 
-	//GetNonReporters returns a list of non reporters in selected time period
+	//GetNonReporters returns a list of non reporters in selected time period. Members away
+	//during the whole period are not non reporters
 	GetNonReporters(string, time.Time, time.Time) ([]model.ChannelMember, error)
 
 	// IsNonReporter returns true if user did not submit standup in time period, false othervise
@@ -75,6 +92,77 @@ type Storage interface {
 	// AddToStandupHistory creates backup standup entry in standup_edit_history database
 	AddToStandupHistory(model.StandupEditHistory) (model.StandupEditHistory, error)
 
+	// ListStandupHistory returns previous versions of standup from the oldest to the newest one
+	ListStandupHistory(int64) ([]model.StandupEditHistory, error)
+
+	// CreateStandupQuestion creates standup question of channel
+	CreateStandupQuestion(model.StandupQuestion) (model.StandupQuestion, error)
+
+	// ListStandupQuestions returns standup questions of channel in the order they were added
+	ListStandupQuestions(string) ([]model.StandupQuestion, error)
+
+	// DeleteStandupQuestion deletes standup question
+	DeleteStandupQuestion(int64) error
+
+	// CreateOutgoingMessage puts message to outbox. Fails if identical message is waiting for delivery
+	CreateOutgoingMessage(model.OutgoingMessage) (model.OutgoingMessage, error)
+
+	// UpdateOutgoingMessage updates delivery state of outbox message
+	UpdateOutgoingMessage(model.OutgoingMessage) (model.OutgoingMessage, error)
+
+	// FindPendingMessage returns outbox message with the dedup key which is not delivered yet
+	FindPendingMessage(string) (model.OutgoingMessage, error)
+
+	// ListDueMessages returns undelivered outbox messages which should be sent by the time, the oldest first
+	ListDueMessages(time.Time) ([]model.OutgoingMessage, error)
+
+	// ClaimOutgoingMessage takes undelivered outbox message which is due by the time for delivery
+	// until the other time, so that nobody else delivers it meanwhile. Returns false if message is taken
+	ClaimOutgoingMessage(id int64, now, until time.Time) (bool, error)
+
+	// ListUnreportedFailures returns outbox messages which failed to be delivered and were not reported yet
+	ListUnreportedFailures() ([]model.OutgoingMessage, error)
+
+	// DeleteSentMessages deletes outbox messages delivered before the time
+	DeleteSentMessages(time.Time) error
+
+	// CreateScheduledNotification saves the next fire time of notification
+	CreateScheduledNotification(model.ScheduledNotification) (model.ScheduledNotification, error)
+
+	// UpdateScheduledNotification updates fire time of scheduled notification
+	UpdateScheduledNotification(model.ScheduledNotification) (model.ScheduledNotification, error)
+
+	// ListScheduledNotifications returns scheduled notifications of workspace, the earliest first
+	ListScheduledNotifications() ([]model.ScheduledNotification, error)
+
+	// DeleteScheduledNotification deletes scheduled notification
+	DeleteScheduledNotification(int64) error
+
+	// CreateHoliday saves holiday of channel, or of the workspace if channel is empty.
+	// Holiday the channel already has on the day is replaced
+	CreateHoliday(model.Holiday) (model.Holiday, error)
+
+	// ImportHolidays saves holidays like CreateHoliday does, all of them or none
+	ImportHolidays([]model.Holiday) error
+
+	// DeleteHoliday deletes holiday of channel, or of the workspace if channel is empty, on the day
+	DeleteHoliday(channelID, day string) error
+
+	// ListHolidays returns holidays of the workspace and of the channel ordered by day
+	ListHolidays(channelID string) ([]model.Holiday, error)
+
+	// CreateAbsence saves period user is away
+	CreateAbsence(model.Absence) (model.Absence, error)
+
+	// UpdateAbsence updates days and reason of absence
+	UpdateAbsence(model.Absence) (model.Absence, error)
+
+	// DeleteAbsence deletes absence
+	DeleteAbsence(int64) error
+
+	// ListAbsences returns absences of user ordered by first day
+	ListAbsences(userID string) ([]model.Absence, error)
+
 	//GetAllChannels returns list of unique channels
 	GetAllChannels() ([]model.Channel, error)
 
@@ -84,7 +172,7 @@ type Storage interface {
 	//GetChannelName returns channel name
 	GetChannelName(string) (string, error)
 
-	//GetChannelID returns channel name
+	//GetChannelID returns ID of channel with the name, deleted channels are skipped
 	GetChannelID(string) (string, error)
 
 	// ListStandups returns array of standup entries from database
@@ -100,13 +188,25 @@ type Storage interface {
 	// GetChannels selects Channel entry from database
 	GetChannels() ([]model.Channel, error)
 
+	// UpdateChannel updates name, status, auto-enroll mode, report channel, time zone and working days of channel
+	UpdateChannel(model.Channel) (model.Channel, error)
+
+	// ExcludeFromAutoEnroll keeps user from being enrolled in channel automatically
+	ExcludeFromAutoEnroll(string, string) error
+
+	// IncludeInAutoEnroll removes user from auto-enroll exclusions of channel
+	IncludeInAutoEnroll(string, string) error
+
+	// ListAutoEnrollExclusions returns IDs of users excluded from auto-enroll in channel
+	ListAutoEnrollExclusions(string) ([]string, error)
+
 	// DeleteChannel deletes Channel entry from database
 	DeleteChannel(int64) error
 
 	// CreateUser creates standup entry in database
 	CreateUser(model.User) (model.User, error)
 
-	// UpdateUser updates User entry in database
+	// UpdateUser updates role, name and time zone of user
 	UpdateUser(model.User) (model.User, error)
 
 	// SelectUser selects User entry from database
@@ -118,10 +218,13 @@ type Storage interface {
 	// DeleteUser deletes User entry from database
 	DeleteUser(int64) error
 
+	// DeactivateUser deletes user with their channel memberships and timetables in one transaction
+	DeactivateUser(string) error
+
 	// ListAdmins selects User entry from database
 	ListAdmins() ([]model.User, error)
 
-	//SubmittedStandupToday shows if a user submitted standup today
+	//SubmittedStandupToday shows if a user submitted standup today in their time zone
 	SubmittedStandupToday(string, string) bool
 
 	// UserIsPMForProject returns true if user is a project's PM.
@@ -145,9 +248,91 @@ type Storage interface {
 	//MemberHasTimeTable returns true if member has timetable
 	MemberHasTimeTable(int64) bool
 
-	//MemberShouldBeTracked returns true if member has timetable
+	//MemberShouldBeTracked returns true if member has deadline on the day by timetable and is not away
 	MemberShouldBeTracked(int64, time.Time) bool
 
 	// SelectUser selects User entry from database
 	ListUsers() ([]model.User, error)
 }
+
+// New creates storage for the database set in COMEDIAN_DATABASE. DSN scheme selects
+// the database: postgres:// and postgresql:// open PostgreSQL, memory:// keeps everything
+// in memory, everything else is MySQL
+func New(c config.Config) (Storage, error) {
+	switch {
+	case strings.HasPrefix(c.DatabaseURL, "memory://"):
+		return NewMemory(), nil
+	case strings.HasPrefix(c.DatabaseURL, "postgres://"), strings.HasPrefix(c.DatabaseURL, "postgresql://"):
+		return NewPostgres(c)
+	case strings.HasPrefix(c.DatabaseURL, "mysql://"):
+		c.DatabaseURL = strings.TrimPrefix(c.DatabaseURL, "mysql://")
+		return NewMySQL(c)
+	default:
+		return NewMySQL(c)
+	}
+}
+
+// MemberLocation returns time zone of user in channel: user's own one if it is known,
+// channel's one or server time zone otherwise
+func MemberLocation(db Storage, userID, channelID string) *time.Location {
+	user, _ := db.SelectUser(userID)
+	channel, _ := db.SelectChannel(channelID)
+	return model.MemberLocation(user, channel)
+}
+
+// MoveTimeTable keeps hours and minutes of deadlines in timetable of channel member when
+// time zone of the member changes. Members without timetable are skipped
+func MoveTimeTable(db Storage, memberID int64, from, to *time.Location) error {
+	if from.String() == to.String() {
+		return nil
+	}
+	tt, err := db.SelectTimeTable(memberID)
+	if err != nil {
+		return nil
+	}
+	_, err = db.UpdateTimeTable(model.MoveTimeTable(tt, from, to))
+	return err
+}
+
+// presentMembers returns members who are not away on every day of time period
+func presentMembers(db Storage, members []model.ChannelMember, dateFrom, dateTo time.Time) ([]model.ChannelMember, error) {
+	present := []model.ChannelMember{}
+	for _, member := range members {
+		absences, err := db.ListAbsences(member.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !model.AwayAllDays(absences, dateFrom, dateTo) {
+			present = append(present, member)
+		}
+	}
+	return present, nil
+}
+
+// standupCreated returns time standup is created at
+func standupCreated(s model.Standup) time.Time {
+	if s.Created.IsZero() {
+		return time.Now().UTC()
+	}
+	return s.Created.UTC()
+}
+
+// AbsenceOn returns absence of user on the day in time zone of the day. Returns false
+// if user is not away that day
+func AbsenceOn(db Storage, userID string, day time.Time) (model.Absence, bool) {
+	absences, _ := db.ListAbsences(userID)
+	return model.AbsenceOn(absences, day)
+}
+
+// outboxColumns are columns of outbox selected into model.OutgoingMessage
+const outboxColumns = "id, team_id, kind, channel_id, user_id, text, attachments, blocks, dedup_key, attempts, next_attempt, last_error, created, sent_at, failed_at, reported"
+
+// pendingKey is stored in unique pending_key column of outbox while message waits for
+// delivery, so that identical messages can not wait together. Delivered and failed
+// messages have no pending key
+func pendingKey(msg model.OutgoingMessage) interface{} {
+	if msg.SentAt != nil || msg.FailedAt != nil {
+		return nil
+	}
+	return msg.DedupKey
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maddevsio/comedian/model"
+)
+
+// maxHolidayDays keeps long events of calendars (e.g. "Summer") from becoming months of holidays
+const maxHolidayDays = 31
+
+// ParseHolidays reads holidays from iCalendar (ICS) file, the format public holiday
+// calendars are published in. Every day of all-day events is a holiday, events with
+// time make holiday of the day they start. Yearly recurring events are repeated up to
+// until, calendars with other recurrences are refused
+func ParseHolidays(r io.Reader, until time.Time) ([]model.Holiday, error) {
+	lines, err := unfoldICSLines(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || lines[0] != "BEGIN:VCALENDAR" {
+		return nil, errors.New("not an iCalendar file")
+	}
+
+	holidays := []model.Holiday{}
+	var event map[string]string
+	for _, line := range lines {
+		switch line {
+		case "BEGIN:VEVENT":
+			event = map[string]string{}
+			continue
+		case "END:VEVENT":
+			if event != nil {
+				days, err := eventHolidays(event, until)
+				if err != nil {
+					return nil, err
+				}
+				holidays = append(holidays, days...)
+			}
+			event = nil
+			continue
+		}
+		if event == nil {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		// parameters like ";VALUE=DATE" are not needed, dates are told by their length
+		name := strings.ToUpper(strings.SplitN(line[:i], ";", 2)[0])
+		// event may have several lines of excluded dates
+		if name == "EXDATE" && event[name] != "" {
+			event[name] += "," + line[i+1:]
+			continue
+		}
+		event[name] = line[i+1:]
+	}
+	return holidays, nil
+}
+
+// unfoldICSLines returns content lines of ICS file. Long lines are folded into several
+// starting with a space, they are joined back
+func unfoldICSLines(r io.Reader) ([]string, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// eventHolidays returns a holiday for every day of event and of its recurrences up to until
+func eventHolidays(event map[string]string, until time.Time) ([]model.Holiday, error) {
+	start, err := icsDate(event["DTSTART"])
+	if err != nil {
+		return nil, nil
+	}
+	name := strings.TrimSpace(strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\N`, " ", `\\`, `\`).Replace(event["SUMMARY"]))
+	starts, err := eventStarts(start, event, until)
+	if err != nil {
+		return nil, fmt.Errorf("event %q: %v", name, err)
+	}
+	days := 1
+	// all-day events have dates without time, they end the day after their last day
+	if len(event["DTSTART"]) == len("20060102") {
+		if end, err := icsDate(event["DTEND"]); err == nil && end.After(start) {
+			days = int(end.Sub(start).Hours() / 24)
+		}
+	}
+	if days > maxHolidayDays {
+		days = maxHolidayDays
+	}
+	holidays := []model.Holiday{}
+	for _, s := range starts {
+		for d := 0; d < days; d++ {
+			holidays = append(holidays, model.Holiday{Day: s.AddDate(0, 0, d).Format(model.HolidayDay), Name: name})
+		}
+	}
+	return holidays, nil
+}
+
+// eventStarts returns days event starts on: the first one and the ones its RRULE repeats
+// it on up to until, except EXDATE ones. Only yearly rules with INTERVAL, COUNT and UNTIL
+// are supported
+func eventStarts(start time.Time, event map[string]string, until time.Time) ([]time.Time, error) {
+	if event["RDATE"] != "" {
+		return nil, errors.New("RDATE is not supported")
+	}
+	rule := event["RRULE"]
+	if rule == "" {
+		return []time.Time{start}, nil
+	}
+	yearly := false
+	interval, count := 1, 0
+	for _, part := range strings.Split(rule, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("wrong RRULE %v", rule)
+		}
+		var err error
+		switch strings.ToUpper(kv[0]) {
+		case "FREQ":
+			yearly = strings.ToUpper(kv[1]) == "YEARLY"
+		case "INTERVAL":
+			interval, err = strconv.Atoi(kv[1])
+			if err == nil && interval < 1 {
+				err = errors.New("INTERVAL is not positive")
+			}
+		case "COUNT":
+			count, err = strconv.Atoi(kv[1])
+			if err == nil && count < 1 {
+				err = errors.New("COUNT is not positive")
+			}
+		case "UNTIL":
+			var last time.Time
+			last, err = icsDate(kv[1])
+			if err == nil && last.Before(until) {
+				until = last
+			}
+		case "WKST":
+			// week start does not matter for yearly events
+		default:
+			err = fmt.Errorf("%v is not supported", kv[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("wrong RRULE %v: %v", rule, err)
+		}
+	}
+	if !yearly {
+		return nil, fmt.Errorf("RRULE %v is not supported, only yearly events are", rule)
+	}
+
+	excluded := map[string]bool{}
+	for _, value := range strings.Split(event["EXDATE"], ",") {
+		if day, err := icsDate(value); err == nil {
+			excluded[day.Format(model.HolidayDay)] = true
+		}
+	}
+	starts := []time.Time{}
+	occurrences := 0
+	for year := 0; count == 0 || occurrences < count; year += interval {
+		day := start.AddDate(year, 0, 0)
+		if day.After(until) {
+			break
+		}
+		// February 29 is repeated in leap years only
+		if day.Day() != start.Day() {
+			continue
+		}
+		occurrences++
+		if excluded[day.Format(model.HolidayDay)] {
+			continue
+		}
+		starts = append(starts, day)
+	}
+	return starts, nil
+}
+
+// icsDate returns date of ICS date ("20190101") or date-time ("20190101T090000Z")
+func icsDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, errors.New("wrong date")
+	}
+	return time.Parse("20060102", value[:8])
+}
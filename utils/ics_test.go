@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maddevsio/comedian/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHolidays(t *testing.T) {
+	calendar := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20190101",
+		"DTEND;VALUE=DATE:20190103",
+		"SUMMARY:New Year",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20190308",
+		"SUMMARY:International Women's Day\\, observed in",
+		"  Kyrgyzstan",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20190501T090000Z",
+		"DTEND:20190502T090000Z",
+		"SUMMARY:Labour Day",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+	until := time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC)
+	holidays, err := ParseHolidays(strings.NewReader(calendar), until)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Holiday{
+		{Day: "2019-01-01", Name: "New Year"},
+		{Day: "2019-01-02", Name: "New Year"},
+		{Day: "2019-03-08", Name: "International Women's Day, observed in Kyrgyzstan"},
+		{Day: "2019-05-01", Name: "Labour Day"},
+	}, holidays)
+
+	_, err = ParseHolidays(strings.NewReader("<html></html>"), until)
+	assert.Error(t, err)
+}
+
+func TestParseRecurringHolidays(t *testing.T) {
+	calendar := func(lines ...string) string {
+		return strings.Join(append(append([]string{"BEGIN:VCALENDAR", "BEGIN:VEVENT"}, lines...), "END:VEVENT", "END:VCALENDAR"), "\r\n")
+	}
+	until := time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	// yearly events are repeated up to until
+	holidays, err := ParseHolidays(strings.NewReader(calendar(
+		"DTSTART;VALUE=DATE:20190101",
+		"RRULE:FREQ=YEARLY",
+		"EXDATE;VALUE=DATE:20200101",
+		"SUMMARY:New Year",
+	)), until)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Holiday{
+		{Day: "2019-01-01", Name: "New Year"},
+		{Day: "2021-01-01", Name: "New Year"},
+		{Day: "2022-01-01", Name: "New Year"},
+	}, holidays)
+
+	// COUNT, UNTIL and INTERVAL limit repetitions, February 29 is repeated in leap years
+	holidays, err = ParseHolidays(strings.NewReader(calendar(
+		"DTSTART;VALUE=DATE:20120229",
+		"RRULE:FREQ=YEARLY;COUNT=2",
+		"SUMMARY:Leap Day",
+	)), until)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Holiday{{Day: "2012-02-29", Name: "Leap Day"}, {Day: "2016-02-29", Name: "Leap Day"}}, holidays)
+	holidays, err = ParseHolidays(strings.NewReader(calendar(
+		"DTSTART;VALUE=DATE:20190308",
+		"RRULE:FREQ=YEARLY;INTERVAL=2;UNTIL=20211231",
+		"SUMMARY:Spring",
+	)), until)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Holiday{{Day: "2019-03-08", Name: "Spring"}, {Day: "2021-03-08", Name: "Spring"}}, holidays)
+
+	// other recurrences are refused rather than imported as one day
+	for _, rule := range []string{"RRULE:FREQ=WEEKLY", "RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", "RDATE;VALUE=DATE:20200101"} {
+		_, err = ParseHolidays(strings.NewReader(calendar("DTSTART;VALUE=DATE:20190101", rule, "SUMMARY:Other")), until)
+		assert.Error(t, err, rule)
+	}
+}
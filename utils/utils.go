@@ -141,3 +141,49 @@ func SetupDays(dateFrom, dateTo time.Time) (time.Time, int, error) {
 	numberOfDays := int(dateToRounded.Sub(dateFromRounded).Hours() / 24)
 	return dateFromRounded, numberOfDays, nil
 }
+
+//DiffLines returns line by line diff of two texts. Removed lines are prefixed with "- ",
+//added lines with "+ " and unchanged lines with two spaces
+func DiffLines(before, after string) string {
+	a := strings.Split(before, "\n")
+	b := strings.Split(after, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	diff := []string{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, "  "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "- "+a[i])
+			i++
+		default:
+			diff = append(diff, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, "+ "+b[j])
+	}
+	return strings.Join(diff, "\n")
+}
@@ -171,3 +171,20 @@ func TestPrepareTimetable(t *testing.T) {
 	assert.NoError(t, slack.DB.DeleteTimeTable(tt.ID))
 
 }
+
+func TestDiffLines(t *testing.T) {
+	testCases := []struct {
+		before string
+		after  string
+		diff   string
+	}{
+		{"same", "same", "  same"},
+		{"yesterday: fixed bug", "yesterday: fixed two bugs", "- yesterday: fixed bug\n+ yesterday: fixed two bugs"},
+		{"yesterday\ntoday\nproblems", "yesterday\ntoday: deploy\nproblems", "  yesterday\n- today\n+ today: deploy\n  problems"},
+		{"yesterday\ntoday", "yesterday\ntoday\nproblems: none", "  yesterday\n  today\n+ problems: none"},
+		{"yesterday\ntoday\nproblems", "today", "- yesterday\n  today\n- problems"},
+	}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.diff, DiffLines(tt.before, tt.after))
+	}
+}